@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undy45/medicine-webapi/internal/auth"
+)
+
+// authConfig configures the authentication of the requests by the bearer JWTs of the issuer.
+// The API is not authenticated if there is no issuer.
+type authConfig struct {
+	Verifier auth.VerifierConfig
+	// JwksUrl and JwksFile are the alternatives to the OpenID Connect discovery of the keys of the issuer
+	JwksUrl  string
+	JwksFile string
+	// PublicPaths are served without the authentication
+	PublicPaths []string
+}
+
+// enabled returns whether the requests have to be authenticated
+func (config authConfig) enabled() bool {
+	return config.Verifier.Issuer != ""
+}
+
+// validate reports the incomplete or conflicting settings
+func (config authConfig) validate() error {
+	if !config.enabled() {
+		if config.JwksUrl != "" || config.JwksFile != "" || config.Verifier.Audience != "" {
+			return errors.New("the issuer is required to authenticate the requests")
+		}
+		return nil
+	}
+	if config.JwksUrl != "" && config.JwksFile != "" {
+		return errors.New("either the JWKS url or the JWKS file may be configured")
+	}
+	if config.JwksUrl == "" && config.JwksFile == "" && !strings.HasPrefix(config.Verifier.Issuer, "https://") &&
+		!strings.HasPrefix(config.Verifier.Issuer, "http://") {
+		return fmt.Errorf("issuer %q is not an url, configure the JWKS url or file", config.Verifier.Issuer)
+	}
+	return nil
+}
+
+// keySet returns the keys of the file, of the url or of the discovery document of the issuer
+func (config authConfig) keySet() (*auth.KeySet, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch {
+	case config.JwksFile != "":
+		return auth.NewFileKeySet(config.JwksFile)
+	case config.JwksUrl != "":
+		return auth.NewRemoteKeySet(client, config.JwksUrl), nil
+	default:
+		return auth.NewDiscoveredKeySet(client, config.Verifier.Issuer), nil
+	}
+}
+
+// newAuthentication returns middleware authenticating the requests to the paths which are not public by
+// the bearer tokens or the API keys, the principal is added to the context of the request. It returns nil
+// if the authentication is not enabled.
+func newAuthentication(config authConfig, apiKeys *auth.ApiKeys) (gin.HandlerFunc, error) {
+	if !config.enabled() {
+		return nil, nil
+	}
+	keys, err := config.keySet()
+	if err != nil {
+		return nil, err
+	}
+	return authentication(auth.NewVerifier(config.Verifier, keys), apiKeys, config.PublicPaths), nil
+}
+
+func authentication(verifier *auth.Verifier, apiKeys *auth.ApiKeys, publicPaths []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if slices.Contains(publicPaths, ctx.Request.URL.Path) {
+			ctx.Next()
+			return
+		}
+		if key := ctx.GetHeader(auth.ApiKeyHeader); key != "" {
+			principal, err := apiKeys.Verify(ctx.Request.Context(), key)
+			if errors.Is(err, auth.ErrInvalidApiKey) {
+				slog.InfoContext(ctx.Request.Context(), "Invalid API key", "error", err)
+				unauthorized(ctx, "invalid_token", err.Error())
+				return
+			} else if err != nil {
+				slog.WarnContext(ctx.Request.Context(), "Failed to verify API key", "error", err)
+				unauthorized(ctx, "invalid_token", "API key cannot be verified")
+				return
+			}
+			ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), principal))
+			ctx.Next()
+			return
+		}
+		scheme, token, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			unauthorized(ctx, "", "Bearer token or API key is required")
+			return
+		}
+		principal, err := verifier.Verify(ctx.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			slog.InfoContext(ctx.Request.Context(), "Invalid bearer token", "error", err)
+			unauthorized(ctx, "invalid_token", err.Error())
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), principal))
+		ctx.Next()
+	}
+}
+
+// unauthorized responds with the challenge of RFC 6750, the error code is empty if there was no token
+func unauthorized(ctx *gin.Context, code string, description string) {
+	challenge := `Bearer realm="medicine-webapi"`
+	if code != "" {
+		challenge += fmt.Sprintf(`, error=%q, error_description=%q`, code, strings.ReplaceAll(description, `"`, `'`))
+	}
+	ctx.Header("WWW-Authenticate", challenge)
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"status":  http.StatusText(http.StatusUnauthorized),
+		"message": "Authentication required",
+		"error":   description,
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"github.com/undy45/medicine-webapi/internal/auth/authtest"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+type AuthSuite struct {
+	suite.Suite
+	issuer  *authtest.Issuer
+	storage storage
+	router  *gin.Engine
+	logs    bytes.Buffer
+}
+
+func TestAuthSuite(t *testing.T) {
+	suite.Run(t, new(AuthSuite))
+}
+
+// authenticatedRouter returns router on top of the memory storage accepting the tokens of the issuer
+// for the medicine-webapi audience
+func authenticatedRouter(t *testing.T, issuer *authtest.Issuer) (*gin.Engine, storage) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("MEDICINE_API_STORAGE", "memory")
+	t.Setenv("MEDICINE_API_AUTH_ISSUER", issuer.Url)
+	t.Setenv("MEDICINE_API_AUTH_AUDIENCE", "medicine-webapi")
+	t.Setenv("MEDICINE_API_AUTH_JWKS_FILE", issuer.WriteJWKS(t))
+	config := loadTestConfig(t)
+
+	metrics := newMetrics()
+	storage, err := newStorage(context.Background(), config.Storage, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate, err := newAuthentication(config.Auth, auth.NewApiKeys(storage.apiKeys))
+	if err != nil || authenticate == nil {
+		t.Fatal("authentication is not enabled", err)
+	}
+	notifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), time.Hour)
+	return newRouter(storage, notifier, metrics, newReadiness(storage), config.Cors, authenticate), storage
+}
+
+func (suite *AuthSuite) SetupTest() {
+	suite.issuer = authtest.NewIssuer(suite.T(), "https://sso.example.com/realms/hospital")
+	suite.router, suite.storage = authenticatedRouter(suite.T(), suite.issuer)
+
+	suite.logs.Reset()
+	previous := slog.Default()
+	suite.T().Cleanup(func() { restoreLogger(previous) })
+	slog.SetDefault(newLogger(&suite.logs, slog.LevelInfo))
+}
+
+func (suite *AuthSuite) TearDownTest() {
+	suite.NoError(suite.storage.Disconnect(context.Background()))
+}
+
+// get sends GET request with the bearer token, no Authorization header is sent if the token is empty
+func (suite *AuthSuite) get(path string, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// token returns valid token of the audience of the service
+func (suite *AuthSuite) token(claims jwt.MapClaims) string {
+	if claims == nil {
+		claims = jwt.MapClaims{}
+	}
+	if _, ok := claims["aud"]; !ok {
+		claims["aud"] = "medicine-webapi"
+	}
+	return suite.issuer.Token(suite.T(), "user-1", []string{"nurse"}, claims)
+}
+
+func (suite *AuthSuite) Test_PublicPaths_AreServedWithoutToken() {
+	// ACT
+	healthz := suite.get("/healthz", "")
+	readyz := suite.get("/readyz", "")
+	openapi := suite.get("/openapi", "")
+
+	// ASSERT
+	suite.Equal(http.StatusOK, healthz.Code)
+	suite.Equal(http.StatusOK, readyz.Code)
+	suite.Equal(http.StatusOK, openapi.Code)
+}
+
+func (suite *AuthSuite) Test_Api_WithoutToken_IsUnauthorized() {
+	// ACT
+	response := suite.get("/api/ambulance", "")
+
+	// ASSERT
+	suite.Equal(http.StatusUnauthorized, response.Code)
+	suite.Equal(`Bearer realm="medicine-webapi"`, response.Header().Get("WWW-Authenticate"))
+	var body map[string]string
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &body))
+	suite.Equal("Unauthorized", body["status"])
+	suite.Equal("Bearer token or API key is required", body["error"])
+	suite.Equal(response.Header().Get(requestIdHeader), body["requestId"])
+}
+
+func (suite *AuthSuite) Test_Api_WithValidToken_IsServedAndLogsSubject() {
+	// ACT
+	response := suite.get("/api/ambulance", suite.token(nil))
+
+	// ASSERT
+	suite.Equal(http.StatusOK, response.Code, response.Body.String())
+	suite.Contains(suite.logs.String(), `"subject":"user-1"`)
+}
+
+func (suite *AuthSuite) Test_Api_WithInvalidToken_IsUnauthorized() {
+	// ARRANGE
+	other := authtest.NewIssuer(suite.T(), suite.issuer.Url)
+	tokens := map[string]string{
+		"expired":   suite.token(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"audience":  suite.token(jwt.MapClaims{"aud": "other-service"}),
+		"issuer":    suite.token(jwt.MapClaims{"iss": "https://sso.example.com/realms/other"}),
+		"signature": other.Token(suite.T(), "user-1", nil, jwt.MapClaims{"aud": "medicine-webapi"}),
+		"malformed": "not-a-token",
+	}
+
+	for name, token := range tokens {
+		// ACT
+		response := suite.get("/api/ambulance", token)
+
+		// ASSERT
+		suite.Equal(http.StatusUnauthorized, response.Code, name)
+		suite.Contains(response.Header().Get("WWW-Authenticate"), `error="invalid_token"`, name)
+	}
+}
+
+func (suite *AuthSuite) Test_Authentication_AddsPrincipalToContext() {
+	// ARRANGE
+	keys, err := auth.NewStaticKeySet(suite.issuer.JWKS())
+	suite.Require().NoError(err)
+	verifier := auth.NewVerifier(auth.VerifierConfig{Issuer: suite.issuer.Url}, keys)
+	engine := gin.New()
+	engine.Use(authentication(verifier, nil, nil))
+	var principal auth.Principal
+	engine.GET("/whoami", func(ctx *gin.Context) {
+		principal, _ = auth.PrincipalFromContext(ctx.Request.Context())
+		ctx.Status(http.StatusNoContent)
+	})
+	request := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	request.Header.Set("Authorization", "bearer "+suite.issuer.Token(suite.T(), "user-2", []string{"pharmacist"},
+		jwt.MapClaims{"name": "Jana Nováková"}))
+	recorder := httptest.NewRecorder()
+
+	// ACT
+	engine.ServeHTTP(recorder, request)
+
+	// ASSERT
+	suite.Equal(http.StatusNoContent, recorder.Code)
+	suite.Equal(auth.Principal{Subject: "user-2", Name: "Jana Nováková", Roles: []string{"pharmacist"}}, principal)
+}
+
+func (suite *AuthSuite) Test_LoadConfig_RejectsIncompleteAuthentication() {
+	// ARRANGE
+	suite.T().Setenv("MEDICINE_API_AUTH_ISSUER", "")
+	suite.T().Setenv("MEDICINE_API_AUTH_AUDIENCE", "")
+
+	// ACT
+	_, _, noIssuerErr := loadConfig("test", nil, io.Discard)
+	_, _, bothErr := loadConfig("test", []string{"--auth.issuer=https://sso.example.com", "--auth.jwksUrl=https://sso.example.com/certs"}, io.Discard)
+	_, _, notUrlErr := loadConfig("test", []string{"--auth.issuer=hospital", "--auth.jwksFile="}, io.Discard)
+
+	// ASSERT
+	suite.ErrorContains(noIssuerErr, "invalid auth.issuer (MEDICINE_API_AUTH_ISSUER): the issuer is required")
+	suite.ErrorContains(bothErr, "either the JWKS url or the JWKS file may be configured")
+	suite.ErrorContains(notUrlErr, `issuer "hospital" is not an url`)
+}
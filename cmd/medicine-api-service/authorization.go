@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undy45/medicine-webapi/internal/auth"
+)
+
+// routePolicy is what the principal needs to call the API route. The routes with the ambulanceId parameter
+// also require the membership of the principal in the ambulance.
+type routePolicy struct {
+	permission auth.Permission
+	// allAmbulances routes return the entries of every ambulance
+	allAmbulances bool
+}
+
+// routePolicies are the policies of the API routes keyed by the route name, the API routes without the policy
+// are forbidden
+var routePolicies = map[string]routePolicy{
+	"CreateAmbulance": {permission: auth.ManageAmbulances},
+	"DeleteAmbulance": {permission: auth.ManageAmbulances},
+	"GetAmbulances":   {permission: auth.ReadAmbulances},
+	"UpdateAmbulance": {permission: auth.ManageAmbulances},
+
+	"ExportMedicineInventory": {permission: auth.Export, allAmbulances: true},
+	"ExportMedicineOrders":    {permission: auth.Export, allAmbulances: true},
+
+	"GetMedicineInventoryEntries":   {permission: auth.ReadInventory},
+	"GetMedicineInventoryEntry":     {permission: auth.ReadInventory},
+	"BatchMedicineInventoryEntries": {permission: auth.WriteInventory},
+	"DeleteMedicineInventoryEntry":  {permission: auth.WriteInventory},
+	"ImportMedicineInventory":       {permission: auth.WriteInventory},
+	"UpdateMedicineInventoryEntry":  {permission: auth.WriteInventory},
+
+	"GetMedicineOrderEntries":   {permission: auth.ReadOrders},
+	"GetMedicineOrderEntry":     {permission: auth.ReadOrders},
+	"BatchMedicineOrderEntries": {permission: auth.WriteOrders},
+	"CreateMedicineOrderEntry":  {permission: auth.WriteOrders},
+	"DeleteMedicineOrderEntry":  {permission: auth.WriteOrders},
+	"UpdateMedicineOrderEntry":  {permission: auth.WriteOrders},
+
+	"GetInitialStatus": {permission: auth.ReadStatuses},
+	"GetStatus":        {permission: auth.ReadStatuses},
+	"GetStatuses":      {permission: auth.ReadStatuses},
+
+	"GetOrderTemplate":         {permission: auth.ReadTemplates},
+	"GetOrderTemplates":        {permission: auth.ReadTemplates},
+	"InstantiateOrderTemplate": {permission: auth.WriteOrders},
+	"CreateOrderTemplate":      {permission: auth.ManageTemplates},
+	"DeleteOrderTemplate":      {permission: auth.ManageTemplates},
+	"UpdateOrderTemplate":      {permission: auth.ManageTemplates},
+
+	"GetApiKeys":   {permission: auth.ManageApiKeys},
+	"IssueApiKey":  {permission: auth.ManageApiKeys},
+	"RevokeApiKey": {permission: auth.ManageApiKeys},
+}
+
+// authorization returns middleware forbidding the API routes the principal of the authenticated request
+// may not call, see routePolicies. The other routes, e.g. /metrics, only need the authentication.
+func authorization(routeNames map[string]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name, ok := routeNames[ctx.Request.Method+" "+ctx.FullPath()]
+		if !ok {
+			ctx.Next()
+			return
+		}
+		principal, _ := auth.PrincipalFromContext(ctx.Request.Context())
+		if err := authorize(principal, routePolicies[name], ctx.Param("ambulanceId")); err != nil {
+			slog.InfoContext(ctx.Request.Context(), "Request forbidden", "route", name, "error", err)
+			forbidden(ctx, err.Error())
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// authorize returns why the principal may not call the route of the policy for the ambulance
+func authorize(principal auth.Principal, policy routePolicy, ambulanceId string) error {
+	if policy.permission == "" {
+		return errors.New("the route has no policy")
+	}
+	if !principal.Can(policy.permission) {
+		return fmt.Errorf("permission %v is required", policy.permission)
+	}
+	if ambulanceId != "" && !principal.MemberOf(ambulanceId) {
+		return fmt.Errorf("ambulance %v is not accessible", ambulanceId)
+	}
+	if policy.allAmbulances && !principal.MemberOfAll() {
+		return errors.New("access to all ambulances is required")
+	}
+	return nil
+}
+
+// forbidden responds with the problem details of RFC 9457
+func forbidden(ctx *gin.Context, detail string) {
+	ctx.Header("Content-Type", problemContentType)
+	ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"type":   "about:blank",
+		"title":  http.StatusText(http.StatusForbidden),
+		"status": http.StatusForbidden,
+		"detail": detail,
+	})
+}
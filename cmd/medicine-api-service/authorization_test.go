@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"github.com/undy45/medicine-webapi/internal/auth/authtest"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+type AuthorizationSuite struct {
+	suite.Suite
+	issuer  *authtest.Issuer
+	storage storage
+	router  *gin.Engine
+}
+
+func TestAuthorizationSuite(t *testing.T) {
+	suite.Run(t, new(AuthorizationSuite))
+}
+
+func (suite *AuthorizationSuite) SetupTest() {
+	suite.issuer = authtest.NewIssuer(suite.T(), "https://sso.example.com/realms/hospital")
+	suite.router, suite.storage = authenticatedRouter(suite.T(), suite.issuer)
+}
+
+func (suite *AuthorizationSuite) TearDownTest() {
+	suite.NoError(suite.storage.Disconnect(context.Background()))
+}
+
+// token returns token of the role for the ambulances
+func (suite *AuthorizationSuite) token(role string, ambulances ...string) string {
+	return suite.issuer.Token(suite.T(), role+"-1", []string{role}, jwt.MapClaims{
+		"aud":        "medicine-webapi",
+		"ambulances": ambulances,
+	})
+}
+
+// request sends the request with the token and the JSON body, if given
+func (suite *AuthorizationSuite) request(method string, path string, token string, body any) *httptest.ResponseRecorder {
+	return suite.send(method, path, "Authorization", "Bearer "+token, body)
+}
+
+// send sends the request with the credentials in the header and the JSON body, if given
+func (suite *AuthorizationSuite) send(method string, path string, header string, credentials string, body any) *httptest.ResponseRecorder {
+	var content bytes.Buffer
+	if body != nil {
+		suite.Require().NoError(json.NewEncoder(&content).Encode(body))
+	}
+	request := httptest.NewRequest(method, path, &content)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(header, credentials)
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (suite *AuthorizationSuite) Test_Nurse_EditsInventoryOfOwnAmbulanceOnly() {
+	// ARRANGE
+	token := suite.token(auth.RoleNurse, "bobulova")
+	entry := medicine.MedicineInventoryEntry{MedicineId: "paralen", Name: "Paralen", Count: 10}
+
+	// ACT
+	own := suite.request(http.MethodPut, "/api/medicine-inventory/bobulova/entries/paralen", token, entry)
+	other := suite.request(http.MethodPut, "/api/medicine-inventory/e2e/entries/paralen", token, entry)
+
+	// ASSERT
+	suite.NotEqual(http.StatusForbidden, own.Code, own.Body.String())
+	suite.Equal(http.StatusForbidden, other.Code)
+	suite.Equal(problemContentType, other.Header().Get("Content-Type"))
+	var problem map[string]any
+	suite.Require().NoError(json.Unmarshal(other.Body.Bytes(), &problem))
+	suite.Equal("Forbidden", problem["title"])
+	suite.Equal(float64(http.StatusForbidden), problem["status"])
+	suite.Equal("ambulance e2e is not accessible", problem["detail"])
+	suite.Equal(other.Header().Get(requestIdHeader), problem["requestId"])
+}
+
+func (suite *AuthorizationSuite) Test_ManagingAmbulances_RequiresAdmin() {
+	// ARRANGE
+	ambulance := medicine.Ambulance{Id: "e2e", Name: "End to end", RoomNumber: "42"}
+
+	// ACT
+	byHeadNurse := suite.request(http.MethodPost, "/api/ambulance", suite.token(auth.RoleHeadNurse, auth.AllAmbulances), ambulance)
+	byAdmin := suite.request(http.MethodPost, "/api/ambulance", suite.token(auth.RoleAdmin), ambulance)
+	deleteByNurse := suite.request(http.MethodDelete, "/api/ambulance/e2e", suite.token(auth.RoleNurse, "e2e"), nil)
+	deleteByAdmin := suite.request(http.MethodDelete, "/api/ambulance/e2e", suite.token(auth.RoleAdmin), nil)
+
+	// ASSERT
+	suite.Equal(http.StatusForbidden, byHeadNurse.Code)
+	suite.Equal(http.StatusCreated, byAdmin.Code, byAdmin.Body.String())
+	suite.Equal(http.StatusForbidden, deleteByNurse.Code)
+	suite.Equal(http.StatusNoContent, deleteByAdmin.Code)
+}
+
+func (suite *AuthorizationSuite) Test_Templates_AreManagedByHeadNurse() {
+	// ARRANGE
+	template := medicine.OrderTemplate{Name: "Weekly", Entries: []medicine.OrderTemplateEntry{{MedicineId: "paralen", Count: 1}}}
+
+	// ACT
+	byNurse := suite.request(http.MethodPost, "/api/medicine-order/bobulova/templates", suite.token(auth.RoleNurse, "bobulova"), template)
+	byHeadNurse := suite.request(http.MethodPost, "/api/medicine-order/bobulova/templates", suite.token(auth.RoleHeadNurse, "bobulova"), template)
+
+	// ASSERT
+	suite.Equal(http.StatusForbidden, byNurse.Code)
+	suite.Equal("permission templates:manage is required", suite.problemDetail(byNurse))
+	suite.NotEqual(http.StatusForbidden, byHeadNurse.Code, byHeadNurse.Body.String())
+}
+
+func (suite *AuthorizationSuite) Test_Export_RequiresAllAmbulances() {
+	// ACT
+	ofOneAmbulance := suite.request(http.MethodGet, "/api/export/medicine-inventory", suite.token(auth.RolePharmacist, "bobulova"), nil)
+	ofAllAmbulances := suite.request(http.MethodGet, "/api/export/medicine-inventory", suite.token(auth.RolePharmacist, auth.AllAmbulances), nil)
+
+	// ASSERT
+	suite.Equal(http.StatusForbidden, ofOneAmbulance.Code)
+	suite.Equal(http.StatusOK, ofAllAmbulances.Code, ofAllAmbulances.Body.String())
+}
+
+func (suite *AuthorizationSuite) Test_UnknownRole_IsForbidden() {
+	// ACT
+	response := suite.request(http.MethodGet, "/api/medicine-order/statuses", suite.token("visitor"), nil)
+
+	// ASSERT
+	suite.Equal(http.StatusForbidden, response.Code)
+}
+
+func (suite *AuthorizationSuite) Test_ApiKey_IsScopedToAmbulancesAndPermissions() {
+	// ARRANGE
+	admin := suite.token(auth.RoleAdmin)
+	response := suite.request(http.MethodPost, "/api/api-keys", admin, medicine.ApiKeyRequest{
+		Name:        "Supplier integration",
+		Ambulances:  []string{"bobulova"},
+		Permissions: []string{"inventory:read", "orders:write"},
+	})
+	suite.Require().Equal(http.StatusCreated, response.Code, response.Body.String())
+	var issued medicine.IssuedApiKey
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &issued))
+	order := medicine.MedicineOrderEntry{MedicineId: "paralen", Count: 1}
+
+	// ACT
+	inventory := suite.send(http.MethodGet, "/api/medicine-inventory/bobulova/entries", auth.ApiKeyHeader, issued.Secret, nil)
+	created := suite.send(http.MethodPost, "/api/medicine-order/bobulova/entries", auth.ApiKeyHeader, issued.Secret, order)
+	otherAmbulance := suite.send(http.MethodGet, "/api/medicine-inventory/e2e/entries", auth.ApiKeyHeader, issued.Secret, nil)
+	notPermitted := suite.send(http.MethodDelete, "/api/medicine-inventory/bobulova/entries/paralen", auth.ApiKeyHeader, issued.Secret, nil)
+	issuing := suite.send(http.MethodGet, "/api/api-keys", auth.ApiKeyHeader, issued.Secret, nil)
+	var listed []medicine.ApiKey
+	suite.Require().NoError(json.Unmarshal(suite.request(http.MethodGet, "/api/api-keys", admin, nil).Body.Bytes(), &listed))
+
+	// ASSERT
+	suite.Equal(http.StatusOK, inventory.Code, inventory.Body.String())
+	suite.Equal(http.StatusOK, created.Code, created.Body.String())
+	suite.Equal(http.StatusForbidden, otherAmbulance.Code)
+	suite.Equal(http.StatusForbidden, notPermitted.Code)
+	suite.Equal(http.StatusForbidden, issuing.Code)
+	suite.Require().Len(listed, 1)
+	suite.Equal("admin-1", listed[0].CreatedBy)
+	suite.NotNil(listed[0].LastUsedAt)
+}
+
+func (suite *AuthorizationSuite) Test_RevokedApiKey_IsUnauthorized() {
+	// ARRANGE
+	admin := suite.token(auth.RoleAdmin)
+	response := suite.request(http.MethodPost, "/api/api-keys", admin, medicine.ApiKeyRequest{
+		Name: "Nightly export", Ambulances: []string{auth.AllAmbulances}, Permissions: []string{"exports:read"},
+	})
+	suite.Require().Equal(http.StatusCreated, response.Code, response.Body.String())
+	var issued medicine.IssuedApiKey
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &issued))
+	beforeRevoke := suite.send(http.MethodGet, "/api/export/medicine-orders", auth.ApiKeyHeader, issued.Secret, nil)
+
+	// ACT
+	revoked := suite.request(http.MethodDelete, "/api/api-keys/"+issued.Id, admin, nil)
+	afterRevoke := suite.send(http.MethodGet, "/api/export/medicine-orders", auth.ApiKeyHeader, issued.Secret, nil)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, beforeRevoke.Code, beforeRevoke.Body.String())
+	suite.Equal(http.StatusNoContent, revoked.Code)
+	suite.Equal(http.StatusUnauthorized, afterRevoke.Code)
+	suite.Contains(afterRevoke.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
+
+func (suite *AuthorizationSuite) Test_EveryRoute_HasPolicy() {
+	// ARRANGE
+	routeNames := medicine.RouteNames(newHandleFunctions())
+
+	for _, name := range routeNames {
+		// ASSERT
+		suite.NotEmpty(routePolicies[name].permission, name)
+	}
+}
+
+// problemDetail returns the detail of the problem response
+func (suite *AuthorizationSuite) problemDetail(response *httptest.ResponseRecorder) string {
+	var problem map[string]any
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &problem), response.Body.String())
+	detail, _ := problem["detail"].(string)
+	return detail
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEnv names the variable with path of the config file, the --config flag overrides it
+const configFileEnv = "MEDICINE_API_CONFIG_FILE"
+
+// config is the configuration of the service. It is loaded from the defaults, the YAML config file,
+// the MEDICINE_API_* variables and the command line flags, each of them overrides the previous ones.
+type config struct {
+	// Environment production disables the debug mode of gin
+	Environment string
+	Server      serverConfig
+	Cors        corsConfig
+	Auth        authConfig
+	LogLevel    slog.Level
+	// TracingExporter is none, stdout or otlp, see setupTracing
+	TracingExporter string
+	Storage         storageConfig
+	// DigestInterval is the period of the digests of the routine and urgent orders
+	DigestInterval time.Duration
+	// MigrateRetryDelay is the delay between the connection attempts of the migrate command
+	MigrateRetryDelay time.Duration
+
+	// sources records where the values which are not the defaults come from
+	sources map[string]string
+}
+
+// storageConfig selects the storage backend and its layout, see newStorage
+type storageConfig struct {
+	Kind                 string
+	Layout               string
+	Mongo                db_service.MongoServiceConfig
+	MongoConnectAttempts int
+	InventoryCollection  string
+	OrderCollection      string
+	Sqlite               db_service.SqliteServiceConfig
+}
+
+// defaultConfig returns the config used when nothing is configured
+func defaultConfig() *config {
+	return &config{
+		Server: serverConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      120 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownDelay:     5 * time.Second,
+			GracePeriod:       20 * time.Second,
+		},
+		Cors: defaultCorsConfig(),
+		Auth: authConfig{
+			Verifier: auth.VerifierConfig{
+				RolesClaim:      "roles",
+				NameClaim:       "name",
+				AmbulancesClaim: "ambulances",
+				ClockSkew:       30 * time.Second,
+			},
+			PublicPaths: []string{"/healthz", "/readyz", "/openapi"},
+		},
+		LogLevel:        slog.LevelInfo,
+		TracingExporter: "none",
+		Storage: storageConfig{
+			Kind:   "mongo",
+			Layout: "embedded",
+			Mongo: db_service.MongoServiceConfig{
+				ServerHost: "localhost",
+				ServerPort: 27017,
+				AppName:    "medicine-webapi",
+				DbName:     "ee-medicine",
+				Collection: "ambulance",
+				Timeout:    10 * time.Second,
+			},
+			MongoConnectAttempts: 10,
+			InventoryCollection:  "inventory",
+			OrderCollection:      "orders",
+			Sqlite: db_service.SqliteServiceConfig{
+				Path:    "medicine.db",
+				Timeout: 10 * time.Second,
+			},
+		},
+		DigestInterval:    60 * time.Minute,
+		MigrateRetryDelay: 5 * time.Second,
+		sources:           map[string]string{},
+	}
+}
+
+// option binds one value of the config to its key in the config file, which is also the name of its flag,
+// and to its environment variable
+type option struct {
+	key string
+	env string
+	// deprecatedEnv is the former name of env, read only when env is not set
+	deprecatedEnv string
+	usage         string
+	value         configValue
+	// secret values are not printed
+	secret bool
+	// check validates the value after all the sources are applied
+	check func() error
+}
+
+// configValue parses and formats one value of the config
+type configValue interface {
+	flag.Getter
+	IsBoolFlag() bool
+}
+
+// options returns the options bound to the values of the config
+func (c *config) options() []option {
+	return []option{
+		{key: "environment", env: "MEDICINE_API_ENVIRONMENT", usage: "production disables the debug mode",
+			value: stringValue{&c.Environment}},
+		{key: "server.port", env: "MEDICINE_API_PORT", usage: "port of the HTTP server",
+			value: intValue{&c.Server.Port}, check: inRange(&c.Server.Port, 0, 65535)},
+		{key: "server.readHeaderTimeout", env: "MEDICINE_API_READ_HEADER_TIMEOUT_SECONDS", usage: "time to read the request headers",
+			value: durationValue{&c.Server.ReadHeaderTimeout, time.Second}},
+		{key: "server.readTimeout", env: "MEDICINE_API_READ_TIMEOUT_SECONDS", usage: "time to read the whole request",
+			value: durationValue{&c.Server.ReadTimeout, time.Second}},
+		{key: "server.writeTimeout", env: "MEDICINE_API_WRITE_TIMEOUT_SECONDS", usage: "time to write the response, including the exports",
+			value: durationValue{&c.Server.WriteTimeout, time.Second}},
+		{key: "server.idleTimeout", env: "MEDICINE_API_IDLE_TIMEOUT_SECONDS", usage: "time the idle keep-alive connections are kept open",
+			value: durationValue{&c.Server.IdleTimeout, time.Second}},
+		{key: "server.shutdownDelay", env: "MEDICINE_API_SHUTDOWN_DELAY_SECONDS", usage: "time the service is not ready before the shutdown",
+			value: durationValue{&c.Server.ShutdownDelay, time.Second}},
+		{key: "server.shutdownGracePeriod", env: "MEDICINE_API_SHUTDOWN_GRACE_SECONDS", usage: "time to drain the in-flight requests",
+			value: durationValue{&c.Server.GracePeriod, time.Second}},
+		{key: "cors.allowedOrigins", env: "MEDICINE_API_CORS_ALLOWED_ORIGINS", usage: "origins allowed to call the API, * outside of production by default",
+			value: listValue{&c.Cors.AllowedOrigins}, check: func() error { return c.Cors.validate() }},
+		{key: "cors.allowedMethods", env: "MEDICINE_API_CORS_ALLOWED_METHODS", usage: "methods allowed from the other origins",
+			value: listValue{&c.Cors.AllowedMethods}},
+		{key: "cors.allowedHeaders", env: "MEDICINE_API_CORS_ALLOWED_HEADERS", usage: "request headers allowed from the other origins",
+			value: listValue{&c.Cors.AllowedHeaders}},
+		{key: "cors.exposedHeaders", env: "MEDICINE_API_CORS_EXPOSED_HEADERS", usage: "response headers readable by the other origins",
+			value: listValue{&c.Cors.ExposedHeaders}},
+		{key: "cors.allowCredentials", env: "MEDICINE_API_CORS_ALLOW_CREDENTIALS", usage: "allow cookies and authorization of the other origins",
+			value: boolValue{&c.Cors.AllowCredentials}},
+		{key: "cors.maxAge", env: "MEDICINE_API_CORS_MAX_AGE_SECONDS", usage: "time the browsers cache the preflight responses",
+			value: durationValue{&c.Cors.MaxAge, time.Second}},
+		{key: "auth.issuer", env: "MEDICINE_API_AUTH_ISSUER", usage: "issuer of the bearer tokens, the API is not authenticated if empty",
+			value: stringValue{&c.Auth.Verifier.Issuer}, check: func() error { return c.Auth.validate() }},
+		{key: "auth.audience", env: "MEDICINE_API_AUTH_AUDIENCE", usage: "audience the tokens have to be issued for",
+			value: stringValue{&c.Auth.Verifier.Audience}},
+		{key: "auth.jwksUrl", env: "MEDICINE_API_AUTH_JWKS_URL", usage: "url of the signing keys, discovered from the issuer if empty",
+			value: stringValue{&c.Auth.JwksUrl}},
+		{key: "auth.jwksFile", env: "MEDICINE_API_AUTH_JWKS_FILE", usage: "file with the signing keys for the offline setups",
+			value: stringValue{&c.Auth.JwksFile}},
+		{key: "auth.rolesClaim", env: "MEDICINE_API_AUTH_ROLES_CLAIM", usage: "claim with the roles, e.g. realm_access.roles",
+			value: stringValue{&c.Auth.Verifier.RolesClaim}, check: notEmpty(&c.Auth.Verifier.RolesClaim)},
+		{key: "auth.nameClaim", env: "MEDICINE_API_AUTH_NAME_CLAIM", usage: "claim with the name of the caller",
+			value: stringValue{&c.Auth.Verifier.NameClaim}, check: notEmpty(&c.Auth.Verifier.NameClaim)},
+		{key: "auth.ambulancesClaim", env: "MEDICINE_API_AUTH_AMBULANCES_CLAIM", usage: "claim with the ids of the ambulances of the caller, * is every ambulance",
+			value: stringValue{&c.Auth.Verifier.AmbulancesClaim}, check: notEmpty(&c.Auth.Verifier.AmbulancesClaim)},
+		{key: "auth.clockSkew", env: "MEDICINE_API_AUTH_CLOCK_SKEW_SECONDS", usage: "tolerated difference of the clocks of the issuer and the service",
+			value: durationValue{&c.Auth.Verifier.ClockSkew, time.Second}},
+		{key: "auth.publicPaths", env: "MEDICINE_API_AUTH_PUBLIC_PATHS", usage: "paths served without the authentication",
+			value: listValue{&c.Auth.PublicPaths}},
+		{key: "log.level", env: "MEDICINE_API_LOG_LEVEL", usage: "debug, info, warn or error",
+			value: levelValue{&c.LogLevel}},
+		{key: "tracing.exporter", env: "MEDICINE_API_TRACING_EXPORTER", usage: "none, stdout or otlp",
+			value: stringValue{&c.TracingExporter}, check: oneOf(&c.TracingExporter, "none", "stdout", "otlp")},
+		{key: "storage.kind", env: "MEDICINE_API_STORAGE", usage: "mongo, sqlite or memory",
+			value: stringValue{&c.Storage.Kind}, check: oneOf(&c.Storage.Kind, "mongo", "mongodb", "sqlite", "memory")},
+		{key: "storage.layout", env: "MEDICINE_API_STORAGE_LAYOUT", usage: "embedded or split",
+			value: stringValue{&c.Storage.Layout}, check: oneOf(&c.Storage.Layout, "embedded", "split")},
+		{key: "mongodb.uri", env: "MEDICINE_API_MONGODB_URI", usage: "connection string, overrides the host and the port",
+			value: stringValue{&c.Storage.Mongo.URI}, secret: true},
+		{key: "mongodb.uriFile", env: "MEDICINE_API_MONGODB_URI_FILE", usage: "file with the connection string",
+			value: stringValue{&c.Storage.Mongo.URIFile}},
+		{key: "mongodb.host", env: "MEDICINE_API_MONGODB_HOST", usage: "host of the MongoDB server",
+			value: stringValue{&c.Storage.Mongo.ServerHost}},
+		{key: "mongodb.port", env: "MEDICINE_API_MONGODB_PORT", usage: "port of the MongoDB server",
+			value: intValue{&c.Storage.Mongo.ServerPort}, check: inRange(&c.Storage.Mongo.ServerPort, 0, 65535)},
+		{key: "mongodb.username", env: "MEDICINE_API_MONGODB_USERNAME", usage: "MongoDB user",
+			value: stringValue{&c.Storage.Mongo.UserName}},
+		{key: "mongodb.usernameFile", env: "MEDICINE_API_MONGODB_USERNAME_FILE", usage: "file with the MongoDB user",
+			value: stringValue{&c.Storage.Mongo.UserNameFile}},
+		{key: "mongodb.password", env: "MEDICINE_API_MONGODB_PASSWORD", usage: "password of the MongoDB user",
+			value: stringValue{&c.Storage.Mongo.Password}, secret: true},
+		{key: "mongodb.passwordFile", env: "MEDICINE_API_MONGODB_PASSWORD_FILE", usage: "file with the password of the MongoDB user",
+			value: stringValue{&c.Storage.Mongo.PasswordFile}},
+		{key: "mongodb.authSource", env: "MEDICINE_API_MONGODB_AUTH_SOURCE", usage: "database of the MongoDB user",
+			value: stringValue{&c.Storage.Mongo.AuthSource}},
+		{key: "mongodb.replicaSet", env: "MEDICINE_API_MONGODB_REPLICA_SET", usage: "name of the replica set",
+			value: stringValue{&c.Storage.Mongo.ReplicaSet}},
+		{key: "mongodb.tls", env: "MEDICINE_API_MONGODB_TLS", usage: "connect with TLS",
+			value: boolValue{&c.Storage.Mongo.TLS}},
+		{key: "mongodb.tlsCaFile", env: "MEDICINE_API_MONGODB_TLS_CA_FILE", usage: "CA certificates of the server",
+			value: stringValue{&c.Storage.Mongo.TLSCAFile}},
+		{key: "mongodb.tlsCertFile", env: "MEDICINE_API_MONGODB_TLS_CERT_FILE", usage: "client certificate",
+			value: stringValue{&c.Storage.Mongo.TLSCertFile}},
+		{key: "mongodb.tlsKeyFile", env: "MEDICINE_API_MONGODB_TLS_KEY_FILE", usage: "key of the client certificate",
+			value: stringValue{&c.Storage.Mongo.TLSKeyFile}},
+		{key: "mongodb.readConcern", env: "MEDICINE_API_MONGODB_READ_CONCERN", usage: "read concern level, e.g. majority",
+			value: stringValue{&c.Storage.Mongo.ReadConcern}},
+		{key: "mongodb.writeConcern", env: "MEDICINE_API_MONGODB_WRITE_CONCERN", usage: "majority, number of the nodes or tag set",
+			value: stringValue{&c.Storage.Mongo.WriteConcern}},
+		{key: "mongodb.maxPoolSize", env: "MEDICINE_API_MONGODB_MAX_POOL_SIZE", usage: "maximum connections, 0 is the driver default",
+			value: uintValue{&c.Storage.Mongo.MaxPoolSize}},
+		{key: "mongodb.minPoolSize", env: "MEDICINE_API_MONGODB_MIN_POOL_SIZE", usage: "connections kept open",
+			value: uintValue{&c.Storage.Mongo.MinPoolSize}, check: c.checkPoolSize},
+		{key: "mongodb.appName", env: "MEDICINE_API_MONGODB_APP_NAME", usage: "application name reported to the server",
+			value: stringValue{&c.Storage.Mongo.AppName}},
+		{key: "mongodb.database", env: "MEDICINE_API_MONGODB_DATABASE", usage: "database of the service",
+			value: stringValue{&c.Storage.Mongo.DbName}, check: notEmpty(&c.Storage.Mongo.DbName)},
+		{key: "mongodb.collection", env: "MEDICINE_API_MONGODB_COLLECTION", usage: "collection of the ambulances",
+			value: stringValue{&c.Storage.Mongo.Collection}, check: notEmpty(&c.Storage.Mongo.Collection)},
+		{key: "mongodb.inventoryCollection", env: "MEDICINE_API_MONGODB_INVENTORY_COLLECTION", usage: "collection of the inventory in split layout",
+			value: stringValue{&c.Storage.InventoryCollection}, check: notEmpty(&c.Storage.InventoryCollection)},
+		{key: "mongodb.orderCollection", env: "MEDICINE_API_MONGODB_ORDER_COLLECTION", usage: "collection of the orders in split layout",
+			value: stringValue{&c.Storage.OrderCollection}, check: notEmpty(&c.Storage.OrderCollection)},
+		{key: "mongodb.timeout", env: "MEDICINE_API_MONGODB_TIMEOUT_SECONDS", usage: "timeout of the MongoDB operations",
+			value: durationValue{&c.Storage.Mongo.Timeout, time.Second}, check: positive(&c.Storage.Mongo.Timeout)},
+		{key: "mongodb.connectAttempts", env: "MEDICINE_API_MONGODB_CONNECT_ATTEMPTS", usage: "pings before giving up at startup, 0 retries forever",
+			value: intValue{&c.Storage.MongoConnectAttempts}, check: inRange(&c.Storage.MongoConnectAttempts, 0, 1000)},
+		{key: "sqlite.path", env: "MEDICINE_API_SQLITE_PATH", usage: "SQLite database file",
+			value: stringValue{&c.Storage.Sqlite.Path}, check: notEmpty(&c.Storage.Sqlite.Path)},
+		{key: "sqlite.timeout", env: "MEDICINE_API_SQLITE_TIMEOUT_SECONDS", usage: "timeout of the SQLite operations",
+			value: durationValue{&c.Storage.Sqlite.Timeout, time.Second}, check: positive(&c.Storage.Sqlite.Timeout)},
+		{key: "orders.digestInterval", env: "MEDICINE_API_ORDER_DIGEST_MINUTES", usage: "period of the order digests",
+			value: durationValue{&c.DigestInterval, time.Minute}, check: positive(&c.DigestInterval)},
+		{key: "migrate.retryDelay", env: "MEDICINE_API_MIGRATE_RETRY_DELAY_SECONDS", deprecatedEnv: "RETRY_CONNECTION_SECONDS",
+			usage: "delay between the connection attempts of migrate",
+			value: durationValue{&c.MigrateRetryDelay, time.Second}, check: positive(&c.MigrateRetryDelay)},
+	}
+}
+
+// loadConfig returns the config and the arguments which follow the flags. The config file is given
+// by the --config flag or MEDICINE_API_CONFIG_FILE. All the invalid values are reported at once.
+func loadConfig(name string, args []string, output io.Writer) (*config, []string, error) {
+	c := defaultConfig()
+	options := c.options()
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	configFile := flags.String("config", os.Getenv(configFileEnv), "YAML config file, also "+configFileEnv)
+	for _, option := range options {
+		flags.Var(&flagValue{text: option.value.String(), isBool: option.value.IsBoolFlag()},
+			option.key, fmt.Sprintf("%v (%v)", option.usage, option.env))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	invalid := map[string]bool{}
+	set := func(option option, value string, source string) {
+		if err := option.value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %v %q from %v: %w", option.key, value, source, err))
+			invalid[option.key] = true
+			return
+		}
+		c.sources[option.key] = source
+	}
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, option := range options {
+			if value, ok := values[option.key]; ok {
+				set(option, value, *configFile)
+				delete(values, option.key)
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			errs = append(errs, fmt.Errorf("unknown key %v in %v", key, *configFile))
+		}
+	}
+	for _, option := range options {
+		// the empty variables are ignored, as the service always did
+		if value := os.Getenv(option.env); value != "" {
+			set(option, value, option.env)
+		} else if value := os.Getenv(option.deprecatedEnv); option.deprecatedEnv != "" && value != "" {
+			fmt.Fprintf(output, "%v is deprecated, use %v instead\n", option.deprecatedEnv, option.env)
+			set(option, value, option.deprecatedEnv)
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		for _, option := range options {
+			if option.key == f.Name {
+				set(option, f.Value.String(), "--"+f.Name)
+			}
+		}
+	})
+	if _, configured := c.sources["cors.allowedOrigins"]; !configured {
+		c.Cors = c.Cors.withEnvironmentDefaults(c.production())
+	}
+	for _, option := range options {
+		if option.check == nil || invalid[option.key] {
+			continue
+		}
+		if err := option.check(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %v (%v): %w", option.key, option.env, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return c, flags.Args(), nil
+}
+
+// production returns whether the service runs in the production environment
+func (c *config) production() bool {
+	return strings.EqualFold(c.Environment, "production")
+}
+
+// readConfigFile returns the values of the YAML file by their dotted keys, e.g. mongodb.host
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+	var document map[string]any
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("invalid config file %v: %w", path, err)
+	}
+	values := map[string]string{}
+	var flatten func(prefix string, section map[string]any)
+	flatten = func(prefix string, section map[string]any) {
+		for key, value := range section {
+			switch value := value.(type) {
+			case map[string]any:
+				flatten(prefix+key+".", value)
+			case []any:
+				items := make([]string, len(value))
+				for i, item := range value {
+					items[i] = fmt.Sprint(item)
+				}
+				values[prefix+key] = strings.Join(items, ",")
+			case nil:
+				values[prefix+key] = ""
+			default:
+				values[prefix+key] = fmt.Sprint(value)
+			}
+		}
+	}
+	flatten("", document)
+	return values, nil
+}
+
+// print writes the config as YAML, which can be used as the config file. The secrets are redacted
+// and the values which are not the defaults are commented with their source.
+func (c *config) print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, option := range c.options() {
+		parent := root
+		path := strings.Split(option.key, ".")
+		for _, section := range path[:len(path)-1] {
+			parent = sectionNode(parent, section)
+		}
+		value := &yaml.Node{}
+		if err := value.Encode(option.value.Get()); err != nil {
+			return err
+		}
+		value.LineComment = c.sources[option.key]
+		if option.secret && option.value.String() != "" {
+			value.SetString("xxxxx")
+			value.LineComment += ", redacted"
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: path[len(path)-1]}, value)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// sectionNode returns the mapping of the section in the parent mapping, it is added if missing
+func sectionNode(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name {
+			return parent.Content[i+1]
+		}
+	}
+	section := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, section)
+	return section
+}
+
+func (c *config) checkPoolSize() error {
+	mongo := c.Storage.Mongo
+	if mongo.MaxPoolSize > 0 && mongo.MinPoolSize > mongo.MaxPoolSize {
+		return fmt.Errorf("%v is more than maxPoolSize %v", mongo.MinPoolSize, mongo.MaxPoolSize)
+	}
+	return nil
+}
+
+func oneOf(value *string, allowed ...string) func() error {
+	return func() error {
+		if !slices.Contains(allowed, strings.ToLower(*value)) {
+			return fmt.Errorf("%q is not one of %v", *value, strings.Join(allowed, ", "))
+		}
+		return nil
+	}
+}
+
+func inRange(value *int, minimum int, maximum int) func() error {
+	return func() error {
+		if *value < minimum || *value > maximum {
+			return fmt.Errorf("%v is not between %v and %v", *value, minimum, maximum)
+		}
+		return nil
+	}
+}
+
+func notEmpty(value *string) func() error {
+	return func() error {
+		if *value == "" {
+			return errors.New("must not be empty")
+		}
+		return nil
+	}
+}
+
+func positive(value *time.Duration) func() error {
+	return func() error {
+		if *value <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	}
+}
+
+// flagValue keeps the command line value, it is applied after the config file and the environment
+type flagValue struct {
+	text   string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	return v.text
+}
+
+func (v *flagValue) Set(text string) error {
+	v.text = text
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+type stringValue struct {
+	target *string
+}
+
+func (v stringValue) String() string {
+	return *v.target
+}
+
+func (v stringValue) Get() any {
+	return *v.target
+}
+
+func (v stringValue) IsBoolFlag() bool {
+	return false
+}
+
+func (v stringValue) Set(text string) error {
+	*v.target = text
+	return nil
+}
+
+// listValue accepts comma separated values, the lists of the config file are joined by commas
+type listValue struct {
+	target *[]string
+}
+
+func (v listValue) String() string {
+	return strings.Join(*v.target, ",")
+}
+
+func (v listValue) Get() any {
+	return slices.Clone(*v.target)
+}
+
+func (v listValue) IsBoolFlag() bool {
+	return false
+}
+
+func (v listValue) Set(text string) error {
+	values := []string{}
+	for _, value := range strings.Split(text, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	*v.target = values
+	return nil
+}
+
+type intValue struct {
+	target *int
+}
+
+func (v intValue) String() string {
+	return strconv.Itoa(*v.target)
+}
+
+func (v intValue) Get() any {
+	return *v.target
+}
+
+func (v intValue) IsBoolFlag() bool {
+	return false
+}
+
+func (v intValue) Set(text string) error {
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return errors.New("not a number")
+	}
+	*v.target = value
+	return nil
+}
+
+type uintValue struct {
+	target *uint64
+}
+
+func (v uintValue) String() string {
+	return strconv.FormatUint(*v.target, 10)
+}
+
+func (v uintValue) Get() any {
+	return *v.target
+}
+
+func (v uintValue) IsBoolFlag() bool {
+	return false
+}
+
+func (v uintValue) Set(text string) error {
+	value, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return errors.New("not a non-negative number")
+	}
+	*v.target = value
+	return nil
+}
+
+type boolValue struct {
+	target *bool
+}
+
+func (v boolValue) String() string {
+	return strconv.FormatBool(*v.target)
+}
+
+func (v boolValue) Get() any {
+	return *v.target
+}
+
+func (v boolValue) IsBoolFlag() bool {
+	return true
+}
+
+func (v boolValue) Set(text string) error {
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return errors.New("not true or false")
+	}
+	*v.target = value
+	return nil
+}
+
+// durationValue accepts a duration like 1m30s or a whole number of the units, which is how
+// the MEDICINE_API_*_SECONDS and MEDICINE_API_*_MINUTES variables are given
+type durationValue struct {
+	target *time.Duration
+	unit   time.Duration
+}
+
+func (v durationValue) String() string {
+	return v.target.String()
+}
+
+func (v durationValue) Get() any {
+	return v.target.String()
+}
+
+func (v durationValue) IsBoolFlag() bool {
+	return false
+}
+
+func (v durationValue) Set(text string) error {
+	value, err := time.ParseDuration(text)
+	if number, numberErr := strconv.Atoi(text); numberErr == nil {
+		value, err = time.Duration(number)*v.unit, nil
+	}
+	if err != nil {
+		unit := "seconds"
+		if v.unit == time.Minute {
+			unit = "minutes"
+		}
+		return fmt.Errorf("not a duration like 30s or a number of %v", unit)
+	}
+	if value < 0 {
+		return errors.New("must not be negative")
+	}
+	*v.target = value
+	return nil
+}
+
+type levelValue struct {
+	target *slog.Level
+}
+
+func (v levelValue) String() string {
+	return strings.ToLower(v.target.String())
+}
+
+func (v levelValue) Get() any {
+	return v.String()
+}
+
+func (v levelValue) IsBoolFlag() bool {
+	return false
+}
+
+func (v levelValue) Set(text string) error {
+	if err := v.target.UnmarshalText([]byte(text)); err != nil {
+		return errors.New("not one of debug, info, warn or error")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// corsConfig is the policy for the browsers calling the API from other origins
+type corsConfig struct {
+	// AllowedOrigins are the origins like https://ambulance.example.com, a pattern like
+	// https://*.example.com or * for any origin. No origins means the same origin only.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers the scripts of the other origins may read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long the browsers cache the responses to the preflight requests
+	MaxAge time.Duration
+}
+
+// defaultCorsConfig returns the policy allowing the methods, the headers and the exposed headers the API uses.
+// The allowed origins depend on the environment, see withEnvironmentDefaults.
+func defaultCorsConfig() corsConfig {
+	return corsConfig{
+		AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Origin", "Authorization", "Content-Type", requestIdHeader, "traceparent", "tracestate"},
+		ExposedHeaders: []string{requestIdHeader, "X-Total-Count", "Content-Disposition", "WWW-Authenticate"},
+		MaxAge:         12 * time.Hour,
+	}
+}
+
+// withEnvironmentDefaults returns the policy with the origins, which were not configured, allowed
+// only outside of production
+func (config corsConfig) withEnvironmentDefaults(production bool) corsConfig {
+	if !production {
+		config.AllowedOrigins = []string{"*"}
+	}
+	return config
+}
+
+// validate reports the policies the middleware or the browsers refuse
+func (config corsConfig) validate() error {
+	if config.AllowCredentials && slices.Contains(config.AllowedOrigins, "*") {
+		return errors.New("credentials cannot be allowed for any origin, list the allowed origins")
+	}
+	if len(config.AllowedOrigins) == 0 {
+		return nil
+	}
+	return config.middlewareConfig().Validate()
+}
+
+func (config corsConfig) middlewareConfig() cors.Config {
+	middlewareConfig := cors.Config{
+		AllowOrigins:     config.AllowedOrigins,
+		AllowMethods:     config.AllowedMethods,
+		AllowHeaders:     config.AllowedHeaders,
+		ExposeHeaders:    config.ExposedHeaders,
+		AllowCredentials: config.AllowCredentials,
+		AllowWildcard:    true,
+		MaxAge:           config.MaxAge,
+	}
+	if len(config.AllowedOrigins) == 0 {
+		middlewareConfig.AllowOriginFunc = func(origin string) bool {
+			return false
+		}
+	}
+	return middlewareConfig
+}
+
+// middleware applies the policy, the requests from the origins which are not allowed are forbidden
+func (config corsConfig) middleware() gin.HandlerFunc {
+	return cors.New(config.middlewareConfig())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type CorsSuite struct {
+	suite.Suite
+}
+
+func TestCorsSuite(t *testing.T) {
+	suite.Run(t, new(CorsSuite))
+}
+
+func (suite *CorsSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+}
+
+// router returns engine with the policy serving the API like endpoint with the total count
+func (suite *CorsSuite) router(config corsConfig) *gin.Engine {
+	engine := gin.New()
+	engine.Use(config.middleware())
+	engine.GET("/api/ambulances", func(ctx *gin.Context) {
+		ctx.Header("X-Total-Count", "1")
+		ctx.JSON(http.StatusOK, []string{"bobulova"})
+	})
+	return engine
+}
+
+// corsRequest sends the request from the origin to the router
+func (suite *CorsSuite) corsRequest(router *gin.Engine, method string, origin string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/api/ambulances", nil)
+	request.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		request.Header.Set("Access-Control-Request-Headers", "authorization,x-request-id")
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (suite *CorsSuite) Test_Development_AllowsAnyOriginAndExposesHeaders() {
+	// ARRANGE
+	config := loadTestConfig(suite.T())
+	router := suite.router(config.Cors)
+
+	// ACT
+	preflight := suite.corsRequest(router, http.MethodOptions, "http://localhost:3000")
+	response := suite.corsRequest(router, http.MethodGet, "http://localhost:3000")
+
+	// ASSERT
+	suite.Equal(http.StatusNoContent, preflight.Code)
+	suite.Equal("*", preflight.Header().Get("Access-Control-Allow-Origin"))
+	suite.Equal("GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS", preflight.Header().Get("Access-Control-Allow-Methods"))
+	suite.Contains(preflight.Header().Get("Access-Control-Allow-Headers"), "X-Request-Id")
+	suite.Equal(http.StatusOK, response.Code)
+	suite.Equal("X-Request-Id,X-Total-Count,Content-Disposition,Www-Authenticate", response.Header().Get("Access-Control-Expose-Headers"))
+}
+
+func (suite *CorsSuite) Test_Production_ForbidsOtherOriginsByDefault() {
+	// ARRANGE
+	suite.T().Setenv("MEDICINE_API_ENVIRONMENT", "production")
+	config := loadTestConfig(suite.T())
+	router := suite.router(config.Cors)
+
+	// ACT
+	preflight := suite.corsRequest(router, http.MethodOptions, "https://evil.example.com")
+	response := suite.corsRequest(router, http.MethodGet, "https://evil.example.com")
+	sameOrigin := suite.corsRequest(router, http.MethodGet, "http://example.com")
+
+	// ASSERT
+	suite.Empty(config.Cors.AllowedOrigins)
+	suite.Equal(http.StatusForbidden, preflight.Code)
+	suite.Equal(http.StatusForbidden, response.Code)
+	suite.Equal(http.StatusOK, sameOrigin.Code, "the requests of the same origin are not restricted")
+}
+
+func (suite *CorsSuite) Test_Production_AllowsConfiguredOrigins() {
+	// ARRANGE
+	suite.T().Setenv("MEDICINE_API_ENVIRONMENT", "production")
+	suite.T().Setenv("MEDICINE_API_CORS_ALLOWED_ORIGINS", "https://ambulance.example.com, https://*.hospital.example.com")
+	suite.T().Setenv("MEDICINE_API_CORS_ALLOW_CREDENTIALS", "true")
+	config := loadTestConfig(suite.T())
+	router := suite.router(config.Cors)
+
+	// ACT
+	allowed := suite.corsRequest(router, http.MethodGet, "https://ambulance.example.com")
+	wildcard := suite.corsRequest(router, http.MethodOptions, "https://ward.hospital.example.com")
+	other := suite.corsRequest(router, http.MethodGet, "https://ambulance.example.org")
+
+	// ASSERT
+	suite.Equal(http.StatusOK, allowed.Code)
+	suite.Equal("https://ambulance.example.com", allowed.Header().Get("Access-Control-Allow-Origin"))
+	suite.Equal("true", allowed.Header().Get("Access-Control-Allow-Credentials"))
+	suite.Equal(http.StatusNoContent, wildcard.Code)
+	suite.Equal("https://ward.hospital.example.com", wildcard.Header().Get("Access-Control-Allow-Origin"))
+	suite.Equal(http.StatusForbidden, other.Code)
+}
+
+func (suite *CorsSuite) Test_LoadConfig_RejectsInvalidPolicy() {
+	// ARRANGE
+	suite.T().Setenv("MEDICINE_API_CORS_ALLOW_CREDENTIALS", "true")
+
+	// ACT
+	anyOrigin, _, anyOriginErr := loadConfig("test", nil, io.Discard)
+	_, _, schemeErr := loadConfig("test", []string{"--cors.allowedOrigins=ambulance.example.com"}, io.Discard)
+
+	// ASSERT
+	suite.Nil(anyOrigin)
+	suite.ErrorContains(anyOriginErr, "invalid cors.allowedOrigins (MEDICINE_API_CORS_ALLOWED_ORIGINS): credentials cannot be allowed for any origin")
+	suite.ErrorContains(schemeErr, "bad origin: origins must contain '*' or include http://,https://")
+}
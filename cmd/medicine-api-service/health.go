@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+const (
+	checkUp   = "up"
+	checkDown = "down"
+
+	// initialStatusId is the status the new orders start in, see GetInitialStatus
+	initialStatusId = 1
+	// readinessTimeout bounds all the checks of one readiness probe
+	readinessTimeout = 5 * time.Second
+)
+
+// dependencyCheck verifies one dependency the service needs to handle requests
+type dependencyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// checkResult is reported for each dependency by /readyz
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// readiness runs the dependency checks and logs the results which changed since the previous probe
+type readiness struct {
+	checks   []dependencyCheck
+	lock     sync.Mutex
+	previous map[string]checkResult
+	// stopping is set when the shutdown begins, the service is not ready since then
+	stopping atomic.Bool
+}
+
+// newReadiness returns readiness of the storage, the database server is pinged only if it has one
+func newReadiness(storage storage) *readiness {
+	r := &readiness{previous: map[string]checkResult{}}
+	checks := []dependencyCheck{{
+		name: "shutdown",
+		check: func(ctx context.Context) error {
+			if r.stopping.Load() {
+				return errors.New("the service is shutting down")
+			}
+			return nil
+		},
+	}}
+	if storage.ping != nil {
+		checks = append(checks, dependencyCheck{name: "mongodb", check: storage.ping})
+	}
+	checks = append(checks, dependencyCheck{
+		name: "initialStatus",
+		check: func(ctx context.Context) error {
+			_, err := storage.statuses.FindDocument(ctx, initialStatusId)
+			if errors.Is(err, db_service.ErrNotFound) {
+				return fmt.Errorf("initial status %v is missing, were the migrations applied?", initialStatusId)
+			}
+			return err
+		},
+	})
+	r.checks = checks
+	return r
+}
+
+// stop makes the service not ready, so that it receives no new requests while the in-flight ones are drained
+func (r *readiness) stop() {
+	r.stopping.Store(true)
+}
+
+// run checks all the dependencies, the service is ready if all of them are up
+func (r *readiness) run(ctx context.Context) (bool, map[string]checkResult) {
+	ctx, contextCancel := context.WithTimeout(ctx, readinessTimeout)
+	defer contextCancel()
+
+	ready := true
+	results := make(map[string]checkResult, len(r.checks))
+	for _, dependency := range r.checks {
+		result := checkResult{Status: checkUp}
+		if err := dependency.check(ctx); err != nil {
+			ready = false
+			result = checkResult{Status: checkDown, Error: err.Error()}
+		}
+		results[dependency.name] = result
+	}
+	r.logChanges(results)
+	return ready, results
+}
+
+func (r *readiness) logChanges(results map[string]checkResult) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, dependency := range r.checks {
+		result := results[dependency.name]
+		previous, known := r.previous[dependency.name]
+		if known && previous.Status == result.Status {
+			continue
+		}
+		if result.Status == checkUp {
+			slog.Info("Readiness check changed", "check", dependency.name, "status", result.Status)
+		} else {
+			slog.Warn("Readiness check changed", "check", dependency.name, "status", result.Status, "error", result.Error)
+		}
+		r.previous[dependency.name] = result
+	}
+}
+
+// handleReady responds 200 when all the dependencies are up and 503 otherwise
+func (r *readiness) handleReady(ctx *gin.Context) {
+	ready, results := r.run(ctx.Request.Context())
+	status, code := checkUp, http.StatusOK
+	if !ready {
+		status, code = checkDown, http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+// handleHealth responds as long as the process serves requests, it does not check the dependencies
+// so that the liveness probe does not restart the service when the database is unavailable
+func handleHealth(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": checkUp})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+type HealthSuite struct {
+	suite.Suite
+	storage storage
+	metrics *metrics
+	pingErr error
+	logs    bytes.Buffer
+}
+
+type readyResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+func TestHealthSuite(t *testing.T) {
+	suite.Run(t, new(HealthSuite))
+}
+
+func (suite *HealthSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.T().Setenv("MEDICINE_API_STORAGE", "memory")
+	suite.T().Setenv("MEDICINE_API_STORAGE_LAYOUT", "embedded")
+	suite.metrics = newMetrics()
+	var err error
+	suite.storage, err = newStorage(context.Background(), loadTestConfig(suite.T()).Storage, suite.metrics)
+	suite.Require().NoError(err)
+	suite.pingErr = nil
+	suite.storage.ping = func(ctx context.Context) error {
+		return suite.pingErr
+	}
+	suite.logs.Reset()
+	previous := slog.Default()
+	suite.T().Cleanup(func() { restoreLogger(previous) })
+	slog.SetDefault(newLogger(&suite.logs, slog.LevelInfo))
+}
+
+func (suite *HealthSuite) TearDownTest() {
+	suite.NoError(suite.storage.Disconnect(context.Background()))
+}
+
+// get sends GET request to the router of the storage
+func (suite *HealthSuite) get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+// ready requests the readiness and decodes the response
+func (suite *HealthSuite) ready(router *gin.Engine) (int, readyResponse) {
+	recorder := suite.get(router, "/readyz")
+	var response readyResponse
+	suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &response), recorder.Body.String())
+	return recorder.Code, response
+}
+
+func (suite *HealthSuite) newRouter() *gin.Engine {
+	notifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), time.Hour)
+	return newRouter(suite.storage, notifier, suite.metrics, newReadiness(suite.storage), loadTestConfig(suite.T()).Cors, nil)
+}
+
+func (suite *HealthSuite) Test_Healthz_IgnoresDependencies() {
+	// ARRANGE
+	suite.pingErr = errors.New("connection refused")
+	router := suite.newRouter()
+
+	// ACT
+	response := suite.get(router, "/healthz")
+
+	// ASSERT
+	suite.Equal(http.StatusOK, response.Code)
+	suite.JSONEq(`{"status": "up"}`, response.Body.String())
+}
+
+func (suite *HealthSuite) Test_Readyz_AllDependenciesUp() {
+	// ARRANGE
+	router := suite.newRouter()
+
+	// ACT
+	code, response := suite.ready(router)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, code)
+	suite.Equal("up", response.Status)
+	suite.Equal(map[string]checkResult{
+		"shutdown":      {Status: "up"},
+		"mongodb":       {Status: "up"},
+		"initialStatus": {Status: "up"},
+	}, response.Checks)
+}
+
+func (suite *HealthSuite) Test_Readyz_ReportsFailedDependencies() {
+	// ARRANGE
+	suite.pingErr = errors.New("connection refused")
+	suite.Require().NoError(suite.storage.statuses.DeleteDocument(context.Background(), initialStatusId))
+	router := suite.newRouter()
+
+	// ACT
+	code, response := suite.ready(router)
+
+	// ASSERT
+	suite.Equal(http.StatusServiceUnavailable, code)
+	suite.Equal("down", response.Status)
+	suite.Equal(checkResult{Status: "down", Error: "connection refused"}, response.Checks["mongodb"])
+	suite.Equal("down", response.Checks["initialStatus"].Status)
+	suite.Contains(response.Checks["initialStatus"].Error, "initial status 1 is missing")
+}
+
+func (suite *HealthSuite) Test_Readyz_WithoutDatabaseServer() {
+	// ARRANGE
+	suite.storage.ping = nil
+	router := suite.newRouter()
+
+	// ACT
+	code, response := suite.ready(router)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, code)
+	suite.Equal(map[string]checkResult{"shutdown": {Status: "up"}, "initialStatus": {Status: "up"}}, response.Checks)
+}
+
+func (suite *HealthSuite) Test_Readyz_FailsWhenStopping() {
+	// ARRANGE
+	notifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), time.Hour)
+	readiness := newReadiness(suite.storage)
+	router := newRouter(suite.storage, notifier, suite.metrics, readiness, loadTestConfig(suite.T()).Cors, nil)
+
+	// ACT
+	readiness.stop()
+	code, response := suite.ready(router)
+
+	// ASSERT
+	suite.Equal(http.StatusServiceUnavailable, code)
+	suite.Equal(checkResult{Status: "down", Error: "the service is shutting down"}, response.Checks["shutdown"])
+	suite.Equal("up", response.Checks["mongodb"].Status)
+	suite.Equal(http.StatusOK, suite.get(router, "/healthz").Code, "the service stays alive while draining")
+}
+
+func (suite *HealthSuite) Test_Readyz_LogsOnlyChanges() {
+	// ARRANGE
+	router := suite.newRouter()
+
+	// ACT
+	suite.ready(router)
+	suite.ready(router)
+	suite.pingErr = errors.New("connection refused")
+	suite.ready(router)
+	suite.ready(router)
+	suite.pingErr = nil
+	suite.ready(router)
+
+	// ASSERT
+	logs := suite.logs.String()
+	suite.Equal(2, strings.Count(logs, `"check":"mongodb","status":"up"`), logs)
+	suite.Equal(1, strings.Count(logs, `"check":"mongodb","status":"down","error":"connection refused"`), logs)
+	suite.Equal(1, strings.Count(logs, `"check":"initialStatus","status":"up"`), logs)
+}
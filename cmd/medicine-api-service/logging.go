@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// problemContentType is the type of the problem details of RFC 9457
+const problemContentType = "application/problem+json"
+
+// validRequestId limits the request ids accepted from the callers, the others are replaced
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIdKey struct{}
+
+// withRequestId returns context of the request with the id, the log records of the context include it
+func withRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// requestId returns id of the request of the context or empty string
+func requestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request id and the trace of the context to the log records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestId(ctx); id != "" {
+		record.AddAttrs(slog.String("requestId", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("traceId", span.TraceID().String()), slog.String("spanId", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// newLogger returns logger writing JSON records of the level and above
+func newLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// setupLogging makes the JSON logger of the level the default of slog and of the log package
+func setupLogging(level slog.Level) {
+	slog.SetDefault(newLogger(os.Stderr, level))
+
+	// gin writes the routes and its warnings in debug mode
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)))
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		slog.Debug("Route", "method", method, "path", path, "handler", handler)
+	}
+}
+
+// requestLogging assigns the request id, or takes it from the X-Request-ID header of the request, and returns it
+// in the header of the response and in the JSON error responses. Every request is logged when it completes.
+func requestLogging(routeNames map[string]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		started := time.Now()
+		id := ctx.GetHeader(requestIdHeader)
+		if !validRequestId.MatchString(id) {
+			id = uuid.NewString()
+		}
+		ctx.Request = ctx.Request.WithContext(withRequestId(ctx.Request.Context(), id))
+		ctx.Header(requestIdHeader, id)
+		writer := &errorBodyWriter{ResponseWriter: ctx.Writer, requestId: id}
+		ctx.Writer = writer
+
+		ctx.Next()
+
+		writer.flush()
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		attributes := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", routeName(ctx, routeNames)),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latencyMs", float64(time.Since(started).Microseconds())/1000),
+		}
+		if ambulanceId := ctx.Param("ambulanceId"); ambulanceId != "" {
+			attributes = append(attributes, slog.String("ambulanceId", ambulanceId))
+		}
+		if principal, ok := auth.PrincipalFromContext(ctx.Request.Context()); ok {
+			attributes = append(attributes, slog.String("subject", principal.Subject))
+		}
+		if len(ctx.Errors) > 0 {
+			attributes = append(attributes, slog.String("error", ctx.Errors.String()))
+		}
+		slog.LogAttrs(ctx.Request.Context(), level, "Request completed", attributes...)
+	}
+}
+
+// errorBodyWriter adds the request id to the JSON objects in the bodies of the error responses
+type errorBodyWriter struct {
+	gin.ResponseWriter
+	requestId string
+	body      *bytes.Buffer
+}
+
+// buffers returns whether the written content is the body of the JSON error response
+func (w *errorBodyWriter) buffers() bool {
+	if w.body != nil {
+		return true
+	}
+	contentType := w.Header().Get("Content-Type")
+	if w.Status() < http.StatusBadRequest || w.ResponseWriter.Written() ||
+		!strings.HasPrefix(contentType, "application/json") && !strings.HasPrefix(contentType, problemContentType) {
+		return false
+	}
+	w.body = &bytes.Buffer{}
+	return true
+}
+
+func (w *errorBodyWriter) Write(data []byte) (int, error) {
+	if w.buffers() {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorBodyWriter) WriteString(data string) (int, error) {
+	if w.buffers() {
+		return w.body.WriteString(data)
+	}
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *errorBodyWriter) Written() bool {
+	return w.body != nil || w.ResponseWriter.Written()
+}
+
+func (w *errorBodyWriter) Size() int {
+	if w.body != nil {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+// flush writes the buffered error response, JSON object gets the requestId field
+func (w *errorBodyWriter) flush() {
+	if w.body == nil {
+		return
+	}
+	content := w.body.Bytes()
+	var object map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if decoder.Decode(&object) == nil && object != nil {
+		if _, ok := object["requestId"]; !ok {
+			object["requestId"] = w.requestId
+			if withId, err := json.Marshal(object); err == nil {
+				content = withId
+			}
+		}
+	}
+	w.body = nil
+	w.ResponseWriter.Write(content)
+}
+
+// recovery responds with internal server error to the requests which panicked and logs the panic
+func recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, err any) {
+		slog.ErrorContext(ctx.Request.Context(), "Request handler panicked", "error", fmt.Sprint(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Internal server error",
+		})
+	})
+}
+
+// fatal logs the error and exits the process
+func fatal(message string, args ...any) {
+	slog.Error(message, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+// restoreLogger makes the logger default again, the log package writes to stderr as without slog
+func restoreLogger(logger *slog.Logger) {
+	slog.SetDefault(logger)
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.LstdFlags)
+}
+
+type LoggingSuite struct {
+	suite.Suite
+	storage storage
+	router  *gin.Engine
+	logs    bytes.Buffer
+}
+
+func TestLoggingSuite(t *testing.T) {
+	suite.Run(t, new(LoggingSuite))
+}
+
+func (suite *LoggingSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.T().Setenv("MEDICINE_API_STORAGE", "memory")
+	suite.T().Setenv("MEDICINE_API_STORAGE_LAYOUT", "embedded")
+	metrics := newMetrics()
+	config := loadTestConfig(suite.T())
+	var err error
+	suite.storage, err = newStorage(context.Background(), config.Storage, metrics)
+	suite.Require().NoError(err)
+	notifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), time.Hour)
+	suite.router = newRouter(suite.storage, notifier, metrics, newReadiness(suite.storage), config.Cors, nil)
+	suite.router.GET("/panic", func(ctx *gin.Context) { panic("broken handler") })
+
+	suite.logs.Reset()
+	previous := slog.Default()
+	suite.T().Cleanup(func() { restoreLogger(previous) })
+	slog.SetDefault(newLogger(&suite.logs, slog.LevelInfo))
+}
+
+func (suite *LoggingSuite) TearDownTest() {
+	suite.NoError(suite.storage.Disconnect(context.Background()))
+}
+
+// get sends the request with the request id header, if it is not empty
+func (suite *LoggingSuite) get(path string, requestId string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if requestId != "" {
+		request.Header.Set(requestIdHeader, requestId)
+	}
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// records returns the logged records with the message
+func (suite *LoggingSuite) records(message string) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(suite.logs.String()), "\n") {
+		var record map[string]any
+		suite.Require().NoError(json.Unmarshal([]byte(line), &record), line)
+		if record["msg"] == message {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func (suite *LoggingSuite) Test_RequestIdIsPropagated() {
+	// ACT
+	response := suite.get("/api/medicine-inventory/bobulova/entries", "gateway-42")
+
+	// ASSERT
+	suite.Equal(http.StatusOK, response.Code)
+	suite.Equal("gateway-42", response.Header().Get(requestIdHeader))
+	records := suite.records("Request completed")
+	suite.Require().Len(records, 1)
+	suite.Equal("INFO", records[0]["level"])
+	suite.Equal("gateway-42", records[0]["requestId"])
+	suite.Equal("GET", records[0]["method"])
+	suite.Equal("GetMedicineInventoryEntries", records[0]["route"])
+	suite.Equal(float64(http.StatusOK), records[0]["status"])
+	suite.Equal("bobulova", records[0]["ambulanceId"])
+	suite.Contains(records[0], "latencyMs")
+}
+
+func (suite *LoggingSuite) Test_ErrorResponseHasRequestId() {
+	// ACT
+	response := suite.get("/api/medicine-inventory/missing/entries", "invalid id with spaces")
+
+	// ASSERT
+	suite.Equal(http.StatusNotFound, response.Code)
+	requestId := response.Header().Get(requestIdHeader)
+	suite.NoError(uuid.Validate(requestId), "invalid request id is replaced")
+	var body map[string]any
+	suite.Require().NoError(json.Unmarshal(response.Body.Bytes(), &body), response.Body.String())
+	suite.Equal(requestId, body["requestId"])
+	suite.Equal("Not Found", body["status"], "the other fields are kept")
+	records := suite.records("Request completed")
+	suite.Require().Len(records, 1)
+	suite.Equal("WARN", records[0]["level"])
+	suite.Equal(requestId, records[0]["requestId"])
+}
+
+func (suite *LoggingSuite) Test_PanicIsLoggedWithRequestId() {
+	// ACT
+	response := suite.get("/panic", "")
+
+	// ASSERT
+	suite.Equal(http.StatusInternalServerError, response.Code)
+	requestId := response.Header().Get(requestIdHeader)
+	suite.JSONEq(`{"status": 500, "message": "Internal server error", "requestId": "`+requestId+`"}`, response.Body.String())
+	panics := suite.records("Request handler panicked")
+	suite.Require().Len(panics, 1)
+	suite.Equal("broken handler", panics[0]["error"])
+	suite.Equal(requestId, panics[0]["requestId"])
+	requests := suite.records("Request completed")
+	suite.Require().Len(requests, 1)
+	suite.Equal("ERROR", requests[0]["level"])
+	suite.Equal("/panic", requests[0]["route"])
+}
+
+func (suite *LoggingSuite) Test_SetupLogging_Level() {
+	// ARRANGE
+	suite.T().Setenv("MEDICINE_API_LOG_LEVEL", "warn")
+	config := loadTestConfig(suite.T())
+
+	// ACT
+	setupLogging(config.LogLevel)
+
+	// ASSERT
+	suite.False(slog.Default().Enabled(context.Background(), slog.LevelInfo))
+	suite.True(slog.Default().Enabled(context.Background(), slog.LevelWarn))
+}
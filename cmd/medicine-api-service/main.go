@@ -2,65 +2,164 @@ package main
 
 import (
 	"context"
-	"github.com/gin-contrib/cors"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/undy45/medicine-webapi/api"
-	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/auth"
 	"github.com/undy45/medicine-webapi/internal/medicine"
-	"log"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"syscall"
 )
 
+const usageText = "usage: medicine-webapi-srv [migrate | config print] [flags], -h lists the flags"
+
 func main() {
-	log.Printf("Server started")
-	port := os.Getenv("MEDICINE_API_PORT")
-	if port == "" {
-		port = "8080"
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "":
+		config, _ := loadConfigOrExit("medicine-webapi-srv", args, false)
+		setupLogging(config.LogLevel)
+		serveApi(config)
+	case "migrate":
+		config, args := loadConfigOrExit("migrate", args, true)
+		setupLogging(config.LogLevel)
+		migrate(config, args)
+	case "config":
+		if len(args) == 0 || args[0] != "print" {
+			exitWithUsage(usageText)
+		}
+		config, _ := loadConfigOrExit("config print", args[1:], false)
+		if err := config.print(os.Stdout); err != nil {
+			fatal("Failed to print the config", "error", err)
+		}
+	default:
+		exitWithUsage(usageText)
+	}
+}
+
+// loadConfigOrExit returns the config and the remaining arguments of the command, the process exits
+// if the configuration is invalid or if there are arguments the command does not expect
+func loadConfigOrExit(command string, args []string, withArgs bool) (*config, []string) {
+	config, rest, err := loadConfig(command, args, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	case len(rest) > 0 && !withArgs:
+		exitWithUsage(fmt.Sprintf("unexpected argument %q\n%v", rest[0], usageText))
 	}
-	environment := os.Getenv("MEDICINE_API_ENVIRONMENT")
-	if !strings.EqualFold(environment, "production") { // case insensitive comparison
+	return config, rest
+}
+
+// serveApi serves the API until SIGINT or SIGTERM is received
+func serveApi(config *config) {
+	// the second signal terminates the process without waiting for the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+
+	if !config.production() {
 		gin.SetMode(gin.DebugMode)
 	}
-	engine := gin.New()
-	engine.Use(gin.Recovery())
-	corsMiddleware := cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "PUT", "POST", "DELETE", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{""},
-		AllowCredentials: false,
-		MaxAge:           12 * time.Hour,
-	})
-	engine.Use(corsMiddleware)
+	shutdownTracing, err := setupTracing(ctx, config.TracingExporter)
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+	metrics := newMetrics()
+	storage, err := newStorage(ctx, config.Storage, metrics)
+	if err != nil {
+		fatal("Failed to open the storage", "error", err)
+	}
 
-	// setup context update  middleware
-	ambulanceSvc := db_service.NewMongoService[medicine.Ambulance](db_service.MongoServiceConfig{
-		Collection: "ambulance",
-	})
-	defer ambulanceSvc.Disconnect(context.Background())
-	statusSvc := db_service.NewMongoService[medicine.Status](db_service.MongoServiceConfig{
-		Collection: "status",
-	})
-	defer statusSvc.Disconnect(context.Background())
-	engine.Use(func(ctx *gin.Context) {
-		ctx.Set("db_service_ambulance", ambulanceSvc)
-		ctx.Set("db_service_status", statusSvc)
-		ctx.Next()
-	})
-	//engine.Use(func(ctx *gin.Context) {
-	//	ctx.Set("db_service", dbService)
-	//	ctx.Next()
-	//})
-	// request routings
-	handleFunctions := &medicine.ApiHandleFunctions{
+	// routine and urgent orders are reported in periodic digest, emergency orders immediately
+	orderNotifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), config.DigestInterval)
+	jobs := newBackgroundJobs()
+	jobs.start(orderNotifier.Run)
+
+	authenticate, err := newAuthentication(config.Auth, auth.NewApiKeys(storage.apiKeys))
+	if err != nil {
+		fatal("Failed to set up authentication", "error", err)
+	}
+	if authenticate == nil && config.production() {
+		slog.Warn("The API is not authenticated, configure MEDICINE_API_AUTH_ISSUER")
+	}
+
+	readiness := newReadiness(storage)
+	engine := newRouter(storage, orderNotifier, metrics, readiness, config.Cors, authenticate)
+	listener, err := net.Listen("tcp", config.Server.address())
+	if err != nil {
+		fatal("Failed to listen", "address", config.Server.address(), "error", err)
+	}
+	if err := serve(ctx, listener, config.Server, engine.Handler(), readiness, jobs, storage); err != nil {
+		shutdownTracing(context.Background())
+		fatal("Server failed", "error", err)
+	}
+}
+
+// newHandleFunctions returns the handlers of the API routes
+func newHandleFunctions() medicine.ApiHandleFunctions {
+	return medicine.ApiHandleFunctions{
 		OrderStatusesAPI:     medicine.NewOrderStatusesApi(),
 		MedicineInventoryAPI: medicine.NewMedicineInventoryAPI(),
 		MedicineOrderAPI:     medicine.NewMedicineOrderAPI(),
 		AmbulancesAPI:        medicine.NewAmbulancesAPI(),
+		ApiKeysAPI:           medicine.NewApiKeysAPI(),
+		ExportsAPI:           medicine.NewExportsAPI(),
+		OrderTemplatesAPI:    medicine.NewOrderTemplatesAPI(),
+	}
+}
+
+// newRouter returns engine serving the API on top of the storage to the origins allowed by the CORS policy,
+// the requests and the statistics of the storage are exported to the metrics. The requests are authenticated
+// and authorized unless authenticate is nil.
+func newRouter(
+	storage storage,
+	orderNotifier medicine.OrderNotifier,
+	metrics *metrics,
+	readiness *readiness,
+	cors corsConfig,
+	authenticate gin.HandlerFunc,
+) *gin.Engine {
+	handleFunctions := newHandleFunctions()
+
+	routeNames := medicine.RouteNames(handleFunctions)
+
+	engine := gin.New()
+	// the handlers pass the gin context to the storage, it has to provide the values of the request
+	// context, e.g. the span of the request
+	engine.ContextWithFallback = true
+	// before the recovery, so that the panics are counted as the server errors
+	engine.Use(requestLogging(routeNames), metrics.middleware(routeNames), tracingMiddleware(routeNames))
+	engine.Use(recovery())
+	engine.Use(cors.middleware())
+	if authenticate != nil {
+		engine.Use(authenticate, authorization(routeNames))
 	}
-	medicine.NewRouterWithGinEngine(engine, *handleFunctions)
+
+	// setup context update  middleware
+	engine.Use(func(ctx *gin.Context) {
+		ctx.Set("db_service_ambulance", storage.ambulances)
+		ctx.Set("db_service_status", storage.statuses)
+		ctx.Set("db_service_api_key", storage.apiKeys)
+		ctx.Set("order_notifier", orderNotifier)
+		ctx.Next()
+	})
+	medicine.NewRouterWithGinEngine(engine, handleFunctions)
 	engine.GET("/openapi", api.HandleOpenApi)
-	engine.Run(":" + port)
+	engine.GET("/healthz", handleHealth)
+	engine.GET("/readyz", readiness.handleReady)
+	metrics.registerStatistics(storage)
+	engine.GET("/metrics", metrics.handler())
+	return engine
 }
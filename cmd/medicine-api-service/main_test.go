@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+// ApiSuite exercises the whole HTTP API on top of the storages without external services
+type ApiSuite struct {
+	suite.Suite
+	storageKind string
+	layout      string
+	storage     storage
+	metrics     *metrics
+	router      *gin.Engine
+}
+
+func TestApiSuite_Memory(t *testing.T) {
+	suite.Run(t, &ApiSuite{storageKind: "memory", layout: "embedded"})
+}
+
+func TestApiSuite_MemorySplit(t *testing.T) {
+	suite.Run(t, &ApiSuite{storageKind: "memory", layout: "split"})
+}
+
+func TestApiSuite_Sqlite(t *testing.T) {
+	suite.Run(t, &ApiSuite{storageKind: "sqlite", layout: "embedded"})
+}
+
+func TestApiSuite_SqliteSplit(t *testing.T) {
+	suite.Run(t, &ApiSuite{storageKind: "sqlite", layout: "split"})
+}
+
+func (suite *ApiSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.T().Setenv("MEDICINE_API_STORAGE", suite.storageKind)
+	suite.T().Setenv("MEDICINE_API_STORAGE_LAYOUT", suite.layout)
+	suite.T().Setenv("MEDICINE_API_SQLITE_PATH", filepath.Join(suite.T().TempDir(), "medicine.db"))
+	suite.metrics = newMetrics()
+	config := loadTestConfig(suite.T())
+	var err error
+	suite.storage, err = newStorage(context.Background(), config.Storage, suite.metrics)
+	suite.Require().NoError(err)
+	notifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), time.Hour)
+	suite.router = newRouter(suite.storage, notifier, suite.metrics, newReadiness(suite.storage), config.Cors, nil)
+}
+
+func (suite *ApiSuite) TearDownTest() {
+	suite.NoError(suite.storage.Disconnect(context.Background()))
+}
+
+// request sends the request and decodes the JSON response into the response object, if given
+func (suite *ApiSuite) request(method string, path string, body any, response any) *httptest.ResponseRecorder {
+	var content bytes.Buffer
+	if body != nil {
+		suite.Require().NoError(json.NewEncoder(&content).Encode(body))
+	}
+	request := httptest.NewRequest(method, path, &content)
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, request)
+	if response != nil {
+		suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), response), recorder.Body.String())
+	}
+	return recorder
+}
+
+func (suite *ApiSuite) Test_SeededData() {
+	// ACT
+	var statuses []medicine.Status
+	statusesResponse := suite.request(http.MethodGet, "/api/medicine-order/statuses", nil, &statuses)
+	var initialStatus medicine.Status
+	suite.request(http.MethodGet, "/api/medicine-order/initial-status", nil, &initialStatus)
+	var ambulances []medicine.Ambulance
+	ambulancesResponse := suite.request(http.MethodGet, "/api/ambulance", nil, &ambulances)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, statusesResponse.Code)
+	suite.Len(statuses, 5)
+	suite.Equal([]int32{2, 4}, statuses[0].ValidTransitions)
+	suite.Equal(int32(5), initialStatus.Id)
+	suite.True(initialStatus.ApprovalStage)
+	suite.Equal(http.StatusOK, ambulancesResponse.Code)
+	suite.Equal("1", ambulancesResponse.Header().Get("X-Total-Count"))
+	suite.Equal("bobulova", ambulances[0].Id)
+}
+
+func (suite *ApiSuite) Test_DeliveredOrderIsAddedToInventory() {
+	// ARRANGE
+	response := suite.request(http.MethodPost, "/api/ambulance",
+		medicine.Ambulance{Id: "e2e", Name: "End to end", RoomNumber: "42"}, nil)
+	suite.Require().Equal(http.StatusCreated, response.Code)
+
+	// ACT
+	var order medicine.MedicineOrderEntry
+	response = suite.request(http.MethodPost, "/api/medicine-order/e2e/entries",
+		medicine.MedicineOrderEntry{MedicineId: "ibuprofen", Name: "Ibuprofen", Count: 5}, &order)
+	suite.Require().Equal(http.StatusOK, response.Code)
+	// the order is approved, shipped and delivered
+	for _, statusId := range []int32{1, 2, 3} {
+		response = suite.request(http.MethodPut, "/api/medicine-order/e2e/entries/"+order.Id,
+			medicine.MedicineOrderEntry{Status: medicine.Status{Id: statusId}}, &order)
+		suite.Require().Equal(http.StatusOK, response.Code, response.Body.String())
+	}
+
+	// ASSERT
+	suite.Equal("Delivered", order.Status.Value)
+	var inventory []medicine.MedicineInventoryEntry
+	response = suite.request(http.MethodGet, "/api/medicine-inventory/e2e/entries", nil, &inventory)
+	suite.Equal(http.StatusOK, response.Code)
+	suite.Require().Len(inventory, 1)
+	suite.Equal("ibuprofen", inventory[0].MedicineId)
+	suite.Equal(int32(5), inventory[0].Count)
+
+	var orders []medicine.MedicineOrderEntry
+	suite.request(http.MethodGet, "/api/medicine-order/e2e/entries", nil, &orders)
+	suite.Require().Len(orders, 1)
+	suite.Equal(int32(3), orders[0].Status.Id)
+}
+
+func (suite *ApiSuite) Test_UrgentOrderSkipsApproval() {
+	// ACT
+	var routine medicine.MedicineOrderEntry
+	routineResponse := suite.request(http.MethodPost, "/api/medicine-order/bobulova/entries",
+		medicine.MedicineOrderEntry{MedicineId: "paralen", Count: 1}, &routine)
+	var urgent medicine.MedicineOrderEntry
+	urgentResponse := suite.request(http.MethodPost, "/api/medicine-order/bobulova/entries",
+		medicine.MedicineOrderEntry{MedicineId: "ibuprofen", Count: 1, Priority: medicine.URGENT}, &urgent)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, routineResponse.Code)
+	suite.Equal(int32(5), routine.Status.Id)
+	suite.Equal(http.StatusOK, urgentResponse.Code)
+	suite.Equal(int32(1), urgent.Status.Id)
+}
+
+func (suite *ApiSuite) Test_DeletedAmbulanceIsGone() {
+	// ARRANGE
+	response := suite.request(http.MethodPost, "/api/medicine-order/bobulova/entries",
+		medicine.MedicineOrderEntry{MedicineId: "paralen", Count: 1}, nil)
+	suite.Require().Equal(http.StatusOK, response.Code)
+
+	// ACT
+	response = suite.request(http.MethodDelete, "/api/ambulance/bobulova", nil, nil)
+
+	// ASSERT
+	suite.Equal(http.StatusNoContent, response.Code)
+	response = suite.request(http.MethodGet, "/api/medicine-order/bobulova/entries", nil, nil)
+	suite.Equal(http.StatusNotFound, response.Code)
+	response = suite.request(http.MethodPost, "/api/ambulance", medicine.Ambulance{Id: "bobulova"}, nil)
+	suite.Equal(http.StatusCreated, response.Code)
+	var orders []medicine.MedicineOrderEntry
+	suite.request(http.MethodGet, "/api/medicine-order/bobulova/entries", nil, &orders)
+	suite.Empty(orders, "orders of the deleted ambulance must not reappear")
+}
+
+func (suite *ApiSuite) Test_Metrics() {
+	// ARRANGE
+	response := suite.request(http.MethodPost, "/api/ambulance", medicine.Ambulance{Id: "metrics", Name: "Metrics"}, nil)
+	suite.Require().Equal(http.StatusCreated, response.Code)
+	suite.request(http.MethodGet, "/api/medicine-order/missing/entries", nil, nil)
+	suite.request(http.MethodGet, "/api/unknown", nil, nil)
+
+	// ACT
+	response = suite.request(http.MethodGet, "/metrics", nil, nil)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, response.Code)
+	metrics := response.Body.String()
+	suite.Contains(metrics, `medicine_http_requests_total{code="201",method="POST",route="CreateAmbulance"} 1`)
+	suite.Contains(metrics, `medicine_http_requests_total{code="404",method="GET",route="GetMedicineOrderEntries"} 1`)
+	suite.Contains(metrics, `medicine_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	suite.Contains(metrics, `medicine_http_request_duration_seconds_count{method="POST",route="CreateAmbulance"} 1`)
+	suite.Regexp(`medicine_db_operation_duration_seconds_count\{collection="ambulance",operation="CreateDocument"\} \d+`, metrics)
+	suite.Regexp(`medicine_db_operation_errors_total\{collection="ambulance",error="not_found",operation="FindDocument"\} 1`, metrics)
+	suite.Regexp(`medicine_open_orders\{status="To_ship"\} \d+`, metrics)
+	suite.Regexp(`medicine_inventory_lines \d+`, metrics)
+	suite.Contains(metrics, "go_goroutines")
+}
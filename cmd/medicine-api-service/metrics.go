@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// statisticsTimeout bounds loading of the business statistics during one scrape
+const statisticsTimeout = 10 * time.Second
+
+// metrics are exported on /metrics in the Prometheus text format
+type metrics struct {
+	registry            *prometheus.Registry
+	requests            *prometheus.CounterVec
+	requestDuration     *prometheus.HistogramVec
+	operationDuration   *prometheus.HistogramVec
+	operationErrors     *prometheus.CounterVec
+	poolConnections     prometheus.Gauge
+	poolInUse           prometheus.Gauge
+	poolCheckoutFailure prometheus.Counter
+	poolCleared         prometheus.Counter
+}
+
+// newMetrics returns metrics with their own registry, which includes also the go runtime and process metrics
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "medicine_http_requests_total",
+			Help: "Number of the handled HTTP requests by the route name and the response code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "medicine_http_request_duration_seconds",
+			Help:    "Duration of the HTTP requests by the route name.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "medicine_db_operation_duration_seconds",
+			Help:    "Duration of the database operations by the collection and the operation.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"collection", "operation"}),
+		operationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "medicine_db_operation_errors_total",
+			Help: "Number of the failed database operations by the collection, the operation and the kind of the error.",
+		}, []string{"collection", "operation", "error"}),
+		poolConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "medicine_mongodb_pool_connections",
+			Help: "Number of the open connections in the MongoDB connection pool.",
+		}),
+		poolInUse: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "medicine_mongodb_pool_connections_in_use",
+			Help: "Number of the MongoDB connections checked out of the pool.",
+		}),
+		poolCheckoutFailure: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "medicine_mongodb_pool_checkout_failures_total",
+			Help: "Number of the failed checkouts of a connection from the MongoDB connection pool.",
+		}),
+		poolCleared: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "medicine_mongodb_pool_cleared_total",
+			Help: "Number of the times the MongoDB connection pool was cleared after an error.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.operationDuration,
+		m.operationErrors,
+		m.poolConnections,
+		m.poolInUse,
+		m.poolCheckoutFailure,
+		m.poolCleared,
+	)
+	return m
+}
+
+// routeName returns name of the API route of the request. The other routes are named by their
+// pattern and the requests of no route by "unmatched".
+func routeName(ctx *gin.Context, routeNames map[string]string) string {
+	route := ctx.FullPath()
+	if name, ok := routeNames[ctx.Request.Method+" "+route]; ok {
+		return name
+	}
+	if route == "" {
+		return "unmatched"
+	}
+	return route
+}
+
+// middleware observes the requests labeled by their route name, see routeName
+func (m *metrics) middleware(routeNames map[string]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		started := time.Now()
+		ctx.Next()
+
+		route := routeName(ctx, routeNames)
+		m.requests.WithLabelValues(route, ctx.Request.Method, strconv.Itoa(ctx.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(route, ctx.Request.Method).Observe(time.Since(started).Seconds())
+	}
+}
+
+// observeOperation is the db_service.OperationObserver of the storage services
+func (m *metrics) observeOperation(collection string, operation string, duration time.Duration, err error) {
+	m.operationDuration.WithLabelValues(collection, operation).Observe(duration.Seconds())
+	if err != nil {
+		m.operationErrors.WithLabelValues(collection, operation, errorKind(err)).Inc()
+	}
+}
+
+// errorKind returns the label of the error with bounded number of values
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, db_service.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, db_service.ErrConflict):
+		return "conflict"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "other"
+	}
+}
+
+// poolMonitor returns monitor updating the statistics of the MongoDB connection pool
+func (m *metrics) poolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(poolEvent *event.PoolEvent) {
+			switch poolEvent.Type {
+			case event.ConnectionCreated:
+				m.poolConnections.Inc()
+			case event.ConnectionClosed:
+				m.poolConnections.Dec()
+			case event.GetSucceeded:
+				m.poolInUse.Inc()
+			case event.ConnectionReturned:
+				m.poolInUse.Dec()
+			case event.GetFailed:
+				m.poolCheckoutFailure.Inc()
+			case event.PoolCleared:
+				m.poolCleared.Inc()
+			}
+		},
+	}
+}
+
+// registerStatistics exports the business statistics of the storage, they are loaded on every scrape
+func (m *metrics) registerStatistics(storage storage) {
+	m.registry.MustRegister(&statisticsCollector{
+		storage: storage,
+		openOrders: prometheus.NewDesc(
+			"medicine_open_orders",
+			"Number of the orders in the statuses with valid transitions.",
+			[]string{"status"}, nil,
+		),
+		inventoryLines: prometheus.NewDesc(
+			"medicine_inventory_lines",
+			"Number of the inventory entries of all the ambulances.",
+			nil, nil,
+		),
+	})
+}
+
+// handler serves the metrics, the failure of a collector does not prevent exporting the others
+func (m *metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	}))
+}
+
+// statisticsCollector loads medicine.Statistics when the metrics are scraped
+type statisticsCollector struct {
+	storage        storage
+	openOrders     *prometheus.Desc
+	inventoryLines *prometheus.Desc
+}
+
+func (c *statisticsCollector) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- c.openOrders
+	descriptions <- c.inventoryLines
+}
+
+func (c *statisticsCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx, contextCancel := context.WithTimeout(context.Background(), statisticsTimeout)
+	defer contextCancel()
+	statistics, err := medicine.LoadStatistics(ctx, c.storage.ambulances, c.storage.statuses)
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(c.openOrders, err)
+		metrics <- prometheus.NewInvalidMetric(c.inventoryLines, err)
+		return
+	}
+	for status, count := range statistics.OpenOrders {
+		metrics <- prometheus.MustNewConstMetric(c.openOrders, prometheus.GaugeValue, float64(count), status)
+	}
+	metrics <- prometheus.MustNewConstMetric(c.inventoryLines, prometheus.GaugeValue, float64(statistics.InventoryLines))
+}
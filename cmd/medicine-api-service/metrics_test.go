@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+type MetricsSuite struct {
+	suite.Suite
+	metrics *metrics
+}
+
+func TestMetricsSuite(t *testing.T) {
+	suite.Run(t, new(MetricsSuite))
+}
+
+func (suite *MetricsSuite) SetupTest() {
+	suite.metrics = newMetrics()
+}
+
+func (suite *MetricsSuite) Test_PoolMonitor_TracksConnections() {
+	// ARRANGE
+	monitor := suite.metrics.poolMonitor()
+
+	// ACT
+	for _, eventType := range []string{
+		event.ConnectionCreated,
+		event.ConnectionCreated,
+		event.ConnectionCreated,
+		event.GetSucceeded,
+		event.GetSucceeded,
+		event.ConnectionReturned,
+		event.ConnectionClosed,
+		event.GetFailed,
+		event.PoolCleared,
+	} {
+		monitor.Event(&event.PoolEvent{Type: eventType})
+	}
+
+	// ASSERT
+	suite.Equal(2.0, testutil.ToFloat64(suite.metrics.poolConnections))
+	suite.Equal(1.0, testutil.ToFloat64(suite.metrics.poolInUse))
+	suite.Equal(1.0, testutil.ToFloat64(suite.metrics.poolCheckoutFailure))
+	suite.Equal(1.0, testutil.ToFloat64(suite.metrics.poolCleared))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/migrations"
+)
+
+const migrateUsage = "usage: medicine-webapi-srv migrate [flags] [up [version] | down [version] | status]"
+
+// migrate runs the database migrations. Without arguments all pending migrations are applied,
+// down without version reverts the last applied migration.
+func migrate(config *config, args []string) {
+	ctx := context.Background()
+	// the database may be still starting, wait until it is available
+	retryDelay := config.MigrateRetryDelay
+	connection, err := db_service.ConnectMongo(
+		ctx,
+		config.Storage.Mongo,
+		db_service.ConnectRetry{InitialDelay: retryDelay, MaxDelay: retryDelay},
+	)
+	if err != nil {
+		fatal("Failed to connect to MongoDB", "error", err)
+	}
+	defer connection.Disconnect(ctx)
+
+	collections := migrations.Collections{
+		Ambulance: config.Storage.Mongo.Collection,
+		Status:    "status",
+		Inventory: config.Storage.InventoryCollection,
+		Orders:    config.Storage.OrderCollection,
+		ApiKey:    "apikey",
+	}
+	if strings.EqualFold(config.Storage.Layout, "split") {
+		collections.Layout = layoutMigration{backend: mongoCollections(connection, config.Storage)}
+	}
+	runner, err := migrations.NewRunner(migrations.NewMongoStore(connection.Database()), migrations.All(collections))
+	if err != nil {
+		fatal("Invalid migrations", "error", err)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	target := -1
+	if len(args) > 1 {
+		if target, err = strconv.Atoi(args[1]); err != nil || target < 0 || len(args) > 2 {
+			exitWithUsage(migrateUsage)
+		}
+	}
+
+	switch command {
+	case "up":
+		applied, err := runner.Up(ctx, max(target, 0))
+		if err != nil {
+			fatal("Failed to apply migrations", "error", err)
+		}
+		slog.Info("Applied migrations", "count", len(applied), "versions", applied)
+	case "down":
+		if target < 0 {
+			if target, err = previousVersion(ctx, runner); err != nil {
+				fatal("Failed to find the migration to revert", "error", err)
+			}
+		}
+		reverted, err := runner.Down(ctx, target)
+		if err != nil {
+			fatal("Failed to revert migrations", "error", err)
+		}
+		slog.Info("Reverted migrations", "count", len(reverted), "versions", reverted)
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			fatal("Failed to read the migrations status", "error", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Unknown {
+				state += ", unknown to this version"
+			}
+			fmt.Printf("%4d  %-60s %v\n", status.Version, status.Description, state)
+		}
+	default:
+		exitWithUsage(migrateUsage)
+	}
+}
+
+// exitWithUsage prints the usage of the command and exits the process
+func exitWithUsage(usage string) {
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
+}
+
+// previousVersion returns version of the last but one applied migration, so that only the last one is reverted
+func previousVersion(ctx context.Context, runner *migrations.Runner) (int, error) {
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var applied []int
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			applied = append(applied, status.Version)
+		}
+	}
+	if len(applied) < 2 {
+		return 0, nil
+	}
+	return applied[len(applied)-2], nil
+}
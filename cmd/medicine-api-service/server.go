@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// serverConfig configures the HTTP server and its shutdown
+type serverConfig struct {
+	Port              int
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout bounds also the streamed exports
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownDelay is the time between the readiness starts failing and the server stops accepting
+	// the connections, so that the load balancers stop sending new requests in the meantime
+	ShutdownDelay time.Duration
+	// GracePeriod limits draining of the in-flight requests, the remaining connections are closed then
+	GracePeriod time.Duration
+}
+
+// address returns the address the server listens on
+func (config serverConfig) address() string {
+	return net.JoinHostPort("", strconv.Itoa(config.Port))
+}
+
+// stopTimeout limits both stopping of the background jobs and disconnecting of the storage, each of them has
+// its own so that they are done even when the requests used up the whole grace period
+const stopTimeout = 5 * time.Second
+
+// backgroundJobs runs the jobs until they are stopped
+type backgroundJobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wait   sync.WaitGroup
+}
+
+func newBackgroundJobs() *backgroundJobs {
+	jobs := &backgroundJobs{}
+	jobs.ctx, jobs.cancel = context.WithCancel(context.Background())
+	return jobs
+}
+
+// start runs the job, its context is done when the jobs are stopped
+func (j *backgroundJobs) start(job func(ctx context.Context)) {
+	j.wait.Add(1)
+	go func() {
+		defer j.wait.Done()
+		job(j.ctx)
+	}()
+}
+
+// stop cancels the jobs and waits until they finish or the context is done
+func (j *backgroundJobs) stop(ctx context.Context) error {
+	j.cancel()
+	finished := make(chan struct{})
+	go func() {
+		j.wait.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serve runs the server on the listener until it fails or the context is done. Then the service becomes not ready,
+// after the shutdown delay the server stops accepting new connections and drains the in-flight requests within
+// the grace period. The background jobs are stopped and the storage disconnected after the requests are drained.
+func serve(ctx context.Context, listener net.Listener, config serverConfig, handler http.Handler, readiness *readiness, jobs *backgroundJobs, storage storage) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+	slog.Info("Server started", "address", listener.Addr().String())
+
+	var failure error
+	select {
+	case failure = <-serverErr:
+		slog.Error("Server failed", "error", failure)
+	case <-ctx.Done():
+		slog.Info("Shutdown started", "delay", config.ShutdownDelay.String(), "gracePeriod", config.GracePeriod.String())
+		readiness.stop()
+		time.Sleep(config.ShutdownDelay)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.GracePeriod)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("In-flight requests were not drained within the grace period", "error", err)
+		server.Close()
+	}
+	jobsCtx, jobsCancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+	defer jobsCancel()
+	if err := jobs.stop(jobsCtx); err != nil {
+		slog.Warn("Background jobs did not stop in time", "error", err)
+	}
+	disconnectCtx, disconnectCancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+	defer disconnectCancel()
+	if err := storage.Disconnect(disconnectCtx); err != nil {
+		slog.Warn("Failed to disconnect the storage", "error", err)
+	}
+	slog.Info("Server stopped")
+	if errors.Is(failure, http.ErrServerClosed) {
+		return nil
+	}
+	return failure
+}
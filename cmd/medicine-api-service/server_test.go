@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerSuite struct {
+	suite.Suite
+	storage storage
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
+
+func (suite *ServerSuite) SetupTest() {
+	suite.T().Setenv("MEDICINE_API_STORAGE", "memory")
+	suite.T().Setenv("MEDICINE_API_STORAGE_LAYOUT", "embedded")
+	var err error
+	suite.storage, err = newStorage(context.Background(), loadTestConfig(suite.T()).Storage, newMetrics())
+	suite.Require().NoError(err)
+}
+
+// testServerConfig returns config of the server with short delays
+func (suite *ServerSuite) testServerConfig() serverConfig {
+	return serverConfig{
+		ReadHeaderTimeout: time.Second,
+		ReadTimeout:       time.Second,
+		WriteTimeout:      5 * time.Second,
+		IdleTimeout:       time.Second,
+		ShutdownDelay:     200 * time.Millisecond,
+		GracePeriod:       5 * time.Second,
+	}
+}
+
+func (suite *ServerSuite) Test_Serve_DrainsInFlightRequests() {
+	// ARRANGE
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("drained"))
+	})
+	readiness := newReadiness(suite.storage)
+	jobs := newBackgroundJobs()
+	var jobStopped, disconnected atomic.Bool
+	jobs.start(func(ctx context.Context) {
+		<-ctx.Done()
+		jobStopped.Store(true)
+	})
+	suite.storage.disconnect = func(ctx context.Context) error {
+		suite.True(jobStopped.Load(), "jobs are stopped before the storage is disconnected")
+		disconnected.Store(true)
+		return nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, listener, suite.testServerConfig(), handler, readiness, jobs, suite.storage)
+	}()
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+
+	// ACT
+	cancel()
+	suite.Eventually(readiness.stopping.Load, time.Second, 10*time.Millisecond)
+	ready, results := readiness.run(context.Background())
+	close(release)
+
+	// ASSERT
+	suite.False(ready, "readiness fails as soon as the shutdown begins")
+	suite.Equal(checkDown, results["shutdown"].Status)
+	suite.Equal("drained", <-response)
+	suite.NoError(<-served)
+	suite.True(jobStopped.Load())
+	suite.True(disconnected.Load())
+}
+
+func (suite *ServerSuite) Test_Serve_ClosesConnectionsAfterGracePeriod() {
+	// ARRANGE
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	config := suite.testServerConfig()
+	config.ShutdownDelay, config.GracePeriod = 0, 100*time.Millisecond
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, listener, config, handler, newReadiness(suite.storage), newBackgroundJobs(), suite.storage)
+	}()
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-started
+
+	// ACT
+	began := time.Now()
+	cancel()
+
+	// ASSERT
+	suite.NoError(<-served)
+	suite.Less(time.Since(began), 2*time.Second)
+	suite.Error(<-requestErr, "the stuck request is cut off")
+}
+
+func (suite *ServerSuite) Test_Serve_StopsJobsAfterGracePeriodIsUsedUp() {
+	// ARRANGE
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	config := suite.testServerConfig()
+	config.ShutdownDelay, config.GracePeriod = 0, 100*time.Millisecond
+	jobs := newBackgroundJobs()
+	var jobStopped atomic.Bool
+	jobs.start(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(200 * time.Millisecond)
+		jobStopped.Store(true)
+	})
+	disconnectErr := make(chan error, 1)
+	suite.storage.disconnect = func(ctx context.Context) error {
+		disconnectErr <- ctx.Err()
+		return nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, listener, config, handler, newReadiness(suite.storage), jobs, suite.storage)
+	}()
+	go func() {
+		if resp, err := http.Get("http://" + listener.Addr().String()); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	// ACT
+	cancel()
+
+	// ASSERT
+	suite.NoError(<-served)
+	suite.True(jobStopped.Load(), "jobs get their own time to stop")
+	suite.NoError(<-disconnectErr, "storage gets its own time to disconnect")
+}
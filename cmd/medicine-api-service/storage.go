@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+	"github.com/undy45/medicine-webapi/internal/migrations"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// storage holds the services the handlers work with
+type storage struct {
+	ambulances db_service.DbService[medicine.Ambulance]
+	statuses   db_service.DbService[medicine.Status]
+	apiKeys    db_service.DbService[auth.ApiKey]
+	// disconnect releases the resources shared by the services, if there are any
+	disconnect func(ctx context.Context) error
+	// ping checks the database server is reachable, the embedded databases have none
+	ping func(ctx context.Context) error
+}
+
+func (s storage) Disconnect(ctx context.Context) error {
+	err := errors.Join(s.ambulances.Disconnect(ctx), s.statuses.Disconnect(ctx), s.apiKeys.Disconnect(ctx))
+	if s.disconnect != nil {
+		err = errors.Join(err, s.disconnect(ctx))
+	}
+	return err
+}
+
+// collections of one storage backend
+type backend struct {
+	ambulances db_service.DbService[medicine.Ambulance]
+	inventory  db_service.DbService[medicine.StoredInventoryEntry]
+	orders     db_service.DbService[medicine.StoredOrderEntry]
+	statuses   db_service.DbService[medicine.Status]
+	apiKeys    db_service.DbService[auth.ApiKey]
+	disconnect func(ctx context.Context) error
+	ping       func(ctx context.Context) error
+}
+
+// mongoBackend returns collections sharing single client, it waits until the database is available
+func mongoBackend(ctx context.Context, config storageConfig) (backend, error) {
+	retry := db_service.ConnectRetry{}.WithDefaults()
+	retry.Attempts = config.MongoConnectAttempts
+	connection, err := db_service.ConnectMongo(ctx, config.Mongo, retry)
+	if err != nil {
+		return backend{}, err
+	}
+	return mongoCollections(connection, config), nil
+}
+
+// mongoCollections returns the collections of the connected database
+func mongoCollections(connection *db_service.MongoConnection, config storageConfig) backend {
+	return backend{
+		ambulances: db_service.NewMongoCollectionService[medicine.Ambulance](connection, config.Mongo.Collection),
+		inventory:  db_service.NewMongoCollectionService[medicine.StoredInventoryEntry](connection, config.InventoryCollection),
+		orders:     db_service.NewMongoCollectionService[medicine.StoredOrderEntry](connection, config.OrderCollection),
+		statuses:   db_service.NewMongoCollectionService[medicine.Status](connection, "status"),
+		apiKeys:    db_service.NewMongoCollectionService[auth.ApiKey](connection, "apikey"),
+		disconnect: connection.Disconnect,
+		ping:       connection.Ping,
+	}
+}
+
+func sqliteBackend(config db_service.SqliteServiceConfig) backend {
+	table := func(name string, indexedFields ...string) db_service.SqliteServiceConfig {
+		config.Table, config.IndexedFields = name, indexedFields
+		return config
+	}
+	return backend{
+		// the ambulance list is sorted by name and room number, the entries by their position
+		ambulances: db_service.NewSqliteService[medicine.Ambulance](table("ambulance", "name", "roomnumber")),
+		inventory:  db_service.NewSqliteService[medicine.StoredInventoryEntry](table("inventory", "ambulanceid", "position")),
+		orders:     db_service.NewSqliteService[medicine.StoredOrderEntry](table("orders", "ambulanceid", "position")),
+		statuses:   db_service.NewSqliteService[medicine.Status](table("status")),
+		apiKeys:    db_service.NewSqliteService[auth.ApiKey](table("apikey")),
+	}
+}
+
+func memoryBackend() backend {
+	return backend{
+		ambulances: db_service.NewMemoryService[medicine.Ambulance](),
+		inventory:  db_service.NewMemoryService[medicine.StoredInventoryEntry](),
+		orders:     db_service.NewMemoryService[medicine.StoredOrderEntry](),
+		statuses:   db_service.NewMemoryService[medicine.Status](),
+		apiKeys:    db_service.NewMemoryService[auth.ApiKey](),
+	}
+}
+
+// instrument returns the service with traced operations reported to the metrics
+func instrument[DocType interface{}](svc db_service.DbService[DocType], collection string, metrics *metrics) db_service.DbService[DocType] {
+	return db_service.NewObservedService(db_service.NewTracedService(svc, collection), collection, metrics.observeOperation)
+}
+
+// newStorage returns storage of the configured backend and layout. The storages without migrations
+// are seeded when they are empty. The operations of all the collections are reported to the metrics and traced.
+func newStorage(ctx context.Context, config storageConfig, metrics *metrics) (storage, error) {
+	layout := config.Layout
+	switch strings.ToLower(layout) {
+	case "", "embedded", "split":
+	default:
+		return storage{}, fmt.Errorf("unknown storage layout %q, expected embedded or split", layout)
+	}
+
+	var selected backend
+	var err error
+	kind := config.Kind
+	switch strings.ToLower(kind) {
+	case "", "mongo", "mongodb":
+		config.Mongo.PoolMonitor = metrics.poolMonitor()
+		config.Mongo.CommandMonitor = otelmongo.NewMonitor()
+		selected, err = mongoBackend(ctx, config)
+		if err != nil {
+			return storage{}, err
+		}
+	case "sqlite":
+		selected = sqliteBackend(config.Sqlite)
+	case "memory":
+		slog.Warn("Using in-memory storage, the data are lost when the service stops")
+		selected = memoryBackend()
+	default:
+		return storage{}, fmt.Errorf("unknown storage %q, expected mongo, sqlite or memory", kind)
+	}
+	selected.ambulances = instrument(selected.ambulances, "ambulance", metrics)
+	selected.inventory = instrument(selected.inventory, "inventory", metrics)
+	selected.orders = instrument(selected.orders, "orders", metrics)
+	selected.statuses = instrument(selected.statuses, "status", metrics)
+	selected.apiKeys = instrument(selected.apiKeys, "apikey", metrics)
+
+	result := storage{
+		ambulances: selected.ambulances,
+		statuses:   selected.statuses,
+		apiKeys:    selected.apiKeys,
+		disconnect: selected.disconnect,
+		ping:       selected.ping,
+	}
+	if strings.EqualFold(layout, "split") {
+		result.ambulances = medicine.NewSplitAmbulanceService(selected.ambulances, selected.inventory, selected.orders)
+	}
+
+	if strings.EqualFold(kind, "memory") || strings.EqualFold(kind, "sqlite") {
+		if err := seed(ctx, result); err != nil {
+			return storage{}, err
+		}
+	}
+	return result, nil
+}
+
+// seed creates the documents the migrations create in a new database, unless there are any statuses already
+func seed(ctx context.Context, storage storage) error {
+	count, err := storage.statuses.CountDocuments(ctx, db_service.Filter{})
+	if err != nil || count > 0 {
+		return err
+	}
+	for _, status := range append(slices.Clone(migrations.DefaultStatuses), migrations.ApprovalStatus) {
+		if err := storage.statuses.CreateDocument(ctx, status.Id, &status); err != nil {
+			return err
+		}
+	}
+	ambulance := migrations.SampleAmbulance
+	return storage.ambulances.CreateDocument(ctx, ambulance.Id, &ambulance)
+}
+
+// layoutMigration moves inventory and orders of the existing ambulances between the ambulance documents
+// and their own collections
+type layoutMigration struct {
+	backend backend
+}
+
+func (m layoutMigration) Split(ctx context.Context) error {
+	split := medicine.NewSplitAmbulanceService(m.backend.ambulances, m.backend.inventory, m.backend.orders)
+	migrated, err := medicine.SplitAmbulanceDocuments(ctx, m.backend.ambulances, split)
+	slog.InfoContext(ctx, "Split ambulance documents", "migrated", migrated)
+	return err
+}
+
+func (m layoutMigration) Embed(ctx context.Context) error {
+	migrated, err := medicine.EmbedAmbulanceDocuments(ctx, m.backend.ambulances, m.backend.inventory, m.backend.orders)
+	slog.InfoContext(ctx, "Embedded ambulance documents", "migrated", migrated)
+	return err
+}
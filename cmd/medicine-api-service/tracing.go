@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/undy45/medicine-webapi/cmd/medicine-api-service"
+
+// setupTracing sets the global tracer provider with the exporter of the kind: none (the default) keeps
+// the no-op provider, stdout prints the spans and otlp sends them to the collector configured by the standard
+// OTEL_EXPORTER_OTLP_* variables. The trace context of the incoming requests
+// is propagated in any case. The returned function flushes the spans which were not exported yet.
+func setupTracing(ctx context.Context, kind string) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(kind) {
+	case "", "none":
+		return func(ctx context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected none, stdout or otlp", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %v tracing exporter: %w", kind, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name
+	serviceResource, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("medicine-webapi")),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot describe the service for tracing: %w", err)
+	}
+	// the sampler may be configured by OTEL_TRACES_SAMPLER
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// tracingMiddleware starts span of every request named by its route, see routeName. The span continues
+// the trace of the caller and the handlers see it in the context of the request.
+func tracingMiddleware(routeNames map[string]string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request := ctx.Request
+		parent := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		spanCtx, span := otel.Tracer(tracerName).Start(parent, routeName(ctx, routeNames),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(request.Method),
+				semconv.HTTPRoute(ctx.FullPath()),
+				semconv.URLPath(request.URL.Path),
+			),
+		)
+		defer span.End()
+		ctx.Request = request.WithContext(spanCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, ctx.Errors.String())
+		}
+	}
+}
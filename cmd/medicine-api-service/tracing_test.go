@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type TracingSuite struct {
+	suite.Suite
+	recorder *tracetest.SpanRecorder
+	storage  storage
+	router   *gin.Engine
+}
+
+func TestTracingSuite(t *testing.T) {
+	suite.Run(t, new(TracingSuite))
+}
+
+func (suite *TracingSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	suite.T().Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	_, err := setupTracing(context.Background(), "none")
+	suite.Require().NoError(err)
+
+	suite.T().Setenv("MEDICINE_API_STORAGE", "memory")
+	suite.T().Setenv("MEDICINE_API_STORAGE_LAYOUT", "split")
+	metrics := newMetrics()
+	config := loadTestConfig(suite.T())
+	suite.storage, err = newStorage(context.Background(), config.Storage, metrics)
+	suite.Require().NoError(err)
+	notifier := medicine.NewDigestOrderNotifier(medicine.NewLogNotificationSink(), time.Hour)
+	suite.router = newRouter(suite.storage, notifier, metrics, newReadiness(suite.storage), config.Cors, nil)
+	// after the storage is seeded
+	suite.recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(suite.recorder)))
+}
+
+func (suite *TracingSuite) TearDownTest() {
+	suite.NoError(suite.storage.Disconnect(context.Background()))
+}
+
+func (suite *TracingSuite) Test_RequestSpanContinuesCallerTrace() {
+	// ARRANGE
+	request := httptest.NewRequest(http.MethodGet, "/api/medicine-inventory/bobulova/entries", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+
+	// ACT
+	suite.router.ServeHTTP(recorder, request)
+
+	// ASSERT
+	suite.Require().Equal(http.StatusOK, recorder.Code)
+	var server trace.SpanContext
+	var children []string
+	spans := suite.recorder.Ended()
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindServer {
+			server = span.SpanContext()
+			suite.Equal("GetMedicineInventoryEntries", span.Name())
+			suite.Equal("00f067aa0ba902b7", span.Parent().SpanID().String())
+			suite.Contains(span.Attributes(), attribute.String("http.route", "/api/medicine-inventory/:ambulanceId/entries"))
+			suite.Contains(span.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+		}
+	}
+	suite.Require().True(server.IsValid(), "server span is recorded")
+	for _, span := range spans {
+		suite.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String(), span.Name())
+		if span.Parent().SpanID() == server.SpanID() {
+			children = append(children, span.Name())
+		}
+	}
+	suite.Contains(children, "ambulance.FindDocument", "split layout loads the ambulance")
+	suite.Contains(children, "inventory.FindDocuments", "and its inventory")
+}
+
+func (suite *TracingSuite) Test_SetupTracing_Exporters() {
+	for _, exporter := range []string{"", "none", "stdout", "otlp"} {
+		// ACT
+		shutdown, err := setupTracing(context.Background(), exporter)
+
+		// ASSERT
+		suite.Require().NoError(err, exporter)
+		suite.NoError(shutdown(context.Background()), exporter)
+	}
+	_, err := setupTracing(context.Background(), "zipkin")
+	suite.ErrorContains(err, `unknown tracing exporter "zipkin"`)
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+// ApiKeyHeader is the request header with the API key
+const ApiKeyHeader = "X-API-Key"
+
+const (
+	// apiKeyPrefix tells the API keys apart from the other secrets, e.g. for the secret scanners
+	apiKeyPrefix = "mwk"
+	// lastUsedResolution limits the writes of the last use of the key
+	lastUsedResolution = time.Minute
+	// DefaultApiKeyLifetime is the lifetime of the keys issued without the expiry
+	DefaultApiKeyLifetime = 90 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidApiKey is returned for the keys which do not exist, have been revoked or expired
+	ErrInvalidApiKey = errors.New("invalid API key")
+	// ErrInvalidApiKeyRequest is returned for the keys which cannot be issued
+	ErrInvalidApiKeyRequest = errors.New("invalid API key request")
+)
+
+// ApiKey is the stored API key of an integration, only the hash of its secret is stored
+type ApiKey struct {
+	Id   string
+	Name string
+	// SecretHash is hex encoded SHA-256 of the secret
+	SecretHash string
+	// Ambulances the key may access, * is every ambulance
+	Ambulances  []string
+	Permissions []Permission
+	// CreatedBy is the subject of the principal who issued the key
+	CreatedBy  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time `bson:",omitempty"`
+}
+
+// ApiKeys issues and verifies the API keys stored in the service
+type ApiKeys struct {
+	store db_service.DbService[ApiKey]
+	now   func() time.Time
+}
+
+// NewApiKeys returns the API keys of the store
+func NewApiKeys(store db_service.DbService[ApiKey]) *ApiKeys {
+	return &ApiKeys{store: store, now: time.Now}
+}
+
+// Issue stores new key with the name, the scope and the expiry of the given key and returns it together
+// with its secret. The secret cannot be retrieved later.
+func (k *ApiKeys) Issue(ctx context.Context, key ApiKey) (ApiKey, string, error) {
+	now := k.now().UTC()
+	if key.ExpiresAt.IsZero() {
+		key.ExpiresAt = now.Add(DefaultApiKeyLifetime)
+	}
+	if err := validateApiKey(key, now); err != nil {
+		return ApiKey{}, "", err
+	}
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return ApiKey{}, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return ApiKey{}, "", err
+	}
+	key.Id, key.SecretHash, key.CreatedAt, key.LastUsedAt = id, hashSecret(secret), now, nil
+	if err := k.store.CreateDocument(ctx, key.Id, &key); err != nil {
+		return ApiKey{}, "", err
+	}
+	return key, fmt.Sprintf("%v_%v_%v", apiKeyPrefix, id, secret), nil
+}
+
+// validateApiKey reports the key which cannot be issued
+func validateApiKey(key ApiKey, now time.Time) error {
+	switch {
+	case strings.TrimSpace(key.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidApiKeyRequest)
+	case len(key.Ambulances) == 0:
+		return fmt.Errorf("%w: ambulances are required, * is every ambulance", ErrInvalidApiKeyRequest)
+	case len(key.Permissions) == 0:
+		return fmt.Errorf("%w: permissions are required", ErrInvalidApiKeyRequest)
+	case !key.ExpiresAt.After(now):
+		return fmt.Errorf("%w: expiry has to be in the future", ErrInvalidApiKeyRequest)
+	}
+	for _, permission := range key.Permissions {
+		if !slices.Contains(apiKeyPermissions, permission) {
+			return fmt.Errorf("%w: permission %q cannot be granted to API key", ErrInvalidApiKeyRequest, permission)
+		}
+	}
+	return nil
+}
+
+// List returns the stored keys
+func (k *ApiKeys) List(ctx context.Context) ([]*ApiKey, error) {
+	return k.store.FindAllDocuments(ctx)
+}
+
+// Revoke deletes the key, it returns db_service.ErrNotFound if there is no such key
+func (k *ApiKeys) Revoke(ctx context.Context, id string) error {
+	return k.store.DeleteDocument(ctx, id)
+}
+
+// Verify returns the principal of the valid key and records its use
+func (k *ApiKeys) Verify(ctx context.Context, secret string) (Principal, error) {
+	prefix, rest, _ := strings.Cut(secret, "_")
+	id, keySecret, found := strings.Cut(rest, "_")
+	if prefix != apiKeyPrefix || !found {
+		return Principal{}, fmt.Errorf("%w: malformed key", ErrInvalidApiKey)
+	}
+	key, err := k.store.FindDocument(ctx, id)
+	if errors.Is(err, db_service.ErrNotFound) {
+		return Principal{}, fmt.Errorf("%w: unknown or revoked key", ErrInvalidApiKey)
+	} else if err != nil {
+		return Principal{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(keySecret)), []byte(key.SecretHash)) != 1 {
+		return Principal{}, fmt.Errorf("%w: unknown or revoked key", ErrInvalidApiKey)
+	}
+	now := k.now().UTC()
+	if !now.Before(key.ExpiresAt) {
+		return Principal{}, fmt.Errorf("%w: key expired at %v", ErrInvalidApiKey, key.ExpiresAt.Format(time.RFC3339))
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		// only the time of use is written, so a concurrent change of the key is not overwritten
+		err := k.store.UpdateFields(ctx, key.Id, db_service.FieldChange{Set: map[string]any{"lastusedat": now}})
+		if err != nil && !errors.Is(err, db_service.ErrNotFound) {
+			return Principal{}, fmt.Errorf("failed to record use of API key %v: %w", key.Id, err)
+		}
+	}
+	return Principal{
+		Subject:     "apikey:" + key.Id,
+		Name:        key.Name,
+		Ambulances:  key.Ambulances,
+		Permissions: key.Permissions,
+	}, nil
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return encode(data), nil
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type ApiKeysSuite struct {
+	suite.Suite
+	store db_service.DbService[ApiKey]
+	keys  *ApiKeys
+	now   time.Time
+}
+
+func TestApiKeysSuite(t *testing.T) {
+	suite.Run(t, new(ApiKeysSuite))
+}
+
+func (suite *ApiKeysSuite) SetupTest() {
+	suite.store = db_service.NewMemoryService[ApiKey]()
+	suite.keys = NewApiKeys(suite.store)
+	suite.now = time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	suite.keys.now = func() time.Time { return suite.now }
+}
+
+// issue returns secret of new key of the supplier integration
+func (suite *ApiKeysSuite) issue() (ApiKey, string) {
+	key, secret, err := suite.keys.Issue(context.Background(), ApiKey{
+		Name:        "supplier",
+		Ambulances:  []string{"bobulova"},
+		Permissions: []Permission{ReadInventory, WriteOrders},
+	})
+	suite.Require().NoError(err)
+	return key, secret
+}
+
+func (suite *ApiKeysSuite) Test_Issue_StoresHashOfSecret() {
+	// ACT
+	key, secret := suite.issue()
+
+	// ASSERT
+	suite.True(strings.HasPrefix(secret, "mwk_"+key.Id+"_"), secret)
+	stored, err := suite.store.FindDocument(context.Background(), key.Id)
+	suite.Require().NoError(err)
+	suite.NotContains(secret, stored.SecretHash)
+	suite.Equal(hashSecret(strings.TrimPrefix(secret, "mwk_"+key.Id+"_")), stored.SecretHash)
+	suite.Equal(suite.now.Add(DefaultApiKeyLifetime), stored.ExpiresAt)
+	suite.Nil(stored.LastUsedAt)
+}
+
+func (suite *ApiKeysSuite) Test_Verify_ReturnsScopeAndRecordsUse() {
+	// ARRANGE
+	key, secret := suite.issue()
+
+	// ACT
+	principal, err := suite.keys.Verify(context.Background(), secret)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal(Principal{
+		Subject:     "apikey:" + key.Id,
+		Name:        "supplier",
+		Ambulances:  []string{"bobulova"},
+		Permissions: []Permission{ReadInventory, WriteOrders},
+	}, principal)
+	suite.True(principal.Can(WriteOrders))
+	suite.False(principal.Can(WriteInventory))
+	stored, err := suite.store.FindDocument(context.Background(), key.Id)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored.LastUsedAt)
+	suite.Equal(suite.now, *stored.LastUsedAt)
+}
+
+func (suite *ApiKeysSuite) Test_Verify_RecordsUseOncePerMinute() {
+	// ARRANGE
+	key, secret := suite.issue()
+	used := suite.now
+	_, err := suite.keys.Verify(context.Background(), secret)
+	suite.Require().NoError(err)
+
+	// ACT
+	suite.now = used.Add(30 * time.Second)
+	_, withinMinute := suite.keys.Verify(context.Background(), secret)
+	stored, _ := suite.store.FindDocument(context.Background(), key.Id)
+	suite.now = used.Add(2 * time.Minute)
+	_, later := suite.keys.Verify(context.Background(), secret)
+	storedLater, _ := suite.store.FindDocument(context.Background(), key.Id)
+
+	// ASSERT
+	suite.NoError(withinMinute)
+	suite.NoError(later)
+	suite.Equal(used, *stored.LastUsedAt)
+	suite.Equal(suite.now, *storedLater.LastUsedAt)
+}
+
+func (suite *ApiKeysSuite) Test_Verify_RejectsInvalidKeys() {
+	// ARRANGE
+	key, secret := suite.issue()
+	revoked, revokedSecret := suite.issue()
+	suite.Require().NoError(suite.keys.Revoke(context.Background(), revoked.Id))
+
+	for name, candidate := range map[string]string{
+		"malformed":    "secret",
+		"other prefix": strings.Replace(secret, "mwk_", "abc_", 1),
+		"wrong secret": "mwk_" + key.Id + "_wrong",
+		"unknown id":   strings.Replace(secret, key.Id, "0000000000000000", 1),
+		"revoked":      revokedSecret,
+	} {
+		// ACT
+		_, err := suite.keys.Verify(context.Background(), candidate)
+
+		// ASSERT
+		suite.ErrorIs(err, ErrInvalidApiKey, name)
+	}
+}
+
+func (suite *ApiKeysSuite) Test_Verify_RejectsExpiredKey() {
+	// ARRANGE
+	_, secret := suite.issue()
+	suite.now = suite.now.Add(DefaultApiKeyLifetime)
+
+	// ACT
+	_, err := suite.keys.Verify(context.Background(), secret)
+
+	// ASSERT
+	suite.ErrorIs(err, ErrInvalidApiKey)
+	suite.ErrorContains(err, "key expired at 2026-05-30T08:00:00Z")
+}
+
+func (suite *ApiKeysSuite) Test_Issue_RejectsInvalidRequests() {
+	// ARRANGE
+	valid := ApiKey{Name: "nightly", Ambulances: []string{AllAmbulances}, Permissions: []Permission{Export}}
+	withoutName, withoutAmbulances, expired, issuing := valid, valid, valid, valid
+	withoutName.Name = " "
+	withoutAmbulances.Ambulances = nil
+	expired.ExpiresAt = suite.now.Add(-time.Hour)
+	issuing.Permissions = []Permission{Export, ManageApiKeys}
+
+	for name, request := range map[string]ApiKey{
+		"without name":       withoutName,
+		"without ambulances": withoutAmbulances,
+		"expired":            expired,
+		"issuing keys":       issuing,
+	} {
+		// ACT
+		_, _, err := suite.keys.Issue(context.Background(), request)
+
+		// ASSERT
+		suite.ErrorIs(err, ErrInvalidApiKeyRequest, name)
+	}
+	count, err := suite.store.CountDocuments(context.Background(), db_service.Filter{})
+	suite.NoError(err)
+	suite.Zero(count)
+}
+
+func (suite *ApiKeysSuite) Test_Verify_RecordsOnlyTimeOfUse() {
+	// ARRANGE
+	_, secret := suite.issue()
+	store := &recordingApiKeyStore{DbService: suite.store}
+	suite.keys.store = store
+
+	// ACT
+	_, err := suite.keys.Verify(context.Background(), secret)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]db_service.FieldChange{{Set: map[string]any{"lastusedat": suite.now}}}, store.changes)
+}
+
+func (suite *ApiKeysSuite) Test_Verify_FailsWhenUseCannotBeRecorded() {
+	// ARRANGE
+	_, secret := suite.issue()
+	suite.keys.store = &recordingApiKeyStore{DbService: suite.store, err: errors.New("connection reset")}
+
+	// ACT
+	_, err := suite.keys.Verify(context.Background(), secret)
+
+	// ASSERT
+	suite.Error(err)
+	suite.NotErrorIs(err, ErrInvalidApiKey, "failure of the store does not tell the key is invalid")
+}
+
+// recordingApiKeyStore records the field changes and fails them with err if it is set
+type recordingApiKeyStore struct {
+	db_service.DbService[ApiKey]
+	changes []db_service.FieldChange
+	err     error
+}
+
+func (s *recordingApiKeyStore) UpdateFields(ctx context.Context, id any, change db_service.FieldChange) error {
+	s.changes = append(s.changes, change)
+	if s.err != nil {
+		return s.err
+	}
+	return s.DbService.UpdateFields(ctx, id, change)
+}
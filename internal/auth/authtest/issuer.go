@@ -0,0 +1,86 @@
+// Package authtest issues tokens for the tests of the authenticated services, so the tests do not
+// need an identity provider.
+package authtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer signs the tokens with its RSA key
+type Issuer struct {
+	// Url is the iss claim of the tokens
+	Url   string
+	KeyId string
+	key   *rsa.PrivateKey
+}
+
+// NewIssuer returns issuer with new key
+func NewIssuer(t testing.TB, url string) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Issuer{Url: url, KeyId: "test-key", key: key}
+}
+
+// JWKS returns the JSON Web Key Set with the public key of the issuer
+func (i *Issuer) JWKS() []byte {
+	encode := func(number *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(number.Bytes())
+	}
+	document, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": i.KeyId,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   encode(i.key.N),
+		"e":   encode(big.NewInt(int64(i.key.E))),
+	}}})
+	return document
+}
+
+// WriteJWKS writes the JSON Web Key Set into the temporary directory of the test and returns its path
+func (i *Issuer) WriteJWKS(t testing.TB) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, i.JWKS(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Token returns token of the subject with the roles valid for an hour, the claims are added or override
+// the default ones, the claims with nil value are removed
+func (i *Issuer) Token(t testing.TB, subject string, roles []string, claims jwt.MapClaims) string {
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{
+		"iss":   i.Url,
+		"sub":   subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": roles,
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(tokenClaims, name)
+		} else {
+			tokenClaims[name] = value
+		}
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims)
+	token.Header["kid"] = i.KeyId
+	signed, err := token.SignedString(i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minRefreshInterval limits the fetches of the keys caused by the tokens with unknown key id
+	minRefreshInterval = time.Minute
+	// maxKeysAge is the time the fetched keys are used before they are fetched again
+	maxKeysAge = 15 * time.Minute
+	// maxDocumentSize limits the discovery and JWKS documents
+	maxDocumentSize = 1 << 20
+)
+
+// ErrUnknownKey is returned for key id which is not in the key set
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet holds the public keys of the issuer by their key id. The keys of the remote key set are fetched
+// when they are needed first, then periodically and when a token is signed by unknown key, which happens
+// after the issuer rotates its keys.
+type KeySet struct {
+	fetch   func(ctx context.Context) ([]byte, error)
+	lock    sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewStaticKeySet returns key set of the JSON Web Key Set document, it never changes
+func NewStaticKeySet(document []byte) (*KeySet, error) {
+	keys, err := ParseJWKS(document)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+// NewFileKeySet returns key set of the JSON Web Key Set file, e.g. for the setups without the issuer
+func NewFileKeySet(path string) (*KeySet, error) {
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read JWKS file: %w", err)
+	}
+	keys, err := NewStaticKeySet(document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS file %v: %w", path, err)
+	}
+	return keys, nil
+}
+
+// NewRemoteKeySet returns key set fetched from the url
+func NewRemoteKeySet(client *http.Client, url string) *KeySet {
+	return &KeySet{fetch: func(ctx context.Context) ([]byte, error) {
+		return getDocument(ctx, client, url)
+	}}
+}
+
+// NewDiscoveredKeySet returns key set fetched from the jwks_uri of the OpenID Connect discovery document
+// of the issuer
+func NewDiscoveredKeySet(client *http.Client, issuer string) *KeySet {
+	discoveryUrl := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	return &KeySet{fetch: func(ctx context.Context) ([]byte, error) {
+		document, err := getDocument(ctx, client, discoveryUrl)
+		if err != nil {
+			return nil, err
+		}
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JwksUri string `json:"jwks_uri"`
+		}
+		if err := json.Unmarshal(document, &discovery); err != nil {
+			return nil, fmt.Errorf("invalid discovery document %v: %w", discoveryUrl, err)
+		}
+		if discovery.Issuer != issuer {
+			return nil, fmt.Errorf("discovery document %v is of other issuer %q", discoveryUrl, discovery.Issuer)
+		}
+		if discovery.JwksUri == "" {
+			return nil, fmt.Errorf("discovery document %v has no jwks_uri", discoveryUrl)
+		}
+		return getDocument(ctx, client, discovery.JwksUri)
+	}}
+}
+
+// Key returns the key of the key id. The token without key id may be signed by the only key of the key set.
+func (s *KeySet) Key(ctx context.Context, keyId string) (crypto.PublicKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key, found := s.find(keyId)
+	if s.fetch != nil && (s.keys == nil || time.Since(s.fetched) > maxKeysAge ||
+		!found && time.Since(s.fetched) > minRefreshInterval) {
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+		key, found = s.find(keyId)
+	}
+	if !found {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyId)
+	}
+	return key, nil
+}
+
+func (s *KeySet) find(keyId string) (crypto.PublicKey, bool) {
+	if keyId == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, found := s.keys[keyId]
+	return key, found
+}
+
+// refresh fetches the keys, the previous keys are kept if the fetch fails
+func (s *KeySet) refresh(ctx context.Context) error {
+	s.fetched = time.Now()
+	document, err := s.fetch(ctx)
+	if err == nil {
+		var keys map[string]crypto.PublicKey
+		if keys, err = ParseJWKS(document); err == nil {
+			s.keys = keys
+			return nil
+		}
+	}
+	if s.keys == nil {
+		return fmt.Errorf("cannot fetch signing keys: %w", err)
+	}
+	slog.WarnContext(ctx, "Failed to refresh signing keys, the previous keys are used", "error", err)
+	return nil
+}
+
+// getDocument returns the body of the successful response to GET request of the url
+func getDocument(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %v responded %v", url, response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxDocumentSize))
+}
+
+// jsonWebKey is the public key of JSON Web Key Set, see RFC 7517 and RFC 7518
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyId   string `json:"kid"`
+	Use     string `json:"use"`
+	Curve   string `json:"crv"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// ParseJWKS returns the signing keys of the JSON Web Key Set by their key ids. The RSA, EC and Ed25519 keys
+// are supported, the keys of other types or for encryption are skipped.
+func ParseJWKS(document []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(document, &set); err != nil {
+		return nil, fmt.Errorf("invalid JSON Web Key Set: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, webKey := range set.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+		key, err := webKey.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", webKey.KeyId, err)
+		}
+		if key != nil {
+			keys[webKey.KeyId] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JSON Web Key Set has no signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey returns the key or nil if its type is not supported
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeNumber(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeNumber(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeNumber(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeNumber(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeNumber(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(bytes) == 0 {
+		return nil, errors.New("invalid base64url number")
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/auth/authtest"
+)
+
+type KeySetSuite struct {
+	suite.Suite
+}
+
+func TestKeySetSuite(t *testing.T) {
+	suite.Run(t, new(KeySetSuite))
+}
+
+func (suite *KeySetSuite) Test_ParseJWKS_KeyTypes() {
+	// ARRANGE
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Require().NoError(err)
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	suite.Require().NoError(err)
+	encode := base64.RawURLEncoding.EncodeToString
+	document := `{"keys": [
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "` + encode(ecKey.X.Bytes()) + `", "y": "` + encode(ecKey.Y.Bytes()) + `"},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "` + encode(edKey) + `"},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"}
+	]}`
+
+	// ACT
+	keys, err := ParseJWKS([]byte(document))
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Len(keys, 2, "encryption and symmetric keys are skipped")
+	suite.True(ecKey.PublicKey.Equal(keys["ec"]))
+	suite.True(edKey.Equal(keys["ed"]))
+}
+
+func (suite *KeySetSuite) Test_ParseJWKS_Invalid() {
+	for name, document := range map[string]string{
+		"not json":         `keys`,
+		"no signing keys":  `{"keys": []}`,
+		"invalid exponent": `{"keys": [{"kty": "RSA", "kid": "rsa", "n": "AQAB", "e": "AQ"}]}`,
+		"not on curve":     `{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+	} {
+		// ACT
+		_, err := ParseJWKS([]byte(document))
+
+		// ASSERT
+		suite.Error(err, name)
+	}
+}
+
+func (suite *KeySetSuite) Test_FileKeySet() {
+	// ARRANGE
+	issuer := authtest.NewIssuer(suite.T(), "https://issuer.example.com")
+	keys, err := NewFileKeySet(issuer.WriteJWKS(suite.T()))
+	suite.Require().NoError(err)
+
+	// ACT
+	key, keyErr := keys.Key(context.Background(), issuer.KeyId)
+	onlyKey, onlyKeyErr := keys.Key(context.Background(), "")
+	_, unknownErr := keys.Key(context.Background(), "other")
+
+	// ASSERT
+	suite.Require().NoError(keyErr)
+	suite.IsType(&rsa.PublicKey{}, key)
+	suite.Require().NoError(onlyKeyErr, "token without key id uses the only key")
+	suite.Equal(key, onlyKey)
+	suite.ErrorIs(unknownErr, ErrUnknownKey)
+}
+
+func (suite *KeySetSuite) Test_DiscoveredKeySet_FetchesLazilyAndKeepsKeys() {
+	// ARRANGE
+	issuer := authtest.NewIssuer(suite.T(), "")
+	var jwksRequests atomic.Int32
+	available := atomic.Bool{}
+	available.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/realms/ambulance/.well-known/openid-configuration":
+			w.Write([]byte(`{"issuer": "` + issuer.Url + `", "jwks_uri": "http://` + r.Host + `/certs"}`))
+		case "/certs":
+			jwksRequests.Add(1)
+			w.Write(issuer.JWKS())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	issuer.Url = server.URL + "/realms/ambulance"
+	keys := NewDiscoveredKeySet(server.Client(), issuer.Url)
+	suite.Equal(int32(0), jwksRequests.Load(), "keys are fetched when needed")
+
+	// ACT
+	_, firstErr := keys.Key(context.Background(), issuer.KeyId)
+	_, cachedErr := keys.Key(context.Background(), issuer.KeyId)
+	available.Store(false)
+	keys.fetched = time.Now().Add(-maxKeysAge - time.Second)
+	_, unavailableErr := keys.Key(context.Background(), issuer.KeyId)
+
+	// ASSERT
+	suite.NoError(firstErr)
+	suite.NoError(cachedErr)
+	suite.Equal(int32(1), jwksRequests.Load())
+	suite.NoError(unavailableErr, "previous keys are used while the issuer is not available")
+}
+
+func (suite *KeySetSuite) Test_RemoteKeySet_RefreshesOnUnknownKey() {
+	// ARRANGE
+	previous := authtest.NewIssuer(suite.T(), "https://issuer.example.com")
+	rotated := authtest.NewIssuer(suite.T(), previous.Url)
+	rotated.KeyId = "rotated"
+	var current atomic.Pointer[authtest.Issuer]
+	current.Store(previous)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(current.Load().JWKS())
+	}))
+	defer server.Close()
+	keys := NewRemoteKeySet(server.Client(), server.URL)
+	_, err := keys.Key(context.Background(), previous.KeyId)
+	suite.Require().NoError(err)
+	current.Store(rotated)
+
+	// ACT
+	_, tooSoonErr := keys.Key(context.Background(), rotated.KeyId)
+	keys.fetched = time.Now().Add(-minRefreshInterval - time.Second)
+	key, rotatedErr := keys.Key(context.Background(), rotated.KeyId)
+
+	// ASSERT
+	suite.ErrorIs(tooSoonErr, ErrUnknownKey, "unknown keys do not cause a fetch per request")
+	suite.Require().NoError(rotatedErr)
+	suite.NotNil(key)
+}
+
+func (suite *KeySetSuite) Test_DiscoveredKeySet_OtherIssuer() {
+	// ARRANGE
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer": "https://evil.example.com", "jwks_uri": "https://evil.example.com/certs"}`))
+	}))
+	defer server.Close()
+	keys := NewDiscoveredKeySet(server.Client(), server.URL)
+
+	// ACT
+	_, err := keys.Key(context.Background(), "key")
+
+	// ASSERT
+	suite.ErrorContains(err, `is of other issuer "https://evil.example.com"`)
+}
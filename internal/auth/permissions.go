@@ -0,0 +1,69 @@
+package auth
+
+import "slices"
+
+// Permission allows an operation of the API
+type Permission string
+
+const (
+	ReadAmbulances   Permission = "ambulances:read"
+	ManageAmbulances Permission = "ambulances:manage"
+	ReadStatuses     Permission = "statuses:read"
+	ReadInventory    Permission = "inventory:read"
+	WriteInventory   Permission = "inventory:write"
+	ReadOrders       Permission = "orders:read"
+	WriteOrders      Permission = "orders:write"
+	ReadTemplates    Permission = "templates:read"
+	ManageTemplates  Permission = "templates:manage"
+	Export           Permission = "exports:read"
+	ManageApiKeys    Permission = "apikeys:manage"
+)
+
+// The roles of the staff, the roles other than admin apply only to the ambulances the principal is member of
+const (
+	RoleNurse      = "nurse"
+	RolePharmacist = "pharmacist"
+	RoleHeadNurse  = "head-nurse"
+	RoleAdmin      = "admin"
+)
+
+// AllAmbulances in the ambulances of the principal makes it member of every ambulance
+const AllAmbulances = "*"
+
+var readPermissions = []Permission{ReadAmbulances, ReadStatuses, ReadInventory, ReadOrders, ReadTemplates}
+
+// rolePermissions are the permissions granted by the roles
+var rolePermissions = map[string][]Permission{
+	RoleNurse:      append(slices.Clone(readPermissions), WriteInventory, WriteOrders),
+	RolePharmacist: append(slices.Clone(readPermissions), WriteInventory, WriteOrders, Export),
+	RoleHeadNurse:  append(slices.Clone(readPermissions), WriteInventory, WriteOrders, ManageTemplates, Export),
+	RoleAdmin: append(slices.Clone(readPermissions), WriteInventory, WriteOrders, ManageTemplates, Export,
+		ManageAmbulances, ManageApiKeys),
+}
+
+// apiKeyPermissions are the permissions which may be granted to the API keys, the keys cannot issue other keys
+var apiKeyPermissions = append(slices.Clone(readPermissions), WriteInventory, WriteOrders, ManageTemplates, Export,
+	ManageAmbulances)
+
+// Can returns whether the principal was granted the permission directly or by any of its roles
+func (p Principal) Can(permission Permission) bool {
+	if slices.Contains(p.Permissions, permission) {
+		return true
+	}
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemberOf returns whether the principal may access the ambulance, admin may access every ambulance
+func (p Principal) MemberOf(ambulanceId string) bool {
+	return p.MemberOfAll() || slices.Contains(p.Ambulances, ambulanceId)
+}
+
+// MemberOfAll returns whether the principal may access every ambulance
+func (p Principal) MemberOfAll() bool {
+	return p.HasRole(RoleAdmin) || slices.Contains(p.Ambulances, AllAmbulances)
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PermissionsSuite struct {
+	suite.Suite
+}
+
+func TestPermissionsSuite(t *testing.T) {
+	suite.Run(t, new(PermissionsSuite))
+}
+
+func (suite *PermissionsSuite) Test_Can_GrantsPermissionsOfRoles() {
+	// ARRANGE
+	nurse := Principal{Roles: []string{RoleNurse}}
+	headNurse := Principal{Roles: []string{"offline_access", RoleHeadNurse}}
+	admin := Principal{Roles: []string{RoleAdmin}}
+	unknown := Principal{Roles: []string{"medic"}}
+
+	// ASSERT
+	suite.True(nurse.Can(WriteInventory))
+	suite.False(nurse.Can(ManageTemplates))
+	suite.False(nurse.Can(Export))
+	suite.True(headNurse.Can(ManageTemplates))
+	suite.False(headNurse.Can(ManageAmbulances))
+	suite.True(admin.Can(ManageAmbulances))
+	suite.False(unknown.Can(ReadAmbulances))
+}
+
+func (suite *PermissionsSuite) Test_MemberOf_AmbulancesOfPrincipal() {
+	// ARRANGE
+	nurse := Principal{Roles: []string{RoleNurse}, Ambulances: []string{"bobulova"}}
+	pharmacist := Principal{Roles: []string{RolePharmacist}, Ambulances: []string{AllAmbulances}}
+	admin := Principal{Roles: []string{RoleAdmin}}
+
+	// ASSERT
+	suite.True(nurse.MemberOf("bobulova"))
+	suite.False(nurse.MemberOf("e2e"))
+	suite.False(nurse.MemberOfAll())
+	suite.True(pharmacist.MemberOf("e2e"))
+	suite.True(pharmacist.MemberOfAll())
+	suite.True(admin.MemberOf("e2e"))
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of the request
+type Principal struct {
+	// Subject identifies the caller at the issuer
+	Subject string
+	// Name is the display name of the caller, it may be empty
+	Name  string
+	Roles []string
+	// Ambulances are the ids of the ambulances the principal is member of
+	Ambulances []string
+	// Permissions are granted directly, e.g. to the API keys, in addition to the permissions of the roles
+	Permissions []Permission
+}
+
+// HasRole returns whether the principal was granted the role
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns context of the request authenticated as the principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the request, it is not present if the request was not authenticated
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingMethods are the asymmetric algorithms of the tokens, the shared secrets are not supported
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// VerifierConfig describes the tokens the verifier accepts
+type VerifierConfig struct {
+	Issuer string
+	// Audience the token has to be issued for, any audience is accepted if empty
+	Audience string
+	// RolesClaim is the claim with the roles, either array or space separated string. Dots separate
+	// the nested claims, e.g. realm_access.roles of Keycloak.
+	RolesClaim string
+	// NameClaim is the claim with the display name, preferred_username is used if the token has not it
+	NameClaim string
+	// AmbulancesClaim is the claim with the ids of the ambulances of the principal, like the RolesClaim
+	AmbulancesClaim string
+	// ClockSkew tolerates the difference of the clocks of the issuer and of the service
+	ClockSkew time.Duration
+}
+
+// Verifier validates the bearer JWTs issued by the configured issuer
+type Verifier struct {
+	config VerifierConfig
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// NewVerifier returns verifier of the tokens signed by the keys
+func NewVerifier(config VerifierConfig, keys *KeySet) *Verifier {
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	if config.NameClaim == "" {
+		config.NameClaim = "name"
+	}
+	if config.AmbulancesClaim == "" {
+		config.AmbulancesClaim = "ambulances"
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithLeeway(config.ClockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	return &Verifier{config: config, keys: keys, parser: jwt.NewParser(options...)}
+}
+
+// Verify returns the principal of the valid token
+func (v *Verifier) Verify(ctx context.Context, token string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+		keyId, _ := token.Header["kid"].(string)
+		return v.keys.Key(ctx, keyId)
+	})
+	if err != nil {
+		return Principal{}, err
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, errors.New("token has no subject")
+	}
+	principal := Principal{Subject: subject}
+	principal.Name, _ = claims[v.config.NameClaim].(string)
+	if principal.Name == "" {
+		principal.Name, _ = claims["preferred_username"].(string)
+	}
+	if principal.Roles, err = stringsClaim(claims, v.config.RolesClaim); err != nil {
+		return Principal{}, err
+	}
+	if principal.Ambulances, err = stringsClaim(claims, v.config.AmbulancesClaim); err != nil {
+		return Principal{}, err
+	}
+	return principal, nil
+}
+
+// stringsClaim returns the strings of the claim with the dotted path, the token without the claim has no strings
+func stringsClaim(claims jwt.MapClaims, path string) ([]string, error) {
+	var value any = map[string]any(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		if value, ok = object[name]; !ok {
+			return nil, nil
+		}
+	}
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(value), nil
+	case []any:
+		strs := make([]string, 0, len(value))
+		for _, item := range value {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %v is not array of strings", path)
+			}
+			strs = append(strs, str)
+		}
+		return strs, nil
+	default:
+		return nil, fmt.Errorf("claim %v is neither array nor string", path)
+	}
+}
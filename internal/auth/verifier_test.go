@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/auth/authtest"
+)
+
+const testIssuer = "https://login.example.com/realms/ambulance"
+
+type VerifierSuite struct {
+	suite.Suite
+	issuer   *authtest.Issuer
+	verifier *Verifier
+}
+
+func TestVerifierSuite(t *testing.T) {
+	suite.Run(t, new(VerifierSuite))
+}
+
+func (suite *VerifierSuite) SetupTest() {
+	suite.issuer = authtest.NewIssuer(suite.T(), testIssuer)
+	keys, err := NewStaticKeySet(suite.issuer.JWKS())
+	suite.Require().NoError(err)
+	suite.verifier = NewVerifier(VerifierConfig{Issuer: testIssuer, Audience: "medicine-webapi"}, keys)
+}
+
+func (suite *VerifierSuite) Test_Verify_ValidToken() {
+	// ARRANGE
+	token := suite.issuer.Token(suite.T(), "user-1", []string{"medic"}, jwt.MapClaims{
+		"aud":        []string{"account", "medicine-webapi"},
+		"name":       "Jana Nováková",
+		"ambulances": []string{"bobulova", "e2e"},
+	})
+
+	// ACT
+	principal, err := suite.verifier.Verify(context.Background(), token)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal(Principal{Subject: "user-1", Name: "Jana Nováková", Roles: []string{"medic"},
+		Ambulances: []string{"bobulova", "e2e"}}, principal)
+	suite.True(principal.HasRole("medic"))
+	suite.False(principal.HasRole("admin"))
+}
+
+func (suite *VerifierSuite) Test_Verify_RejectsInvalidTokens() {
+	other := authtest.NewIssuer(suite.T(), testIssuer)
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": testIssuer, "sub": "user-1", "aud": "medicine-webapi", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	suite.Require().NoError(err)
+	valid := jwt.MapClaims{"aud": "medicine-webapi"}
+	for name, token := range map[string]string{
+		"expired": suite.issuer.Token(suite.T(), "user-1", nil, jwt.MapClaims{
+			"aud": "medicine-webapi", "exp": time.Now().Add(-time.Minute).Unix(),
+		}),
+		"without expiration": suite.issuer.Token(suite.T(), "user-1", nil, jwt.MapClaims{"aud": "medicine-webapi", "exp": nil}),
+		"other issuer":       suite.issuer.Token(suite.T(), "user-1", nil, jwt.MapClaims{"aud": "medicine-webapi", "iss": "https://evil.example.com"}),
+		"other audience":     suite.issuer.Token(suite.T(), "user-1", nil, jwt.MapClaims{"aud": "other-api"}),
+		"without subject":    suite.issuer.Token(suite.T(), "", nil, valid),
+		"other key":          other.Token(suite.T(), "user-1", nil, valid),
+		"shared secret":      hmacToken,
+		"malformed":          "not.a.token",
+	} {
+		// ACT
+		_, err := suite.verifier.Verify(context.Background(), token)
+
+		// ASSERT
+		suite.Error(err, name)
+	}
+}
+
+func (suite *VerifierSuite) Test_Verify_NestedRolesAndPreferredUsername() {
+	// ARRANGE
+	verifier := NewVerifier(VerifierConfig{Issuer: testIssuer, RolesClaim: "realm_access.roles"}, suite.verifier.keys)
+	token := suite.issuer.Token(suite.T(), "user-2", nil, jwt.MapClaims{
+		"realm_access":       map[string]any{"roles": []string{"pharmacist", "offline_access"}},
+		"preferred_username": "peter",
+	})
+
+	// ACT
+	principal, err := verifier.Verify(context.Background(), token)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal("peter", principal.Name)
+	suite.Equal([]string{"pharmacist", "offline_access"}, principal.Roles)
+}
+
+func (suite *VerifierSuite) Test_Verify_SpaceSeparatedRolesAndClockSkew() {
+	// ARRANGE
+	verifier := NewVerifier(VerifierConfig{Issuer: testIssuer, RolesClaim: "scope", ClockSkew: time.Minute}, suite.verifier.keys)
+	token := suite.issuer.Token(suite.T(), "service", nil, jwt.MapClaims{
+		"scope": "inventory:read inventory:write",
+		"exp":   time.Now().Add(-30 * time.Second).Unix(),
+	})
+
+	// ACT
+	principal, err := verifier.Verify(context.Background(), token)
+
+	// ASSERT
+	suite.Require().NoError(err, "expired within the clock skew")
+	suite.Equal([]string{"inventory:read", "inventory:write"}, principal.Roles)
+}
+
+func (suite *VerifierSuite) Test_PrincipalFromContext() {
+	// ARRANGE
+	principal := Principal{Subject: "user-1"}
+
+	// ACT
+	found, ok := PrincipalFromContext(WithPrincipal(context.Background(), principal))
+	_, missing := PrincipalFromContext(context.Background())
+
+	// ASSERT
+	suite.True(ok)
+	suite.Equal(principal, found)
+	suite.False(missing)
+}
@@ -0,0 +1,546 @@
+// Package conformance provides tests verifying that an implementation of db_service.DbService
+// behaves the same way as the other storages, so the handlers can rely on its semantics.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Fixture describes the tested storage. Documents of the tested type must have the string fields
+// id and name and an array of elements having the string field id and the integer field count,
+// all of them stored under these names.
+type Fixture[DocType any] struct {
+	// NewService returns service over an empty storage, the service is disconnected after each test
+	NewService func(t *testing.T) db_service.DbService[DocType]
+	// ArrayField is the name of the array of elements in the stored documents
+	ArrayField string
+	// NewElement returns element to push into the array
+	NewElement func(id string, count int32) any
+}
+
+// Document is a document type usable with the Fixture, for storages not bound to a particular type
+type Document struct {
+	Id       string
+	Name     string
+	Elements []Element
+}
+
+type Element struct {
+	Id    string
+	Count int32
+}
+
+// DocumentFixture returns fixture for the services storing Document
+func DocumentFixture(newService func(t *testing.T) db_service.DbService[Document]) Fixture[Document] {
+	return Fixture[Document]{
+		NewService: newService,
+		ArrayField: "elements",
+		NewElement: func(id string, count int32) any {
+			return Element{Id: id, Count: count}
+		},
+	}
+}
+
+// Run runs the conformance tests against services returned by the fixture
+func Run[DocType any](t *testing.T, fixture Fixture[DocType]) {
+	suite.Run(t, &conformanceSuite[DocType]{fixture: fixture})
+}
+
+type conformanceSuite[DocType any] struct {
+	suite.Suite
+	fixture Fixture[DocType]
+	sut     db_service.DbService[DocType]
+	ctx     context.Context
+}
+
+// storedElement is the element of the array as seen by the tests
+type storedElement struct {
+	Id    string
+	Count int64
+}
+
+func (suite *conformanceSuite[DocType]) SetupTest() {
+	suite.ctx = context.Background()
+	suite.sut = suite.fixture.NewService(suite.T())
+}
+
+func (suite *conformanceSuite[DocType]) TearDownTest() {
+	suite.NoError(suite.sut.Disconnect(suite.ctx))
+}
+
+// newDocument returns document of the tested type with the given id and name
+func (suite *conformanceSuite[DocType]) newDocument(id string, name string) *DocType {
+	data, err := bson.Marshal(bson.M{"id": id, "name": name})
+	suite.Require().NoError(err)
+	var document DocType
+	suite.Require().NoError(bson.Unmarshal(data, &document))
+	return &document
+}
+
+func (suite *conformanceSuite[DocType]) create(id string, name string) {
+	suite.Require().NoError(suite.sut.CreateDocument(suite.ctx, id, suite.newDocument(id, name)))
+}
+
+func (suite *conformanceSuite[DocType]) nameOf(document *DocType) string {
+	data, err := bson.Marshal(document)
+	suite.Require().NoError(err)
+	name, _ := bson.Raw(data).Lookup("name").StringValueOK()
+	return name
+}
+
+func (suite *conformanceSuite[DocType]) elementsOf(document *DocType) []storedElement {
+	data, err := bson.Marshal(document)
+	suite.Require().NoError(err)
+	elements := []storedElement{}
+	if value, err := bson.Raw(data).LookupErr(suite.fixture.ArrayField); err == nil && value.Type == bson.TypeArray {
+		suite.Require().NoError(value.Unmarshal(&elements))
+	}
+	return elements
+}
+
+// find returns the stored document, failing the test if it cannot be loaded
+func (suite *conformanceSuite[DocType]) find(id string) *DocType {
+	document, err := suite.sut.FindDocument(suite.ctx, id)
+	suite.Require().NoError(err)
+	return document
+}
+
+func (suite *conformanceSuite[DocType]) names(documents []*DocType) []string {
+	names := []string{}
+	for _, document := range documents {
+		names = append(names, suite.nameOf(document))
+	}
+	return names
+}
+
+func (suite *conformanceSuite[DocType]) Test_CreateAndFind() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	suite.create("b", "Beta")
+
+	// ACT
+	document, err := suite.sut.FindDocument(suite.ctx, "b")
+	all, allErr := suite.sut.FindAllDocuments(suite.ctx)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal("Beta", suite.nameOf(document))
+	suite.Empty(suite.elementsOf(document))
+	suite.Require().NoError(allErr)
+	suite.ElementsMatch([]string{"Alpha", "Beta"}, suite.names(all))
+}
+
+func (suite *conformanceSuite[DocType]) Test_CreateDocument_DuplicateIdIsConflict() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+
+	// ACT
+	err := suite.sut.CreateDocument(suite.ctx, "a", suite.newDocument("a", "Other"))
+
+	// ASSERT
+	suite.ErrorIs(err, db_service.ErrConflict)
+	suite.Equal("Alpha", suite.nameOf(suite.find("a")))
+	count, err := suite.sut.CountDocuments(suite.ctx, db_service.Filter{})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *conformanceSuite[DocType]) Test_MissingDocumentIsNotFound() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+
+	// ACT
+	_, findErr := suite.sut.FindDocument(suite.ctx, "missing")
+	updateErr := suite.sut.UpdateDocument(suite.ctx, "missing", suite.newDocument("missing", "Missing"))
+	deleteErr := suite.sut.DeleteDocument(suite.ctx, "missing")
+	pushErr := suite.sut.PushElement(suite.ctx, "missing", arrayField, "x", suite.fixture.NewElement("x", 1))
+	pullErr := suite.sut.PullElement(suite.ctx, "missing", arrayField, "x")
+	setErr := suite.sut.SetElementFields(suite.ctx, "missing", arrayField, "x", map[string]any{"count": 1})
+	incrementErr := suite.sut.IncrementElementField(suite.ctx, "missing", arrayField, "x", "count", 1)
+
+	// ASSERT
+	suite.ErrorIs(findErr, db_service.ErrNotFound)
+	suite.ErrorIs(updateErr, db_service.ErrNotFound)
+	suite.ErrorIs(deleteErr, db_service.ErrNotFound)
+	suite.ErrorIs(pushErr, db_service.ErrNotFound)
+	suite.ErrorIs(pullErr, db_service.ErrNotFound)
+	suite.ErrorIs(setErr, db_service.ErrNotFound)
+	suite.ErrorIs(incrementErr, db_service.ErrNotFound)
+	_, err := suite.sut.FindDocument(suite.ctx, "missing")
+	suite.ErrorIs(err, db_service.ErrNotFound, "update must not create the document")
+}
+
+func (suite *conformanceSuite[DocType]) Test_MissingElementIsNotFound() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+
+	// ACT
+	pullErr := suite.sut.PullElement(suite.ctx, "a", arrayField, "x")
+	setErr := suite.sut.SetElementFields(suite.ctx, "a", arrayField, "x", map[string]any{"count": 1})
+	incrementErr := suite.sut.IncrementElementField(suite.ctx, "a", arrayField, "x", "count", 1)
+
+	// ASSERT
+	suite.ErrorIs(pullErr, db_service.ErrNotFound)
+	suite.ErrorIs(setErr, db_service.ErrNotFound)
+	suite.ErrorIs(incrementErr, db_service.ErrNotFound)
+	suite.Empty(suite.elementsOf(suite.find("a")))
+}
+
+func (suite *conformanceSuite[DocType]) Test_UpdateDocument_ReplacesDocument() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	suite.create("b", "Beta")
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", suite.fixture.ArrayField, "x", suite.fixture.NewElement("x", 1)))
+
+	// ACT
+	err := suite.sut.UpdateDocument(suite.ctx, "a", suite.newDocument("a", "Renamed"))
+
+	// ASSERT
+	suite.Require().NoError(err)
+	document := suite.find("a")
+	suite.Equal("Renamed", suite.nameOf(document))
+	suite.Empty(suite.elementsOf(document), "elements missing in the new document are removed")
+	suite.Equal("Beta", suite.nameOf(suite.find("b")))
+}
+
+func (suite *conformanceSuite[DocType]) Test_DeleteDocument() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	suite.create("b", "Beta")
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", suite.fixture.ArrayField, "x", suite.fixture.NewElement("x", 1)))
+
+	// ACT
+	err := suite.sut.DeleteDocument(suite.ctx, "a")
+
+	// ASSERT
+	suite.Require().NoError(err)
+	_, findErr := suite.sut.FindDocument(suite.ctx, "a")
+	suite.ErrorIs(findErr, db_service.ErrNotFound)
+	suite.ErrorIs(suite.sut.DeleteDocument(suite.ctx, "a"), db_service.ErrNotFound)
+	suite.Equal("Beta", suite.nameOf(suite.find("b")))
+	// the id can be used again and nothing of the deleted document is left
+	suite.create("a", "Again")
+	suite.Empty(suite.elementsOf(suite.find("a")))
+}
+
+func (suite *conformanceSuite[DocType]) Test_ElementOperations() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+
+	// ACT
+	pushErr := suite.sut.PushElement(suite.ctx, "a", arrayField, "x", suite.fixture.NewElement("x", 1))
+	secondPushErr := suite.sut.PushElement(suite.ctx, "a", arrayField, "y", suite.fixture.NewElement("y", 2))
+	duplicatePushErr := suite.sut.PushElement(suite.ctx, "a", arrayField, "x", suite.fixture.NewElement("x", 5))
+	setErr := suite.sut.SetElementFields(suite.ctx, "a", arrayField, "y", map[string]any{"count": int32(7)})
+	incrementErr := suite.sut.IncrementElementField(suite.ctx, "a", arrayField, "x", "count", 3)
+	afterChanges := suite.elementsOf(suite.find("a"))
+	pullErr := suite.sut.PullElement(suite.ctx, "a", arrayField, "x")
+
+	// ASSERT
+	suite.Require().NoError(pushErr)
+	suite.Require().NoError(secondPushErr)
+	suite.ErrorIs(duplicatePushErr, db_service.ErrConflict)
+	suite.Require().NoError(setErr)
+	suite.Require().NoError(incrementErr)
+	suite.Equal([]storedElement{{Id: "x", Count: 4}, {Id: "y", Count: 7}}, afterChanges)
+	suite.Require().NoError(pullErr)
+	document := suite.find("a")
+	suite.Equal([]storedElement{{Id: "y", Count: 7}}, suite.elementsOf(document))
+	suite.Equal("Alpha", suite.nameOf(document), "element operations keep the rest of the document")
+}
+
+func (suite *conformanceSuite[DocType]) Test_UpdateFields_ChangesExpectedDocument() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	suite.create("b", "Beta")
+
+	// ACT
+	err := suite.sut.UpdateFields(suite.ctx, "a", db_service.FieldChange{
+		Expect:    db_service.Eq("name", "Alpha"),
+		Set:       map[string]any{"name": "Renamed"},
+		Increment: map[string]int64{"revision": 2},
+	})
+	staleErr := suite.sut.UpdateFields(suite.ctx, "a", db_service.FieldChange{
+		Expect: db_service.Eq("name", "Alpha"),
+		Set:    map[string]any{"name": "Stale"},
+	})
+	incrementErr := suite.sut.UpdateFields(suite.ctx, "a", db_service.FieldChange{
+		Increment: map[string]int64{"revision": 3},
+	})
+	missingErr := suite.sut.UpdateFields(suite.ctx, "missing", db_service.FieldChange{
+		Set: map[string]any{"name": "Missing"},
+	})
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.ErrorIs(staleErr, db_service.ErrConflict)
+	suite.Require().NoError(incrementErr)
+	suite.ErrorIs(missingErr, db_service.ErrNotFound)
+	suite.Equal("Renamed", suite.nameOf(suite.find("a")))
+	suite.Equal("Beta", suite.nameOf(suite.find("b")))
+	count, err := suite.sut.CountDocuments(suite.ctx, db_service.Eq("revision", 5))
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), count)
+	_, err = suite.sut.FindDocument(suite.ctx, "missing")
+	suite.ErrorIs(err, db_service.ErrNotFound, "update must not create the document")
+}
+
+func (suite *conformanceSuite[DocType]) Test_UpdateElements_AppliesAllOrNone() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, "x", suite.fixture.NewElement("x", 1)))
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, "y", suite.fixture.NewElement("y", 2)))
+
+	// ACT
+	changeErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", FieldChange: db_service.FieldChange{
+			Expect:    db_service.Eq("count", 1),
+			Increment: map[string]int64{"count": 3},
+		}},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "y", FieldChange: db_service.FieldChange{
+			Set: map[string]any{"count": int32(7)},
+		}},
+	)
+	staleErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "y", FieldChange: db_service.FieldChange{
+			Increment: map[string]int64{"count": 10},
+		}},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", FieldChange: db_service.FieldChange{
+			Expect: db_service.Eq("count", 1),
+			Set:    map[string]any{"count": int32(100)},
+		}},
+	)
+	missingElementErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "missing", FieldChange: db_service.FieldChange{
+			Increment: map[string]int64{"count": 1},
+		}},
+	)
+	duplicatePushErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "z", Push: suite.fixture.NewElement("z", 5)},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", Push: suite.fixture.NewElement("x", 5)},
+	)
+	afterFailures := suite.elementsOf(suite.find("a"))
+	pushErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "z", Push: suite.fixture.NewElement("z", 5)},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "w", Push: suite.fixture.NewElement("w", 6)},
+	)
+	pullErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", Pull: true},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "z", Pull: true},
+	)
+	missingErr := suite.sut.UpdateElements(suite.ctx, "missing",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", Pull: true},
+	)
+
+	// ASSERT
+	suite.Require().NoError(changeErr)
+	suite.ErrorIs(staleErr, db_service.ErrConflict)
+	suite.ErrorIs(missingElementErr, db_service.ErrNotFound)
+	suite.ErrorIs(duplicatePushErr, db_service.ErrConflict)
+	suite.Equal([]storedElement{{Id: "x", Count: 4}, {Id: "y", Count: 7}}, afterFailures,
+		"failed updates must not change any element")
+	suite.Require().NoError(pushErr)
+	suite.Require().NoError(pullErr)
+	suite.ErrorIs(missingErr, db_service.ErrNotFound)
+	document := suite.find("a")
+	suite.Equal([]storedElement{{Id: "y", Count: 7}, {Id: "w", Count: 6}}, suite.elementsOf(document))
+	suite.Equal("Alpha", suite.nameOf(document), "element changes keep the rest of the document")
+}
+
+func (suite *conformanceSuite[DocType]) Test_UpdateElements_ChangesArrayInSeveralWays() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, "x", suite.fixture.NewElement("x", 1)))
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, "y", suite.fixture.NewElement("y", 2)))
+
+	// ACT
+	failedErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", FieldChange: db_service.FieldChange{
+			Set: map[string]any{"count": int32(10)},
+		}},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "y", Pull: true},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", Push: suite.fixture.NewElement("x", 5)},
+	)
+	duplicateErr := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "y", Pull: true},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "z", Push: suite.fixture.NewElement("z", 5)},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", Push: suite.fixture.NewElement("x", 5)},
+	)
+	afterFailures := suite.elementsOf(suite.find("a"))
+	err := suite.sut.UpdateElements(suite.ctx, "a",
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "x", FieldChange: db_service.FieldChange{
+			Expect: db_service.Eq("count", 1),
+			Set:    map[string]any{"count": int32(10)},
+		}},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "y", Pull: true},
+		db_service.ElementChange{ArrayField: arrayField, ElementId: "z", Push: suite.fixture.NewElement("z", 5)},
+	)
+
+	// ASSERT
+	suite.Error(failedErr, "element cannot be changed twice in one update")
+	suite.ErrorIs(duplicateErr, db_service.ErrConflict)
+	suite.Equal([]storedElement{{Id: "x", Count: 1}, {Id: "y", Count: 2}}, afterFailures,
+		"failed updates must not change any element")
+	suite.Require().NoError(err)
+	document := suite.find("a")
+	suite.Equal([]storedElement{{Id: "x", Count: 10}, {Id: "z", Count: 5}}, suite.elementsOf(document))
+	suite.Equal("Alpha", suite.nameOf(document), "element changes keep the rest of the document")
+}
+
+func (suite *conformanceSuite[DocType]) Test_FindDocuments_FiltersSortsAndPages() {
+	// ARRANGE
+	for id, name := range map[string]string{"a": "Alpha", "b": "Beta", "c": "Gamma", "d": "Delta"} {
+		suite.create(id, name)
+	}
+
+	// ACT
+	byName, err := suite.sut.FindDocuments(suite.ctx, db_service.Query{
+		Filter: db_service.Ne("name", "Beta"),
+		Sort:   []db_service.SortField{{Field: "name", Descending: true}},
+		Skip:   1,
+		Limit:  2,
+	})
+	projected, projectedErr := suite.sut.FindDocuments(suite.ctx, db_service.Query{
+		Filter:     db_service.In("id", "a", "c"),
+		Projection: []string{"id"},
+	})
+	none, noneErr := suite.sut.FindDocuments(suite.ctx, db_service.Query{Filter: db_service.Eq("name", "Omega")})
+	count, countErr := suite.sut.CountDocuments(suite.ctx, db_service.Or(db_service.Eq("id", "a"), db_service.Gt("name", "C")))
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]string{"Delta", "Alpha"}, suite.names(byName))
+	suite.Require().NoError(projectedErr)
+	suite.Equal([]string{"", ""}, suite.names(projected), "only the projected fields are loaded")
+	suite.Require().NoError(noneErr)
+	suite.NotNil(none, "no matching documents is an empty result")
+	suite.Empty(none)
+	suite.Require().NoError(countErr)
+	suite.Equal(int64(3), count)
+}
+
+func (suite *conformanceSuite[DocType]) Test_ConcurrentCreate_OnlyOneSucceeds() {
+	// ARRANGE
+	const workers = 10
+	var wait sync.WaitGroup
+	errs := make([]error, workers)
+
+	// ACT
+	for worker := range workers {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			errs[worker] = suite.sut.CreateDocument(suite.ctx, "race", suite.newDocument("race", fmt.Sprint(worker)))
+		}()
+	}
+	wait.Wait()
+
+	// ASSERT
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+		} else {
+			suite.ErrorIs(err, db_service.ErrConflict)
+		}
+	}
+	suite.Equal(1, created)
+	count, err := suite.sut.CountDocuments(suite.ctx, db_service.Eq("id", "race"))
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *conformanceSuite[DocType]) Test_ConcurrentElementChanges_AreNotLost() {
+	// ARRANGE
+	// enough changes overlap, so a read-modify-write implementation reliably loses some of them
+	const workers = 20
+	const changes = 10
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, "counter", suite.fixture.NewElement("counter", 0)))
+	var wait sync.WaitGroup
+	start := make(chan struct{})
+
+	// ACT
+	for worker := range workers {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			<-start
+			for range changes {
+				suite.NoError(suite.sut.IncrementElementField(suite.ctx, "a", arrayField, "counter", "count", 1))
+			}
+		}()
+		go func() {
+			defer wait.Done()
+			<-start
+			for change := range changes {
+				id := fmt.Sprint("element-", worker, "-", change)
+				suite.NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, id, suite.fixture.NewElement(id, 1)))
+			}
+		}()
+	}
+	close(start)
+	wait.Wait()
+
+	// ASSERT
+	elements := suite.elementsOf(suite.find("a"))
+	suite.Len(elements, workers*changes+1)
+	suite.Contains(elements, storedElement{Id: "counter", Count: workers * changes})
+}
+
+func (suite *conformanceSuite[DocType]) Test_ExpiredContext_FailsWithoutChanges() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	arrayField := suite.fixture.ArrayField
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", arrayField, "x", suite.fixture.NewElement("x", 1)))
+	ctx, contextCancel := context.WithDeadline(suite.ctx, time.Now().Add(-time.Second))
+	defer contextCancel()
+
+	// ACT
+	createErr := suite.sut.CreateDocument(ctx, "b", suite.newDocument("b", "Beta"))
+	_, findErr := suite.sut.FindDocument(ctx, "a")
+	_, queryErr := suite.sut.FindDocuments(ctx, db_service.Query{})
+	updateErr := suite.sut.UpdateDocument(ctx, "a", suite.newDocument("a", "Renamed"))
+	incrementErr := suite.sut.IncrementElementField(ctx, "a", arrayField, "x", "count", 1)
+	deleteErr := suite.sut.DeleteDocument(ctx, "a")
+
+	// ASSERT
+	for _, err := range []error{createErr, findErr, queryErr, updateErr, incrementErr, deleteErr} {
+		suite.True(errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+	}
+	_, err := suite.sut.FindDocument(suite.ctx, "b")
+	suite.ErrorIs(err, db_service.ErrNotFound)
+	document := suite.find("a")
+	suite.Equal("Alpha", suite.nameOf(document))
+	suite.Equal([]storedElement{{Id: "x", Count: 1}}, suite.elementsOf(document))
+}
+
+func (suite *conformanceSuite[DocType]) Test_CanceledContext_Fails() {
+	// ARRANGE
+	suite.create("a", "Alpha")
+	ctx, contextCancel := context.WithCancel(suite.ctx)
+	contextCancel()
+
+	// ACT
+	_, findErr := suite.sut.FindDocument(ctx, "a")
+	_, countErr := suite.sut.CountDocuments(ctx, db_service.Filter{})
+
+	// ASSERT
+	suite.ErrorIs(findErr, context.Canceled)
+	suite.ErrorIs(countErr, context.Canceled)
+}
@@ -0,0 +1,81 @@
+package db_service_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/db_service/conformance"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMemoryService_Conformance(t *testing.T) {
+	conformance.Run(t, conformance.DocumentFixture(func(t *testing.T) db_service.DbService[conformance.Document] {
+		return db_service.NewMemoryService[conformance.Document]()
+	}))
+}
+
+func TestSqliteService_Conformance(t *testing.T) {
+	conformance.Run(t, conformance.DocumentFixture(func(t *testing.T) db_service.DbService[conformance.Document] {
+		return db_service.NewSqliteService[conformance.Document](db_service.SqliteServiceConfig{
+			Path:          filepath.Join(t.TempDir(), "conformance.db"),
+			Table:         "documents",
+			IndexedFields: []string{"name"},
+			// the writers of the concurrency tests queue for the database lock, which is not fair
+			Timeout: time.Minute,
+		})
+	}))
+}
+
+func TestMongoService_Conformance(t *testing.T) {
+	server := startMongoStandIn(t)
+	// the stand-in does not authenticate
+	t.Setenv("MEDICINE_API_MONGODB_USERNAME", "")
+	conformance.Run(t, conformance.DocumentFixture(func(t *testing.T) db_service.DbService[conformance.Document] {
+		return newMongoService(t, db_service.MongoServiceConfig{ServerHost: "127.0.0.1", ServerPort: server.port()})
+	}))
+}
+
+// TestMongoService_Conformance_Server runs the conformance tests against the mongo server
+// given by MEDICINE_API_TEST_MONGODB_HOST, the other connection settings are taken from
+// the usual MEDICINE_API_MONGODB_* variables.
+func TestMongoService_Conformance_Server(t *testing.T) {
+	host := os.Getenv("MEDICINE_API_TEST_MONGODB_HOST")
+	if host == "" {
+		t.Skip("MEDICINE_API_TEST_MONGODB_HOST is not set")
+	}
+	conformance.Run(t, conformance.DocumentFixture(func(t *testing.T) db_service.DbService[conformance.Document] {
+		return newMongoService(t, db_service.MongoServiceConfig{ServerHost: host})
+	}))
+}
+
+var mongoCollections atomic.Int64
+
+// newMongoService returns service over new collection with the unique id index, which the
+// deployment creates by the migrations. The collection is dropped when the test ends.
+func newMongoService(t *testing.T, config db_service.MongoServiceConfig) db_service.DbService[conformance.Document] {
+	config.Collection = fmt.Sprintf("conformance_%v", mongoCollections.Add(1))
+	config = config.WithDefaults()
+	ctx := context.Background()
+	client, err := config.Connect(ctx)
+	require.NoError(t, err)
+	collection := client.Database(config.DbName).Collection(config.Collection)
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetName("id_1").SetUnique(true),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, collection.Drop(ctx))
+		require.NoError(t, client.Disconnect(ctx))
+	})
+	return db_service.NewMongoService[conformance.Document](config)
+}
@@ -0,0 +1,181 @@
+package db_service
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// The functions in this file change elements of the document arrays with the semantics of the mongo
+// array updates, for the storage implementations working with decoded bson documents.
+
+// elementChange changes the array, elementIndex is the index of the first element with the requested id
+type elementChange = func(array bson.A, elementIndex int) (bson.A, error)
+
+// pushDocumentElement appends the element to the array field of the document,
+// ErrConflict is returned if element with the same id already exists
+func pushDocumentElement(document bson.M, arrayField string, elementId any, element any) error {
+	stored, err := toBsonValue(element)
+	if err != nil {
+		return err
+	}
+	array, err := arrayOf(document, arrayField)
+	if err != nil {
+		return err
+	}
+	elementIndex, err := elementIndexOf(array, elementId)
+	if err != nil {
+		return err
+	}
+	if elementIndex >= 0 {
+		return ErrConflict
+	}
+	document[arrayField] = append(array, stored)
+	return nil
+}
+
+// updateDocumentElement applies the change to the array of the document having the element with the given id.
+// ErrNotFound is returned if there is no such element.
+func updateDocumentElement(document bson.M, arrayField string, elementId any, change elementChange) error {
+	array, err := arrayOf(document, arrayField)
+	if err != nil {
+		return err
+	}
+	elementIndex, err := elementIndexOf(array, elementId)
+	if err != nil {
+		return err
+	}
+	if elementIndex < 0 {
+		return ErrNotFound
+	}
+	// the change works on a copy, so a failed change leaves the document intact
+	array, err = change(deepCopyArray(array), elementIndex)
+	if err != nil {
+		return err
+	}
+	document[arrayField] = array
+	return nil
+}
+
+func pullElementChange(elementId any) elementChange {
+	return func(array bson.A, elementIndex int) (bson.A, error) {
+		// like $pull, all elements with the id are removed
+		return slices.DeleteFunc(array, func(item any) bool {
+			item, ok := item.(bson.M)
+			if !ok {
+				return false
+			}
+			matches, _ := containsEqual(lookupValues(item, []string{"id"}), elementId)
+			return matches
+		}), nil
+	}
+}
+
+func setFieldsChange(fields map[string]any) (elementChange, error) {
+	values := bson.M{}
+	for field, value := range fields {
+		stored, err := toBsonValue(value)
+		if err != nil {
+			return nil, err
+		}
+		values[field] = stored
+	}
+	return func(array bson.A, elementIndex int) (bson.A, error) {
+		maps.Copy(array[elementIndex].(bson.M), values)
+		return array, nil
+	}, nil
+}
+
+func incrementChange(field string, delta int64) elementChange {
+	return func(array bson.A, elementIndex int) (bson.A, error) {
+		element := array[elementIndex].(bson.M)
+		sum, err := incrementValue(field, element[field], delta)
+		if err != nil {
+			return nil, err
+		}
+		element[field] = sum
+		return array, nil
+	}
+}
+
+// applyElementChanges applies all changes to the arrays of the document or none of them if any change fails
+func applyElementChanges(document bson.M, changes []ElementChange) error {
+	changed := bson.M{}
+	for _, change := range changes {
+		if _, ok := changed[change.ArrayField]; ok {
+			continue
+		}
+		array, err := arrayOf(document, change.ArrayField)
+		if err != nil {
+			return err
+		}
+		changed[change.ArrayField] = deepCopyArray(array)
+	}
+	for _, change := range changes {
+		var err error
+		switch {
+		case change.Push != nil:
+			err = pushDocumentElement(changed, change.ArrayField, change.ElementId, change.Push)
+		case change.Pull:
+			err = updateDocumentElement(changed, change.ArrayField, change.ElementId, pullElementChange(change.ElementId))
+		default:
+			err = updateDocumentElement(changed, change.ArrayField, change.ElementId, fieldsChange(change.FieldChange))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	maps.Copy(document, changed)
+	return nil
+}
+
+// fieldsChange applies the change to the element, field names and the expected filter are within the element
+func fieldsChange(change FieldChange) elementChange {
+	return func(array bson.A, elementIndex int) (bson.A, error) {
+		if err := changeFields(array[elementIndex].(bson.M), change); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+}
+
+// arrayOf returns the array field of the document, missing and null arrays are empty
+func arrayOf(document bson.M, arrayField string) (bson.A, error) {
+	switch array := document[arrayField].(type) {
+	case nil:
+		return bson.A{}, nil
+	case bson.A:
+		return array, nil
+	default:
+		return nil, fmt.Errorf("field %v is not an array", arrayField)
+	}
+}
+
+// elementIndexOf returns index of the first array element with the given id or -1
+func elementIndexOf(array bson.A, elementId any) (int, error) {
+	for i, item := range array {
+		element, ok := item.(bson.M)
+		if !ok {
+			continue
+		}
+		matches, err := containsEqual(lookupValues(element, []string{"id"}), elementId)
+		if err != nil {
+			return -1, err
+		}
+		if matches {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func deepCopyArray(array bson.A) bson.A {
+	copied, err := toDocument(bson.M{"v": array})
+	if err != nil {
+		// the array was decoded from bson, so it can always be encoded again
+		panic(err)
+	}
+	return copied["v"].(bson.A)
+}
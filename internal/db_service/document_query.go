@@ -0,0 +1,347 @@
+package db_service
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The functions in this file evaluate queries on decoded bson documents with the semantics of
+// the mongo queries, for the storage implementations without their own query language.
+
+// toDocument returns deep copy of the value as bson document
+func toDocument(value any) (bson.M, error) {
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	document := bson.M{}
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// fromDocument decodes copy of the document into new value of the document type
+func fromDocument[DocType any](document bson.M) (*DocType, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var value DocType
+	if err := bson.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// toBsonValue converts go value into the value stored in bson document
+func toBsonValue(value any) (any, error) {
+	document, err := toDocument(bson.M{"v": value})
+	if err != nil {
+		return nil, err
+	}
+	return document["v"], nil
+}
+
+// lookupValues returns the values at the dotted path. Arrays on the path are traversed
+// and the values of the arrays at the end of the path are included besides the arrays.
+func lookupValues(value any, path []string) []any {
+	if len(path) == 0 {
+		if array, ok := value.(bson.A); ok {
+			return append([]any{array}, array...)
+		}
+		return []any{value}
+	}
+	switch value := value.(type) {
+	case bson.M:
+		child, ok := value[path[0]]
+		if !ok {
+			return nil
+		}
+		return lookupValues(child, path[1:])
+	case bson.A:
+		var values []any
+		for _, item := range value {
+			if _, ok := item.(bson.M); ok {
+				values = append(values, lookupValues(item, path)...)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func splitPath(field string) []string {
+	return strings.Split(field, ".")
+}
+
+// matchesFilter reports whether the document satisfies the filter
+func matchesFilter(document bson.M, filter Filter) (bool, error) {
+	switch filter.Op {
+	case "":
+		return true, nil
+	case OpAnd, OpOr:
+		for _, nested := range filter.Filters {
+			matches, err := matchesFilter(document, nested)
+			if err != nil {
+				return false, err
+			}
+			if matches == (filter.Op == OpOr) {
+				return matches, nil
+			}
+		}
+		return filter.Op == OpAnd, nil
+	case OpNot:
+		matches, err := matchesFilter(document, filter.Filters[0])
+		return !matches, err
+	case OpExists:
+		exists := len(lookupValues(document, splitPath(filter.Field))) > 0
+		return exists == filter.Value.(bool), nil
+	case OpElemMatch:
+		for _, value := range lookupValues(document, splitPath(filter.Field)) {
+			array, ok := value.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, item := range array {
+				element, ok := item.(bson.M)
+				if !ok {
+					continue
+				}
+				if matches, err := matchesFilter(element, filter.Filters[0]); err != nil || matches {
+					return matches, err
+				}
+			}
+		}
+		return false, nil
+	}
+
+	values := lookupValues(document, splitPath(filter.Field))
+	switch filter.Op {
+	case OpEq:
+		return containsEqual(values, filter.Value)
+	case OpNe:
+		matches, err := containsEqual(values, filter.Value)
+		return !matches, err
+	case OpIn:
+		for _, item := range filter.Value.([]any) {
+			matches, err := containsEqual(values, item)
+			if err != nil || matches {
+				return matches, err
+			}
+		}
+		return false, nil
+	default:
+		expected, err := toBsonValue(filter.Value)
+		if err != nil {
+			return false, err
+		}
+		for _, value := range values {
+			// like mongo, only values of the same kind are compared
+			if typeRank(value) != typeRank(expected) {
+				continue
+			}
+			result := compareValues(value, expected)
+			switch {
+			case filter.Op == OpGt && result > 0,
+				filter.Op == OpGte && result >= 0,
+				filter.Op == OpLt && result < 0,
+				filter.Op == OpLte && result <= 0:
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// containsEqual reports whether any of the values equals the expected value, nil matches also missing field
+func containsEqual(values []any, expected any) (bool, error) {
+	expected, err := toBsonValue(expected)
+	if err != nil {
+		return false, err
+	}
+	if expected == nil && len(values) == 0 {
+		return true, nil
+	}
+	for _, value := range values {
+		if typeRank(value) == typeRank(expected) && compareValues(value, expected) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// typeRank orders the kinds of values the way mongo sorts them
+func typeRank(value any) int {
+	switch value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.M, bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	default:
+		return 11
+	}
+}
+
+// compareValues compares bson values, values of different kinds are ordered by their kind
+func compareValues(a any, b any) int {
+	if rankA, rankB := typeRank(a), typeRank(b); rankA != rankB {
+		return cmp.Compare(rankA, rankB)
+	}
+	switch a := a.(type) {
+	case int32, int64, float64:
+		return compareNumbers(a, b)
+	case string:
+		return strings.Compare(a, fmt.Sprint(b))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case primitive.DateTime:
+		return cmp.Compare(a, b.(primitive.DateTime))
+	case bson.A:
+		b := b.(bson.A)
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if result := compareValues(a[i], b[i]); result != 0 {
+				return result
+			}
+		}
+		return cmp.Compare(len(a), len(b))
+	default:
+		if reflect.DeepEqual(a, b) {
+			return 0
+		}
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+func compareNumbers(a any, b any) int {
+	integerA, isIntegerA := asInt64(a)
+	integerB, isIntegerB := asInt64(b)
+	if isIntegerA && isIntegerB {
+		return cmp.Compare(integerA, integerB)
+	}
+	return cmp.Compare(asFloat64(a), asFloat64(b))
+}
+
+func asInt64(value any) (int64, bool) {
+	switch value := value.(type) {
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+func asFloat64(value any) float64 {
+	switch value := value.(type) {
+	case int32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case float64:
+		return value
+	default:
+		return 0
+	}
+}
+
+// sortDocuments orders the documents by the sort fields, documents with equal values keep their order
+func sortDocuments(documents []bson.M, sort []SortField) {
+	slices.SortStableFunc(documents, func(a, b bson.M) int {
+		for _, field := range sort {
+			result := compareValues(sortValue(a, field.Field), sortValue(b, field.Field))
+			if field.Descending {
+				result = -result
+			}
+			if result != 0 {
+				return result
+			}
+		}
+		return 0
+	})
+}
+
+func sortValue(document bson.M, field string) any {
+	values := lookupValues(document, splitPath(field))
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// projectDocument returns the document with only the listed fields
+func projectDocument(document bson.M, projection []string) bson.M {
+	if len(projection) == 0 {
+		return document
+	}
+	projected := bson.M{}
+	for _, field := range projection {
+		projectPath(document, projected, splitPath(field))
+	}
+	return projected
+}
+
+func projectPath(source bson.M, target bson.M, path []string) {
+	value, ok := source[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		target[path[0]] = value
+		return
+	}
+	switch value := value.(type) {
+	case bson.M:
+		nested, ok := target[path[0]].(bson.M)
+		if !ok {
+			nested = bson.M{}
+			target[path[0]] = nested
+		}
+		projectPath(value, nested, path[1:])
+	case bson.A:
+		nested, ok := target[path[0]].(bson.A)
+		if !ok {
+			nested = make(bson.A, len(value))
+			for i := range nested {
+				nested[i] = bson.M{}
+			}
+			target[path[0]] = nested
+		}
+		for i, item := range value {
+			if item, ok := item.(bson.M); ok {
+				if projectedItem, ok := nested[i].(bson.M); ok {
+					projectPath(item, projectedItem, path[1:])
+				}
+			}
+		}
+	}
+}
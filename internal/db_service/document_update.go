@@ -0,0 +1,94 @@
+package db_service
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// The functions in this file change fields of decoded bson documents with the semantics of the mongo
+// $set and $inc updates, for the storage implementations working with decoded bson documents.
+
+// changeFields applies the change to the document, ErrConflict is returned if the document does not
+// match the expected filter. The document is left intact if the change fails.
+func changeFields(document bson.M, change FieldChange) error {
+	matches, err := matchesFilter(document, change.Expect)
+	if err != nil {
+		return err
+	}
+	if !matches {
+		return ErrConflict
+	}
+	changed := bson.M{}
+	for field, value := range change.Set {
+		stored, err := toBsonValue(value)
+		if err != nil {
+			return err
+		}
+		changed[field] = stored
+	}
+	for field, delta := range change.Increment {
+		values := lookupValues(document, splitPath(field))
+		var current any
+		if len(values) > 0 {
+			current = values[0]
+		}
+		sum, err := incrementValue(field, current, delta)
+		if err != nil {
+			return err
+		}
+		changed[field] = sum
+	}
+	// the paths are checked before anything is set
+	for field := range changed {
+		if _, err := parentOf(document, splitPath(field), false); err != nil {
+			return err
+		}
+	}
+	for field, value := range changed {
+		path := splitPath(field)
+		parent, _ := parentOf(document, path, true)
+		parent[path[len(path)-1]] = value
+	}
+	return nil
+}
+
+// parentOf returns the document holding the last field of the path, the missing documents on the path
+// are created if create is set
+func parentOf(document bson.M, path []string, create bool) (bson.M, error) {
+	parent := document
+	for i, field := range path[:len(path)-1] {
+		switch child := parent[field].(type) {
+		case nil:
+			if !create {
+				return nil, nil
+			}
+			created := bson.M{}
+			parent[field] = created
+			parent = created
+		case bson.M:
+			parent = child
+		default:
+			return nil, fmt.Errorf("cannot change field %v, %v is not a document",
+				strings.Join(path, "."), strings.Join(path[:i+1], "."))
+		}
+	}
+	return parent, nil
+}
+
+// incrementValue adds delta to the value of the field, like $inc the result has the wider of the two types
+func incrementValue(field string, value any, delta int64) (any, error) {
+	switch value := value.(type) {
+	case nil:
+		return delta, nil
+	case int32:
+		return int64(value) + delta, nil
+	case int64:
+		return value + delta, nil
+	case float64:
+		return value + float64(delta), nil
+	default:
+		return nil, fmt.Errorf("cannot increment non-numeric field %v of type %T", field, value)
+	}
+}
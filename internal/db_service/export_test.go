@@ -0,0 +1,8 @@
+package db_service
+
+// the query evaluation is shared with the mongo wire stand-in of the external tests
+var (
+	MatchesFilter   = matchesFilter
+	SortDocuments   = sortDocuments
+	ProjectDocument = projectDocument
+)
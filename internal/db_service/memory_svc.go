@@ -0,0 +1,260 @@
+package db_service
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// memorySvc keeps the documents in memory with the same semantics as mongoSvc. Documents are
+// stored in their bson form, so every read and write works with a deep copy of the document.
+type memorySvc[DocType interface{}] struct {
+	lock      sync.RWMutex
+	documents []bson.M
+}
+
+// NewMemoryService returns service storing the documents in memory, intended for local development and tests
+func NewMemoryService[DocType interface{}]() DbService[DocType] {
+	return &memorySvc[DocType]{}
+}
+
+// indexOf returns index of the document with the given id or -1
+func (m *memorySvc[DocType]) indexOf(id any) (int, error) {
+	for i, document := range m.documents {
+		matches, err := containsEqual(lookupValues(document, []string{"id"}), id)
+		if err != nil {
+			return -1, err
+		}
+		if matches {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func (m *memorySvc[DocType]) CreateDocument(ctx context.Context, id any, document *DocType) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stored, err := toDocument(document)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	index, err := m.indexOf(id)
+	if err != nil {
+		return err
+	}
+	if index >= 0 {
+		return ErrConflict
+	}
+	m.documents = append(m.documents, stored)
+	return nil
+}
+
+func (m *memorySvc[DocType]) FindDocument(ctx context.Context, id any) (*DocType, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	index, err := m.indexOf(id)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 {
+		return nil, ErrNotFound
+	}
+	return fromDocument[DocType](m.documents[index])
+}
+
+func (m *memorySvc[DocType]) FindAllDocuments(ctx context.Context) ([]*DocType, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var documents []*DocType
+	for _, stored := range m.documents {
+		document, err := fromDocument[DocType](stored)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+func (m *memorySvc[DocType]) FindDocuments(ctx context.Context, query Query) ([]*DocType, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	matching, err := m.matching(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+	sortDocuments(matching, query.Sort)
+	matching = matching[min(int64(len(matching)), query.Skip):]
+	if query.Limit > 0 && int64(len(matching)) > query.Limit {
+		matching = matching[:query.Limit]
+	}
+
+	documents := []*DocType{}
+	for _, stored := range matching {
+		document, err := fromDocument[DocType](projectDocument(stored, query.Projection))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+func (m *memorySvc[DocType]) CountDocuments(ctx context.Context, filter Filter) (int64, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	matching, err := m.matching(filter)
+	return int64(len(matching)), err
+}
+
+func (m *memorySvc[DocType]) matching(filter Filter) ([]bson.M, error) {
+	var matching []bson.M
+	for _, document := range m.documents {
+		matches, err := matchesFilter(document, filter)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			matching = append(matching, document)
+		}
+	}
+	return matching, nil
+}
+
+func (m *memorySvc[DocType]) UpdateDocument(ctx context.Context, id any, document *DocType) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stored, err := toDocument(document)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	index, err := m.indexOf(id)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		return ErrNotFound
+	}
+	m.documents[index] = stored
+	return nil
+}
+
+// PushElement appends the element to the array field of the document. Elements of the array are
+// identified by their id field, ErrConflict is returned if element with the same id already exists.
+func (m *memorySvc[DocType]) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	return m.changeDocument(ctx, id, func(document bson.M) error {
+		return pushDocumentElement(document, arrayField, elementId, element)
+	})
+}
+
+// PullElement removes the element with the given id from the array field of the document
+func (m *memorySvc[DocType]) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	return m.changeDocument(ctx, id, func(document bson.M) error {
+		return updateDocumentElement(document, arrayField, elementId, pullElementChange(elementId))
+	})
+}
+
+// SetElementFields sets the fields of the element with the given id in the array field of the document
+func (m *memorySvc[DocType]) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	change, err := setFieldsChange(fields)
+	if err != nil {
+		return err
+	}
+	return m.changeDocument(ctx, id, func(document bson.M) error {
+		return updateDocumentElement(document, arrayField, elementId, change)
+	})
+}
+
+// IncrementElementField atomically adds delta to the numeric field of the element with the given id
+func (m *memorySvc[DocType]) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	return m.changeDocument(ctx, id, func(document bson.M) error {
+		return updateDocumentElement(document, arrayField, elementId, incrementChange(field, delta))
+	})
+}
+
+// UpdateFields atomically applies the change to the document with the given id. ErrConflict is returned
+// if the document does not match the expected filter of the change.
+func (m *memorySvc[DocType]) UpdateFields(ctx context.Context, id any, change FieldChange) error {
+	if err := change.Validate(); err != nil {
+		return err
+	}
+	return m.changeDocument(ctx, id, func(document bson.M) error {
+		return changeFields(document, change)
+	})
+}
+
+// UpdateElements atomically applies all changes to the elements of the document with the given id
+func (m *memorySvc[DocType]) UpdateElements(ctx context.Context, id any, changes ...ElementChange) error {
+	if err := ValidateElementChanges(changes); err != nil {
+		return err
+	}
+	return m.changeDocument(ctx, id, func(document bson.M) error {
+		return applyElementChanges(document, changes)
+	})
+}
+
+// changeDocument applies the change to the stored document with the given id under the write lock
+func (m *memorySvc[DocType]) changeDocument(ctx context.Context, id any, change func(document bson.M) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	index, err := m.indexOf(id)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		return ErrNotFound
+	}
+	return change(m.documents[index])
+}
+
+func (m *memorySvc[DocType]) DeleteDocument(ctx context.Context, id any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	index, err := m.indexOf(id)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		return ErrNotFound
+	}
+	m.documents = slices.Delete(m.documents, index, index+1)
+	return nil
+}
+
+func (m *memorySvc[DocType]) Disconnect(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,149 @@
+package db_service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type testItem struct {
+	Id    string
+	Count int32
+}
+
+type testDocument struct {
+	Id    string
+	Name  string
+	Rank  int
+	Tags  []string
+	Items []testItem
+}
+
+type MemoryServiceSuite struct {
+	suite.Suite
+	sut DbService[testDocument]
+	ctx context.Context
+}
+
+func TestMemoryServiceSuite(t *testing.T) {
+	suite.Run(t, new(MemoryServiceSuite))
+}
+
+func (suite *MemoryServiceSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.sut = NewMemoryService[testDocument]()
+	for _, document := range []testDocument{
+		{Id: "a", Name: "Alpha", Rank: 3, Tags: []string{"red"}},
+		{Id: "b", Name: "Beta", Rank: 1, Tags: []string{"red", "blue"}, Items: []testItem{{Id: "x", Count: 2}}},
+		{Id: "c", Name: "Gamma", Rank: 2},
+	} {
+		suite.Require().NoError(suite.sut.CreateDocument(suite.ctx, document.Id, &document))
+	}
+}
+
+func (suite *MemoryServiceSuite) Test_ReadsAndWritesCopies() {
+	// ARRANGE
+	document, err := suite.sut.FindDocument(suite.ctx, "b")
+	suite.Require().NoError(err)
+
+	// ACT
+	document.Items[0].Count = 100
+	document.Tags = append(document.Tags, "green")
+
+	// ASSERT
+	stored, err := suite.sut.FindDocument(suite.ctx, "b")
+	suite.Require().NoError(err)
+	suite.Equal(int32(2), stored.Items[0].Count)
+	suite.Equal([]string{"red", "blue"}, stored.Tags)
+}
+
+func (suite *MemoryServiceSuite) Test_ConflictAndNotFound() {
+	// ACT
+	createErr := suite.sut.CreateDocument(suite.ctx, "a", &testDocument{Id: "a"})
+	_, findErr := suite.sut.FindDocument(suite.ctx, "missing")
+	updateErr := suite.sut.UpdateDocument(suite.ctx, "missing", &testDocument{Id: "missing"})
+	deleteErr := suite.sut.DeleteDocument(suite.ctx, "missing")
+
+	// ASSERT
+	suite.ErrorIs(createErr, ErrConflict)
+	suite.ErrorIs(findErr, ErrNotFound)
+	suite.ErrorIs(updateErr, ErrNotFound)
+	suite.ErrorIs(deleteErr, ErrNotFound)
+}
+
+func (suite *MemoryServiceSuite) Test_FindDocuments_FiltersSortsAndPages() {
+	// ACT
+	documents, err := suite.sut.FindDocuments(suite.ctx, Query{
+		Filter:     Or(Eq("tags", "red"), Gte("rank", 2)),
+		Projection: []string{"id", "rank"},
+		Sort:       []SortField{{Field: "rank", Descending: true}},
+		Skip:       1,
+		Limit:      1,
+	})
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]*testDocument{{Id: "c", Rank: 2}}, documents)
+}
+
+func (suite *MemoryServiceSuite) Test_CountDocuments_MongoSemantics() {
+	testCases := []struct {
+		filter   Filter
+		expected int64
+	}{
+		{Filter{}, 3},
+		{Eq("items.id", "x"), 1},
+		{In("name", "Alpha", "Gamma", "Delta"), 2},
+		{Not(Exists("items.id", true)), 2},
+		{Eq("items", nil), 2},
+		{Ne("tags", "blue"), 2},
+		{And(Lt("rank", int64(3)), Gt("rank", 1.5)), 1},
+		{Gt("name", 1), 0},
+		{Or(), 0},
+	}
+	for _, testCase := range testCases {
+		// ACT
+		count, err := suite.sut.CountDocuments(suite.ctx, testCase.filter)
+
+		// ASSERT
+		suite.Require().NoError(err)
+		suite.Equal(testCase.expected, count, "%+v", testCase.filter)
+	}
+}
+
+func (suite *MemoryServiceSuite) Test_ElementOperations() {
+	// ACT
+	suite.Require().NoError(suite.sut.PushElement(suite.ctx, "a", "items", "y", testItem{Id: "y", Count: 1}))
+	pushConflict := suite.sut.PushElement(suite.ctx, "a", "items", "y", testItem{Id: "y"})
+	suite.Require().NoError(suite.sut.IncrementElementField(suite.ctx, "a", "items", "y", "count", 4))
+	suite.Require().NoError(suite.sut.SetElementFields(suite.ctx, "b", "items", "x", map[string]any{"count": 7}))
+	suite.Require().NoError(suite.sut.PullElement(suite.ctx, "b", "items", "x"))
+	pullMissing := suite.sut.PullElement(suite.ctx, "b", "items", "x")
+	pushMissing := suite.sut.PushElement(suite.ctx, "missing", "items", "y", testItem{Id: "y"})
+	incrementText := suite.sut.IncrementElementField(suite.ctx, "a", "items", "y", "id", 1)
+
+	// ASSERT
+	suite.ErrorIs(pushConflict, ErrConflict)
+	suite.ErrorIs(pullMissing, ErrNotFound)
+	suite.ErrorIs(pushMissing, ErrNotFound)
+	suite.Error(incrementText)
+	a, err := suite.sut.FindDocument(suite.ctx, "a")
+	suite.Require().NoError(err)
+	suite.Equal([]testItem{{Id: "y", Count: 5}}, a.Items)
+	b, err := suite.sut.FindDocument(suite.ctx, "b")
+	suite.Require().NoError(err)
+	suite.Empty(b.Items)
+}
+
+func (suite *MemoryServiceSuite) Test_CancelledContext() {
+	// ARRANGE
+	ctx, cancel := context.WithCancel(suite.ctx)
+	cancel()
+
+	// ACT
+	_, err := suite.sut.FindDocument(ctx, "a")
+
+	// ASSERT
+	suite.ErrorIs(err, context.Canceled)
+}
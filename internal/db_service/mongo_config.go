@@ -0,0 +1,352 @@
+package db_service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// MongoServiceConfig describes the connection to the MongoDB. The config never exposes the
+// credentials when printed, so it can be logged.
+type MongoServiceConfig struct {
+	// URI is the full connection string, for example mongodb+srv://cluster.example.com/?replicaSet=rs0.
+	// ServerHost and ServerPort are used only if there is no URI. The other options override the URI.
+	URI        string
+	ServerHost string
+	ServerPort int
+	UserName   string
+	Password   string
+	// The credential files, for example mounted kubernetes secrets, are read when connecting.
+	// They are used if the corresponding value is empty.
+	URIFile      string
+	UserNameFile string
+	PasswordFile string
+	AuthSource   string
+	ReplicaSet   string
+	// TLS enables the TLS also without the CA or the client certificate. TLSKeyFile may be empty
+	// if TLSCertFile contains both the client certificate and its key.
+	TLS         bool
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// ReadConcern is the read concern level, e.g. majority. WriteConcern is either majority,
+	// number of the nodes or name of the tag set.
+	ReadConcern  string
+	WriteConcern string
+	MaxPoolSize  uint64
+	MinPoolSize  uint64
+	AppName      string
+	DbName       string
+	Collection   string
+	Timeout      time.Duration
+	// PoolMonitor receives the events of the connection pool, for example to export its statistics
+	PoolMonitor *event.PoolMonitor
+	// CommandMonitor receives the started and finished commands, for example to trace them
+	CommandMonitor *event.CommandMonitor
+}
+
+// WithDefaults returns copy of the config with the missing values taken from the environment
+func (config MongoServiceConfig) WithDefaults() MongoServiceConfig {
+	enviro := func(name string, defaultValue string) string {
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return defaultValue
+	}
+	enviroNumber := func(name string, defaultValue int) int {
+		value := enviro(name, strconv.Itoa(defaultValue))
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			slog.Warn("Invalid configuration value", "name", name, "value", value)
+			return defaultValue
+		}
+		return number
+	}
+	setString := func(field *string, name string, defaultValue string) {
+		if *field == "" {
+			*field = enviro(name, defaultValue)
+		}
+	}
+
+	setString(&config.URI, "MEDICINE_API_MONGODB_URI", "")
+	setString(&config.URIFile, "MEDICINE_API_MONGODB_URI_FILE", "")
+	setString(&config.ServerHost, "MEDICINE_API_MONGODB_HOST", "localhost")
+	if config.ServerPort == 0 {
+		config.ServerPort = enviroNumber("MEDICINE_API_MONGODB_PORT", 27017)
+	}
+	setString(&config.UserName, "MEDICINE_API_MONGODB_USERNAME", "")
+	setString(&config.UserNameFile, "MEDICINE_API_MONGODB_USERNAME_FILE", "")
+	setString(&config.Password, "MEDICINE_API_MONGODB_PASSWORD", "")
+	setString(&config.PasswordFile, "MEDICINE_API_MONGODB_PASSWORD_FILE", "")
+	setString(&config.AuthSource, "MEDICINE_API_MONGODB_AUTH_SOURCE", "")
+	setString(&config.ReplicaSet, "MEDICINE_API_MONGODB_REPLICA_SET", "")
+	if !config.TLS {
+		config.TLS, _ = strconv.ParseBool(enviro("MEDICINE_API_MONGODB_TLS", "false"))
+	}
+	setString(&config.TLSCAFile, "MEDICINE_API_MONGODB_TLS_CA_FILE", "")
+	setString(&config.TLSCertFile, "MEDICINE_API_MONGODB_TLS_CERT_FILE", "")
+	setString(&config.TLSKeyFile, "MEDICINE_API_MONGODB_TLS_KEY_FILE", "")
+	setString(&config.ReadConcern, "MEDICINE_API_MONGODB_READ_CONCERN", "")
+	setString(&config.WriteConcern, "MEDICINE_API_MONGODB_WRITE_CONCERN", "")
+	if config.MaxPoolSize == 0 {
+		config.MaxPoolSize = uint64(enviroNumber("MEDICINE_API_MONGODB_MAX_POOL_SIZE", 0))
+	}
+	if config.MinPoolSize == 0 {
+		config.MinPoolSize = uint64(enviroNumber("MEDICINE_API_MONGODB_MIN_POOL_SIZE", 0))
+	}
+	setString(&config.AppName, "MEDICINE_API_MONGODB_APP_NAME", "medicine-webapi")
+	setString(&config.DbName, "MEDICINE_API_MONGODB_DATABASE", "ee-medicine")
+	setString(&config.Collection, "MEDICINE_API_MONGODB_COLLECTION", "ambulance")
+	if config.Timeout == 0 {
+		config.Timeout = time.Duration(enviroNumber("MEDICINE_API_MONGODB_TIMEOUT_SECONDS", 10)) * time.Second
+	}
+	return config
+}
+
+// Connect creates new client connected to the configured server
+func (config MongoServiceConfig) Connect(ctx context.Context) (*mongo.Client, error) {
+	ctx, contextCancel := context.WithTimeout(ctx, config.Timeout)
+	defer contextCancel()
+
+	clientOptions, err := config.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return mongo.Connect(ctx, clientOptions)
+}
+
+// clientOptions returns the options of the driver, reading the credential and certificate files
+func (config MongoServiceConfig) clientOptions() (*options.ClientOptions, error) {
+	uri, err := valueOrFile(config.URI, config.URIFile)
+	if err != nil {
+		return nil, err
+	}
+	if uri == "" {
+		uri = config.hostURI()
+	}
+	if uri, err = config.withCredential(uri); err != nil {
+		return nil, err
+	}
+	clientOptions := options.Client().ApplyURI(uri).SetConnectTimeout(10 * time.Second)
+	if err := clientOptions.Validate(); err != nil {
+		// the errors of the driver do not repeat the uri with the credentials
+		return nil, fmt.Errorf("invalid MongoDB connection options: %w", err)
+	}
+
+	if auth := clientOptions.Auth; auth != nil {
+		if config.AuthSource != "" {
+			auth.AuthSource = config.AuthSource
+		}
+		if auth.Username != "" && !auth.PasswordSet && auth.AuthMechanism == "" {
+			return nil, fmt.Errorf(
+				"MongoDB user %v has no password, set MEDICINE_API_MONGODB_PASSWORD or MEDICINE_API_MONGODB_PASSWORD_FILE",
+				auth.Username,
+			)
+		}
+	}
+	if config.ReplicaSet != "" {
+		clientOptions.SetReplicaSet(config.ReplicaSet)
+	}
+	if config.AppName != "" {
+		clientOptions.SetAppName(config.AppName)
+	}
+	if config.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(config.MaxPoolSize)
+	}
+	if config.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(config.MinPoolSize)
+	}
+	if config.PoolMonitor != nil {
+		clientOptions.SetPoolMonitor(config.PoolMonitor)
+	}
+	if config.CommandMonitor != nil {
+		clientOptions.SetMonitor(config.CommandMonitor)
+	}
+	if config.ReadConcern != "" {
+		clientOptions.SetReadConcern(&readconcern.ReadConcern{Level: config.ReadConcern})
+	}
+	if config.WriteConcern != "" {
+		var nodes any = config.WriteConcern
+		if number, err := strconv.Atoi(config.WriteConcern); err == nil {
+			nodes = number
+		}
+		clientOptions.SetWriteConcern(&writeconcern.WriteConcern{W: nodes})
+	}
+	if config.TLS || config.TLSCAFile != "" || config.TLSCertFile != "" {
+		tlsConfig, err := config.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+	return clientOptions, clientOptions.Validate()
+}
+
+// withCredential returns the uri with the configured user and password, which are escaped here,
+// so they can be configured as they are. The user and password of the uri are used if not configured.
+func (config MongoServiceConfig) withCredential(uri string) (string, error) {
+	userName, err := valueOrFile(config.UserName, config.UserNameFile)
+	if err != nil {
+		return "", err
+	}
+	password, err := valueOrFile(config.Password, config.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+	if userName == "" && password == "" {
+		return uri, nil
+	}
+
+	scheme, userInfo, hosts, rest, err := splitURI(uri)
+	if err != nil {
+		return "", err
+	}
+	uriUser, uriPassword, passwordSet := strings.Cut(userInfo, ":")
+	user, userErr := url.PathUnescape(uriUser)
+	uriPassword, passwordErr := url.PathUnescape(uriPassword)
+	if userErr != nil || passwordErr != nil {
+		return "", fmt.Errorf("invalid MongoDB URI: user info is not properly escaped")
+	}
+	if userName != "" && userName != user {
+		// the password of the uri belongs to other user
+		user, uriPassword, passwordSet = userName, "", false
+	}
+	if password != "" {
+		uriPassword, passwordSet = password, true
+	}
+	if user == "" {
+		return "", fmt.Errorf("MongoDB password is configured without user name")
+	}
+
+	credential := url.User(user)
+	if passwordSet {
+		credential = url.UserPassword(user, uriPassword)
+	}
+	return scheme + "://" + credential.String() + "@" + hosts + rest, nil
+}
+
+// hostURI returns uri of the configured server, used if there is no uri configured
+func (config MongoServiceConfig) hostURI() string {
+	if config.ServerPort == 0 {
+		return "mongodb://" + config.ServerHost
+	}
+	return "mongodb://" + net.JoinHostPort(config.ServerHost, strconv.Itoa(config.ServerPort))
+}
+
+func (config MongoServiceConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSCAFile != "" {
+		certificates, err := os.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read MongoDB CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(certificates) {
+			return nil, fmt.Errorf("no certificates found in MongoDB CA file %v", config.TLSCAFile)
+		}
+	}
+	if config.TLSCertFile != "" {
+		keyFile := config.TLSKeyFile
+		if keyFile == "" {
+			keyFile = config.TLSCertFile
+		}
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load MongoDB client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// String describes the connection without the secrets
+func (config MongoServiceConfig) String() string {
+	target := config.hostURI()
+	switch {
+	case config.URI != "":
+		target = redactURI(config.URI)
+	case config.URIFile != "":
+		target = "uri from " + config.URIFile
+	}
+	user := config.UserName
+	if user == "" && config.UserNameFile != "" {
+		user = "from " + config.UserNameFile
+	}
+
+	description := fmt.Sprintf("%v/%v/%v", target, config.DbName, config.Collection)
+	var details []string
+	if user != "" {
+		details = append(details, "user "+user)
+	}
+	if config.AuthSource != "" {
+		details = append(details, "auth source "+config.AuthSource)
+	}
+	if config.ReplicaSet != "" {
+		details = append(details, "replica set "+config.ReplicaSet)
+	}
+	if config.TLS || config.TLSCAFile != "" || config.TLSCertFile != "" {
+		details = append(details, "tls")
+	}
+	if len(details) > 0 {
+		description += " (" + strings.Join(details, ", ") + ")"
+	}
+	return description
+}
+
+// redactURI returns the scheme, user and hosts of the uri. The password and the options,
+// which may contain other secrets, are left out.
+func redactURI(uri string) string {
+	scheme, userInfo, hosts, _, err := splitURI(uri)
+	if err != nil {
+		return "invalid uri"
+	}
+	if user, _, hasPassword := strings.Cut(userInfo, ":"); hasPassword {
+		hosts = user + ":xxxxx@" + hosts
+	} else if user != "" {
+		hosts = user + "@" + hosts
+	}
+	return scheme + "://" + hosts
+}
+
+// splitURI splits the uri into the scheme, the user info, the hosts and the rest with the database and the options
+func splitURI(uri string) (scheme string, userInfo string, hosts string, rest string, err error) {
+	scheme, remainder, found := strings.Cut(uri, "://")
+	if !found {
+		return "", "", "", "", fmt.Errorf("invalid MongoDB URI: missing scheme")
+	}
+	end := strings.IndexAny(remainder, "/?")
+	if end < 0 {
+		end = len(remainder)
+	}
+	hosts, rest = remainder[:end], remainder[end:]
+	if at := strings.LastIndex(hosts, "@"); at >= 0 {
+		userInfo, hosts = hosts[:at], hosts[at+1:]
+	}
+	return scheme, userInfo, hosts, rest, nil
+}
+
+// valueOrFile returns the value or, if it is empty, the content of the file without the trailing new line
+func valueOrFile(value string, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read MongoDB credential file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
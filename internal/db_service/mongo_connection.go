@@ -0,0 +1,111 @@
+package db_service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ConnectRetry configures waiting for the database when connecting. The delay between the pings
+// doubles after each failure up to MaxDelay. Attempts 0 means to retry until the context is done.
+type ConnectRetry struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Attempts     int
+}
+
+// WithDefaults returns copy of the retry with the missing values, the number of attempts is taken
+// from MEDICINE_API_MONGODB_CONNECT_ATTEMPTS
+func (retry ConnectRetry) WithDefaults() ConnectRetry {
+	if retry.InitialDelay == 0 {
+		retry.InitialDelay = time.Second
+	}
+	if retry.MaxDelay == 0 {
+		retry.MaxDelay = 30 * time.Second
+	}
+	if retry.Attempts == 0 {
+		retry.Attempts = 10
+		if value := os.Getenv("MEDICINE_API_MONGODB_CONNECT_ATTEMPTS"); value != "" {
+			if attempts, err := strconv.Atoi(value); err == nil && attempts >= 0 {
+				retry.Attempts = attempts
+			} else {
+				slog.Warn("Invalid MEDICINE_API_MONGODB_CONNECT_ATTEMPTS value", "value", value)
+			}
+		}
+	}
+	return retry
+}
+
+// MongoConnection owns single client, its connection pool is shared by all the collection
+// services of the connection
+type MongoConnection struct {
+	config MongoServiceConfig
+	client *mongo.Client
+}
+
+// ConnectMongo connects to the configured server and waits until it responds to ping
+func ConnectMongo(ctx context.Context, config MongoServiceConfig, retry ConnectRetry) (*MongoConnection, error) {
+	config = config.WithDefaults()
+	client, err := config.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connection := &MongoConnection{config: config, client: client}
+
+	delay := retry.InitialDelay
+	for attempt := 1; ; attempt++ {
+		err := connection.Ping(ctx)
+		if err == nil {
+			slog.InfoContext(ctx, "Connected to MongoDB", "config", config.String())
+			return connection, nil
+		}
+		if retry.Attempts > 0 && attempt >= retry.Attempts {
+			client.Disconnect(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("MongoDB is not available after %v attempts: %w", attempt, err)
+		}
+		slog.WarnContext(ctx, "Cannot connect to MongoDB",
+			"config", config.String(),
+			"attempt", attempt,
+			"retryAfter", delay.String(),
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			client.Disconnect(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("MongoDB is not available: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, max(retry.MaxDelay, retry.InitialDelay))
+	}
+}
+
+// Ping checks the server responds within the configured timeout
+func (c *MongoConnection) Ping(ctx context.Context) error {
+	ctx, contextCancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer contextCancel()
+	return c.client.Ping(ctx, nil)
+}
+
+// Database returns the configured database
+func (c *MongoConnection) Database() *mongo.Database {
+	return c.client.Database(c.config.DbName)
+}
+
+// Disconnect closes the shared client, the services of the connection cannot be used afterwards
+func (c *MongoConnection) Disconnect(ctx context.Context) error {
+	return c.client.Disconnect(ctx)
+}
+
+// NewMongoCollectionService returns service of the collection in the database of the connection.
+// The service uses the client of the connection, its Disconnect leaves the client connected.
+func NewMongoCollectionService[DocType interface{}](connection *MongoConnection, collection string) DbService[DocType] {
+	svc := &mongoSvc[DocType]{shared: connection}
+	svc.MongoServiceConfig = connection.config
+	svc.Collection = collection
+	return svc
+}
@@ -0,0 +1,117 @@
+package db_service_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/db_service/conformance"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoConnectionSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestMongoConnectionSuite(t *testing.T) {
+	suite.Run(t, new(MongoConnectionSuite))
+}
+
+func (suite *MongoConnectionSuite) SetupTest() {
+	suite.ctx = context.Background()
+	// the stand-in does not authenticate
+	suite.T().Setenv("MEDICINE_API_MONGODB_USERNAME", "")
+}
+
+// config returns config of the server listening on the address
+func (suite *MongoConnectionSuite) config(address string) db_service.MongoServiceConfig {
+	host, port, err := net.SplitHostPort(address)
+	suite.Require().NoError(err)
+	config := db_service.MongoServiceConfig{ServerHost: host, Timeout: time.Second}
+	config.ServerPort, err = net.LookupPort("tcp", port)
+	suite.Require().NoError(err)
+	return config
+}
+
+// unusedAddress returns local address nobody listens on
+func (suite *MongoConnectionSuite) unusedAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	address := listener.Addr().String()
+	suite.Require().NoError(listener.Close())
+	return address
+}
+
+func (suite *MongoConnectionSuite) Test_CollectionServices_ShareClient() {
+	// ARRANGE
+	server := startMongoStandIn(suite.T())
+	connection, err := db_service.ConnectMongo(suite.ctx, suite.config(server.listener.Addr().String()), db_service.ConnectRetry{Attempts: 1})
+	suite.Require().NoError(err)
+	first := db_service.NewMongoCollectionService[conformance.Document](connection, "first")
+	second := db_service.NewMongoCollectionService[conformance.Document](connection, "second")
+
+	// ACT
+	firstErr := first.CreateDocument(suite.ctx, "a", &conformance.Document{Id: "a", Name: "First"})
+	secondErr := second.CreateDocument(suite.ctx, "a", &conformance.Document{Id: "a", Name: "Second"})
+	disconnectErr := first.Disconnect(suite.ctx)
+	afterServiceDisconnect, findErr := second.FindDocument(suite.ctx, "a")
+	connectionDisconnectErr := connection.Disconnect(suite.ctx)
+	_, afterConnectionDisconnectErr := first.FindDocument(suite.ctx, "a")
+
+	// ASSERT
+	suite.Require().NoError(firstErr)
+	suite.Require().NoError(secondErr, "the collections are separate")
+	suite.NoError(disconnectErr)
+	suite.Require().NoError(findErr, "service disconnect leaves the shared client connected")
+	suite.Equal("Second", afterServiceDisconnect.Name)
+	suite.NoError(connectionDisconnectErr)
+	suite.ErrorIs(afterConnectionDisconnectErr, mongo.ErrClientDisconnected)
+}
+
+func (suite *MongoConnectionSuite) Test_ConnectMongo_WaitsForServer() {
+	// ARRANGE
+	address := suite.unusedAddress()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		listener, err := net.Listen("tcp", address)
+		if suite.NoError(err) {
+			serveMongoStandIn(suite.T(), listener)
+		}
+	}()
+	ctx, contextCancel := context.WithTimeout(suite.ctx, 20*time.Second)
+	defer contextCancel()
+
+	// ACT
+	connection, err := db_service.ConnectMongo(ctx, suite.config(address), db_service.ConnectRetry{
+		InitialDelay: 50 * time.Millisecond,
+		MaxDelay:     200 * time.Millisecond,
+	})
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.NoError(connection.Ping(suite.ctx))
+	suite.NoError(connection.Disconnect(suite.ctx))
+}
+
+func (suite *MongoConnectionSuite) Test_ConnectMongo_GivesUp() {
+	// ARRANGE
+	config := suite.config(suite.unusedAddress())
+	config.Timeout = 100 * time.Millisecond
+
+	// ACT
+	_, attemptsErr := db_service.ConnectMongo(suite.ctx, config, db_service.ConnectRetry{
+		InitialDelay: 10 * time.Millisecond,
+		Attempts:     2,
+	})
+	ctx, contextCancel := context.WithTimeout(suite.ctx, 300*time.Millisecond)
+	defer contextCancel()
+	_, contextErr := db_service.ConnectMongo(ctx, config, db_service.ConnectRetry{InitialDelay: 10 * time.Millisecond})
+
+	// ASSERT
+	suite.ErrorContains(attemptsErr, "not available after 2 attempts")
+	suite.ErrorIs(contextErr, context.DeadlineExceeded)
+}
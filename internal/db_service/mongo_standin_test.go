@@ -0,0 +1,848 @@
+package db_service_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+
+	duplicateKeyCode = 11000
+)
+
+// mongoStandIn is an in-process server speaking enough of the mongo wire protocol for mongoSvc
+// and the driver handshake. The documents are evaluated with the query code of the memory
+// storage, so the stand-in verifies the commands sent by mongoSvc rather than mongo itself.
+type mongoStandIn struct {
+	listener    net.Listener
+	lock        sync.Mutex
+	collections map[string]*standInCollection
+	connections map[net.Conn]bool
+}
+
+type standInCollection struct {
+	documents []bson.M
+	// unique lists the fields with unique single field index
+	unique []string
+}
+
+func startMongoStandIn(t *testing.T) *mongoStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return serveMongoStandIn(t, listener)
+}
+
+// serveMongoStandIn starts the stand-in on the listener, it is stopped when the test ends
+func serveMongoStandIn(t *testing.T, listener net.Listener) *mongoStandIn {
+	server := &mongoStandIn{
+		listener:    listener,
+		collections: map[string]*standInCollection{},
+		connections: map[net.Conn]bool{},
+	}
+	go server.serve()
+	t.Cleanup(server.close)
+	return server
+}
+
+func (s *mongoStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *mongoStandIn) close() {
+	s.listener.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for connection := range s.connections {
+		connection.Close()
+	}
+}
+
+func (s *mongoStandIn) serve() {
+	for {
+		connection, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.connections[connection] = true
+		s.lock.Unlock()
+		go s.handle(connection)
+	}
+}
+
+// handle answers the messages of the connection until it is closed
+func (s *mongoStandIn) handle(connection net.Conn) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.connections, connection)
+		s.lock.Unlock()
+		connection.Close()
+	}()
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(connection, header); err != nil {
+			return
+		}
+		length := binary.LittleEndian.Uint32(header)
+		requestId := binary.LittleEndian.Uint32(header[4:])
+		opCode := binary.LittleEndian.Uint32(header[12:])
+		if length < 16 {
+			return
+		}
+		body := make([]byte, length-16)
+		if _, err := io.ReadFull(connection, body); err != nil {
+			return
+		}
+
+		var replyCode uint32
+		var reply []byte
+		var err error
+		switch opCode {
+		case opQuery:
+			replyCode, reply, err = s.handleQuery(body)
+		case opMsg:
+			replyCode, reply, err = s.handleMsg(body)
+		default:
+			err = fmt.Errorf("unsupported op code %v", opCode)
+		}
+		if err != nil {
+			return
+		}
+		if reply == nil {
+			continue
+		}
+
+		message := binary.LittleEndian.AppendUint32(nil, uint32(16+len(reply)))
+		message = binary.LittleEndian.AppendUint32(message, requestId)
+		message = binary.LittleEndian.AppendUint32(message, requestId)
+		message = binary.LittleEndian.AppendUint32(message, replyCode)
+		if _, err := connection.Write(append(message, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// handleQuery answers the legacy OP_QUERY, used by the driver only for the handshake
+func (s *mongoStandIn) handleQuery(body []byte) (uint32, []byte, error) {
+	end := slices.Index(body[4:], 0)
+	if end < 0 {
+		return 0, nil, fmt.Errorf("malformed query")
+	}
+	var command bson.D
+	if err := bson.Unmarshal(body[4+end+1+8:], &command); err != nil {
+		return 0, nil, err
+	}
+	document, err := bson.Marshal(s.execute(command))
+	if err != nil {
+		return 0, nil, err
+	}
+	reply := binary.LittleEndian.AppendUint32(nil, 0)  // response flags
+	reply = binary.LittleEndian.AppendUint64(reply, 0) // cursor id
+	reply = binary.LittleEndian.AppendUint32(reply, 0) // starting from
+	reply = binary.LittleEndian.AppendUint32(reply, 1) // number returned
+	return opReply, append(reply, document...), nil
+}
+
+func (s *mongoStandIn) handleMsg(body []byte) (uint32, []byte, error) {
+	const checksumPresent, moreToCome = 1, 2
+	flags := binary.LittleEndian.Uint32(body)
+	end := len(body)
+	if flags&checksumPresent != 0 {
+		end -= 4
+	}
+
+	var command bson.D
+	var sequences bson.D
+	for position := 4; position < end; {
+		kind := body[position]
+		size := int(binary.LittleEndian.Uint32(body[position+1:]))
+		section := body[position+1 : position+1+size]
+		position += 1 + size
+		switch kind {
+		case 0:
+			if err := bson.Unmarshal(section, &command); err != nil {
+				return 0, nil, err
+			}
+		case 1:
+			nameEnd := 4 + slices.Index(section[4:], 0)
+			documents := bson.A{}
+			for rest := section[nameEnd+1:]; len(rest) > 0; {
+				documentSize := binary.LittleEndian.Uint32(rest)
+				var document bson.D
+				if err := bson.Unmarshal(rest[:documentSize], &document); err != nil {
+					return 0, nil, err
+				}
+				documents = append(documents, document)
+				rest = rest[documentSize:]
+			}
+			sequences = append(sequences, bson.E{Key: string(section[4:nameEnd]), Value: documents})
+		default:
+			return 0, nil, fmt.Errorf("unsupported section kind %v", kind)
+		}
+	}
+
+	result := s.execute(append(command, sequences...))
+	if flags&moreToCome != 0 {
+		return 0, nil, nil
+	}
+	document, err := bson.Marshal(result)
+	if err != nil {
+		return 0, nil, err
+	}
+	reply := binary.LittleEndian.AppendUint32(nil, 0) // flags
+	reply = append(reply, 0)                          // single document section
+	return opMsg, append(reply, document...), nil
+}
+
+// execute runs the command, commands are executed one at a time like on a single node
+func (s *mongoStandIn) execute(command bson.D) bson.D {
+	if len(command) == 0 {
+		return commandError(fmt.Errorf("empty command"))
+	}
+	name := command[0].Key
+	collectionName, _ := command[0].Value.(string)
+	databaseName, _ := lookup(command, "$db").(string)
+	namespace := databaseName + "." + collectionName
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var result bson.D
+	var err error
+	switch strings.ToLower(name) {
+	case "hello", "ismaster":
+		result = bson.D{
+			{Key: "helloOk", Value: true},
+			{Key: "ismaster", Value: true},
+			{Key: "isWritablePrimary", Value: true},
+			{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+			{Key: "maxMessageSizeBytes", Value: int32(48_000_000)},
+			{Key: "maxWriteBatchSize", Value: int32(100_000)},
+			{Key: "localTime", Value: time.Now()},
+			{Key: "minWireVersion", Value: int32(0)},
+			{Key: "maxWireVersion", Value: int32(17)},
+		}
+	case "ping", "endsessions":
+	case "find":
+		result, err = s.find(namespace, command)
+	case "aggregate":
+		result, err = s.aggregate(namespace, command)
+	case "insert":
+		result, err = s.insert(namespace, command)
+	case "update":
+		result, err = s.update(namespace, command)
+	case "delete":
+		result, err = s.delete(namespace, command)
+	case "createindexes":
+		err = s.createIndexes(namespace, command)
+	case "drop":
+		delete(s.collections, namespace)
+	default:
+		err = fmt.Errorf("no such command: '%v'", name)
+	}
+	if err != nil {
+		return commandError(err)
+	}
+	return append(result, bson.E{Key: "ok", Value: 1.0})
+}
+
+func commandError(err error) bson.D {
+	return bson.D{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: err.Error()},
+		{Key: "code", Value: int32(2)},
+		{Key: "codeName", Value: "BadValue"},
+	}
+}
+
+func (s *mongoStandIn) collection(namespace string) *standInCollection {
+	collection, ok := s.collections[namespace]
+	if !ok {
+		collection = &standInCollection{}
+		s.collections[namespace] = collection
+	}
+	return collection
+}
+
+func (s *mongoStandIn) find(namespace string, command bson.D) (bson.D, error) {
+	documents, err := s.collection(namespace).matching(lookup(command, "filter"))
+	if err != nil {
+		return nil, err
+	}
+	var sort []db_service.SortField
+	if sortDocument, ok := lookup(command, "sort").(bson.D); ok {
+		for _, field := range sortDocument {
+			sort = append(sort, db_service.SortField{Field: field.Key, Descending: asInt64(field.Value) < 0})
+		}
+	}
+	db_service.SortDocuments(documents, sort)
+	documents = documents[min(int64(len(documents)), asInt64(lookup(command, "skip"))):]
+	// negative limit of the legacy single batch find
+	if limit := asInt64(lookup(command, "limit")); limit != 0 && int64(len(documents)) > max(limit, -limit) {
+		documents = documents[:max(limit, -limit)]
+	}
+	var projection []string
+	if projectionDocument, ok := lookup(command, "projection").(bson.D); ok {
+		for _, field := range projectionDocument {
+			projection = append(projection, field.Key)
+		}
+	}
+
+	batch := bson.A{}
+	for _, document := range documents {
+		batch = append(batch, db_service.ProjectDocument(document, projection))
+	}
+	return cursorResult(namespace, batch), nil
+}
+
+// aggregate supports the pipelines of the counting queries
+func (s *mongoStandIn) aggregate(namespace string, command bson.D) (bson.D, error) {
+	documents := slices.Clone(s.collection(namespace).documents)
+	pipeline, _ := lookup(command, "pipeline").(bson.A)
+	for _, item := range pipeline {
+		stage, ok := item.(bson.D)
+		if !ok || len(stage) != 1 {
+			return nil, fmt.Errorf("malformed pipeline stage %v", item)
+		}
+		switch stage[0].Key {
+		case "$match":
+			filter, err := translateFilter(stage[0].Value)
+			if err != nil {
+				return nil, err
+			}
+			documents, err = filterDocuments(documents, filter)
+			if err != nil {
+				return nil, err
+			}
+		case "$skip":
+			documents = documents[min(int64(len(documents)), asInt64(stage[0].Value)):]
+		case "$limit":
+			documents = documents[:min(int64(len(documents)), asInt64(stage[0].Value))]
+		case "$group":
+			group, _ := stage[0].Value.(bson.D)
+			if len(documents) == 0 {
+				continue
+			}
+			// only the counts are supported, that is {$sum: 1} accumulators
+			result := bson.M{}
+			for _, field := range group {
+				result[field.Key] = field.Value
+				if field.Key != "_id" {
+					result[field.Key] = int32(len(documents))
+				}
+			}
+			documents = []bson.M{result}
+		default:
+			return nil, fmt.Errorf("unsupported pipeline stage %v", stage[0].Key)
+		}
+	}
+	batch := bson.A{}
+	for _, document := range documents {
+		batch = append(batch, document)
+	}
+	return cursorResult(namespace, batch), nil
+}
+
+func cursorResult(namespace string, batch bson.A) bson.D {
+	return bson.D{{Key: "cursor", Value: bson.D{
+		{Key: "firstBatch", Value: batch},
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: namespace},
+	}}}
+}
+
+func (s *mongoStandIn) insert(namespace string, command bson.D) (bson.D, error) {
+	collection := s.collection(namespace)
+	documents, _ := lookup(command, "documents").(bson.A)
+	inserted := 0
+	writeErrors := bson.A{}
+	for i, item := range documents {
+		document, err := storedDocument(item)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate := collection.duplicateKey(document, -1); duplicate != nil {
+			writeErrors = append(writeErrors, writeError(i, duplicate))
+			break
+		}
+		collection.documents = append(collection.documents, document)
+		inserted++
+	}
+	return writeResult(inserted, writeErrors, nil), nil
+}
+
+func (s *mongoStandIn) update(namespace string, command bson.D) (bson.D, error) {
+	collection := s.collection(namespace)
+	updates, _ := lookup(command, "updates").(bson.A)
+	matched := 0
+	writeErrors := bson.A{}
+	for i, item := range updates {
+		statement, _ := item.(bson.D)
+		query, _ := lookup(statement, "q").(bson.D)
+		change, _ := lookup(statement, "u").(bson.D)
+		multi, _ := lookup(statement, "multi").(bool)
+		arrayFilters, _ := lookup(statement, "arrayFilters").(bson.A)
+		filter, err := translateFilter(query)
+		if err != nil {
+			return nil, err
+		}
+		for j, document := range collection.documents {
+			matches, err := db_service.MatchesFilter(document, filter)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+			updated, err := applyUpdate(document, query, change, arrayFilters)
+			if err != nil {
+				return nil, err
+			}
+			if duplicate := collection.duplicateKey(updated, j); duplicate != nil {
+				writeErrors = append(writeErrors, writeError(i, duplicate))
+				break
+			}
+			collection.documents[j] = updated
+			matched++
+			if !multi {
+				break
+			}
+		}
+	}
+	return writeResult(matched, writeErrors, bson.D{{Key: "nModified", Value: int32(matched)}}), nil
+}
+
+func (s *mongoStandIn) delete(namespace string, command bson.D) (bson.D, error) {
+	collection := s.collection(namespace)
+	deletes, _ := lookup(command, "deletes").(bson.A)
+	deleted := 0
+	for _, item := range deletes {
+		statement, _ := item.(bson.D)
+		filter, err := translateFilter(lookup(statement, "q"))
+		if err != nil {
+			return nil, err
+		}
+		limit := asInt64(lookup(statement, "limit"))
+		var kept []bson.M
+		for _, document := range collection.documents {
+			matches, err := db_service.MatchesFilter(document, filter)
+			if err != nil {
+				return nil, err
+			}
+			if matches && (limit == 0 || int64(deleted) < limit) {
+				deleted++
+				continue
+			}
+			kept = append(kept, document)
+		}
+		collection.documents = kept
+	}
+	return writeResult(deleted, bson.A{}, nil), nil
+}
+
+func (s *mongoStandIn) createIndexes(namespace string, command bson.D) error {
+	collection := s.collection(namespace)
+	indexes, _ := lookup(command, "indexes").(bson.A)
+	for _, item := range indexes {
+		index, _ := item.(bson.D)
+		keys, _ := lookup(index, "key").(bson.D)
+		if unique, _ := lookup(index, "unique").(bool); !unique {
+			continue
+		}
+		if len(keys) != 1 {
+			return fmt.Errorf("only single field unique indexes are supported")
+		}
+		if !slices.Contains(collection.unique, keys[0].Key) {
+			collection.unique = append(collection.unique, keys[0].Key)
+		}
+	}
+	return nil
+}
+
+func writeError(index int, duplicate *bson.E) bson.D {
+	return bson.D{
+		{Key: "index", Value: int32(index)},
+		{Key: "code", Value: int32(duplicateKeyCode)},
+		{Key: "errmsg", Value: fmt.Sprintf("E11000 duplicate key error index: %v_1 dup key: { %v: %v }", duplicate.Key, duplicate.Key, duplicate.Value)},
+	}
+}
+
+func writeResult(count int, writeErrors bson.A, fields bson.D) bson.D {
+	result := append(bson.D{{Key: "n", Value: int32(count)}}, fields...)
+	if len(writeErrors) > 0 {
+		result = append(result, bson.E{Key: "writeErrors", Value: writeErrors})
+	}
+	return result
+}
+
+// matching returns the documents matching the mongo query document
+func (c *standInCollection) matching(query any) ([]bson.M, error) {
+	filter, err := translateFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	return filterDocuments(c.documents, filter)
+}
+
+func filterDocuments(documents []bson.M, filter db_service.Filter) ([]bson.M, error) {
+	matching := []bson.M{}
+	for _, document := range documents {
+		matches, err := db_service.MatchesFilter(document, filter)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			matching = append(matching, document)
+		}
+	}
+	return matching, nil
+}
+
+// duplicateKey returns the unique field the document shares with other document than the skipped one
+func (c *standInCollection) duplicateKey(document bson.M, skip int) *bson.E {
+	for _, field := range c.unique {
+		value := document[field]
+		for i, other := range c.documents {
+			if i == skip {
+				continue
+			}
+			if matches, _ := db_service.MatchesFilter(other, db_service.Eq(field, value)); matches {
+				return &bson.E{Key: field, Value: value}
+			}
+		}
+	}
+	return nil
+}
+
+// translateFilter converts the mongo query document into the filter of the db_service
+func translateFilter(query any) (db_service.Filter, error) {
+	if query == nil {
+		return db_service.Filter{}, nil
+	}
+	document, ok := query.(bson.D)
+	if !ok {
+		return db_service.Filter{}, fmt.Errorf("query must be a document, got %T", query)
+	}
+	filters := []db_service.Filter{}
+	for _, field := range document {
+		switch field.Key {
+		case "$and", "$or", "$nor":
+			items, _ := field.Value.(bson.A)
+			var nested []db_service.Filter
+			for _, item := range items {
+				filter, err := translateFilter(item)
+				if err != nil {
+					return db_service.Filter{}, err
+				}
+				nested = append(nested, filter)
+			}
+			switch field.Key {
+			case "$and":
+				filters = append(filters, db_service.And(nested...))
+			case "$or":
+				filters = append(filters, db_service.Or(nested...))
+			default:
+				filters = append(filters, db_service.Not(db_service.Or(nested...)))
+			}
+			continue
+		}
+
+		conditions, ok := field.Value.(bson.D)
+		if !ok || len(conditions) == 0 || !strings.HasPrefix(conditions[0].Key, "$") {
+			filters = append(filters, db_service.Eq(field.Key, field.Value))
+			continue
+		}
+		for _, condition := range conditions {
+			if condition.Key == "$elemMatch" {
+				nested, err := translateFilter(condition.Value)
+				if err != nil {
+					return db_service.Filter{}, err
+				}
+				filters = append(filters, db_service.ElemMatch(field.Key, nested))
+				continue
+			}
+			value := condition.Value
+			if items, ok := value.(bson.A); ok {
+				value = []any(items)
+			}
+			filters = append(filters, db_service.Filter{
+				Op:    db_service.Operator(strings.TrimPrefix(condition.Key, "$")),
+				Field: field.Key,
+				Value: value,
+			})
+		}
+	}
+	filter := db_service.And(filters...)
+	if len(filters) == 1 {
+		filter = filters[0]
+	}
+	return filter, filter.Validate()
+}
+
+// applyUpdate returns copy of the document with the update operators applied or the replacement document
+func applyUpdate(document bson.M, query bson.D, update bson.D, arrayFilters bson.A) (bson.M, error) {
+	if len(update) == 0 || !strings.HasPrefix(update[0].Key, "$") {
+		replacement, err := storedDocument(update)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := replacement["_id"]; !ok {
+			replacement["_id"] = document["_id"]
+		}
+		return replacement, nil
+	}
+
+	updated, err := storedDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	for _, operation := range update {
+		fields, ok := operation.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%v requires document", operation.Key)
+		}
+		for _, field := range fields {
+			parent, key, err := resolvePath(updated, field.Key, query, arrayFilters)
+			if err != nil {
+				return nil, err
+			}
+			value, err := storedValue(field.Value)
+			if err != nil {
+				return nil, err
+			}
+			switch operation.Key {
+			case "$set":
+				parent[key] = value
+			case "$inc":
+				sum, err := addNumbers(parent[key], value)
+				if err != nil {
+					return nil, err
+				}
+				parent[key] = sum
+			case "$push":
+				current, exists := parent[key]
+				array, ok := current.(bson.A)
+				if exists && !ok {
+					return nil, fmt.Errorf("the field '%v' must be an array but is of type %T", field.Key, current)
+				}
+				if each, ok := field.Value.(bson.D); ok && len(each) > 0 && each[0].Key == "$each" {
+					items, _ := value.(bson.M)["$each"].(bson.A)
+					parent[key] = append(array, items...)
+					continue
+				}
+				parent[key] = append(array, value)
+			case "$pull":
+				array, ok := parent[key].(bson.A)
+				if !ok {
+					continue
+				}
+				condition, err := translateFilter(field.Value)
+				if err != nil {
+					return nil, err
+				}
+				kept := bson.A{}
+				for _, item := range array {
+					element, ok := item.(bson.M)
+					if ok {
+						if matches, err := db_service.MatchesFilter(element, condition); err != nil {
+							return nil, err
+						} else if matches {
+							continue
+						}
+					}
+					kept = append(kept, item)
+				}
+				parent[key] = kept
+			default:
+				return nil, fmt.Errorf("unsupported update operator %v", operation.Key)
+			}
+		}
+	}
+	return updated, nil
+}
+
+// resolvePath returns the document containing the last field of the dotted path, the positional
+// operator $ is resolved to the first array element matched by the query and the filtered positional
+// operator $[identifier] to the first element matched by the array filter of the identifier
+func resolvePath(document bson.M, path string, query bson.D, arrayFilters bson.A) (bson.M, string, error) {
+	segments := strings.Split(path, ".")
+	var current any = document
+	for i, segment := range segments[:len(segments)-1] {
+		switch value := current.(type) {
+		case bson.M:
+			child, ok := value[segment]
+			if !ok {
+				child = bson.M{}
+				value[segment] = child
+			}
+			current = child
+		case bson.A:
+			index, err := strconv.Atoi(segment)
+			if segment == "$" {
+				index, err = positionalIndex(value, strings.Join(segments[:i], "."), query)
+			} else if identifier, ok := strings.CutPrefix(segment, "$["); ok {
+				index, err = filteredIndex(value, strings.TrimSuffix(identifier, "]"), arrayFilters)
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			if index < 0 || index >= len(value) {
+				return nil, "", fmt.Errorf("cannot resolve %v", path)
+			}
+			current = value[index]
+		default:
+			return nil, "", fmt.Errorf("cannot create field %v in %T", segment, current)
+		}
+	}
+	parent, ok := current.(bson.M)
+	if !ok {
+		return nil, "", fmt.Errorf("cannot resolve %v", path)
+	}
+	return parent, segments[len(segments)-1], nil
+}
+
+func positionalIndex(array bson.A, arrayPath string, query bson.D) (int, error) {
+	conditions := bson.D{}
+	for _, field := range query {
+		if nested, ok := strings.CutPrefix(field.Key, arrayPath+"."); ok {
+			conditions = append(conditions, bson.E{Key: nested, Value: field.Value})
+		}
+	}
+	if len(conditions) == 0 {
+		return -1, fmt.Errorf("the positional operator did not find the match needed from the query")
+	}
+	filter, err := translateFilter(conditions)
+	if err != nil {
+		return -1, err
+	}
+	for i, item := range array {
+		if element, ok := item.(bson.M); ok {
+			if matches, err := db_service.MatchesFilter(element, filter); err != nil || matches {
+				return i, err
+			}
+		}
+	}
+	return -1, fmt.Errorf("the positional operator did not find the match needed from the query")
+}
+
+func filteredIndex(array bson.A, identifier string, arrayFilters bson.A) (int, error) {
+	conditions := bson.D{}
+	for _, item := range arrayFilters {
+		arrayFilter, _ := item.(bson.D)
+		for _, field := range arrayFilter {
+			if nested, ok := strings.CutPrefix(field.Key, identifier+"."); ok {
+				conditions = append(conditions, bson.E{Key: nested, Value: field.Value})
+			}
+		}
+	}
+	if len(conditions) == 0 {
+		return -1, fmt.Errorf("no array filter found for identifier '%v'", identifier)
+	}
+	filter, err := translateFilter(conditions)
+	if err != nil {
+		return -1, err
+	}
+	for i, item := range array {
+		if element, ok := item.(bson.M); ok {
+			if matches, err := db_service.MatchesFilter(element, filter); err != nil || matches {
+				return i, err
+			}
+		}
+	}
+	return -1, fmt.Errorf("no element matches the array filter of identifier '%v'", identifier)
+}
+
+// addNumbers adds the numbers keeping the narrowest type, like $inc does
+func addNumbers(current any, delta any) (any, error) {
+	if current == nil {
+		return delta, nil
+	}
+	switch {
+	case isFloat(current) || isFloat(delta):
+		return asFloat64(current) + asFloat64(delta), nil
+	case isInteger(current) && isInteger(delta):
+		sum := asInt64(current) + asInt64(delta)
+		_, currentInt32 := current.(int32)
+		_, deltaInt32 := delta.(int32)
+		if currentInt32 && deltaInt32 && sum >= math.MinInt32 && sum <= math.MaxInt32 {
+			return int32(sum), nil
+		}
+		return sum, nil
+	default:
+		return nil, fmt.Errorf("cannot apply $inc to %T", current)
+	}
+}
+
+func isFloat(value any) bool {
+	_, ok := value.(float64)
+	return ok
+}
+
+func isInteger(value any) bool {
+	switch value.(type) {
+	case int32, int64:
+		return true
+	}
+	return false
+}
+
+func asInt64(value any) int64 {
+	switch value := value.(type) {
+	case int32:
+		return int64(value)
+	case int64:
+		return value
+	case float64:
+		return int64(value)
+	}
+	return 0
+}
+
+func asFloat64(value any) float64 {
+	if value, ok := value.(float64); ok {
+		return value
+	}
+	return float64(asInt64(value))
+}
+
+func lookup(document bson.D, key string) any {
+	for _, field := range document {
+		if field.Key == key {
+			return field.Value
+		}
+	}
+	return nil
+}
+
+// storedDocument converts the document into the form kept by the stand-in
+func storedDocument(document any) (bson.M, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	stored := bson.M{}
+	return stored, bson.Unmarshal(raw, &stored)
+}
+
+func storedValue(value any) (any, error) {
+	document, err := storedDocument(bson.M{"v": value})
+	if err != nil {
+		return nil, err
+	}
+	return document["v"], nil
+}
@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
-	"os"
-	"strconv"
+	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -19,7 +18,15 @@ type DbService[DocType interface{}] interface {
 	CreateDocument(ctx context.Context, id any, document *DocType) error
 	FindDocument(ctx context.Context, id any) (*DocType, error)
 	FindAllDocuments(ctx context.Context) ([]*DocType, error)
+	FindDocuments(ctx context.Context, query Query) ([]*DocType, error)
+	CountDocuments(ctx context.Context, filter Filter) (int64, error)
 	UpdateDocument(ctx context.Context, id any, document *DocType) error
+	PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error
+	PullElement(ctx context.Context, id any, arrayField string, elementId any) error
+	SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error
+	IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error
+	UpdateFields(ctx context.Context, id any, change FieldChange) error
+	UpdateElements(ctx context.Context, id any, changes ...ElementChange) error
 	DeleteDocument(ctx context.Context, id any) error
 	Disconnect(ctx context.Context) error
 }
@@ -27,85 +34,25 @@ type DbService[DocType interface{}] interface {
 var ErrNotFound = fmt.Errorf("document not found")
 var ErrConflict = fmt.Errorf("conflict: document already exists")
 
-type MongoServiceConfig struct {
-	ServerHost string
-	ServerPort int
-	UserName   string
-	Password   string
-	DbName     string
-	Collection string
-	Timeout    time.Duration
-}
-
 type mongoSvc[DocType interface{}] struct {
 	MongoServiceConfig
 	client     atomic.Pointer[mongo.Client]
 	clientLock sync.Mutex
+	// shared is the connection of the collection services, which do not own their client
+	shared *MongoConnection
 }
 
 func NewMongoService[DocType interface{}](config MongoServiceConfig) DbService[DocType] {
-	enviro := func(name string, defaultValue string) string {
-		if value, ok := os.LookupEnv(name); ok {
-			return value
-		}
-		return defaultValue
-	}
-
 	svc := &mongoSvc[DocType]{}
-	svc.MongoServiceConfig = config
-
-	if svc.ServerHost == "" {
-		svc.ServerHost = enviro("MEDICINE_API_MONGODB_HOST", "localhost")
-	}
-
-	if svc.ServerPort == 0 {
-		port := enviro("MEDICINE_API_MONGODB_PORT", "27017")
-		if port, err := strconv.Atoi(port); err == nil {
-			svc.ServerPort = port
-		} else {
-			log.Printf("Invalid port value: %v", port)
-			svc.ServerPort = 27017
-		}
-	}
-
-	if svc.UserName == "" {
-		svc.UserName = enviro("MEDICINE_API_MONGODB_USERNAME", "root")
-	}
-
-	if svc.Password == "" {
-		svc.Password = enviro("MEDICINE_API_MONGODB_PASSWORD", "neUhaDnes")
-	}
-
-	if svc.DbName == "" {
-		svc.DbName = enviro("MEDICINE_API_MONGODB_DATABASE", "ee-medicine")
-	}
-
-	if svc.Collection == "" {
-		svc.Collection = enviro("MEDICINE_API_MONGODB_COLLECTION", "ambulance")
-	}
-
-	if svc.Timeout == 0 {
-		seconds := enviro("MEDICINE_API_MONGODB_TIMEOUT_SECONDS", "10")
-		if seconds, err := strconv.Atoi(seconds); err == nil {
-			svc.Timeout = time.Duration(seconds) * time.Second
-		} else {
-			log.Printf("Invalid timeout value: %v", seconds)
-			svc.Timeout = 10 * time.Second
-		}
-	}
-
-	log.Printf(
-		"MongoDB config: //%v@%v:%v/%v/%v",
-		svc.UserName,
-		svc.ServerHost,
-		svc.ServerPort,
-		svc.DbName,
-		svc.Collection,
-	)
+	svc.MongoServiceConfig = config.WithDefaults()
+	slog.Debug("MongoDB service", "config", svc.MongoServiceConfig.String())
 	return svc
 }
 
 func (m *mongoSvc[DocType]) connect(ctx context.Context) (*mongo.Client, error) {
+	if m.shared != nil {
+		return m.shared.client, nil
+	}
 	// optimistic check
 	client := m.client.Load()
 	if client != nil {
@@ -120,17 +67,7 @@ func (m *mongoSvc[DocType]) connect(ctx context.Context) (*mongo.Client, error)
 		return client, nil
 	}
 
-	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
-	defer contextCancel()
-
-	var uri = fmt.Sprintf("mongodb://%v:%v", m.ServerHost, m.ServerPort)
-	log.Printf("Using URI: %s", uri)
-
-	if len(m.UserName) != 0 {
-		uri = fmt.Sprintf("mongodb://%v:%v@%v:%v", m.UserName, m.Password, m.ServerHost, m.ServerPort)
-	}
-
-	if client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetConnectTimeout(10*time.Second)); err != nil {
+	if client, err := m.MongoServiceConfig.Connect(ctx); err != nil {
 		return nil, err
 	} else {
 		m.client.Store(client)
@@ -139,6 +76,10 @@ func (m *mongoSvc[DocType]) connect(ctx context.Context) (*mongo.Client, error)
 }
 
 func (m *mongoSvc[DocType]) Disconnect(ctx context.Context) error {
+	if m.shared != nil {
+		// the shared client is disconnected by its connection
+		return nil
+	}
 	client := m.client.Load()
 
 	if client != nil {
@@ -176,6 +117,10 @@ func (m *mongoSvc[DocType]) CreateDocument(ctx context.Context, id any, document
 	}
 
 	_, err = collection.InsertOne(ctx, document)
+	if mongo.IsDuplicateKeyError(err) {
+		// concurrent create of the same document, rejected by the unique id index
+		return ErrConflict
+	}
 	return err
 }
 
@@ -234,6 +179,106 @@ func (m *mongoSvc[DocType]) FindAllDocuments(ctx context.Context) ([]*DocType, e
 	return documents, nil
 }
 
+func (m *mongoSvc[DocType]) FindDocuments(ctx context.Context, query Query) ([]*DocType, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db := client.Database(m.DbName)
+	collection := db.Collection(m.Collection)
+
+	findOptions := options.Find()
+	if len(query.Projection) > 0 {
+		projection := bson.D{}
+		for _, field := range query.Projection {
+			projection = append(projection, bson.E{Key: field, Value: 1})
+		}
+		findOptions.SetProjection(projection)
+	}
+	if len(query.Sort) > 0 {
+		sort := bson.D{}
+		for _, field := range query.Sort {
+			direction := 1
+			if field.Descending {
+				direction = -1
+			}
+			sort = append(sort, bson.E{Key: field.Field, Value: direction})
+		}
+		findOptions.SetSort(sort)
+	}
+	if query.Skip > 0 {
+		findOptions.SetSkip(query.Skip)
+	}
+	if query.Limit > 0 {
+		findOptions.SetLimit(query.Limit)
+	}
+
+	cursor, err := collection.Find(ctx, mongoFilter(query.Filter), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	documents := []*DocType{}
+	for cursor.Next(ctx) {
+		var doc DocType
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		documents = append(documents, &doc)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (m *mongoSvc[DocType]) CountDocuments(ctx context.Context, filter Filter) (int64, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	db := client.Database(m.DbName)
+	collection := db.Collection(m.Collection)
+	return collection.CountDocuments(ctx, mongoFilter(filter))
+}
+
+// mongoFilter translates the filter into mongo query document
+func mongoFilter(filter Filter) bson.D {
+	switch filter.Op {
+	case "":
+		return bson.D{}
+	case OpAnd, OpOr:
+		filters := bson.A{}
+		for _, nested := range filter.Filters {
+			filters = append(filters, mongoFilter(nested))
+		}
+		if len(filters) == 0 {
+			if filter.Op == OpAnd {
+				return bson.D{}
+			}
+			// empty disjunction matches nothing
+			return bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}}}
+		}
+		return bson.D{{Key: "$" + string(filter.Op), Value: filters}}
+	case OpNot:
+		return bson.D{{Key: "$nor", Value: bson.A{mongoFilter(filter.Filters[0])}}}
+	case OpElemMatch:
+		return bson.D{{Key: filter.Field, Value: bson.D{{Key: "$elemMatch", Value: mongoFilter(filter.Filters[0])}}}}
+	default:
+		return bson.D{{Key: filter.Field, Value: bson.D{{Key: "$" + string(filter.Op), Value: filter.Value}}}}
+	}
+}
+
 func (m *mongoSvc[DocType]) UpdateDocument(ctx context.Context, id any, document *DocType) error {
 	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
 	defer contextCancel()
@@ -255,6 +300,311 @@ func (m *mongoSvc[DocType]) UpdateDocument(ctx context.Context, id any, document
 	return err
 }
 
+// PushElement appends the element to the array field of the document. Elements of the array are
+// identified by their id field, ErrConflict is returned if element with the same id already exists.
+func (m *mongoSvc[DocType]) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	db := client.Database(m.DbName)
+	collection := db.Collection(m.Collection)
+	// empty slices are stored as null, which cannot be pushed to
+	_, err = collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "id", Value: id}, {Key: arrayField, Value: nil}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: arrayField, Value: bson.A{}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "id", Value: id}, {Key: arrayField + ".id", Value: bson.D{{Key: "$ne", Value: elementId}}}},
+		bson.D{{Key: "$push", Value: bson.D{{Key: arrayField, Value: element}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// either the document does not exist or it already has such element
+		err = collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Err()
+		switch err {
+		case nil:
+			return ErrConflict
+		case mongo.ErrNoDocuments:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// PullElement removes the element with the given id from the array field of the document
+func (m *mongoSvc[DocType]) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	return m.updateElement(ctx, id, arrayField, elementId, bson.D{
+		{Key: "$pull", Value: bson.D{{Key: arrayField, Value: bson.D{{Key: "id", Value: elementId}}}}},
+	})
+}
+
+// SetElementFields sets the fields of the element with the given id in the array field of the document
+func (m *mongoSvc[DocType]) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	set := bson.D{}
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		set = append(set, bson.E{Key: arrayField + ".$." + field, Value: fields[field]})
+	}
+	return m.updateElement(ctx, id, arrayField, elementId, bson.D{{Key: "$set", Value: set}})
+}
+
+// IncrementElementField atomically adds delta to the numeric field of the element with the given id
+func (m *mongoSvc[DocType]) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	return m.updateElement(ctx, id, arrayField, elementId, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: arrayField + ".$." + field, Value: delta}}},
+	})
+}
+
+// updateElement applies the update to the document having the element with the given id in the array field.
+// ErrNotFound is returned if there is no such document or element.
+func (m *mongoSvc[DocType]) updateElement(ctx context.Context, id any, arrayField string, elementId any, update bson.D) error {
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	db := client.Database(m.DbName)
+	collection := db.Collection(m.Collection)
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "id", Value: id}, {Key: arrayField + ".id", Value: elementId}},
+		update,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateFields atomically applies the change to the document with the given id. ErrConflict is returned
+// if the document does not match the expected filter of the change.
+func (m *mongoSvc[DocType]) UpdateFields(ctx context.Context, id any, change FieldChange) error {
+	if err := change.Validate(); err != nil {
+		return err
+	}
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	db := client.Database(m.DbName)
+	collection := db.Collection(m.Collection)
+	update := bson.D{}
+	if len(change.Set) > 0 {
+		set := bson.D{}
+		for _, field := range slices.Sorted(maps.Keys(change.Set)) {
+			set = append(set, bson.E{Key: field, Value: change.Set[field]})
+		}
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(change.Increment) > 0 {
+		increment := bson.D{}
+		for _, field := range slices.Sorted(maps.Keys(change.Increment)) {
+			increment = append(increment, bson.E{Key: field, Value: change.Increment[field]})
+		}
+		update = append(update, bson.E{Key: "$inc", Value: increment})
+	}
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "id", Value: id}, {Key: "$and", Value: bson.A{mongoFilter(change.Expect)}}},
+		update,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// either the document does not exist or it does not match the expected filter
+		err = collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Err()
+		switch err {
+		case nil:
+			return ErrConflict
+		case mongo.ErrNoDocuments:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateElements atomically applies all changes to the elements of the document with the given id.
+// The elements are identified by their id field, the fields and the expected filter of the changes
+// are within the element. ErrNotFound is returned if the document or a changed element does not exist,
+// ErrConflict if a pushed element already exists or a changed element does not match the expected filter.
+func (m *mongoSvc[DocType]) UpdateElements(ctx context.Context, id any, changes ...ElementChange) error {
+	if err := ValidateElementChanges(changes); err != nil {
+		return err
+	}
+	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
+	defer contextCancel()
+	client, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	db := client.Database(m.DbName)
+	collection := db.Collection(m.Collection)
+	if !changesArraysInOneWay(changes) {
+		// mongo cannot set, push and pull elements of the same array in one update
+		return replaceElementArrays(ctx, collection, id, changes)
+	}
+
+	conditions := bson.A{}
+	set, increment, arrayFilters := bson.D{}, bson.D{}, bson.A{}
+	var pushed, pulled []string
+	pushes, pulls := map[string]bson.A{}, map[string]bson.A{}
+	for i, change := range changes {
+		switch {
+		case change.Push != nil:
+			if _, ok := pushes[change.ArrayField]; !ok {
+				pushed = append(pushed, change.ArrayField)
+			}
+			pushes[change.ArrayField] = append(pushes[change.ArrayField], change.Push)
+			conditions = append(conditions, bson.D{{Key: change.ArrayField + ".id", Value: bson.D{{Key: "$ne", Value: change.ElementId}}}})
+			continue
+		case change.Pull:
+			if _, ok := pulls[change.ArrayField]; !ok {
+				pulled = append(pulled, change.ArrayField)
+			}
+			pulls[change.ArrayField] = append(pulls[change.ArrayField], change.ElementId)
+			conditions = append(conditions, bson.D{{Key: change.ArrayField + ".id", Value: change.ElementId}})
+			continue
+		}
+		identifier := fmt.Sprintf("e%d", i)
+		element := fmt.Sprintf("%v.$[%v].", change.ArrayField, identifier)
+		for _, field := range slices.Sorted(maps.Keys(change.Set)) {
+			set = append(set, bson.E{Key: element + field, Value: change.Set[field]})
+		}
+		for _, field := range slices.Sorted(maps.Keys(change.Increment)) {
+			increment = append(increment, bson.E{Key: element + field, Value: change.Increment[field]})
+		}
+		arrayFilters = append(arrayFilters, bson.D{{Key: identifier + ".id", Value: change.ElementId}})
+		conditions = append(conditions, bson.D{{Key: change.ArrayField, Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "id", Value: change.ElementId},
+			{Key: "$and", Value: bson.A{mongoFilter(change.Expect)}},
+		}}}}})
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(increment) > 0 {
+		update = append(update, bson.E{Key: "$inc", Value: increment})
+	}
+	if len(pushed) > 0 {
+		push := bson.D{}
+		for _, arrayField := range pushed {
+			push = append(push, bson.E{Key: arrayField, Value: bson.D{{Key: "$each", Value: pushes[arrayField]}}})
+			// empty slices are stored as null, which cannot be pushed to
+			_, err = collection.UpdateOne(
+				ctx,
+				bson.D{{Key: "id", Value: id}, {Key: arrayField, Value: nil}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: arrayField, Value: bson.A{}}}}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+		update = append(update, bson.E{Key: "$push", Value: push})
+	}
+	if len(pulled) > 0 {
+		pull := bson.D{}
+		for _, arrayField := range pulled {
+			pull = append(pull, bson.E{Key: arrayField, Value: bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: pulls[arrayField]}}}}})
+		}
+		update = append(update, bson.E{Key: "$pull", Value: pull})
+	}
+	updateOptions := options.Update()
+	if len(arrayFilters) > 0 {
+		updateOptions.SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+	}
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "id", Value: id}, {Key: "$and", Value: conditions}},
+		update,
+		updateOptions,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// replaying the changes on the stored document tells which of them cannot be applied
+		var document bson.M
+		err = collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Decode(&document)
+		switch err {
+		case nil:
+		case mongo.ErrNoDocuments:
+			return ErrNotFound
+		default:
+			return err
+		}
+		if err := applyElementChanges(document, changes); err != nil {
+			return err
+		}
+		// the document was changed meanwhile so that the changes apply now
+		return ErrConflict
+	}
+	return nil
+}
+
+// replaceElementArrays applies the changes to the stored document and writes the changed arrays back
+// in one update, which fails with ErrConflict if any of the arrays was changed meanwhile
+func replaceElementArrays(ctx context.Context, collection *mongo.Collection, id any, changes []ElementChange) error {
+	stored, err := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}}).Raw()
+	switch err {
+	case nil:
+	case mongo.ErrNoDocuments:
+		return ErrNotFound
+	default:
+		return err
+	}
+	var document bson.M
+	if err := bson.Unmarshal(stored, &document); err != nil {
+		return err
+	}
+	if err := applyElementChanges(document, changes); err != nil {
+		return err
+	}
+	filter, set := bson.D{{Key: "id", Value: id}}, bson.D{}
+	arrayFields := map[string]bool{}
+	for _, change := range changes {
+		if arrayFields[change.ArrayField] {
+			continue
+		}
+		arrayFields[change.ArrayField] = true
+		// the stored bytes of the array match only if nobody changed it since it was read
+		var expected any
+		if value, err := stored.LookupErr(change.ArrayField); err == nil {
+			expected = value
+		}
+		filter = append(filter, bson.E{Key: change.ArrayField, Value: expected})
+		set = append(set, bson.E{Key: change.ArrayField, Value: document[change.ArrayField]})
+	}
+	result, err := collection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
 func (m *mongoSvc[DocType]) DeleteDocument(ctx context.Context, id any) error {
 	ctx, contextCancel := context.WithTimeout(ctx, m.Timeout)
 	defer contextCancel()
@@ -0,0 +1,101 @@
+package db_service
+
+import (
+	"context"
+	"time"
+)
+
+// OperationObserver is called after every operation of the observed service with its duration and result
+type OperationObserver func(collection string, operation string, duration time.Duration, err error)
+
+// observedSvc reports the operations of the wrapped service to the observer
+type observedSvc[DocType interface{}] struct {
+	svc        DbService[DocType]
+	collection string
+	observe    OperationObserver
+}
+
+// NewObservedService returns service reporting the operations of the service to the observer, the collection
+// names the service in the reports. Disconnect is not reported.
+func NewObservedService[DocType interface{}](svc DbService[DocType], collection string, observe OperationObserver) DbService[DocType] {
+	return &observedSvc[DocType]{svc: svc, collection: collection, observe: observe}
+}
+
+// report observes the operation started at the given time, it returns the error of the operation
+func (o *observedSvc[DocType]) report(operation string, started time.Time, err error) error {
+	o.observe(o.collection, operation, time.Since(started), err)
+	return err
+}
+
+func (o *observedSvc[DocType]) CreateDocument(ctx context.Context, id any, document *DocType) error {
+	started := time.Now()
+	return o.report("CreateDocument", started, o.svc.CreateDocument(ctx, id, document))
+}
+
+func (o *observedSvc[DocType]) FindDocument(ctx context.Context, id any) (*DocType, error) {
+	started := time.Now()
+	document, err := o.svc.FindDocument(ctx, id)
+	return document, o.report("FindDocument", started, err)
+}
+
+func (o *observedSvc[DocType]) FindAllDocuments(ctx context.Context) ([]*DocType, error) {
+	started := time.Now()
+	documents, err := o.svc.FindAllDocuments(ctx)
+	return documents, o.report("FindAllDocuments", started, err)
+}
+
+func (o *observedSvc[DocType]) FindDocuments(ctx context.Context, query Query) ([]*DocType, error) {
+	started := time.Now()
+	documents, err := o.svc.FindDocuments(ctx, query)
+	return documents, o.report("FindDocuments", started, err)
+}
+
+func (o *observedSvc[DocType]) CountDocuments(ctx context.Context, filter Filter) (int64, error) {
+	started := time.Now()
+	count, err := o.svc.CountDocuments(ctx, filter)
+	return count, o.report("CountDocuments", started, err)
+}
+
+func (o *observedSvc[DocType]) UpdateDocument(ctx context.Context, id any, document *DocType) error {
+	started := time.Now()
+	return o.report("UpdateDocument", started, o.svc.UpdateDocument(ctx, id, document))
+}
+
+func (o *observedSvc[DocType]) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	started := time.Now()
+	return o.report("PushElement", started, o.svc.PushElement(ctx, id, arrayField, elementId, element))
+}
+
+func (o *observedSvc[DocType]) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	started := time.Now()
+	return o.report("PullElement", started, o.svc.PullElement(ctx, id, arrayField, elementId))
+}
+
+func (o *observedSvc[DocType]) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	started := time.Now()
+	return o.report("SetElementFields", started, o.svc.SetElementFields(ctx, id, arrayField, elementId, fields))
+}
+
+func (o *observedSvc[DocType]) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	started := time.Now()
+	return o.report("IncrementElementField", started, o.svc.IncrementElementField(ctx, id, arrayField, elementId, field, delta))
+}
+
+func (o *observedSvc[DocType]) UpdateFields(ctx context.Context, id any, change FieldChange) error {
+	started := time.Now()
+	return o.report("UpdateFields", started, o.svc.UpdateFields(ctx, id, change))
+}
+
+func (o *observedSvc[DocType]) UpdateElements(ctx context.Context, id any, changes ...ElementChange) error {
+	started := time.Now()
+	return o.report("UpdateElements", started, o.svc.UpdateElements(ctx, id, changes...))
+}
+
+func (o *observedSvc[DocType]) DeleteDocument(ctx context.Context, id any) error {
+	started := time.Now()
+	return o.report("DeleteDocument", started, o.svc.DeleteDocument(ctx, id))
+}
+
+func (o *observedSvc[DocType]) Disconnect(ctx context.Context) error {
+	return o.svc.Disconnect(ctx)
+}
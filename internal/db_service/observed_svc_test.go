@@ -0,0 +1,76 @@
+package db_service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/db_service/conformance"
+)
+
+func TestObservedService_Conformance(t *testing.T) {
+	conformance.Run(t, conformance.DocumentFixture(func(t *testing.T) db_service.DbService[conformance.Document] {
+		return db_service.NewObservedService(
+			db_service.NewMemoryService[conformance.Document](),
+			"documents",
+			func(string, string, time.Duration, error) {},
+		)
+	}))
+}
+
+// observation is one call of the observer
+type observation struct {
+	collection string
+	operation  string
+	err        error
+}
+
+type ObservedServiceSuite struct {
+	suite.Suite
+	lock         sync.Mutex
+	observations []observation
+	svc          db_service.DbService[conformance.Document]
+}
+
+func TestObservedServiceSuite(t *testing.T) {
+	suite.Run(t, new(ObservedServiceSuite))
+}
+
+func (suite *ObservedServiceSuite) SetupTest() {
+	suite.observations = nil
+	suite.svc = db_service.NewObservedService(
+		db_service.NewMemoryService[conformance.Document](),
+		"documents",
+		func(collection string, operation string, duration time.Duration, err error) {
+			suite.lock.Lock()
+			defer suite.lock.Unlock()
+			suite.GreaterOrEqual(duration, time.Duration(0))
+			suite.observations = append(suite.observations, observation{collection, operation, err})
+		},
+	)
+}
+
+func (suite *ObservedServiceSuite) Test_ReportsOperationsWithTheirErrors() {
+	// ARRANGE
+	ctx := context.Background()
+
+	// ACT
+	createErr := suite.svc.CreateDocument(ctx, "a", &conformance.Document{Id: "a"})
+	_, findErr := suite.svc.FindDocument(ctx, "missing")
+	pushErr := suite.svc.PushElement(ctx, "a", "elements", "e", conformance.Element{Id: "e"})
+	disconnectErr := suite.svc.Disconnect(ctx)
+
+	// ASSERT
+	suite.NoError(createErr)
+	suite.ErrorIs(findErr, db_service.ErrNotFound)
+	suite.NoError(pushErr)
+	suite.NoError(disconnectErr)
+	suite.Equal([]observation{
+		{"documents", "CreateDocument", nil},
+		{"documents", "FindDocument", db_service.ErrNotFound},
+		{"documents", "PushElement", nil},
+	}, suite.observations)
+}
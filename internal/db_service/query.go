@@ -0,0 +1,167 @@
+package db_service
+
+import (
+	"fmt"
+)
+
+type Operator string
+
+const (
+	OpEq     Operator = "eq"
+	OpNe     Operator = "ne"
+	OpGt     Operator = "gt"
+	OpGte    Operator = "gte"
+	OpLt     Operator = "lt"
+	OpLte    Operator = "lte"
+	OpIn     Operator = "in"
+	OpExists Operator = "exists"
+	OpAnd    Operator = "and"
+	OpOr     Operator = "or"
+	OpNot    Operator = "not"
+	// OpElemMatch matches documents with an element of the array field matching the nested filter
+	OpElemMatch Operator = "elemMatch"
+)
+
+// Filter is a condition on the stored documents. Field names are the names used in the
+// database, nested fields are separated by dots. The zero Filter matches all documents.
+type Filter struct {
+	Op      Operator
+	Field   string
+	Value   any
+	Filters []Filter
+}
+
+func Eq(field string, value any) Filter {
+	return Filter{Op: OpEq, Field: field, Value: value}
+}
+
+func Ne(field string, value any) Filter {
+	return Filter{Op: OpNe, Field: field, Value: value}
+}
+
+func Gt(field string, value any) Filter {
+	return Filter{Op: OpGt, Field: field, Value: value}
+}
+
+func Gte(field string, value any) Filter {
+	return Filter{Op: OpGte, Field: field, Value: value}
+}
+
+func Lt(field string, value any) Filter {
+	return Filter{Op: OpLt, Field: field, Value: value}
+}
+
+func Lte(field string, value any) Filter {
+	return Filter{Op: OpLte, Field: field, Value: value}
+}
+
+// In matches documents where the field equals any of the values
+func In[T any](field string, values ...T) Filter {
+	items := make([]any, len(values))
+	for i, value := range values {
+		items[i] = value
+	}
+	return Filter{Op: OpIn, Field: field, Value: items}
+}
+
+func Exists(field string, exists bool) Filter {
+	return Filter{Op: OpExists, Field: field, Value: exists}
+}
+
+func And(filters ...Filter) Filter {
+	return Filter{Op: OpAnd, Filters: filters}
+}
+
+func Or(filters ...Filter) Filter {
+	return Filter{Op: OpOr, Filters: filters}
+}
+
+func Not(filter Filter) Filter {
+	return Filter{Op: OpNot, Filters: []Filter{filter}}
+}
+
+// ElemMatch matches documents where an element of the array field satisfies the filter,
+// field names of the filter are the names within the element
+func ElemMatch(field string, filter Filter) Filter {
+	return Filter{Op: OpElemMatch, Field: field, Filters: []Filter{filter}}
+}
+
+// IsEmpty reports whether the filter matches all documents
+func (f Filter) IsEmpty() bool {
+	return f.Op == ""
+}
+
+// Validate checks the filter is well formed before it is passed to the database
+func (f Filter) Validate() error {
+	switch f.Op {
+	case "":
+		return nil
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		if f.Field == "" {
+			return fmt.Errorf("filter %v requires field", f.Op)
+		}
+	case OpIn:
+		if f.Field == "" {
+			return fmt.Errorf("filter %v requires field", f.Op)
+		}
+		if _, ok := f.Value.([]any); !ok {
+			return fmt.Errorf("filter %v requires list of values", f.Op)
+		}
+	case OpExists:
+		if f.Field == "" {
+			return fmt.Errorf("filter %v requires field", f.Op)
+		}
+		if _, ok := f.Value.(bool); !ok {
+			return fmt.Errorf("filter %v requires boolean value", f.Op)
+		}
+	case OpAnd, OpOr:
+		for _, filter := range f.Filters {
+			if err := filter.Validate(); err != nil {
+				return err
+			}
+		}
+	case OpNot:
+		if len(f.Filters) != 1 {
+			return fmt.Errorf("filter %v requires exactly one filter", f.Op)
+		}
+		return f.Filters[0].Validate()
+	case OpElemMatch:
+		if f.Field == "" {
+			return fmt.Errorf("filter %v requires field", f.Op)
+		}
+		if len(f.Filters) != 1 {
+			return fmt.Errorf("filter %v requires exactly one filter", f.Op)
+		}
+		return f.Filters[0].Validate()
+	default:
+		return fmt.Errorf("unknown filter operator %q", f.Op)
+	}
+	return nil
+}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Query selects documents matching the filter. Projection lists the fields to load, all
+// fields are loaded if it is empty. Limit 0 means no limit.
+type Query struct {
+	Filter     Filter
+	Projection []string
+	Sort       []SortField
+	Skip       int64
+	Limit      int64
+}
+
+func (q Query) Validate() error {
+	if q.Skip < 0 || q.Limit < 0 {
+		return fmt.Errorf("skip and limit must not be negative")
+	}
+	for _, field := range q.Sort {
+		if field.Field == "" {
+			return fmt.Errorf("sort requires field")
+		}
+	}
+	return q.Filter.Validate()
+}
@@ -0,0 +1,597 @@
+package db_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type SqliteServiceConfig struct {
+	// Path of the database file
+	Path  string
+	Table string
+	// IndexedFields are the document fields with generated column and index, equality filters
+	// and sorting on them are evaluated by the database. The fields must hold strings or numbers
+	// and must not be nested in arrays. The id field is always indexed.
+	IndexedFields []string
+	Timeout       time.Duration
+}
+
+// sqliteSvc stores the documents as JSON in a table of an embedded SQLite database. The documents keep
+// the field names and types of their bson form, queries are evaluated with the semantics of mongoSvc.
+type sqliteSvc[DocType interface{}] struct {
+	SqliteServiceConfig
+	db     atomic.Pointer[sql.DB]
+	dbLock sync.Mutex
+}
+
+func NewSqliteService[DocType interface{}](config SqliteServiceConfig) DbService[DocType] {
+	svc := &sqliteSvc[DocType]{}
+	svc.SqliteServiceConfig = config.WithDefaults()
+	slog.Debug("SQLite service", "path", svc.Path, "table", svc.Table)
+	return svc
+}
+
+// WithDefaults returns copy of the config with the missing values taken from the environment
+func (config SqliteServiceConfig) WithDefaults() SqliteServiceConfig {
+	if config.Path == "" {
+		config.Path = os.Getenv("MEDICINE_API_SQLITE_PATH")
+		if config.Path == "" {
+			config.Path = "medicine.db"
+		}
+	}
+	if config.Table == "" {
+		config.Table = "ambulance"
+	}
+	if config.Timeout == 0 {
+		seconds := os.Getenv("MEDICINE_API_SQLITE_TIMEOUT_SECONDS")
+		if value, err := strconv.Atoi(seconds); err == nil && value > 0 {
+			config.Timeout = time.Duration(value) * time.Second
+		} else {
+			if seconds != "" {
+				slog.Warn("Invalid MEDICINE_API_SQLITE_TIMEOUT_SECONDS value", "value", seconds)
+			}
+			config.Timeout = 10 * time.Second
+		}
+	}
+	return config
+}
+
+var sqliteFieldPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// column returns name of the generated column of the indexed field
+func (s *sqliteSvc[DocType]) column(field string) string {
+	if field == "id" {
+		return "id"
+	}
+	return "f_" + strings.ReplaceAll(field, ".", "_")
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (s *sqliteSvc[DocType]) connect(ctx context.Context) (*sql.DB, error) {
+	// optimistic check
+	db := s.db.Load()
+	if db != nil {
+		return db, nil
+	}
+
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+	// pesimistic check
+	db = s.db.Load()
+	if db != nil {
+		return db, nil
+	}
+
+	// writers wait for each other instead of failing, transactions take the write lock upfront
+	dsn := "file:" + url.PathEscape(s.Path) +
+		"?_pragma=busy_timeout(" + strconv.Itoa(int(s.Timeout.Milliseconds())) + ")" +
+		"&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createSchema(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.db.Store(db)
+	return db, nil
+}
+
+// createSchema creates the table and the generated columns of the indexed fields missing in the database
+func (s *sqliteSvc[DocType]) createSchema(ctx context.Context, db *sql.DB) error {
+	table := quoteIdentifier(s.Table)
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			document TEXT NOT NULL CHECK (json_valid(document)),
+			id GENERATED ALWAYS AS (json_extract(document, '$.id')) VIRTUAL
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS ` + quoteIdentifier(s.Table+"_id") + ` ON ` + table + ` (id)`,
+	}
+
+	columns := map[string]bool{}
+	rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_table_xinfo(?)`, s.Table)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, field := range s.IndexedFields {
+		if !sqliteFieldPattern.MatchString(field) {
+			return fmt.Errorf("invalid indexed field %q", field)
+		}
+		column := s.column(field)
+		if !columns[column] {
+			statements = append(statements, `ALTER TABLE `+table+` ADD COLUMN `+quoteIdentifier(column)+
+				` GENERATED ALWAYS AS (json_extract(document, '$.`+field+`')) VIRTUAL`)
+		}
+		statements = append(statements, `CREATE INDEX IF NOT EXISTS `+quoteIdentifier(s.Table+"_"+column)+
+			` ON `+table+` (`+quoteIdentifier(column)+`)`)
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSvc[DocType]) Disconnect(ctx context.Context) error {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+	db := s.db.Swap(nil)
+	if db != nil {
+		return db.Close()
+	}
+	return nil
+}
+
+func encodeJson(document any) (string, error) {
+	encoded, err := bson.MarshalExtJSON(document, false, false)
+	return string(encoded), err
+}
+
+func decodeJson(encoded string) (bson.M, error) {
+	document := bson.M{}
+	err := bson.UnmarshalExtJSON([]byte(encoded), false, &document)
+	return document, err
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+func (s *sqliteSvc[DocType]) CreateDocument(ctx context.Context, id any, document *DocType) error {
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	db, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeJson(document)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// the document is looked up by the given id, which may differ from the id in the document
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM `+quoteIdentifier(s.Table)+` WHERE id = ?`, id).Scan(&exists)
+	switch {
+	case err == nil:
+		return ErrConflict
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO `+quoteIdentifier(s.Table)+` (document) VALUES (?)`, encoded)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteSvc[DocType]) FindDocument(ctx context.Context, id any) (*DocType, error) {
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	db, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var encoded string
+	err = db.QueryRowContext(ctx, `SELECT document FROM `+quoteIdentifier(s.Table)+` WHERE id = ?`, id).Scan(&encoded)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, err
+	}
+	var document DocType
+	if err := bson.UnmarshalExtJSON([]byte(encoded), false, &document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+func (s *sqliteSvc[DocType]) FindAllDocuments(ctx context.Context) ([]*DocType, error) {
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	stored, err := s.load(ctx, Filter{})
+	if err != nil {
+		return nil, err
+	}
+	var documents []*DocType
+	for _, document := range stored {
+		decoded, err := fromDocument[DocType](document)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, decoded)
+	}
+	return documents, nil
+}
+
+func (s *sqliteSvc[DocType]) FindDocuments(ctx context.Context, query Query) ([]*DocType, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	var matching []bson.M
+	if orderBy, ok := s.indexedOrder(query.Sort); ok && s.isIndexedFilter(query.Filter) {
+		// the database selects the page, negative limit means no limit in SQLite
+		limit := query.Limit
+		if limit == 0 {
+			limit = -1
+		}
+		documents, err := s.query(ctx, query.Filter, ` ORDER BY `+orderBy+` LIMIT ? OFFSET ?`, limit, query.Skip)
+		if err != nil {
+			return nil, err
+		}
+		matching = documents
+	} else {
+		documents, err := s.load(ctx, query.Filter)
+		if err != nil {
+			return nil, err
+		}
+		sortDocuments(documents, query.Sort)
+		matching = documents[min(int64(len(documents)), query.Skip):]
+		if query.Limit > 0 && int64(len(matching)) > query.Limit {
+			matching = matching[:query.Limit]
+		}
+	}
+
+	documents := []*DocType{}
+	for _, stored := range matching {
+		document, err := fromDocument[DocType](projectDocument(stored, query.Projection))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+func (s *sqliteSvc[DocType]) CountDocuments(ctx context.Context, filter Filter) (int64, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	if s.isIndexedFilter(filter) {
+		db, err := s.connect(ctx)
+		if err != nil {
+			return 0, err
+		}
+		statement := `SELECT COUNT(*) FROM ` + quoteIdentifier(s.Table)
+		conditions, args := s.indexedConditions(filter)
+		if len(conditions) > 0 {
+			statement += ` WHERE ` + strings.Join(conditions, ` AND `)
+		}
+		var count int64
+		err = db.QueryRowContext(ctx, statement, args...).Scan(&count)
+		return count, err
+	}
+	matching, err := s.load(ctx, filter)
+	return int64(len(matching)), err
+}
+
+// load returns the documents matching the filter in the order they were created
+func (s *sqliteSvc[DocType]) load(ctx context.Context, filter Filter) ([]bson.M, error) {
+	return s.query(ctx, filter, ` ORDER BY seq`)
+}
+
+// query returns the documents matching the filter, the order and paging clauses of the statement are
+// given by the suffix. Equality conditions on the indexed fields select the candidate rows, the whole
+// filter is evaluated on the decoded documents.
+func (s *sqliteSvc[DocType]) query(ctx context.Context, filter Filter, suffix string, suffixArgs ...any) ([]bson.M, error) {
+	db, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statement := `SELECT document FROM ` + quoteIdentifier(s.Table)
+	conditions, args := s.indexedConditions(filter)
+	if len(conditions) > 0 {
+		statement += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	rows, err := db.QueryContext(ctx, statement+suffix, append(args, suffixArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []bson.M
+	for rows.Next() {
+		var encoded string
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+		document, err := decodeJson(encoded)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := matchesFilter(document, filter)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			documents = append(documents, document)
+		}
+	}
+	return documents, rows.Err()
+}
+
+// indexedConditions returns SQL conditions every document matching the filter satisfies
+func (s *sqliteSvc[DocType]) indexedConditions(filter Filter) ([]string, []any) {
+	switch filter.Op {
+	case OpAnd:
+		var conditions []string
+		var args []any
+		for _, nested := range filter.Filters {
+			nestedConditions, nestedArgs := s.indexedConditions(nested)
+			conditions = append(conditions, nestedConditions...)
+			args = append(args, nestedArgs...)
+		}
+		return conditions, args
+	case OpEq:
+		if value, ok := s.indexedValue(filter); ok {
+			return []string{quoteIdentifier(s.column(filter.Field)) + ` = ?`}, []any{value}
+		}
+	}
+	return nil, nil
+}
+
+// isIndexedFilter reports whether the indexed conditions of the filter select exactly the matching documents
+func (s *sqliteSvc[DocType]) isIndexedFilter(filter Filter) bool {
+	switch filter.Op {
+	case "":
+		return true
+	case OpAnd:
+		for _, nested := range filter.Filters {
+			if !s.isIndexedFilter(nested) {
+				return false
+			}
+		}
+		return true
+	case OpEq:
+		_, ok := s.indexedValue(filter)
+		return ok
+	}
+	return false
+}
+
+// indexedValue returns the value of the equality filter on the indexed field, if the database can compare it
+func (s *sqliteSvc[DocType]) indexedValue(filter Filter) (any, bool) {
+	if !s.isIndexed(filter.Field) {
+		return nil, false
+	}
+	value, err := toBsonValue(filter.Value)
+	if err != nil {
+		return nil, false
+	}
+	switch value.(type) {
+	case string, int32, int64, float64:
+		return value, true
+	}
+	return nil, false
+}
+
+func (s *sqliteSvc[DocType]) isIndexed(field string) bool {
+	return field == "id" || slices.Contains(s.IndexedFields, field)
+}
+
+// indexedOrder returns the ORDER BY clause of the sort if all sort fields are indexed. Documents with
+// equal values keep the order they were created in, like in the sorted documents.
+func (s *sqliteSvc[DocType]) indexedOrder(sort []SortField) (string, bool) {
+	var terms []string
+	for _, field := range sort {
+		if !s.isIndexed(field.Field) {
+			return "", false
+		}
+		term := quoteIdentifier(s.column(field.Field))
+		if field.Descending {
+			term += ` DESC`
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(append(terms, `seq`), `, `), true
+}
+
+func (s *sqliteSvc[DocType]) UpdateDocument(ctx context.Context, id any, document *DocType) error {
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	db, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeJson(document)
+	if err != nil {
+		return err
+	}
+	result, err := db.ExecContext(ctx, `UPDATE `+quoteIdentifier(s.Table)+` SET document = ? WHERE id = ?`, encoded, id)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PushElement appends the element to the array field of the document. Elements of the array are
+// identified by their id field, ErrConflict is returned if element with the same id already exists.
+func (s *sqliteSvc[DocType]) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	return s.changeDocument(ctx, id, func(document bson.M) error {
+		return pushDocumentElement(document, arrayField, elementId, element)
+	})
+}
+
+// PullElement removes the element with the given id from the array field of the document
+func (s *sqliteSvc[DocType]) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	return s.changeDocument(ctx, id, func(document bson.M) error {
+		return updateDocumentElement(document, arrayField, elementId, pullElementChange(elementId))
+	})
+}
+
+// SetElementFields sets the fields of the element with the given id in the array field of the document
+func (s *sqliteSvc[DocType]) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	change, err := setFieldsChange(fields)
+	if err != nil {
+		return err
+	}
+	return s.changeDocument(ctx, id, func(document bson.M) error {
+		return updateDocumentElement(document, arrayField, elementId, change)
+	})
+}
+
+// IncrementElementField atomically adds delta to the numeric field of the element with the given id
+func (s *sqliteSvc[DocType]) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	return s.changeDocument(ctx, id, func(document bson.M) error {
+		return updateDocumentElement(document, arrayField, elementId, incrementChange(field, delta))
+	})
+}
+
+// UpdateFields atomically applies the change to the document with the given id. ErrConflict is returned
+// if the document does not match the expected filter of the change.
+func (s *sqliteSvc[DocType]) UpdateFields(ctx context.Context, id any, change FieldChange) error {
+	if err := change.Validate(); err != nil {
+		return err
+	}
+	return s.changeDocument(ctx, id, func(document bson.M) error {
+		return changeFields(document, change)
+	})
+}
+
+// UpdateElements atomically applies all changes to the elements of the document with the given id
+func (s *sqliteSvc[DocType]) UpdateElements(ctx context.Context, id any, changes ...ElementChange) error {
+	if err := ValidateElementChanges(changes); err != nil {
+		return err
+	}
+	return s.changeDocument(ctx, id, func(document bson.M) error {
+		return applyElementChanges(document, changes)
+	})
+}
+
+// changeDocument applies the change to the document with the given id in a write transaction
+func (s *sqliteSvc[DocType]) changeDocument(ctx context.Context, id any, change func(document bson.M) error) error {
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	db, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	var encoded string
+	err = tx.QueryRowContext(ctx, `SELECT seq, document FROM `+quoteIdentifier(s.Table)+` WHERE id = ?`, id).
+		Scan(&seq, &encoded)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case err != nil:
+		return err
+	}
+	document, err := decodeJson(encoded)
+	if err != nil {
+		return err
+	}
+	if err := change(document); err != nil {
+		return err
+	}
+	if encoded, err = encodeJson(document); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE `+quoteIdentifier(s.Table)+` SET document = ? WHERE seq = ?`, encoded, seq); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteSvc[DocType]) DeleteDocument(ctx context.Context, id any) error {
+	ctx, contextCancel := context.WithTimeout(ctx, s.Timeout)
+	defer contextCancel()
+	db, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := db.ExecContext(ctx, `DELETE FROM `+quoteIdentifier(s.Table)+` WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,158 @@
+package db_service
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SqliteServiceSuite struct {
+	suite.Suite
+	config SqliteServiceConfig
+	sut    DbService[testDocument]
+	ctx    context.Context
+}
+
+func TestSqliteServiceSuite(t *testing.T) {
+	suite.Run(t, new(SqliteServiceSuite))
+}
+
+func (suite *SqliteServiceSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.config = SqliteServiceConfig{
+		Path:          filepath.Join(suite.T().TempDir(), "test.db"),
+		Table:         "documents",
+		IndexedFields: []string{"name"},
+	}
+	suite.sut = NewSqliteService[testDocument](suite.config)
+	for _, document := range []testDocument{
+		{Id: "a", Name: "Alpha", Rank: 3, Tags: []string{"red"}},
+		{Id: "b", Name: "Beta", Rank: 1, Items: []testItem{{Id: "x", Count: 2}}},
+	} {
+		suite.Require().NoError(suite.sut.CreateDocument(suite.ctx, document.Id, &document))
+	}
+}
+
+func (suite *SqliteServiceSuite) TearDownTest() {
+	suite.NoError(suite.sut.Disconnect(suite.ctx))
+}
+
+func (suite *SqliteServiceSuite) Test_DocumentsArePersisted() {
+	// ARRANGE
+	suite.Require().NoError(suite.sut.Disconnect(suite.ctx))
+
+	// ACT
+	// new indexed field is added to the existing table
+	suite.config.IndexedFields = append(suite.config.IndexedFields, "rank")
+	suite.sut = NewSqliteService[testDocument](suite.config)
+	documents, err := suite.sut.FindAllDocuments(suite.ctx)
+	count, countErr := suite.sut.CountDocuments(suite.ctx, Eq("rank", 3))
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]*testDocument{
+		{Id: "a", Name: "Alpha", Rank: 3, Tags: []string{"red"}},
+		{Id: "b", Name: "Beta", Rank: 1, Items: []testItem{{Id: "x", Count: 2}}},
+	}, documents)
+	suite.Require().NoError(countErr)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *SqliteServiceSuite) Test_ConflictAndNotFound() {
+	// ACT
+	createErr := suite.sut.CreateDocument(suite.ctx, "a", &testDocument{Id: "a"})
+	// the document is stored with the id of the document
+	createIdErr := suite.sut.CreateDocument(suite.ctx, "other", &testDocument{Id: "b"})
+	_, findErr := suite.sut.FindDocument(suite.ctx, "missing")
+	updateErr := suite.sut.UpdateDocument(suite.ctx, "missing", &testDocument{Id: "missing"})
+	deleteErr := suite.sut.DeleteDocument(suite.ctx, "missing")
+	pushErr := suite.sut.PushElement(suite.ctx, "missing", "items", "y", testItem{Id: "y"})
+
+	// ASSERT
+	suite.ErrorIs(createErr, ErrConflict)
+	suite.ErrorIs(createIdErr, ErrConflict)
+	suite.ErrorIs(findErr, ErrNotFound)
+	suite.ErrorIs(updateErr, ErrNotFound)
+	suite.ErrorIs(deleteErr, ErrNotFound)
+	suite.ErrorIs(pushErr, ErrNotFound)
+}
+
+func (suite *SqliteServiceSuite) Test_FindDocuments_IndexedAndDocumentFilters() {
+	// ACT
+	documents, err := suite.sut.FindDocuments(suite.ctx, Query{
+		Filter:     And(Eq("name", "Alpha"), Eq("tags", "red")),
+		Projection: []string{"id", "rank"},
+	})
+	none, noneErr := suite.sut.FindDocuments(suite.ctx, Query{Filter: And(Eq("name", "Alpha"), Gt("rank", 5))})
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]*testDocument{{Id: "a", Rank: 3}}, documents)
+	suite.Require().NoError(noneErr)
+	suite.Empty(none)
+}
+
+func (suite *SqliteServiceSuite) Test_FindDocuments_IndexedSortAndPagingMatchesMemory() {
+	// ARRANGE
+	memory := NewMemoryService[testDocument]()
+	for _, document := range []testDocument{
+		{Id: "a", Name: "Alpha", Rank: 3, Tags: []string{"red"}},
+		{Id: "b", Name: "Beta", Rank: 1, Items: []testItem{{Id: "x", Count: 2}}},
+		{Id: "c", Name: "Alpha", Rank: 2},
+		{Id: "d", Rank: 5},
+		{Id: "e", Name: "Beta", Rank: 4},
+	} {
+		suite.Require().NoError(memory.CreateDocument(suite.ctx, document.Id, &document))
+		if document.Id != "a" && document.Id != "b" {
+			suite.Require().NoError(suite.sut.CreateDocument(suite.ctx, document.Id, &document))
+		}
+	}
+	queries := []Query{
+		{Sort: []SortField{{Field: "name"}}},
+		{Sort: []SortField{{Field: "name", Descending: true}}, Skip: 1, Limit: 3},
+		{Sort: []SortField{{Field: "name"}, {Field: "id", Descending: true}}, Limit: 2},
+		{Filter: Eq("name", "Alpha"), Sort: []SortField{{Field: "id", Descending: true}}, Skip: 1},
+		{Filter: Gt("rank", 1), Sort: []SortField{{Field: "name"}}, Skip: 1, Limit: 2},
+		{Sort: []SortField{{Field: "rank"}}, Skip: 4},
+	}
+
+	for _, query := range queries {
+		// ACT
+		documents, err := suite.sut.FindDocuments(suite.ctx, query)
+		expected, expectedErr := memory.FindDocuments(suite.ctx, query)
+		count, countErr := suite.sut.CountDocuments(suite.ctx, query.Filter)
+		expectedCount, expectedCountErr := memory.CountDocuments(suite.ctx, query.Filter)
+
+		// ASSERT
+		suite.Require().NoError(err)
+		suite.Require().NoError(expectedErr)
+		suite.Equal(expected, documents, "query %+v", query)
+		suite.Require().NoError(countErr)
+		suite.Require().NoError(expectedCountErr)
+		suite.Equal(expectedCount, count, "count of filter %+v", query.Filter)
+	}
+}
+
+func (suite *SqliteServiceSuite) Test_ConcurrentElementIncrements() {
+	// ARRANGE
+	const workers = 20
+	var wait sync.WaitGroup
+
+	// ACT
+	for range workers {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			suite.NoError(suite.sut.IncrementElementField(suite.ctx, "b", "items", "x", "count", 1))
+		}()
+	}
+	wait.Wait()
+
+	// ASSERT
+	document, err := suite.sut.FindDocument(suite.ctx, "b")
+	suite.Require().NoError(err)
+	suite.Equal(int32(2+workers), document.Items[0].Count)
+}
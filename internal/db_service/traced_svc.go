@@ -0,0 +1,129 @@
+package db_service
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/undy45/medicine-webapi/internal/db_service"
+
+// documentIdKey is the span attribute with the id of the document the operation works with
+var documentIdKey = attribute.Key("medicine.document.id")
+
+// tracedSvc starts span for every operation of the wrapped service, the wrapped service receives
+// the context of the span, so that its own spans, e.g. of the mongo commands, are its children
+type tracedSvc[DocType interface{}] struct {
+	svc        DbService[DocType]
+	collection string
+}
+
+// NewTracedService returns service tracing the operations of the service with the global tracer provider,
+// which may be set also after the service is created. The spans are named by the collection and the
+// operation. Disconnect is not traced.
+func NewTracedService[DocType interface{}](svc DbService[DocType], collection string) DbService[DocType] {
+	return &tracedSvc[DocType]{svc: svc, collection: collection}
+}
+
+// start starts span of the operation, id is the id of the document or nil
+func (t *tracedSvc[DocType]) start(ctx context.Context, operation string, id any) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		semconv.DBCollectionName(t.collection),
+		semconv.DBOperationName(operation),
+	}
+	if id != nil {
+		attributes = append(attributes, documentIdKey.String(fmt.Sprint(id)))
+	}
+	return otel.Tracer(tracerName).Start(ctx, t.collection+"."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+// endSpan ends the span with the result of the operation and returns its error
+func endSpan(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
+
+func (t *tracedSvc[DocType]) CreateDocument(ctx context.Context, id any, document *DocType) error {
+	ctx, span := t.start(ctx, "CreateDocument", id)
+	return endSpan(span, t.svc.CreateDocument(ctx, id, document))
+}
+
+func (t *tracedSvc[DocType]) FindDocument(ctx context.Context, id any) (*DocType, error) {
+	ctx, span := t.start(ctx, "FindDocument", id)
+	document, err := t.svc.FindDocument(ctx, id)
+	return document, endSpan(span, err)
+}
+
+func (t *tracedSvc[DocType]) FindAllDocuments(ctx context.Context) ([]*DocType, error) {
+	ctx, span := t.start(ctx, "FindAllDocuments", nil)
+	documents, err := t.svc.FindAllDocuments(ctx)
+	return documents, endSpan(span, err)
+}
+
+func (t *tracedSvc[DocType]) FindDocuments(ctx context.Context, query Query) ([]*DocType, error) {
+	ctx, span := t.start(ctx, "FindDocuments", nil)
+	documents, err := t.svc.FindDocuments(ctx, query)
+	return documents, endSpan(span, err)
+}
+
+func (t *tracedSvc[DocType]) CountDocuments(ctx context.Context, filter Filter) (int64, error) {
+	ctx, span := t.start(ctx, "CountDocuments", nil)
+	count, err := t.svc.CountDocuments(ctx, filter)
+	return count, endSpan(span, err)
+}
+
+func (t *tracedSvc[DocType]) UpdateDocument(ctx context.Context, id any, document *DocType) error {
+	ctx, span := t.start(ctx, "UpdateDocument", id)
+	return endSpan(span, t.svc.UpdateDocument(ctx, id, document))
+}
+
+func (t *tracedSvc[DocType]) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	ctx, span := t.start(ctx, "PushElement", id)
+	return endSpan(span, t.svc.PushElement(ctx, id, arrayField, elementId, element))
+}
+
+func (t *tracedSvc[DocType]) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	ctx, span := t.start(ctx, "PullElement", id)
+	return endSpan(span, t.svc.PullElement(ctx, id, arrayField, elementId))
+}
+
+func (t *tracedSvc[DocType]) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	ctx, span := t.start(ctx, "SetElementFields", id)
+	return endSpan(span, t.svc.SetElementFields(ctx, id, arrayField, elementId, fields))
+}
+
+func (t *tracedSvc[DocType]) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	ctx, span := t.start(ctx, "IncrementElementField", id)
+	return endSpan(span, t.svc.IncrementElementField(ctx, id, arrayField, elementId, field, delta))
+}
+
+func (t *tracedSvc[DocType]) UpdateFields(ctx context.Context, id any, change FieldChange) error {
+	ctx, span := t.start(ctx, "UpdateFields", id)
+	return endSpan(span, t.svc.UpdateFields(ctx, id, change))
+}
+
+func (t *tracedSvc[DocType]) UpdateElements(ctx context.Context, id any, changes ...ElementChange) error {
+	ctx, span := t.start(ctx, "UpdateElements", id)
+	return endSpan(span, t.svc.UpdateElements(ctx, id, changes...))
+}
+
+func (t *tracedSvc[DocType]) DeleteDocument(ctx context.Context, id any) error {
+	ctx, span := t.start(ctx, "DeleteDocument", id)
+	return endSpan(span, t.svc.DeleteDocument(ctx, id))
+}
+
+func (t *tracedSvc[DocType]) Disconnect(ctx context.Context) error {
+	return t.svc.Disconnect(ctx)
+}
@@ -0,0 +1,66 @@
+package db_service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/db_service/conformance"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedService_Conformance(t *testing.T) {
+	conformance.Run(t, conformance.DocumentFixture(func(t *testing.T) db_service.DbService[conformance.Document] {
+		return db_service.NewTracedService(db_service.NewMemoryService[conformance.Document](), "documents")
+	}))
+}
+
+type TracedServiceSuite struct {
+	suite.Suite
+	recorder *tracetest.SpanRecorder
+	svc      db_service.DbService[conformance.Document]
+}
+
+func TestTracedServiceSuite(t *testing.T) {
+	suite.Run(t, new(TracedServiceSuite))
+}
+
+func (suite *TracedServiceSuite) SetupTest() {
+	suite.recorder = tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(suite.recorder)))
+	suite.T().Cleanup(func() { otel.SetTracerProvider(previous) })
+	suite.svc = db_service.NewTracedService(db_service.NewMemoryService[conformance.Document](), "documents")
+}
+
+func (suite *TracedServiceSuite) Test_SpansAreChildrenOfTheContextSpan() {
+	// ARRANGE
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	// ACT
+	createErr := suite.svc.CreateDocument(ctx, "a", &conformance.Document{Id: "a"})
+	_, findErr := suite.svc.FindDocument(ctx, "missing")
+	parent.End()
+
+	// ASSERT
+	suite.Require().NoError(createErr)
+	suite.Require().ErrorIs(findErr, db_service.ErrNotFound)
+	spans := suite.recorder.Ended()
+	suite.Require().Len(spans, 3)
+	create, find := spans[0], spans[1]
+	suite.Equal("documents.CreateDocument", create.Name())
+	suite.Equal(parent.SpanContext().SpanID(), create.Parent().SpanID())
+	suite.Contains(create.Attributes(), attribute.String("db.collection.name", "documents"))
+	suite.Contains(create.Attributes(), attribute.String("db.operation.name", "CreateDocument"))
+	suite.Contains(create.Attributes(), attribute.String("medicine.document.id", "a"))
+	suite.Equal(codes.Unset, create.Status().Code)
+	suite.Equal("documents.FindDocument", find.Name())
+	suite.Equal(parent.SpanContext().TraceID(), find.SpanContext().TraceID())
+	suite.Equal(codes.Error, find.Status().Code)
+	suite.Equal(db_service.ErrNotFound.Error(), find.Status().Description)
+}
@@ -0,0 +1,101 @@
+package db_service
+
+import (
+	"fmt"
+)
+
+// FieldChange changes fields of a stored document in a single atomic update. Field names are the names
+// used in the database, nested fields are separated by dots.
+type FieldChange struct {
+	// Expect must match the document, otherwise the change is rejected with ErrConflict.
+	// The zero Filter matches every document.
+	Expect    Filter
+	Set       map[string]any
+	Increment map[string]int64
+}
+
+// IsEmpty reports whether the change changes no field
+func (c FieldChange) IsEmpty() bool {
+	return len(c.Set) == 0 && len(c.Increment) == 0
+}
+
+// Validate checks the change is well formed before it is passed to the database
+func (c FieldChange) Validate() error {
+	if c.IsEmpty() {
+		return fmt.Errorf("change requires fields to set or increment")
+	}
+	for field := range c.Increment {
+		if _, ok := c.Set[field]; ok {
+			return fmt.Errorf("field %v cannot be both set and incremented", field)
+		}
+	}
+	return c.Expect.Validate()
+}
+
+// ElementChange changes the element with the given id in the array field of a document. Instead of
+// changing the fields of the element, it can push new element with the id to the array or pull
+// the element from the array.
+type ElementChange struct {
+	ArrayField string
+	ElementId  any
+	FieldChange
+	// Push is the element appended to the array, ErrConflict is returned if there already is one with the id
+	Push any
+	// Pull removes the element from the array
+	Pull bool
+}
+
+// ValidateElementChanges checks the changes can be applied in one update. The elements of an array
+// can be changed, pushed and pulled in the same update, but each element only once.
+func ValidateElementChanges(changes []ElementChange) error {
+	if len(changes) == 0 {
+		return fmt.Errorf("update requires element changes")
+	}
+	elements := map[string]bool{}
+	for _, change := range changes {
+		if change.ArrayField == "" {
+			return fmt.Errorf("element change requires array field")
+		}
+		kind := change.kind()
+		switch {
+		case change.Push != nil && change.Pull:
+			return fmt.Errorf("element %v cannot be both pushed and pulled", change.ElementId)
+		case kind == "change":
+			if err := change.FieldChange.Validate(); err != nil {
+				return err
+			}
+		case !change.FieldChange.IsEmpty() || !change.Expect.IsEmpty():
+			return fmt.Errorf("%v of element %v cannot change its fields", kind, change.ElementId)
+		}
+		key := fmt.Sprintf("%v/%v", change.ArrayField, change.ElementId)
+		if elements[key] {
+			return fmt.Errorf("element %v of %v can be changed only once in one update", change.ElementId, change.ArrayField)
+		}
+		elements[key] = true
+	}
+	return nil
+}
+
+// kind tells whether the change pushes, pulls or changes the element
+func (c ElementChange) kind() string {
+	switch {
+	case c.Push != nil:
+		return "push"
+	case c.Pull:
+		return "pull"
+	default:
+		return "change"
+	}
+}
+
+// changesArraysInOneWay reports whether the elements of each array are either all changed, or pushed, or pulled
+func changesArraysInOneWay(changes []ElementChange) bool {
+	kinds := map[string]string{}
+	for _, change := range changes {
+		if previous, ok := kinds[change.ArrayField]; ok && previous != change.kind() {
+			return false
+		}
+		kinds[change.ArrayField] = change.kind()
+	}
+	return true
+}
@@ -23,4 +23,12 @@ type AmbulancesAPI interface {
 	// DeleteAmbulance Delete /api/ambulance/:ambulanceId
 	// Deletes specific ambulance
 	DeleteAmbulance(c *gin.Context)
+
+	// GetAmbulances Get /api/ambulance
+	// Provides list of ambulances
+	GetAmbulances(c *gin.Context)
+
+	// UpdateAmbulance Put /api/ambulance/:ambulanceId
+	// Updates details of specific ambulance
+	UpdateAmbulance(c *gin.Context)
 }
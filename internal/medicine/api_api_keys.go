@@ -0,0 +1,30 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type ApiKeysAPI interface {
+
+	// GetApiKeys Get /api/api-keys
+	// Provides list of API keys
+	GetApiKeys(c *gin.Context)
+
+	// IssueApiKey Post /api/api-keys
+	// Issues new API key
+	IssueApiKey(c *gin.Context)
+
+	// RevokeApiKey Delete /api/api-keys/:keyId
+	// Revokes API key
+	RevokeApiKey(c *gin.Context)
+}
@@ -0,0 +1,26 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type ExportsAPI interface {
+
+	// ExportMedicineInventory Get /api/export/medicine-inventory
+	// Exports medicine inventory of all ambulances
+	ExportMedicineInventory(c *gin.Context)
+
+	// ExportMedicineOrders Get /api/export/medicine-orders
+	// Exports medicine orders of all ambulances
+	ExportMedicineOrders(c *gin.Context)
+}
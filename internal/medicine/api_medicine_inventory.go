@@ -16,6 +16,10 @@ import (
 
 type MedicineInventoryAPI interface {
 
+	// BatchMedicineInventoryEntries Post /api/medicine-inventory/:ambulanceId/batch
+	// Applies multiple changes to the medicine inventory
+	BatchMedicineInventoryEntries(c *gin.Context)
+
 	// DeleteMedicineInventoryEntry Delete /api/medicine-inventory/:ambulanceId/entries/:entryId
 	// Deletes specific entry
 	DeleteMedicineInventoryEntry(c *gin.Context)
@@ -28,6 +32,10 @@ type MedicineInventoryAPI interface {
 	// Provides details about ambulance medicine inventory entry
 	GetMedicineInventoryEntry(c *gin.Context)
 
+	// ImportMedicineInventory Post /api/medicine-inventory/:ambulanceId/import
+	// Imports medicine inventory from CSV
+	ImportMedicineInventory(c *gin.Context)
+
 	// UpdateMedicineInventoryEntry Put /api/medicine-inventory/:ambulanceId/entries/:entryId
 	// Updates specific entry
 	UpdateMedicineInventoryEntry(c *gin.Context)
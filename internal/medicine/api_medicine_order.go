@@ -16,6 +16,10 @@ import (
 
 type MedicineOrderAPI interface {
 
+	// BatchMedicineOrderEntries Post /api/medicine-order/:ambulanceId/batch
+	// Applies multiple changes to the medicine orders
+	BatchMedicineOrderEntries(c *gin.Context)
+
 	// CreateMedicineOrderEntry Post /api/medicine-order/:ambulanceId/entries
 	// Saves new entry into medicine order
 	CreateMedicineOrderEntry(c *gin.Context)
@@ -0,0 +1,42 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type OrderTemplatesAPI interface {
+
+	// CreateOrderTemplate Post /api/medicine-order/:ambulanceId/templates
+	// Saves new order template
+	CreateOrderTemplate(c *gin.Context)
+
+	// DeleteOrderTemplate Delete /api/medicine-order/:ambulanceId/templates/:templateId
+	// Deletes specific order template
+	DeleteOrderTemplate(c *gin.Context)
+
+	// GetOrderTemplate Get /api/medicine-order/:ambulanceId/templates/:templateId
+	// Provides details about order template
+	GetOrderTemplate(c *gin.Context)
+
+	// GetOrderTemplates Get /api/medicine-order/:ambulanceId/templates
+	// Provides order templates of the ambulance
+	GetOrderTemplates(c *gin.Context)
+
+	// InstantiateOrderTemplate Post /api/medicine-order/:ambulanceId/templates/:templateId/instantiate
+	// Creates orders from the order template
+	InstantiateOrderTemplate(c *gin.Context)
+
+	// UpdateOrderTemplate Put /api/medicine-order/:ambulanceId/templates/:templateId
+	// Updates specific order template
+	UpdateOrderTemplate(c *gin.Context)
+}
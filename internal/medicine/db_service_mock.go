@@ -3,6 +3,7 @@ package medicine
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"github.com/undy45/medicine-webapi/internal/db_service"
 )
 
 type DbServiceMock[DocType interface{}] struct {
@@ -24,11 +25,51 @@ func (this *DbServiceMock[DocType]) FindAllDocuments(ctx context.Context) ([]*Do
 	return args.Get(0).([]*DocType), args.Error(1)
 }
 
+func (this *DbServiceMock[DocType]) FindDocuments(ctx context.Context, query db_service.Query) ([]*DocType, error) {
+	args := this.Called(ctx, query)
+	return args.Get(0).([]*DocType), args.Error(1)
+}
+
+func (this *DbServiceMock[DocType]) CountDocuments(ctx context.Context, filter db_service.Filter) (int64, error) {
+	args := this.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (this *DbServiceMock[DocType]) UpdateDocument(ctx context.Context, id any, document *DocType) error {
 	args := this.Called(ctx, id, document)
 	return args.Error(0)
 }
 
+func (this *DbServiceMock[DocType]) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	args := this.Called(ctx, id, arrayField, elementId, element)
+	return args.Error(0)
+}
+
+func (this *DbServiceMock[DocType]) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	args := this.Called(ctx, id, arrayField, elementId)
+	return args.Error(0)
+}
+
+func (this *DbServiceMock[DocType]) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	args := this.Called(ctx, id, arrayField, elementId, fields)
+	return args.Error(0)
+}
+
+func (this *DbServiceMock[DocType]) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	args := this.Called(ctx, id, arrayField, elementId, field, delta)
+	return args.Error(0)
+}
+
+func (this *DbServiceMock[DocType]) UpdateFields(ctx context.Context, id any, change db_service.FieldChange) error {
+	args := this.Called(ctx, id, change)
+	return args.Error(0)
+}
+
+func (this *DbServiceMock[DocType]) UpdateElements(ctx context.Context, id any, changes ...db_service.ElementChange) error {
+	args := this.Called(ctx, id, changes)
+	return args.Error(0)
+}
+
 func (this *DbServiceMock[DocType]) DeleteDocument(ctx context.Context, id any) error {
 	args := this.Called(ctx, id)
 	return args.Error(0)
@@ -0,0 +1,748 @@
+package medicine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AmbulanceElement is an inventory entry or order stored in its own collection in the split storage layout.
+// Entry ids are unique only within the ambulance, so the document id combines both ids.
+type AmbulanceElement[E any] struct {
+	Id          string
+	AmbulanceId string
+	// order of the entry within the ambulance
+	Position int64
+	Entry    E
+}
+
+type StoredInventoryEntry = AmbulanceElement[MedicineInventoryEntry]
+type StoredOrderEntry = AmbulanceElement[MedicineOrderEntry]
+
+type splitAmbulanceService struct {
+	ambulances db_service.DbService[Ambulance]
+	inventory  elementStore[MedicineInventoryEntry]
+	orders     elementStore[MedicineOrderEntry]
+}
+
+// NewSplitAmbulanceService returns ambulance service for the split storage layout. Ambulance documents
+// keep everything except the inventory and the orders, which are stored in their own collections.
+// The handlers see the same ambulances as with the embedded layout. Changes of single entries
+// touch only their own documents, whole ambulance updates are not atomic across the collections.
+func NewSplitAmbulanceService(
+	ambulances db_service.DbService[Ambulance],
+	inventory db_service.DbService[StoredInventoryEntry],
+	orders db_service.DbService[StoredOrderEntry],
+) db_service.DbService[Ambulance] {
+	return newSplitAmbulanceService(ambulances, inventory, orders)
+}
+
+func newSplitAmbulanceService(
+	ambulances db_service.DbService[Ambulance],
+	inventory db_service.DbService[StoredInventoryEntry],
+	orders db_service.DbService[StoredOrderEntry],
+) *splitAmbulanceService {
+	return &splitAmbulanceService{
+		ambulances: ambulances,
+		inventory: elementStore[MedicineInventoryEntry]{
+			db:      inventory,
+			entryId: func(entry MedicineInventoryEntry) string { return entry.Id },
+		},
+		orders: elementStore[MedicineOrderEntry]{
+			db:      orders,
+			entryId: func(entry MedicineOrderEntry) string { return entry.Id },
+		},
+	}
+}
+
+func (s *splitAmbulanceService) CreateDocument(ctx context.Context, id any, document *Ambulance) error {
+	if err := validateElementAmbulanceId(id); err != nil {
+		return err
+	}
+	if err := s.ambulances.CreateDocument(ctx, id, stripElements(document)); err != nil {
+		return err
+	}
+	ambulanceId := fmt.Sprint(id)
+	reverts, err := s.inventory.replace(ctx, ambulanceId, document.MedicineInventory)
+	if err == nil {
+		_, err = s.orders.replace(ctx, ambulanceId, document.MedicineOrders)
+	}
+	if err != nil {
+		// the ambulance is not left created without its entries
+		revertChanges(ctx, ambulanceId, append(reverts, func(ctx context.Context) error {
+			return s.ambulances.DeleteDocument(ctx, id)
+		}))
+		return err
+	}
+	return nil
+}
+
+func (s *splitAmbulanceService) FindDocument(ctx context.Context, id any) (*Ambulance, error) {
+	ambulance, err := s.ambulances.FindDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ambulance, s.loadElements(ctx, ambulance, true, true)
+}
+
+func (s *splitAmbulanceService) FindAllDocuments(ctx context.Context) ([]*Ambulance, error) {
+	ambulances, err := s.ambulances.FindAllDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ambulance := range ambulances {
+		if err := s.loadElements(ctx, ambulance, true, true); err != nil {
+			return nil, err
+		}
+	}
+	return ambulances, nil
+}
+
+// FindDocuments loads the inventory and orders only if they are part of the projection.
+// Filters and sorting can refer only to the fields of the ambulance document itself.
+func (s *splitAmbulanceService) FindDocuments(ctx context.Context, query db_service.Query) ([]*Ambulance, error) {
+	withInventory := len(query.Projection) == 0
+	withOrders := len(query.Projection) == 0
+	var projection []string
+	for _, field := range query.Projection {
+		switch field {
+		case inventoryField:
+			withInventory = true
+		case ordersField:
+			withOrders = true
+		default:
+			projection = append(projection, field)
+		}
+	}
+	if len(query.Projection) > 0 {
+		// the id is needed to load the elements
+		query.Projection = append(projection, "id")
+	}
+
+	ambulances, err := s.ambulances.FindDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, ambulance := range ambulances {
+		if err := s.loadElements(ctx, ambulance, withInventory, withOrders); err != nil {
+			return nil, err
+		}
+	}
+	return ambulances, nil
+}
+
+func (s *splitAmbulanceService) CountDocuments(ctx context.Context, filter db_service.Filter) (int64, error) {
+	return s.ambulances.CountDocuments(ctx, filter)
+}
+
+func (s *splitAmbulanceService) UpdateDocument(ctx context.Context, id any, document *Ambulance) error {
+	if err := validateElementAmbulanceId(id); err != nil {
+		return err
+	}
+	if err := s.requireAmbulance(ctx, id); err != nil {
+		return err
+	}
+	// elements are written first, so an interrupted update never loses them, and when a later write
+	// fails the earlier ones are reverted
+	ambulanceId := fmt.Sprint(id)
+	reverts, err := s.inventory.replace(ctx, ambulanceId, document.MedicineInventory)
+	if err != nil {
+		return err
+	}
+	orderReverts, err := s.orders.replace(ctx, ambulanceId, document.MedicineOrders)
+	reverts = append(reverts, orderReverts...)
+	if err == nil {
+		err = s.ambulances.UpdateDocument(ctx, id, stripElements(document))
+	}
+	if err != nil {
+		revertChanges(ctx, ambulanceId, reverts)
+		return err
+	}
+	return nil
+}
+
+func (s *splitAmbulanceService) PushElement(ctx context.Context, id any, arrayField string, elementId any, element any) error {
+	switch arrayField {
+	case inventoryField:
+		entry, ok := element.(MedicineInventoryEntry)
+		if !ok {
+			return fmt.Errorf("element of %v must be MedicineInventoryEntry, got %T", arrayField, element)
+		}
+		if err := s.requireAmbulance(ctx, id); err != nil {
+			return err
+		}
+		return s.inventory.push(ctx, fmt.Sprint(id), entry)
+	case ordersField:
+		entry, ok := element.(MedicineOrderEntry)
+		if !ok {
+			return fmt.Errorf("element of %v must be MedicineOrderEntry, got %T", arrayField, element)
+		}
+		if err := s.requireAmbulance(ctx, id); err != nil {
+			return err
+		}
+		return s.orders.push(ctx, fmt.Sprint(id), entry)
+	default:
+		return s.ambulances.PushElement(ctx, id, arrayField, elementId, element)
+	}
+}
+
+func (s *splitAmbulanceService) PullElement(ctx context.Context, id any, arrayField string, elementId any) error {
+	switch arrayField {
+	case inventoryField:
+		return s.inventory.db.DeleteDocument(ctx, elementKey(id, elementId))
+	case ordersField:
+		return s.orders.db.DeleteDocument(ctx, elementKey(id, elementId))
+	default:
+		return s.ambulances.PullElement(ctx, id, arrayField, elementId)
+	}
+}
+
+func (s *splitAmbulanceService) SetElementFields(ctx context.Context, id any, arrayField string, elementId any, fields map[string]any) error {
+	change := db_service.FieldChange{Set: fields}
+	switch arrayField {
+	case inventoryField:
+		return s.inventory.change(ctx, fmt.Sprint(id), fmt.Sprint(elementId), change)
+	case ordersField:
+		return s.orders.change(ctx, fmt.Sprint(id), fmt.Sprint(elementId), change)
+	default:
+		return s.ambulances.SetElementFields(ctx, id, arrayField, elementId, fields)
+	}
+}
+
+func (s *splitAmbulanceService) IncrementElementField(ctx context.Context, id any, arrayField string, elementId any, field string, delta int64) error {
+	change := db_service.FieldChange{Increment: map[string]int64{field: delta}}
+	switch arrayField {
+	case inventoryField:
+		return s.inventory.change(ctx, fmt.Sprint(id), fmt.Sprint(elementId), change)
+	case ordersField:
+		return s.orders.change(ctx, fmt.Sprint(id), fmt.Sprint(elementId), change)
+	default:
+		return s.ambulances.IncrementElementField(ctx, id, arrayField, elementId, field, delta)
+	}
+}
+
+// UpdateFields changes the fields of the ambulance document, the filter and the fields cannot refer
+// to the inventory and the orders
+func (s *splitAmbulanceService) UpdateFields(ctx context.Context, id any, change db_service.FieldChange) error {
+	return s.ambulances.UpdateFields(ctx, id, change)
+}
+
+// UpdateElements applies the changes one by one in their order. The inventory and the orders are stored
+// in their own collections, so the changes are not atomic. When a change fails, the changes applied
+// before it are reverted, which overwrites the fields they set even if somebody changed them meanwhile.
+func (s *splitAmbulanceService) UpdateElements(ctx context.Context, id any, changes ...db_service.ElementChange) error {
+	if err := db_service.ValidateElementChanges(changes); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.Push != nil && (change.ArrayField == inventoryField || change.ArrayField == ordersField) {
+			if err := s.requireAmbulance(ctx, id); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	ambulanceId := fmt.Sprint(id)
+	var embedded []db_service.ElementChange
+	var reverts []func(ctx context.Context) error
+	for _, change := range changes {
+		var revert func(ctx context.Context) error
+		var err error
+		switch change.ArrayField {
+		case inventoryField:
+			revert, err = s.inventory.apply(ctx, ambulanceId, change)
+		case ordersField:
+			revert, err = s.orders.apply(ctx, ambulanceId, change)
+		default:
+			embedded = append(embedded, change)
+			continue
+		}
+		if err != nil {
+			revertChanges(ctx, ambulanceId, reverts)
+			return err
+		}
+		reverts = append(reverts, revert)
+	}
+	if len(embedded) > 0 {
+		if err := s.ambulances.UpdateElements(ctx, id, embedded...); err != nil {
+			revertChanges(ctx, ambulanceId, reverts)
+			return err
+		}
+	}
+	return nil
+}
+
+// revertChanges reverts the applied changes in the reverse order
+func revertChanges(ctx context.Context, ambulanceId string, reverts []func(ctx context.Context) error) {
+	for i := len(reverts) - 1; i >= 0; i-- {
+		if err := reverts[i](ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to revert element change", "ambulanceId", ambulanceId, "error", err)
+		}
+	}
+}
+
+func (s *splitAmbulanceService) DeleteDocument(ctx context.Context, id any) error {
+	if err := s.ambulances.DeleteDocument(ctx, id); err != nil {
+		return err
+	}
+	ambulanceId := fmt.Sprint(id)
+	if err := s.inventory.remove(ctx, ambulanceId); err != nil {
+		return err
+	}
+	return s.orders.remove(ctx, ambulanceId)
+}
+
+func (s *splitAmbulanceService) Disconnect(ctx context.Context) error {
+	return errors.Join(
+		s.ambulances.Disconnect(ctx),
+		s.inventory.db.Disconnect(ctx),
+		s.orders.db.Disconnect(ctx),
+	)
+}
+
+func (s *splitAmbulanceService) requireAmbulance(ctx context.Context, id any) error {
+	count, err := s.ambulances.CountDocuments(ctx, db_service.Eq("id", id))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return db_service.ErrNotFound
+	}
+	return nil
+}
+
+func (s *splitAmbulanceService) loadElements(ctx context.Context, ambulance *Ambulance, withInventory bool, withOrders bool) error {
+	var err error
+	if withInventory {
+		if ambulance.MedicineInventory, err = s.inventory.load(ctx, ambulance.Id); err != nil {
+			return err
+		}
+	}
+	if withOrders {
+		if ambulance.MedicineOrders, err = s.orders.load(ctx, ambulance.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripElements returns copy of the ambulance without the elements stored in their own collections
+func stripElements(ambulance *Ambulance) *Ambulance {
+	stripped := *ambulance
+	stripped.MedicineInventory = nil
+	stripped.MedicineOrders = nil
+	return &stripped
+}
+
+func elementKey(ambulanceId any, entryId any) string {
+	return fmt.Sprintf("%v/%v", ambulanceId, entryId)
+}
+
+// validateElementAmbulanceId rejects the ambulance ids which would make the element keys ambiguous. Without
+// the separator in the ambulance id, every element key has exactly one ambulance and entry id, and it never
+// equals the key of a position sequence, which is the bare ambulance id.
+func validateElementAmbulanceId(id any) error {
+	if strings.Contains(fmt.Sprint(id), "/") {
+		return fmt.Errorf("ambulance id %v cannot contain / in the split storage layout", id)
+	}
+	return nil
+}
+
+// elementStore keeps entries of one kind in their own collection. Besides the entries, the collection holds
+// the position sequence of every ambulance, a document with the ambulance id as its key and without
+// the ambulance id field, whose position is the next free position of the entries of the ambulance.
+type elementStore[E any] struct {
+	db      db_service.DbService[AmbulanceElement[E]]
+	entryId func(entry E) string
+}
+
+func (s elementStore[E]) load(ctx context.Context, ambulanceId string) ([]E, error) {
+	stored, err := s.db.FindDocuments(ctx, db_service.Query{
+		Filter: db_service.Eq("ambulanceid", ambulanceId),
+		Sort:   []db_service.SortField{{Field: "position"}, {Field: "id"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []E
+	for _, element := range stored {
+		entries = append(entries, element.Entry)
+	}
+	return entries, nil
+}
+
+// replace makes the stored entries of the ambulance equal to the given entries. Only the changed entries
+// are written, the stored entries keep their positions and the new entries are appended after the last one.
+// The entries deleted meanwhile are not created again. Returns the functions reverting the writes, if a write
+// fails the writes before it are reverted.
+func (s elementStore[E]) replace(ctx context.Context, ambulanceId string, entries []E) ([]func(ctx context.Context) error, error) {
+	stored, err := s.db.FindDocuments(ctx, db_service.Query{Filter: db_service.Eq("ambulanceid", ambulanceId)})
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]*AmbulanceElement[E]{}
+	for _, element := range stored {
+		existing[element.Id] = element
+	}
+
+	var reverts []func(ctx context.Context) error
+	fail := func(err error) ([]func(ctx context.Context) error, error) {
+		revertChanges(ctx, ambulanceId, reverts)
+		return nil, err
+	}
+	for _, entry := range entries {
+		key := elementKey(ambulanceId, s.entryId(entry))
+		element, ok := existing[key]
+		if !ok {
+			if err := s.push(ctx, ambulanceId, entry); err != nil {
+				return fail(err)
+			}
+			reverts = append(reverts, func(ctx context.Context) error {
+				return s.db.DeleteDocument(ctx, key)
+			})
+			continue
+		}
+		delete(existing, key)
+		if reflect.DeepEqual(element.Entry, entry) {
+			continue
+		}
+		err := s.db.UpdateFields(ctx, key, db_service.FieldChange{Set: map[string]any{"entry": entry}})
+		if errors.Is(err, db_service.ErrNotFound) {
+			// deleted meanwhile
+			continue
+		}
+		if err != nil {
+			return fail(err)
+		}
+		previous := element.Entry
+		reverts = append(reverts, func(ctx context.Context) error {
+			return s.db.UpdateFields(ctx, key, db_service.FieldChange{Set: map[string]any{"entry": previous}})
+		})
+	}
+
+	for key, element := range existing {
+		err := s.db.DeleteDocument(ctx, key)
+		if errors.Is(err, db_service.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fail(err)
+		}
+		reverts = append(reverts, func(ctx context.Context) error {
+			return s.db.CreateDocument(ctx, key, element)
+		})
+	}
+	return reverts, nil
+}
+
+// remove deletes the entries and the position sequence of the ambulance
+func (s elementStore[E]) remove(ctx context.Context, ambulanceId string) error {
+	if _, err := s.replace(ctx, ambulanceId, nil); err != nil {
+		return err
+	}
+	if err := s.db.DeleteDocument(ctx, ambulanceId); err != nil && !errors.Is(err, db_service.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// push stores the entry after the last entry of the ambulance
+func (s elementStore[E]) push(ctx context.Context, ambulanceId string, entry E) error {
+	position, err := s.reservePositions(ctx, ambulanceId, 1)
+	if err != nil {
+		return err
+	}
+	element := AmbulanceElement[E]{
+		Id:          elementKey(ambulanceId, s.entryId(entry)),
+		AmbulanceId: ambulanceId,
+		Position:    position,
+		Entry:       entry,
+	}
+	return s.db.CreateDocument(ctx, element.Id, &element)
+}
+
+// reservePositions returns the first of count positions no other entry of the ambulance has or gets.
+// The position sequence is advanced only if nobody advanced it since it was read, so concurrent
+// reservations are retried instead of sharing the positions.
+func (s elementStore[E]) reservePositions(ctx context.Context, ambulanceId string, count int64) (int64, error) {
+	for {
+		sequence, err := s.db.FindDocument(ctx, ambulanceId)
+		if errors.Is(err, db_service.ErrNotFound) {
+			// the sequence continues after the entries stored before it was created
+			next, err := s.nextPosition(ctx, ambulanceId)
+			if err != nil {
+				return 0, err
+			}
+			err = s.db.CreateDocument(ctx, ambulanceId, &AmbulanceElement[E]{Id: ambulanceId, Position: next + count})
+			if errors.Is(err, db_service.ErrConflict) {
+				continue
+			}
+			return next, err
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		err = s.db.UpdateFields(ctx, ambulanceId, db_service.FieldChange{
+			Expect: db_service.Eq("position", sequence.Position),
+			Set:    map[string]any{"position": sequence.Position + count},
+		})
+		if !errors.Is(err, db_service.ErrConflict) {
+			return sequence.Position, err
+		}
+	}
+}
+
+// nextPosition returns the position after the last stored entry of the ambulance
+func (s elementStore[E]) nextPosition(ctx context.Context, ambulanceId string) (int64, error) {
+	last, err := s.db.FindDocuments(ctx, db_service.Query{
+		Filter:     db_service.Eq("ambulanceid", ambulanceId),
+		Projection: []string{"position"},
+		Sort:       []db_service.SortField{{Field: "position", Descending: true}},
+		Limit:      1,
+	})
+	if err != nil || len(last) == 0 {
+		return 0, err
+	}
+	return last[0].Position + 1, nil
+}
+
+// change atomically applies the change to the stored entry, the fields of the change are the fields
+// of the entry. If the entry id changes, the entry is moved under its new key, which is not atomic.
+func (s elementStore[E]) change(ctx context.Context, ambulanceId string, entryId string, change db_service.FieldChange) error {
+	key := elementKey(ambulanceId, entryId)
+	stored := db_service.FieldChange{Expect: entryFilter(change.Expect)}
+	for field, value := range change.Set {
+		if stored.Set == nil {
+			stored.Set = map[string]any{}
+		}
+		stored.Set["entry."+field] = value
+	}
+	for field, delta := range change.Increment {
+		if stored.Increment == nil {
+			stored.Increment = map[string]int64{}
+		}
+		stored.Increment["entry."+field] = delta
+	}
+	if err := s.db.UpdateFields(ctx, key, stored); err != nil {
+		return err
+	}
+
+	newId, ok := change.Set["id"]
+	if !ok || fmt.Sprint(newId) == entryId {
+		return nil
+	}
+	element, err := s.db.FindDocument(ctx, key)
+	if err != nil {
+		return err
+	}
+	// the entry keeps its position
+	element.Id = elementKey(ambulanceId, newId)
+	if err := s.db.CreateDocument(ctx, element.Id, element); err != nil {
+		return err
+	}
+	return s.db.DeleteDocument(ctx, key)
+}
+
+// apply applies the element change to the stored entries and returns the function reverting it
+func (s elementStore[E]) apply(ctx context.Context, ambulanceId string, change db_service.ElementChange) (func(ctx context.Context) error, error) {
+	entryId := fmt.Sprint(change.ElementId)
+	key := elementKey(ambulanceId, entryId)
+	switch {
+	case change.Push != nil:
+		entry, ok := change.Push.(E)
+		if !ok {
+			return nil, fmt.Errorf("element of %v must be %T, got %T", change.ArrayField, entry, change.Push)
+		}
+		if err := s.push(ctx, ambulanceId, entry); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			return s.db.DeleteDocument(ctx, key)
+		}, nil
+	case change.Pull:
+		element, err := s.db.FindDocument(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.DeleteDocument(ctx, key); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			return s.db.CreateDocument(ctx, key, element)
+		}, nil
+	}
+
+	revert := db_service.FieldChange{}
+	if len(change.Set) > 0 {
+		element, err := s.db.FindDocument(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if revert.Set, err = entryValues(element.Entry, change.Set); err != nil {
+			return nil, err
+		}
+	}
+	for field, delta := range change.Increment {
+		if revert.Increment == nil {
+			revert.Increment = map[string]int64{}
+		}
+		revert.Increment[field] = -delta
+	}
+	if err := s.change(ctx, ambulanceId, entryId, change.FieldChange); err != nil {
+		return nil, err
+	}
+	if newId, ok := change.Set["id"]; ok {
+		entryId = fmt.Sprint(newId)
+	}
+	return func(ctx context.Context) error {
+		return s.change(ctx, ambulanceId, entryId, revert)
+	}, nil
+}
+
+// entryValues returns the current values of the fields of the entry
+func entryValues[E any](entry E, fields map[string]any) (map[string]any, error) {
+	encoded, err := bson.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	var document bson.M
+	if err := bson.Unmarshal(encoded, &document); err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	for field := range fields {
+		var value any = document
+		for _, name := range strings.Split(field, ".") {
+			nested, _ := value.(bson.M)
+			value = nested[name]
+		}
+		values[field] = value
+	}
+	return values, nil
+}
+
+// entryFilter returns the filter of the entry fields as the filter of the stored element
+func entryFilter(filter db_service.Filter) db_service.Filter {
+	if filter.Field != "" {
+		filter.Field = "entry." + filter.Field
+	}
+	if len(filter.Filters) > 0 {
+		nested := make([]db_service.Filter, len(filter.Filters))
+		for i, item := range filter.Filters {
+			nested[i] = entryFilter(item)
+		}
+		filter.Filters = nested
+	}
+	return filter
+}
+
+// SplitAmbulanceDocuments moves the inventory and orders embedded in the ambulance documents into their own
+// collections. Entries are stored before they are removed from the ambulance document, so the migration
+// can be safely repeated if it is interrupted. Returns number of migrated ambulances.
+func SplitAmbulanceDocuments(
+	ctx context.Context,
+	embedded db_service.DbService[Ambulance],
+	split db_service.DbService[Ambulance],
+) (int, error) {
+	ambulances, err := embedded.FindAllDocuments(ctx)
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, ambulance := range ambulances {
+		if len(ambulance.MedicineInventory) == 0 && len(ambulance.MedicineOrders) == 0 {
+			// already split, or nothing to move
+			continue
+		}
+		// merge with the entries already moved by an interrupted run, embedded entries take precedence
+		current, err := split.FindDocument(ctx, ambulance.Id)
+		if err != nil {
+			return migrated, err
+		}
+		ambulance.MedicineInventory = mergeElements(current.MedicineInventory, ambulance.MedicineInventory,
+			func(entry MedicineInventoryEntry) string { return entry.Id })
+		ambulance.MedicineOrders = mergeElements(current.MedicineOrders, ambulance.MedicineOrders,
+			func(entry MedicineOrderEntry) string { return entry.Id })
+		if err := split.UpdateDocument(ctx, ambulance.Id, ambulance); err != nil {
+			return migrated, err
+		}
+		slog.InfoContext(ctx, "Moved inventory and orders into their own collections",
+			"ambulanceId", ambulance.Id,
+			"inventory", len(ambulance.MedicineInventory),
+			"orders", len(ambulance.MedicineOrders),
+		)
+		migrated++
+	}
+	return migrated, nil
+}
+
+// EmbedAmbulanceDocuments moves the inventory and orders from their own collections back into the ambulance
+// documents, it reverts SplitAmbulanceDocuments. Entries are removed from their collections only after they are
+// stored in the ambulance document, so the migration can be safely repeated if it is interrupted.
+// Returns number of migrated ambulances.
+func EmbedAmbulanceDocuments(
+	ctx context.Context,
+	ambulances db_service.DbService[Ambulance],
+	inventory db_service.DbService[StoredInventoryEntry],
+	orders db_service.DbService[StoredOrderEntry],
+) (int, error) {
+	split := newSplitAmbulanceService(ambulances, inventory, orders)
+	embedded, err := ambulances.FindAllDocuments(ctx)
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, ambulance := range embedded {
+		stored := &Ambulance{Id: ambulance.Id}
+		if err := split.loadElements(ctx, stored, true, true); err != nil {
+			return migrated, err
+		}
+		if len(stored.MedicineInventory) == 0 && len(stored.MedicineOrders) == 0 {
+			// already embedded, or nothing to move
+			continue
+		}
+		// merge with the entries embedded by an interrupted run, stored entries take precedence
+		ambulance.MedicineInventory = mergeElements(ambulance.MedicineInventory, stored.MedicineInventory,
+			func(entry MedicineInventoryEntry) string { return entry.Id })
+		ambulance.MedicineOrders = mergeElements(ambulance.MedicineOrders, stored.MedicineOrders,
+			func(entry MedicineOrderEntry) string { return entry.Id })
+		if err := ambulances.UpdateDocument(ctx, ambulance.Id, ambulance); err != nil {
+			return migrated, err
+		}
+		if err := split.inventory.remove(ctx, ambulance.Id); err != nil {
+			return migrated, err
+		}
+		if err := split.orders.remove(ctx, ambulance.Id); err != nil {
+			return migrated, err
+		}
+		slog.InfoContext(ctx, "Moved inventory and orders into the ambulance document",
+			"ambulanceId", ambulance.Id,
+			"inventory", len(ambulance.MedicineInventory),
+			"orders", len(ambulance.MedicineOrders),
+		)
+		migrated++
+	}
+	return migrated, nil
+}
+
+func mergeElements[E any](stored []E, embedded []E, entryId func(E) string) []E {
+	embeddedIds := map[string]bool{}
+	for _, entry := range embedded {
+		embeddedIds[entryId(entry)] = true
+	}
+	var result []E
+	for _, entry := range stored {
+		if !embeddedIds[entryId(entry)] {
+			result = append(result, entry)
+		}
+	}
+	return append(result, embedded...)
+}
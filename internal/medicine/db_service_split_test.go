@@ -0,0 +1,444 @@
+package medicine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"github.com/undy45/medicine-webapi/internal/db_service/conformance"
+)
+
+type SplitAmbulanceServiceSuite struct {
+	suite.Suite
+	ambulancesMock *DbServiceMock[Ambulance]
+	inventoryMock  *DbServiceMock[StoredInventoryEntry]
+	ordersMock     *DbServiceMock[StoredOrderEntry]
+	sut            db_service.DbService[Ambulance]
+}
+
+func TestSplitAmbulanceServiceSuite(t *testing.T) {
+	suite.Run(t, new(SplitAmbulanceServiceSuite))
+}
+
+func TestSplitAmbulanceService_Conformance(t *testing.T) {
+	newService := func(t *testing.T) db_service.DbService[Ambulance] {
+		return NewSplitAmbulanceService(
+			db_service.NewMemoryService[Ambulance](),
+			db_service.NewMemoryService[StoredInventoryEntry](),
+			db_service.NewMemoryService[StoredOrderEntry](),
+		)
+	}
+	t.Run("inventory", func(t *testing.T) {
+		conformance.Run(t, conformance.Fixture[Ambulance]{
+			NewService: newService,
+			ArrayField: inventoryField,
+			NewElement: func(id string, count int32) any {
+				return MedicineInventoryEntry{Id: id, Count: count}
+			},
+		})
+	})
+	t.Run("orders", func(t *testing.T) {
+		conformance.Run(t, conformance.Fixture[Ambulance]{
+			NewService: newService,
+			ArrayField: ordersField,
+			NewElement: func(id string, count int32) any {
+				return MedicineOrderEntry{Id: id, Count: count}
+			},
+		})
+	})
+}
+
+func (suite *SplitAmbulanceServiceSuite) SetupTest() {
+	suite.ambulancesMock = &DbServiceMock[Ambulance]{}
+	suite.inventoryMock = &DbServiceMock[StoredInventoryEntry]{}
+	suite.ordersMock = &DbServiceMock[StoredOrderEntry]{}
+	suite.sut = NewSplitAmbulanceService(suite.ambulancesMock, suite.inventoryMock, suite.ordersMock)
+}
+
+func byAmbulance(ambulanceId string) any {
+	return mock.MatchedBy(func(query db_service.Query) bool {
+		return query.Filter.Op == db_service.OpEq &&
+			query.Filter.Field == "ambulanceid" &&
+			query.Filter.Value == ambulanceId
+	})
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_FindDocument_LoadsElements() {
+	// ARRANGE
+	suite.ambulancesMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return(&Ambulance{Id: "test-ambulance", Name: "Test"}, nil)
+	suite.inventoryMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredInventoryEntry{
+			{Id: "test-ambulance/a", AmbulanceId: "test-ambulance", Entry: MedicineInventoryEntry{Id: "a", Count: 1}},
+			{Id: "test-ambulance/b", AmbulanceId: "test-ambulance", Position: 1, Entry: MedicineInventoryEntry{Id: "b", Count: 2}},
+		}, nil)
+	suite.ordersMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredOrderEntry{}, nil)
+
+	// ACT
+	ambulance, err := suite.sut.FindDocument(context.Background(), "test-ambulance")
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal("Test", ambulance.Name)
+	suite.Equal([]MedicineInventoryEntry{{Id: "a", Count: 1}, {Id: "b", Count: 2}}, ambulance.MedicineInventory)
+	suite.Nil(ambulance.MedicineOrders)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_UpdateDocument_SyncsElements() {
+	// ARRANGE
+	suite.ambulancesMock.
+		On("CountDocuments", mock.Anything, db_service.Eq("id", "test-ambulance")).
+		Return(int64(1), nil)
+	suite.ambulancesMock.
+		On("UpdateDocument", mock.Anything, "test-ambulance", mock.Anything).
+		Return(nil)
+	suite.inventoryMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredInventoryEntry{
+			{Id: "test-ambulance/kept", AmbulanceId: "test-ambulance", Entry: MedicineInventoryEntry{Id: "kept", Count: 1}},
+			{Id: "test-ambulance/same", AmbulanceId: "test-ambulance", Position: 1, Entry: MedicineInventoryEntry{Id: "same", Count: 2}},
+			{Id: "test-ambulance/gone", AmbulanceId: "test-ambulance", Position: 2, Entry: MedicineInventoryEntry{Id: "gone", Count: 3}},
+			{Id: "test-ambulance/removed", AmbulanceId: "test-ambulance", Position: 3},
+		}, nil)
+	suite.inventoryMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return(&StoredInventoryEntry{Id: "test-ambulance", Position: 10}, nil)
+	suite.inventoryMock.
+		On("UpdateFields", mock.Anything, "test-ambulance", mock.Anything).
+		Return(nil)
+	suite.inventoryMock.On("UpdateFields", mock.Anything, "test-ambulance/kept", mock.Anything).Return(nil)
+	// deleted by another request after the entries were read
+	suite.inventoryMock.On("UpdateFields", mock.Anything, "test-ambulance/gone", mock.Anything).Return(db_service.ErrNotFound)
+	suite.inventoryMock.On("CreateDocument", mock.Anything, "test-ambulance/added", mock.Anything).Return(nil)
+	suite.inventoryMock.On("DeleteDocument", mock.Anything, "test-ambulance/removed").Return(nil)
+	suite.ordersMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredOrderEntry{}, nil)
+
+	ambulance := &Ambulance{
+		Id: "test-ambulance",
+		MedicineInventory: []MedicineInventoryEntry{
+			{Id: "added", Count: 5},
+			{Id: "kept", Count: 4},
+			{Id: "same", Count: 2},
+			{Id: "gone", Count: 9},
+		},
+	}
+
+	// ACT
+	err := suite.sut.UpdateDocument(context.Background(), "test-ambulance", ambulance)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.ambulancesMock.AssertCalled(suite.T(), "UpdateDocument", mock.Anything, "test-ambulance",
+		mock.MatchedBy(func(stored *Ambulance) bool {
+			return stored.MedicineInventory == nil && stored.MedicineOrders == nil
+		}),
+	)
+	// the changed entry keeps its position
+	suite.inventoryMock.AssertCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance/kept",
+		db_service.FieldChange{Set: map[string]any{"entry": MedicineInventoryEntry{Id: "kept", Count: 4}}},
+	)
+	// the new entry gets the position reserved in the sequence of the ambulance
+	suite.inventoryMock.AssertCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance",
+		db_service.FieldChange{
+			Expect: db_service.Eq("position", int64(10)),
+			Set:    map[string]any{"position": int64(11)},
+		},
+	)
+	suite.inventoryMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance/added",
+		&StoredInventoryEntry{
+			Id:          "test-ambulance/added",
+			AmbulanceId: "test-ambulance",
+			Position:    10,
+			Entry:       MedicineInventoryEntry{Id: "added", Count: 5},
+		},
+	)
+	suite.inventoryMock.AssertNotCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance/same", mock.Anything)
+	suite.inventoryMock.AssertNotCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance/gone", mock.Anything)
+	suite.inventoryMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.inventoryMock.AssertCalled(suite.T(), "DeleteDocument", mock.Anything, "test-ambulance/removed")
+	// the original document is left intact
+	suite.Len(ambulance.MedicineInventory, 4)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_UpdateDocument_FailureRevertsElements() {
+	// ARRANGE
+	removed := &StoredInventoryEntry{Id: "test-ambulance/removed", AmbulanceId: "test-ambulance", Position: 1,
+		Entry: MedicineInventoryEntry{Id: "removed", Count: 2}}
+	suite.ambulancesMock.
+		On("CountDocuments", mock.Anything, db_service.Eq("id", "test-ambulance")).
+		Return(int64(1), nil)
+	suite.ambulancesMock.
+		On("UpdateDocument", mock.Anything, "test-ambulance", mock.Anything).
+		Return(assert.AnError)
+	suite.inventoryMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredInventoryEntry{
+			{Id: "test-ambulance/kept", AmbulanceId: "test-ambulance", Entry: MedicineInventoryEntry{Id: "kept", Count: 1}},
+			removed,
+		}, nil)
+	suite.inventoryMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return(&StoredInventoryEntry{Id: "test-ambulance", Position: 10}, nil)
+	suite.inventoryMock.On("UpdateFields", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.inventoryMock.On("CreateDocument", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.inventoryMock.On("DeleteDocument", mock.Anything, mock.Anything).Return(nil)
+	suite.ordersMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredOrderEntry{}, nil)
+
+	ambulance := &Ambulance{
+		Id: "test-ambulance",
+		MedicineInventory: []MedicineInventoryEntry{
+			{Id: "kept", Count: 4},
+			{Id: "added", Count: 5},
+		},
+	}
+
+	// ACT
+	err := suite.sut.UpdateDocument(context.Background(), "test-ambulance", ambulance)
+
+	// ASSERT
+	suite.ErrorIs(err, assert.AnError)
+	suite.inventoryMock.AssertCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance/kept",
+		db_service.FieldChange{Set: map[string]any{"entry": MedicineInventoryEntry{Id: "kept", Count: 1}}},
+	)
+	suite.inventoryMock.AssertCalled(suite.T(), "DeleteDocument", mock.Anything, "test-ambulance/added")
+	suite.inventoryMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance/removed", removed)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_PushElement_StartsSequenceAfterLastEntry() {
+	// ARRANGE
+	suite.ambulancesMock.
+		On("CountDocuments", mock.Anything, db_service.Eq("id", "test-ambulance")).
+		Return(int64(1), nil)
+	suite.ordersMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return((*StoredOrderEntry)(nil), db_service.ErrNotFound)
+	suite.ordersMock.
+		On("FindDocuments", mock.Anything, byAmbulance("test-ambulance")).
+		Return([]*StoredOrderEntry{{Position: 4}}, nil)
+	suite.ordersMock.
+		On("CreateDocument", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	order := MedicineOrderEntry{Id: "new-order", MedicineId: "med", Count: 2}
+
+	// ACT
+	err := suite.sut.PushElement(context.Background(), "test-ambulance", ordersField, "new-order", order)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.ordersMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance",
+		&StoredOrderEntry{Id: "test-ambulance", Position: 6},
+	)
+	suite.ordersMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance/new-order",
+		&StoredOrderEntry{
+			Id:          "test-ambulance/new-order",
+			AmbulanceId: "test-ambulance",
+			Position:    5,
+			Entry:       order,
+		},
+	)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_CreateDocument_RejectsSeparatorInAmbulanceId() {
+	// ARRANGE
+	// the entry b of the ambulance a and the position sequence of the ambulance a/b would share the key
+	ambulance := &Ambulance{Id: "a/b", MedicineInventory: []MedicineInventoryEntry{{Id: "x", Count: 1}}}
+
+	// ACT
+	createErr := suite.sut.CreateDocument(context.Background(), ambulance.Id, ambulance)
+	updateErr := suite.sut.UpdateDocument(context.Background(), ambulance.Id, ambulance)
+
+	// ASSERT
+	suite.Error(createErr)
+	suite.Error(updateErr)
+	suite.ambulancesMock.AssertNotCalled(suite.T(), "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.inventoryMock.AssertNotCalled(suite.T(), "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_PushElement_RetriesPositionTakenMeanwhile() {
+	// ARRANGE
+	suite.ambulancesMock.
+		On("CountDocuments", mock.Anything, db_service.Eq("id", "test-ambulance")).
+		Return(int64(1), nil)
+	suite.ordersMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return(&StoredOrderEntry{Id: "test-ambulance", Position: 7}, nil).Once()
+	suite.ordersMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return(&StoredOrderEntry{Id: "test-ambulance", Position: 8}, nil).Once()
+	suite.ordersMock.
+		On("UpdateFields", mock.Anything, "test-ambulance", db_service.FieldChange{
+			Expect: db_service.Eq("position", int64(7)),
+			Set:    map[string]any{"position": int64(8)},
+		}).
+		Return(db_service.ErrConflict)
+	suite.ordersMock.
+		On("UpdateFields", mock.Anything, "test-ambulance", db_service.FieldChange{
+			Expect: db_service.Eq("position", int64(8)),
+			Set:    map[string]any{"position": int64(9)},
+		}).
+		Return(nil)
+	suite.ordersMock.
+		On("CreateDocument", mock.Anything, "test-ambulance/new-order", mock.Anything).
+		Return(nil)
+	order := MedicineOrderEntry{Id: "new-order", MedicineId: "med", Count: 2}
+
+	// ACT
+	err := suite.sut.PushElement(context.Background(), "test-ambulance", ordersField, "new-order", order)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.ordersMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance/new-order",
+		&StoredOrderEntry{
+			Id:          "test-ambulance/new-order",
+			AmbulanceId: "test-ambulance",
+			Position:    8,
+			Entry:       order,
+		},
+	)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_PushElement_MissingAmbulance() {
+	// ARRANGE
+	suite.ambulancesMock.
+		On("CountDocuments", mock.Anything, db_service.Eq("id", "missing")).
+		Return(int64(0), nil)
+
+	// ACT
+	err := suite.sut.PushElement(context.Background(), "missing", inventoryField, "a", MedicineInventoryEntry{Id: "a"})
+
+	// ASSERT
+	suite.ErrorIs(err, db_service.ErrNotFound)
+	suite.inventoryMock.AssertNotCalled(suite.T(), "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_ElementFieldChanges() {
+	// ARRANGE
+	suite.inventoryMock.On("UpdateFields", mock.Anything, "test-ambulance/a", mock.Anything).Return(nil)
+	suite.inventoryMock.
+		On("FindDocument", mock.Anything, "test-ambulance/a").
+		Return(&StoredInventoryEntry{
+			Id:          "test-ambulance/a",
+			AmbulanceId: "test-ambulance",
+			Position:    2,
+			Entry:       MedicineInventoryEntry{Id: "b", Name: "Renamed", MedicineId: "med", Count: 7},
+		}, nil)
+	suite.inventoryMock.On("CreateDocument", mock.Anything, "test-ambulance/b", mock.Anything).Return(nil)
+	suite.inventoryMock.On("DeleteDocument", mock.Anything, "test-ambulance/a").Return(nil)
+
+	// ACT
+	incrementErr := suite.sut.IncrementElementField(context.Background(), "test-ambulance", inventoryField, "a", "count", 4)
+	setErr := suite.sut.SetElementFields(context.Background(), "test-ambulance", inventoryField, "a",
+		map[string]any{"id": "b", "name": "Renamed"})
+
+	// ASSERT
+	suite.Require().NoError(incrementErr)
+	suite.Require().NoError(setErr)
+	// the stored entries are changed in place, without rewriting them
+	suite.inventoryMock.AssertCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance/a",
+		db_service.FieldChange{
+			Increment: map[string]int64{"entry.count": 4},
+		},
+	)
+	suite.inventoryMock.AssertCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance/a",
+		db_service.FieldChange{
+			Set: map[string]any{"entry.id": "b", "entry.name": "Renamed"},
+		},
+	)
+	suite.inventoryMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	// changed id moves the entry under the new key
+	suite.inventoryMock.AssertCalled(suite.T(), "CreateDocument", mock.Anything, "test-ambulance/b",
+		&StoredInventoryEntry{
+			Id:          "test-ambulance/b",
+			AmbulanceId: "test-ambulance",
+			Position:    2,
+			Entry:       MedicineInventoryEntry{Id: "b", Name: "Renamed", MedicineId: "med", Count: 7},
+		},
+	)
+	suite.inventoryMock.AssertCalled(suite.T(), "DeleteDocument", mock.Anything, "test-ambulance/a")
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_SplitAmbulanceDocuments() {
+	// ARRANGE
+	embeddedMock := &DbServiceMock[Ambulance]{}
+	splitMock := &DbServiceMock[Ambulance]{}
+	embeddedMock.
+		On("FindAllDocuments", mock.Anything).
+		Return([]*Ambulance{
+			{Id: "already-split"},
+			{
+				Id:                "embedded",
+				MedicineInventory: []MedicineInventoryEntry{{Id: "a", Count: 5}},
+			},
+		}, nil)
+	splitMock.
+		On("FindDocument", mock.Anything, "embedded").
+		Return(&Ambulance{
+			Id: "embedded",
+			// moved by an interrupted run
+			MedicineInventory: []MedicineInventoryEntry{{Id: "a", Count: 1}},
+			MedicineOrders:    []MedicineOrderEntry{{Id: "o"}},
+		}, nil)
+	splitMock.On("UpdateDocument", mock.Anything, "embedded", mock.Anything).Return(nil)
+
+	// ACT
+	migrated, err := SplitAmbulanceDocuments(context.Background(), embeddedMock, splitMock)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal(1, migrated)
+	splitMock.AssertCalled(suite.T(), "UpdateDocument", mock.Anything, "embedded",
+		&Ambulance{
+			Id:                "embedded",
+			MedicineInventory: []MedicineInventoryEntry{{Id: "a", Count: 5}},
+			MedicineOrders:    []MedicineOrderEntry{{Id: "o"}},
+		},
+	)
+	splitMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, "already-split", mock.Anything)
+}
+
+func (suite *SplitAmbulanceServiceSuite) Test_EmbedAmbulanceDocuments_RevertsSplit() {
+	// ARRANGE
+	ctx := context.Background()
+	ambulances := db_service.NewMemoryService[Ambulance]()
+	inventory := db_service.NewMemoryService[StoredInventoryEntry]()
+	orders := db_service.NewMemoryService[StoredOrderEntry]()
+	original := &Ambulance{
+		Id:                "test-ambulance",
+		Name:              "Test",
+		MedicineInventory: []MedicineInventoryEntry{{Id: "a", Count: 5}, {Id: "b", Count: 1}},
+		MedicineOrders:    []MedicineOrderEntry{{Id: "o", Count: 2}},
+	}
+	suite.Require().NoError(ambulances.CreateDocument(ctx, original.Id, original))
+	_, err := SplitAmbulanceDocuments(ctx, ambulances, NewSplitAmbulanceService(ambulances, inventory, orders))
+	suite.Require().NoError(err)
+
+	// ACT
+	migrated, err := EmbedAmbulanceDocuments(ctx, ambulances, inventory, orders)
+	repeated, repeatedErr := EmbedAmbulanceDocuments(ctx, ambulances, inventory, orders)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal(1, migrated)
+	suite.Require().NoError(repeatedErr)
+	suite.Equal(0, repeated, "embedded ambulances are skipped")
+	embedded, err := ambulances.FindDocument(ctx, original.Id)
+	suite.Require().NoError(err)
+	suite.Equal(original, embedded)
+	storedInventory, err := inventory.FindAllDocuments(ctx)
+	suite.Require().NoError(err)
+	suite.Empty(storedInventory, "the entries and the position sequence are removed")
+	storedOrders, err := orders.FindAllDocuments(ctx)
+	suite.Require().NoError(err)
+	suite.Empty(storedOrders)
+}
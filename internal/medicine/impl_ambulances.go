@@ -1,10 +1,14 @@
 package medicine
 
 import (
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/undy45/medicine-webapi/internal/db_service"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type implAmbulancesAPI struct {
@@ -56,6 +60,28 @@ func (o implAmbulancesAPI) CreateAmbulance(c *gin.Context) {
 		ambulance.Id = uuid.New().String()
 	}
 
+	if strings.Contains(ambulance.Id, "/") {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "Invalid ambulance id",
+				"error":   "id of the ambulance cannot contain /",
+			})
+		return
+	}
+
+	if !ambulance.DuplicateOrderPolicy.isValid() {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "Unknown duplicate order policy",
+				"error":   "duplicateOrderPolicy must be one of: reject, merge",
+			})
+		return
+	}
+
 	err = db.CreateDocument(c, ambulance.Id, &ambulance)
 
 	switch err {
@@ -85,6 +111,54 @@ func (o implAmbulancesAPI) CreateAmbulance(c *gin.Context) {
 	}
 }
 
+// UpdateAmbulance changes the details of the ambulance. Only the detail fields are written, so the entries
+// of the ambulance changed meanwhile are kept.
+func (o implAmbulancesAPI) UpdateAmbulance(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var details Ambulance
+		if err := c.ShouldBindJSON(&details); err != nil {
+			return nil, gin.H{
+				"status":  "Bad Request",
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
+
+		if details.Id != "" && details.Id != ambulance.Id {
+			return nil, gin.H{
+				"status":  "Bad Request",
+				"message": "Cannot change id of the ambulance",
+				"error":   "id of the ambulance does not match the ambulanceId parameter",
+			}, http.StatusBadRequest
+		}
+
+		if !details.DuplicateOrderPolicy.isValid() {
+			return nil, gin.H{
+				"status":  "Bad Request",
+				"message": "Unknown duplicate order policy",
+				"error":   "duplicateOrderPolicy must be one of: reject, merge",
+			}, http.StatusBadRequest
+		}
+
+		updated := Ambulance{
+			Id:                   ambulance.Id,
+			Name:                 details.Name,
+			RoomNumber:           details.RoomNumber,
+			DuplicateOrderPolicy: details.DuplicateOrderPolicy,
+		}
+		patch := func(ctx context.Context, db db_service.DbService[Ambulance], ambulanceId string) error {
+			return db.UpdateFields(ctx, ambulanceId, db_service.FieldChange{
+				Set: map[string]any{
+					"name":                 updated.Name,
+					"roomnumber":           updated.RoomNumber,
+					"duplicateorderpolicy": updated.DuplicateOrderPolicy,
+				},
+			})
+		}
+		return patch, updated, http.StatusOK
+	})
+}
+
 func (o implAmbulancesAPI) DeleteAmbulance(c *gin.Context) {
 	value, exists := c.Get("db_service_ambulance")
 	if !exists {
@@ -135,3 +209,91 @@ func (o implAmbulancesAPI) DeleteAmbulance(c *gin.Context) {
 			})
 	}
 }
+
+func (p DuplicateOrderPolicy) isValid() bool {
+	return p == "" || p == REJECT || p == MERGE
+}
+
+const (
+	defaultAmbulancesLimit = 50
+	maxAmbulancesLimit     = 1000
+)
+
+// ambulanceSortFields maps the sort parameter to the stored field names
+var ambulanceSortFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"roomNumber": "roomnumber",
+}
+
+// ambulanceListProjection skips the entries of ambulances, they are listed by their own endpoints
+var ambulanceListProjection = []string{"id", "name", "roomnumber", "duplicateorderpolicy"}
+
+func (o implAmbulancesAPI) GetAmbulances(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "Invalid value of offset parameter",
+				"error":   "offset must be non-negative integer",
+			})
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultAmbulancesLimit)), 10, 64)
+	if err != nil || limit < 1 || limit > maxAmbulancesLimit {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "Invalid value of limit parameter",
+				"error":   fmt.Sprintf("limit must be integer between 1 and %v", maxAmbulancesLimit),
+			})
+		return
+	}
+
+	sort := c.DefaultQuery("sort", "id")
+	sortField, ok := ambulanceSortFields[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "Unsupported sort order",
+				"error":   "sort must be one of: id, name, roomNumber, optionally prefixed with -",
+			})
+		return
+	}
+
+	db := HandleConnectionToCollection[Ambulance](c, "db_service_ambulance")
+	if db == nil {
+		return
+	}
+
+	total, err := db.CountDocuments(c, db_service.Filter{})
+	if err != nil {
+		HandleRetrievalError(c, err)
+		return
+	}
+
+	sortFields := []db_service.SortField{{Field: sortField, Descending: strings.HasPrefix(sort, "-")}}
+	if sortField != "id" {
+		// ambulances with the same value keep stable order between pages
+		sortFields = append(sortFields, db_service.SortField{Field: "id"})
+	}
+	ambulances, err := db.FindDocuments(c, db_service.Query{
+		Projection: ambulanceListProjection,
+		Sort:       sortFields,
+		Skip:       offset,
+		Limit:      limit,
+	})
+	if err != nil {
+		HandleRetrievalError(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, ambulances)
+}
@@ -0,0 +1,214 @@
+package medicine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type AmbulancesSuite struct {
+	suite.Suite
+	dbServiceMock *DbServiceMock[Ambulance]
+}
+
+func TestAmbulancesSuite(t *testing.T) {
+	suite.Run(t, new(AmbulancesSuite))
+}
+
+func (suite *AmbulancesSuite) SetupTest() {
+	suite.dbServiceMock = &DbServiceMock[Ambulance]{}
+
+	// Compile time Assert that the mock is of type db_service.DbService[Ambulance]
+	var _ db_service.DbService[Ambulance] = suite.dbServiceMock
+
+	suite.dbServiceMock.
+		On("CountDocuments", mock.Anything, mock.Anything).
+		Return(int64(3), nil)
+
+	suite.dbServiceMock.
+		On("FindDocuments", mock.Anything, mock.Anything).
+		Return(
+			[]*Ambulance{
+				{Id: "second-ambulance", Name: "Second", RoomNumber: "102"},
+			},
+			nil,
+		)
+
+	suite.dbServiceMock.
+		On("FindDocument", mock.Anything, "test-ambulance").
+		Return(
+			&Ambulance{
+				Id:   "test-ambulance",
+				Name: "Test",
+				MedicineOrders: []MedicineOrderEntry{
+					{Id: "test-order", MedicineId: "test-medicine", Count: 1},
+				},
+			},
+			nil,
+		)
+
+	suite.dbServiceMock.
+		On("UpdateFields", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+}
+
+func (suite *AmbulancesSuite) Test_GetAmbulances_DbService() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/ambulance?offset=1&limit=1&sort=-name", nil)
+
+	sut := implAmbulancesAPI{}
+
+	// ACT
+	sut.GetAmbulances(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("3", recorder.Header().Get("X-Total-Count"))
+	var respObj []Ambulance
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.Equal([]Ambulance{{Id: "second-ambulance", Name: "Second", RoomNumber: "102"}}, respObj)
+	suite.dbServiceMock.AssertCalled(
+		suite.T(),
+		"FindDocuments",
+		mock.Anything,
+		db_service.Query{
+			Projection: ambulanceListProjection,
+			Sort: []db_service.SortField{
+				{Field: "name", Descending: true},
+				{Field: "id"},
+			},
+			Skip:  1,
+			Limit: 1,
+		},
+	)
+}
+
+func (suite *AmbulancesSuite) Test_GetAmbulances_InvalidLimit() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/ambulance?limit=5000", nil)
+
+	sut := implAmbulancesAPI{}
+
+	// ACT
+	sut.GetAmbulances(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "FindDocuments", mock.Anything, mock.Anything)
+}
+
+func (suite *AmbulancesSuite) Test_UpdateAmbulance_ChangesDetailsOnly() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/ambulance/test-ambulance", strings.NewReader(
+		`{"name": "Renamed", "roomNumber": "205", "duplicateOrderPolicy": "merge", "medicineOrders": []}`,
+	))
+
+	sut := implAmbulancesAPI{}
+
+	// ACT
+	sut.UpdateAmbulance(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj Ambulance
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.Equal(Ambulance{Id: "test-ambulance", Name: "Renamed", RoomNumber: "205", DuplicateOrderPolicy: MERGE}, respObj)
+	suite.dbServiceMock.AssertCalled(suite.T(), "UpdateFields", mock.Anything, "test-ambulance", db_service.FieldChange{
+		Set: map[string]any{
+			"name":                 "Renamed",
+			"roomnumber":           "205",
+			"duplicateorderpolicy": MERGE,
+		},
+	})
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AmbulancesSuite) Test_UpdateAmbulance_UnknownPolicy() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/ambulance/test-ambulance", strings.NewReader(
+		`{"name": "Test", "roomNumber": "101", "duplicateOrderPolicy": "sum"}`,
+	))
+
+	sut := implAmbulancesAPI{}
+
+	// ACT
+	sut.UpdateAmbulance(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateFields", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AmbulancesSuite) Test_UpdateAmbulance_CannotChangeId() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/ambulance/test-ambulance", strings.NewReader(
+		`{"id": "other-ambulance", "name": "Test", "roomNumber": "101"}`,
+	))
+
+	sut := implAmbulancesAPI{}
+
+	// ACT
+	sut.UpdateAmbulance(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateFields", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AmbulancesSuite) Test_CreateAmbulance_RejectsSlashInId() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("POST", "/ambulance", strings.NewReader(
+		`{"id": "ward/1", "name": "Test", "roomNumber": "101"}`,
+	))
+
+	sut := implAmbulancesAPI{}
+
+	// ACT
+	sut.CreateAmbulance(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,153 @@
+package medicine
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type implApiKeysAPI struct {
+}
+
+func NewApiKeysAPI() ApiKeysAPI {
+	return &implApiKeysAPI{}
+}
+
+// apiKeys returns the API keys of the db service in the context, it responds with the error if there is none
+func apiKeys(c *gin.Context) *auth.ApiKeys {
+	db := HandleConnectionToCollection[auth.ApiKey](c, "db_service_api_key")
+	if db == nil {
+		return nil
+	}
+	return auth.NewApiKeys(db)
+}
+
+// toApiKey returns the stored key without the hash of its secret
+func toApiKey(key auth.ApiKey) ApiKey {
+	permissions := make([]string, 0, len(key.Permissions))
+	for _, permission := range key.Permissions {
+		permissions = append(permissions, string(permission))
+	}
+	return ApiKey{
+		Id:          key.Id,
+		Name:        key.Name,
+		Ambulances:  key.Ambulances,
+		Permissions: permissions,
+		CreatedBy:   key.CreatedBy,
+		CreatedAt:   key.CreatedAt,
+		ExpiresAt:   key.ExpiresAt,
+		LastUsedAt:  key.LastUsedAt,
+	}
+}
+
+func (o implApiKeysAPI) GetApiKeys(c *gin.Context) {
+	keys := apiKeys(c)
+	if keys == nil {
+		return
+	}
+	stored, err := keys.List(c)
+	if err != nil {
+		c.JSON(
+			http.StatusBadGateway,
+			gin.H{
+				"status":  "Bad Gateway",
+				"message": "Failed to load API keys from database",
+				"error":   err.Error(),
+			})
+		return
+	}
+	response := make([]ApiKey, 0, len(stored))
+	for _, key := range stored {
+		response = append(response, toApiKey(*key))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (o implApiKeysAPI) IssueApiKey(c *gin.Context) {
+	keys := apiKeys(c)
+	if keys == nil {
+		return
+	}
+	request := ApiKeyRequest{}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			})
+		return
+	}
+
+	key := auth.ApiKey{Name: request.Name, Ambulances: request.Ambulances, ExpiresAt: request.ExpiresAt}
+	for _, permission := range request.Permissions {
+		key.Permissions = append(key.Permissions, auth.Permission(permission))
+	}
+	if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+		key.CreatedBy = principal.Subject
+	}
+	issued, secret, err := keys.Issue(c, key)
+	switch {
+	case err == nil:
+		response := toApiKey(issued)
+		c.JSON(http.StatusCreated, IssuedApiKey{
+			Id:          response.Id,
+			Name:        response.Name,
+			Ambulances:  response.Ambulances,
+			Permissions: response.Permissions,
+			CreatedBy:   response.CreatedBy,
+			CreatedAt:   response.CreatedAt,
+			ExpiresAt:   response.ExpiresAt,
+			Secret:      secret,
+		})
+	case errors.Is(err, auth.ErrInvalidApiKeyRequest):
+		c.JSON(
+			http.StatusBadRequest,
+			gin.H{
+				"status":  "Bad Request",
+				"message": "API key cannot be issued",
+				"error":   err.Error(),
+			})
+	default:
+		c.JSON(
+			http.StatusBadGateway,
+			gin.H{
+				"status":  "Bad Gateway",
+				"message": "Failed to store API key in database",
+				"error":   err.Error(),
+			})
+	}
+}
+
+func (o implApiKeysAPI) RevokeApiKey(c *gin.Context) {
+	keys := apiKeys(c)
+	if keys == nil {
+		return
+	}
+	err := keys.Revoke(c, c.Param("keyId"))
+	switch err {
+	case nil:
+		c.AbortWithStatus(http.StatusNoContent)
+	case db_service.ErrNotFound:
+		c.JSON(
+			http.StatusNotFound,
+			gin.H{
+				"status":  "Not Found",
+				"message": "API key not found",
+				"error":   err.Error(),
+			},
+		)
+	default:
+		c.JSON(
+			http.StatusBadGateway,
+			gin.H{
+				"status":  "Bad Gateway",
+				"message": "Failed to revoke API key in database",
+				"error":   err.Error(),
+			})
+	}
+}
@@ -0,0 +1,115 @@
+package medicine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/auth"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type ApiKeysSuite struct {
+	suite.Suite
+	db db_service.DbService[auth.ApiKey]
+}
+
+func TestApiKeysSuite(t *testing.T) {
+	suite.Run(t, new(ApiKeysSuite))
+}
+
+func (suite *ApiKeysSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.db = db_service.NewMemoryService[auth.ApiKey]()
+}
+
+// context returns context of the request of the administrator with the JSON body, if given
+func (suite *ApiKeysSuite) context(method string, body any) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_api_key", suite.db)
+	var content bytes.Buffer
+	if body != nil {
+		suite.Require().NoError(json.NewEncoder(&content).Encode(body))
+	}
+	ctx.Request = httptest.NewRequest(method, "/api/api-keys", &content)
+	ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), auth.Principal{Subject: "admin-1"}))
+	return ctx, recorder
+}
+
+func (suite *ApiKeysSuite) Test_IssueApiKey_ShowsSecretOnce() {
+	// ARRANGE
+	ctx, recorder := suite.context(http.MethodPost, ApiKeyRequest{
+		Name:        "Supplier integration",
+		Ambulances:  []string{"bobulova"},
+		Permissions: []string{"inventory:read", "orders:write"},
+	})
+	sut := implApiKeysAPI{}
+
+	// ACT
+	sut.IssueApiKey(ctx)
+
+	// ASSERT
+	suite.Require().Equal(http.StatusCreated, recorder.Code, recorder.Body.String())
+	var issued IssuedApiKey
+	suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &issued))
+	suite.NotEmpty(issued.Secret)
+	suite.Equal("admin-1", issued.CreatedBy)
+	suite.Equal([]string{"inventory:read", "orders:write"}, issued.Permissions)
+
+	listCtx, listRecorder := suite.context(http.MethodGet, nil)
+	sut.GetApiKeys(listCtx)
+	suite.Equal(http.StatusOK, listRecorder.Code)
+	suite.NotContains(listRecorder.Body.String(), issued.Secret)
+	suite.NotContains(listRecorder.Body.String(), "secret")
+	var listed []ApiKey
+	suite.Require().NoError(json.Unmarshal(listRecorder.Body.Bytes(), &listed))
+	suite.Require().Len(listed, 1)
+	suite.Equal(issued.Id, listed[0].Id)
+}
+
+func (suite *ApiKeysSuite) Test_IssueApiKey_RejectsUnknownPermission() {
+	// ARRANGE
+	ctx, recorder := suite.context(http.MethodPost, ApiKeyRequest{
+		Name:        "Nightly",
+		Ambulances:  []string{"*"},
+		Permissions: []string{"apikeys:manage"},
+	})
+	sut := implApiKeysAPI{}
+
+	// ACT
+	sut.IssueApiKey(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Contains(recorder.Body.String(), `permission \"apikeys:manage\" cannot be granted to API key`)
+}
+
+func (suite *ApiKeysSuite) Test_RevokeApiKey_DeletesKey() {
+	// ARRANGE
+	issued, _, err := auth.NewApiKeys(suite.db).Issue(context.Background(), auth.ApiKey{
+		Name: "Nightly", Ambulances: []string{"*"}, Permissions: []auth.Permission{auth.Export},
+	})
+	suite.Require().NoError(err)
+	ctx, _ := suite.context(http.MethodDelete, nil)
+	ctx.Params = gin.Params{{Key: "keyId", Value: issued.Id}}
+	missingCtx, missingRecorder := suite.context(http.MethodDelete, nil)
+	missingCtx.Params = gin.Params{{Key: "keyId", Value: "missing"}}
+	sut := implApiKeysAPI{}
+
+	// ACT
+	sut.RevokeApiKey(ctx)
+	sut.RevokeApiKey(missingCtx)
+
+	// ASSERT
+	suite.Equal(http.StatusNoContent, ctx.Writer.Status())
+	suite.Equal(http.StatusNotFound, missingRecorder.Code)
+	count, err := suite.db.CountDocuments(context.Background(), db_service.Filter{})
+	suite.NoError(err)
+	suite.Zero(count)
+}
@@ -0,0 +1,87 @@
+package medicine
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type implExportsAPI struct {
+}
+
+func NewExportsAPI() ExportsAPI {
+	return &implExportsAPI{}
+}
+
+func (o implExportsAPI) ExportMedicineInventory(c *gin.Context) {
+	exportAllAmbulances(c, "medicine-inventory", inventoryField, inventoryExportColumns, defaultInventoryExportColumns,
+		func(ambulance *Ambulance) []MedicineInventoryEntry {
+			return ambulance.MedicineInventory
+		})
+}
+
+func (o implExportsAPI) ExportMedicineOrders(c *gin.Context) {
+	exportAllAmbulances(c, "medicine-orders", ordersField, orderExportColumns, defaultOrderExportColumns,
+		func(ambulance *Ambulance) []MedicineOrderEntry {
+			return ambulance.MedicineOrders
+		})
+}
+
+// ambulances are loaded page by page, so only one page is held in memory during the export
+const exportPageSize = 50
+
+// exportAllAmbulances streams entries of every ambulance as one spreadsheet,
+// the ambulance columns tell the rows of different ambulances apart.
+// Only the ambulance name and the exported entries field are loaded from the database.
+func exportAllAmbulances[T any](
+	c *gin.Context,
+	fileName string,
+	entriesField string,
+	available []exportColumn[T],
+	defaults []string,
+	entries func(ambulance *Ambulance) []T,
+) {
+	format, err := negotiateExportFormat(c, exportCSV, exportXLSX)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, invalidExportFormat(err))
+		return
+	}
+
+	db := HandleConnectionToCollection[Ambulance](c, "db_service_ambulance")
+	if db == nil {
+		return
+	}
+	query := db_service.Query{
+		Projection: []string{"id", "name", entriesField},
+		Sort:       []db_service.SortField{{Field: "id"}},
+		Limit:      exportPageSize,
+	}
+	// first page is loaded before the response starts, so the failure can still be reported
+	ambulances, err := db.FindDocuments(c, query)
+	if err != nil {
+		HandleRetrievalError(c, err)
+		return
+	}
+
+	responseObject, status := streamExport(c, format, fileName, available, append([]string{"ambulanceId", "ambulanceName"}, defaults...),
+		func(yield func(*Ambulance, []T) error) error {
+			for {
+				for _, ambulance := range ambulances {
+					if err := yield(ambulance, entries(ambulance)); err != nil {
+						return err
+					}
+				}
+				if len(ambulances) < exportPageSize {
+					return nil
+				}
+				query.Skip += exportPageSize
+				if ambulances, err = db.FindDocuments(c, query); err != nil {
+					return err
+				}
+			}
+		})
+	if responseObject != nil {
+		c.JSON(status, responseObject)
+	}
+}
@@ -0,0 +1,219 @@
+package medicine
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type ExportsSuite struct {
+	suite.Suite
+	dbServiceMock *DbServiceMock[Ambulance]
+}
+
+func TestExportsSuite(t *testing.T) {
+	suite.Run(t, new(ExportsSuite))
+}
+
+func (suite *ExportsSuite) SetupTest() {
+	suite.dbServiceMock = &DbServiceMock[Ambulance]{}
+
+	// Compile time Assert that the mock is of type db_service.DbService[Ambulance]
+	var _ db_service.DbService[Ambulance] = suite.dbServiceMock
+
+	suite.dbServiceMock.
+		On("FindDocuments", mock.Anything, mock.MatchedBy(func(query db_service.Query) bool {
+			return query.Skip == 0
+		})).
+		Return(
+			[]*Ambulance{
+				{
+					Id:   "first-ambulance",
+					Name: "First",
+					MedicineInventory: []MedicineInventoryEntry{
+						{Id: "first-entry", Name: "Paralen", MedicineId: "paralen-id", Count: 15},
+					},
+					MedicineOrders: []MedicineOrderEntry{
+						{Id: "first-order", Name: "Paralen", MedicineId: "paralen-id", Count: 5, Status: Status{Id: 1, Value: "To_ship"}, Priority: URGENT},
+					},
+				},
+				{
+					Id:   "second-ambulance",
+					Name: "Second",
+					MedicineInventory: []MedicineInventoryEntry{
+						{Id: "second-entry", Name: "Mig <400>", MedicineId: "mig-id", Count: 3},
+					},
+				},
+			},
+			nil,
+		)
+}
+
+func (suite *ExportsSuite) Test_ExportInventory_CsvWithSlovakHeaders() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/export/medicine-inventory?columns=ambulanceName,name,count", nil)
+	ctx.Request.Header.Set("Accept-Language", "sk-SK,sk;q=0.9")
+
+	sut := implExportsAPI{}
+
+	// ACT
+	sut.ExportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal(mimeCSV, recorder.Header().Get("Content-Type"))
+	suite.Contains(recorder.Header().Get("Content-Disposition"), `filename="medicine-inventory.csv"`)
+	suite.Equal("Ambulancia,Liek,Počet\nFirst,Paralen,15\nSecond,Mig <400>,3\n", recorder.Body.String())
+	suite.dbServiceMock.AssertCalled(
+		suite.T(),
+		"FindDocuments",
+		mock.Anything,
+		mock.MatchedBy(func(query db_service.Query) bool {
+			return slices.Equal(query.Projection, []string{"id", "name", "medicineinventory"})
+		}),
+	)
+}
+
+func (suite *ExportsSuite) Test_ExportOrders_Xlsx() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/export/medicine-orders", nil)
+	ctx.Request.Header.Set("Accept", mimeXLSX)
+
+	sut := implExportsAPI{}
+
+	// ACT
+	sut.ExportMedicineOrders(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal(mimeXLSX, recorder.Header().Get("Content-Type"))
+	archive, err := zip.NewReader(bytes.NewReader(recorder.Body.Bytes()), int64(recorder.Body.Len()))
+	suite.Require().NoError(err)
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	suite.Require().NoError(err)
+	content, err := io.ReadAll(sheet)
+	suite.Require().NoError(err)
+	suite.Contains(string(content), "<t xml:space=\"preserve\">Ambulance ID</t>")
+	suite.Contains(string(content), "<t xml:space=\"preserve\">first-ambulance</t>")
+	suite.Contains(string(content), "<c><v>5</v></c>")
+	suite.Contains(string(content), "<t xml:space=\"preserve\">urgent</t>")
+}
+
+func (suite *ExportsSuite) formulaAmbulances() {
+	suite.dbServiceMock.ExpectedCalls = nil
+	suite.dbServiceMock.
+		On("FindDocuments", mock.Anything, mock.MatchedBy(func(query db_service.Query) bool {
+			return query.Skip == 0
+		})).
+		Return(
+			[]*Ambulance{
+				{
+					Id:   "formula-ambulance",
+					Name: "@SUM(A1:A2)",
+					MedicineInventory: []MedicineInventoryEntry{
+						{Id: "formula-entry", Name: "=HYPERLINK(\"http://evil\")", MedicineId: "+cmd", Count: -2},
+					},
+				},
+			},
+			nil,
+		)
+}
+
+func (suite *ExportsSuite) Test_ExportInventory_CsvEscapesFormulas() {
+	// ARRANGE
+	suite.formulaAmbulances()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/export/medicine-inventory?columns=ambulanceName,medicineId,name,count", nil)
+
+	sut := implExportsAPI{}
+
+	// ACT
+	sut.ExportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("Ambulance,Medicine ID,Medicine,Count\n'@SUM(A1:A2),'+cmd,\"'=HYPERLINK(\"\"http://evil\"\")\",-2\n", recorder.Body.String())
+}
+
+func (suite *ExportsSuite) Test_ExportInventory_XlsxEscapesFormulas() {
+	// ARRANGE
+	suite.formulaAmbulances()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/export/medicine-inventory?format=xlsx&columns=ambulanceName,name,count", nil)
+
+	sut := implExportsAPI{}
+
+	// ACT
+	sut.ExportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	archive, err := zip.NewReader(bytes.NewReader(recorder.Body.Bytes()), int64(recorder.Body.Len()))
+	suite.Require().NoError(err)
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	suite.Require().NoError(err)
+	content, err := io.ReadAll(sheet)
+	suite.Require().NoError(err)
+	suite.Contains(string(content), "<t xml:space=\"preserve\">&#39;@SUM(A1:A2)</t>")
+	suite.Contains(string(content), "<t xml:space=\"preserve\">&#39;=HYPERLINK(&#34;http://evil&#34;)</t>")
+	suite.Contains(string(content), "<c><v>-2</v></c>")
+}
+
+func (suite *ExportsSuite) Test_ExportInventory_UnknownColumn() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/export/medicine-inventory?columns=name,price", nil)
+
+	sut := implExportsAPI{}
+
+	// ACT
+	sut.ExportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Contains(recorder.Body.String(), "price")
+}
+
+func (suite *ExportsSuite) Test_ExportInventory_UnsupportedFormat() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/export/medicine-inventory?format=json", nil)
+
+	sut := implExportsAPI{}
+
+	// ACT
+	sut.ExportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "FindDocuments", mock.Anything, mock.Anything)
+}
@@ -2,8 +2,12 @@ package medicine
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+	"maps"
 	"net/http"
 	"slices"
+	"strconv"
 )
 
 type implMedicineInventoryAPI struct {
@@ -13,50 +17,90 @@ func NewMedicineInventoryAPI() MedicineInventoryAPI {
 	return &implMedicineInventoryAPI{}
 }
 
-func (o implMedicineInventoryAPI) DeleteMedicineInventoryEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		entryId := c.Param("entryId")
+func (o implMedicineInventoryAPI) BatchMedicineInventoryEntries(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var request MedicineInventoryBatchRequest
 
-		if entryId == "" {
+		if err := c.ShouldBindJSON(&request); err != nil {
 			return nil, gin.H{
 				"status":  http.StatusBadRequest,
-				"message": "Entry ID is required",
+				"message": "Invalid request body",
+				"error":   err.Error(),
 			}, http.StatusBadRequest
 		}
 
-		entryIndx := slices.IndexFunc(ambulance.MedicineInventory, func(waiting MedicineInventoryEntry) bool {
-			return entryId == waiting.Id
-		})
+		if responseObject, status := validateBatch(request.Mode, len(request.Operations)); status != http.StatusOK {
+			return nil, responseObject, status
+		}
 
-		if entryIndx < 0 {
-			return nil, gin.H{
-				"status":  http.StatusNotFound,
-				"message": "Entry not found",
-			}, http.StatusNotFound
+		operations := make([]batchOperation, len(request.Operations))
+		for i, operation := range request.Operations {
+			operations[i] = func(ambulance *Ambulance) (string, interface{}, int) {
+				switch operation.Op {
+				case CREATE:
+					created, responseObject, status := createInventoryEntry(ambulance, operation.Entry)
+					return created.Id, responseObject, status
+				case UPDATE:
+					responseObject, status := updateInventoryEntry(ambulance, operation.EntryId, operation.Entry)
+					return operation.EntryId, responseObject, status
+				case DELETE:
+					responseObject, status := deleteInventoryEntry(ambulance, operation.EntryId)
+					return operation.EntryId, responseObject, status
+				default:
+					responseObject, status := unknownBatchOperation(operation.Op)
+					return operation.EntryId, responseObject, status
+				}
+			}
 		}
+		before := slices.Clone(ambulance.MedicineInventory)
+		updatedAmbulance, responseObject, status := applyBatch(c, ambulance, request.Mode, operations)
+		if updatedAmbulance == nil {
+			return nil, responseObject, status
+		}
+		return inventoryPatch(before, updatedAmbulance.MedicineInventory), responseObject, status
+	})
+}
 
-		ambulance.MedicineInventory = append(ambulance.MedicineInventory[:entryIndx], ambulance.MedicineInventory[entryIndx+1:]...)
-		return ambulance, nil, http.StatusNoContent
+func (o implMedicineInventoryAPI) DeleteMedicineInventoryEntry(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		entryId := c.Param("entryId")
+		responseObject, status := deleteInventoryEntry(ambulance, entryId)
+		if status >= http.StatusMultipleChoices {
+			return nil, responseObject, status
+		}
+		return pullElement(inventoryField, entryId), responseObject, status
 	})
 }
 
 func (o implMedicineInventoryAPI) GetMedicineInventoryEntries(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
+	readAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (interface{}, int) {
+		format, err := negotiateExportFormat(c, exportJSON, exportCSV, exportXLSX)
+		if err != nil {
+			return invalidExportFormat(err), http.StatusBadRequest
+		}
+
 		result := ambulance.MedicineInventory
 		if result == nil {
 			result = []MedicineInventoryEntry{}
 		}
-		// return nil ambulance - no need to update it in db
-		return nil, result, http.StatusOK
+		if format != exportJSON {
+			responseObject, status := streamExport(
+				c, format, "medicine-inventory-"+ambulance.Id, inventoryExportColumns, defaultInventoryExportColumns,
+				func(yield func(*Ambulance, []MedicineInventoryEntry) error) error {
+					return yield(ambulance, result)
+				})
+			return responseObject, status
+		}
+		return result, http.StatusOK
 	})
 }
 
 func (o implMedicineInventoryAPI) GetMedicineInventoryEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
+	readAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (interface{}, int) {
 		entryId := c.Param("entryId")
 
 		if entryId == "" {
-			return nil, gin.H{
+			return gin.H{
 				"status":  http.StatusBadRequest,
 				"message": "Entry ID is required",
 			}, http.StatusBadRequest
@@ -67,68 +111,280 @@ func (o implMedicineInventoryAPI) GetMedicineInventoryEntry(c *gin.Context) {
 		})
 
 		if entryIndx < 0 {
-			return nil, gin.H{
+			return gin.H{
 				"status":  http.StatusNotFound,
 				"message": "Entry not found",
 			}, http.StatusNotFound
 		}
 
-		// return nil ambulance - no need to update it in db
-		return nil, ambulance.MedicineInventory[entryIndx], http.StatusOK
+		return ambulance.MedicineInventory[entryIndx], http.StatusOK
 	})
 }
 
-func (o implMedicineInventoryAPI) UpdateMedicineInventoryEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		var entry MedicineInventoryEntry
+func (o implMedicineInventoryAPI) ImportMedicineInventory(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		mode := c.DefaultQuery("mode", importMerge)
+		if mode != importReplace && mode != importMerge && mode != importAdd {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Unknown import mode",
+				"error":   "mode must be one of: replace, merge, add",
+			}, http.StatusBadRequest
+		}
 
-		if err := c.ShouldBindJSON(&entry); err != nil {
+		dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+		if err != nil {
 			return nil, gin.H{
 				"status":  http.StatusBadRequest,
-				"message": "Invalid request body",
+				"message": "Invalid value of dryRun parameter",
 				"error":   err.Error(),
 			}, http.StatusBadRequest
 		}
 
-		entryId := c.Param("entryId")
-
-		if entryId == "" {
+		imported, rowErrors, err := parseInventoryCsv(c.Request.Body)
+		if err != nil {
 			return nil, gin.H{
 				"status":  http.StatusBadRequest,
-				"message": "Entry ID is required",
+				"message": "Invalid CSV",
+				"error":   err.Error(),
 			}, http.StatusBadRequest
 		}
 
-		entryIndx := slices.IndexFunc(ambulance.MedicineInventory, func(inventory MedicineInventoryEntry) bool {
-			return entryId == inventory.Id
-		})
-
-		if entryIndx < 0 {
-			return nil, gin.H{
-				"status":  http.StatusNotFound,
-				"message": "Entry not found",
-			}, http.StatusNotFound
+		inventory, changes, applyErrors := mergeInventoryImport(ambulance.MedicineInventory, mode, imported)
+		result := InventoryImportResult{
+			Mode:    mode,
+			DryRun:  dryRun,
+			Changes: []InventoryImportChange{},
+			Errors:  append(rowErrors, applyErrors...),
+		}
+		if len(result.Errors) > 0 {
+			slices.SortStableFunc(result.Errors, func(a, b InventoryImportRowError) int {
+				return int(a.Row - b.Row)
+			})
+			return nil, result, http.StatusBadRequest
 		}
 
-		if entry.Count > 0 {
-			ambulance.MedicineInventory[entryIndx].Count = entry.Count
-		} else if entry.Count == 0 {
-			ambulance.MedicineInventory = append(ambulance.MedicineInventory[:entryIndx], ambulance.MedicineInventory[entryIndx+1:]...)
-			return ambulance, nil, http.StatusOK
+		result.Errors = []InventoryImportRowError{}
+		result.Changes = changes
+		if dryRun || len(changes) == 0 {
+			// return nil patch - no need to update it in db
+			return nil, result, http.StatusOK
 		}
+		result.Applied = true
+		return inventoryPatch(ambulance.MedicineInventory, inventory), result, http.StatusOK
+	})
+}
+
+func (o implMedicineInventoryAPI) UpdateMedicineInventoryEntry(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var entry MedicineInventoryEntry
 
-		if entry.MedicineId != "" {
-			ambulance.MedicineInventory[entryIndx].MedicineId = entry.MedicineId
+		if err := c.ShouldBindJSON(&entry); err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
 		}
 
-		if entry.Id != "" {
-			ambulance.MedicineInventory[entryIndx].Id = entry.Id
+		entryId := c.Param("entryId")
+		responseObject, status := updateInventoryEntry(ambulance, entryId, entry)
+		if status >= http.StatusMultipleChoices {
+			return nil, responseObject, status
 		}
+		if responseObject == nil {
+			// entry with zero count was removed
+			return pullElement(inventoryField, entryId), nil, status
+		}
+		fields := inventoryEntryFields(entry)
+		if len(fields) == 0 {
+			// nothing to change, e.g. only negative count which is ignored
+			return nil, responseObject, status
+		}
+		return setElementFields(inventoryField, entryId, fields), responseObject, status
+	})
+}
+
+// inventoryPatch changes the stored inventory from the loaded entries to the changed ones in one update
+func inventoryPatch(before []MedicineInventoryEntry, after []MedicineInventoryEntry) ambulancePatch {
+	return elementsPatch(inventoryChanges(before, after))
+}
 
-		if entry.Name != "" {
-			ambulance.MedicineInventory[entryIndx].Name = entry.Name
+// inventoryChanges lists the element changes turning the loaded inventory into the changed one, so the entries
+// changed meanwhile by other requests are kept. The changed entries are expected to keep their loaded values,
+// otherwise nothing is written and ErrConflict is returned.
+func inventoryChanges(before []MedicineInventoryEntry, after []MedicineInventoryEntry) []db_service.ElementChange {
+	var changes []db_service.ElementChange
+	kept := map[string]bool{}
+	for _, entry := range after {
+		kept[entry.Id] = true
+		beforeIndx := slices.IndexFunc(before, func(stored MedicineInventoryEntry) bool {
+			return entry.Id == stored.Id
+		})
+		if beforeIndx < 0 {
+			changes = append(changes, db_service.ElementChange{ArrayField: inventoryField, ElementId: entry.Id, Push: entry})
+			continue
+		}
+		if fields := inventoryFieldChanges(before[beforeIndx], entry); len(fields) > 0 {
+			changes = append(changes, db_service.ElementChange{
+				ArrayField: inventoryField,
+				ElementId:  entry.Id,
+				FieldChange: db_service.FieldChange{
+					Expect: inventoryEntryExpect(before[beforeIndx], fields),
+					Set:    fields,
+				},
+			})
+		}
+	}
+	for _, entry := range before {
+		if !kept[entry.Id] {
+			changes = append(changes, db_service.ElementChange{ArrayField: inventoryField, ElementId: entry.Id, Pull: true})
 		}
+	}
+	return changes
+}
+
+// inventoryEntryExpect expects the changed fields of the stored entry to have their loaded values
+func inventoryEntryExpect(loaded MedicineInventoryEntry, fields map[string]any) db_service.Filter {
+	loadedFields := map[string]any{"name": loaded.Name, "medicineid": loaded.MedicineId, "count": loaded.Count}
+	var conditions []db_service.Filter
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		conditions = append(conditions, db_service.Eq(field, loadedFields[field]))
+	}
+	return allOf(conditions)
+}
 
-		return ambulance, ambulance.MedicineInventory[entryIndx], http.StatusOK
+// inventoryFieldChanges lists the stored fields of the inventory entry which differ after the change
+func inventoryFieldChanges(before MedicineInventoryEntry, after MedicineInventoryEntry) map[string]any {
+	fields := map[string]any{}
+	if before.Name != after.Name {
+		fields["name"] = after.Name
+	}
+	if before.MedicineId != after.MedicineId {
+		fields["medicineid"] = after.MedicineId
+	}
+	if before.Count != after.Count {
+		fields["count"] = after.Count
+	}
+	return fields
+}
+
+// inventoryEntryFields lists the stored fields changed by updateInventoryEntry
+func inventoryEntryFields(entry MedicineInventoryEntry) map[string]any {
+	fields := map[string]any{}
+	if entry.Count > 0 {
+		fields["count"] = entry.Count
+	}
+	if entry.MedicineId != "" {
+		fields["medicineid"] = entry.MedicineId
+	}
+	if entry.Id != "" {
+		fields["id"] = entry.Id
+	}
+	if entry.Name != "" {
+		fields["name"] = entry.Name
+	}
+	return fields
+}
+
+// createInventoryEntry adds new entry into the ambulance inventory.
+// Returns the stored entry, or the error response and its status code.
+func createInventoryEntry(ambulance *Ambulance, entry MedicineInventoryEntry) (MedicineInventoryEntry, interface{}, int) {
+	if entry.MedicineId == "" {
+		return entry, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Medicine ID is required",
+		}, http.StatusBadRequest
+	}
+
+	if entry.Count < 0 {
+		return entry, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Count cannot be negative",
+		}, http.StatusBadRequest
+	}
+
+	if entry.Id == "" || entry.Id == "@new" {
+		entry.Id = uuid.NewString()
+	}
+
+	if slices.ContainsFunc(ambulance.MedicineInventory, func(inventory MedicineInventoryEntry) bool {
+		return entry.Id == inventory.Id || entry.MedicineId == inventory.MedicineId
+	}) {
+		return entry, gin.H{
+			"status":  http.StatusConflict,
+			"message": "Entry for this medicine already exists",
+		}, http.StatusConflict
+	}
+
+	ambulance.MedicineInventory = append(ambulance.MedicineInventory, entry)
+	return entry, entry, http.StatusOK
+}
+
+// updateInventoryEntry applies non-empty fields of the entry to the inventory entry, zero count removes it.
+// Returns the updated entry (nil if removed), or the error response and its status code.
+func updateInventoryEntry(ambulance *Ambulance, entryId string, entry MedicineInventoryEntry) (interface{}, int) {
+	if entryId == "" {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Entry ID is required",
+		}, http.StatusBadRequest
+	}
+
+	entryIndx := slices.IndexFunc(ambulance.MedicineInventory, func(inventory MedicineInventoryEntry) bool {
+		return entryId == inventory.Id
+	})
+
+	if entryIndx < 0 {
+		return gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Entry not found",
+		}, http.StatusNotFound
+	}
+
+	if entry.Count > 0 {
+		ambulance.MedicineInventory[entryIndx].Count = entry.Count
+	} else if entry.Count == 0 {
+		ambulance.MedicineInventory = append(ambulance.MedicineInventory[:entryIndx], ambulance.MedicineInventory[entryIndx+1:]...)
+		return nil, http.StatusOK
+	}
+
+	if entry.MedicineId != "" {
+		ambulance.MedicineInventory[entryIndx].MedicineId = entry.MedicineId
+	}
+
+	if entry.Id != "" {
+		ambulance.MedicineInventory[entryIndx].Id = entry.Id
+	}
+
+	if entry.Name != "" {
+		ambulance.MedicineInventory[entryIndx].Name = entry.Name
+	}
+
+	return ambulance.MedicineInventory[entryIndx], http.StatusOK
+}
+
+// deleteInventoryEntry removes the entry from the ambulance inventory.
+// Returns the error response and its status code if there is no such entry.
+func deleteInventoryEntry(ambulance *Ambulance, entryId string) (interface{}, int) {
+	if entryId == "" {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Entry ID is required",
+		}, http.StatusBadRequest
+	}
+
+	entryIndx := slices.IndexFunc(ambulance.MedicineInventory, func(waiting MedicineInventoryEntry) bool {
+		return entryId == waiting.Id
 	})
+
+	if entryIndx < 0 {
+		return gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Entry not found",
+		}, http.StatusNotFound
+	}
+
+	ambulance.MedicineInventory = append(ambulance.MedicineInventory[:entryIndx], ambulance.MedicineInventory[entryIndx+1:]...)
+	return nil, http.StatusNoContent
 }
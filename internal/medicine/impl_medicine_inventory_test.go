@@ -1,9 +1,11 @@
 package medicine
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -48,6 +50,18 @@ func (suite *MedicineInventorySuite) SetupTest() {
 	suite.dbServiceMock.
 		On("UpdateDocument", mock.Anything, mock.Anything, mock.Anything).
 		Return(nil)
+
+	suite.dbServiceMock.
+		On("PullElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbServiceMock.
+		On("SetElementFields", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbServiceMock.
+		On("UpdateElements", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
 }
 
 func (suite *MedicineInventorySuite) Test_DeleteInventory_DbService() {
@@ -69,15 +83,8 @@ func (suite *MedicineInventorySuite) Test_DeleteInventory_DbService() {
 
 	// ASSERT
 	suite.Equal(http.StatusNoContent, recorder.Code)
-	suite.dbServiceMock.AssertCalled(
-		suite.T(),
-		"UpdateDocument",
-		mock.Anything,
-		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			return len(arg.MedicineInventory) == 0
-		}),
-	)
+	suite.dbServiceMock.AssertCalled(suite.T(), "PullElement", mock.Anything, "test-ambulance", "medicineinventory", "test-entry")
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *MedicineInventorySuite) Test_GetInventory_DbService() {
@@ -165,7 +172,32 @@ func (suite *MedicineInventorySuite) Test_UpdateInventory_DbServiceUpdateCalled(
 
 	// ASSERT
 	suite.Equal(http.StatusOK, recorder.Code)
-	suite.dbServiceMock.AssertCalled(suite.T(), "UpdateDocument", mock.Anything, "test-ambulance", mock.Anything)
+	suite.dbServiceMock.AssertCalled(suite.T(), "SetElementFields", mock.Anything, "test-ambulance", "medicineinventory", "test-entry", mock.Anything)
+}
+
+func (suite *MedicineInventorySuite) Test_UpdateInventory_NoFieldChangesSkipsUpdate() {
+	// ARRANGE
+	json := `{ "count": -1 }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "entryId", Value: "test-entry"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/medicine-inventory/test-ambulance/entries/test-entry", strings.NewReader(json))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.UpdateMedicineInventoryEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Contains(recorder.Body.String(), `"count":15`)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "SetElementFields", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *MedicineInventorySuite) Test_UpdateInventory_DbServiceUpdateCalledWithCorrectInventory() {
@@ -196,17 +228,17 @@ func (suite *MedicineInventorySuite) Test_UpdateInventory_DbServiceUpdateCalledW
 	suite.Equal(http.StatusOK, recorder.Code)
 	suite.dbServiceMock.AssertCalled(
 		suite.T(),
-		"UpdateDocument",
+		"SetElementFields",
 		mock.Anything,
 		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			for _, entry := range arg.MedicineInventory {
-				if entry.Id == "test-entry" && entry.Count == 20 {
-					return true
-				}
-			}
-			return false
-		}),
+		"medicineinventory",
+		"test-entry",
+		map[string]any{
+			"id":         "test-entry",
+			"name":       "test-name",
+			"medicineid": "test-medicine-id",
+			"count":      int32(20),
+		},
 	)
 }
 
@@ -236,13 +268,400 @@ func (suite *MedicineInventorySuite) Test_UpdateInventory_DbServiceDeleteInvento
 
 	// ASSERT
 	suite.Equal(http.StatusOK, recorder.Code)
-	suite.dbServiceMock.AssertCalled(
-		suite.T(),
-		"UpdateDocument",
-		mock.Anything,
-		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			return len(arg.MedicineInventory) == 0
+	suite.dbServiceMock.AssertCalled(suite.T(), "PullElement", mock.Anything, "test-ambulance", "medicineinventory", "test-entry")
+}
+
+func (suite *MedicineInventorySuite) Test_BatchInventory_DbServiceAtomic() {
+	// ARRANGE
+	json := `{
+		"mode": "atomic",
+		"operations": [
+			{ "op": "create", "entry": { "id": "new-entry", "medicineId": "new-medicine-id", "count": 3 } },
+			{ "op": "update", "entryId": "test-entry", "entry": { "count": 30 } }
+		]
+	}`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/batch", strings.NewReader(json))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.BatchMedicineInventoryEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbServiceMock.AssertNumberOfCalls(suite.T(), "FindDocument", 1)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
+	suite.dbServiceMock.AssertCalled(suite.T(), "UpdateElements", mock.Anything, "test-ambulance", []db_service.ElementChange{
+		{
+			ArrayField: "medicineinventory",
+			ElementId:  "test-entry",
+			FieldChange: db_service.FieldChange{
+				Expect: db_service.Eq("count", int32(15)),
+				Set:    map[string]any{"count": int32(30)},
+			},
+		},
+		{
+			ArrayField: "medicineinventory",
+			ElementId:  "new-entry",
+			Push:       MedicineInventoryEntry{Id: "new-entry", MedicineId: "new-medicine-id", Count: 3},
+		},
+	})
+}
+
+func (suite *MedicineInventorySuite) Test_BatchInventory_DbServiceAtomicFailureStoresNothing() {
+	// ARRANGE
+	body := `{
+		"operations": [
+			{ "op": "update", "entryId": "test-entry", "entry": { "count": 30 } },
+			{ "op": "delete", "entryId": "missing-entry" },
+			{ "op": "create", "entry": { "medicineId": "new-medicine-id", "count": 3 } }
+		]
+	}`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/batch", strings.NewReader(body))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.BatchMedicineInventoryEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	var respObj BatchResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.False(respObj.Applied)
+	suite.Require().Len(respObj.Results, 3)
+	suite.Equal(int32(http.StatusOK), respObj.Results[0].Status)
+	suite.Equal(int32(http.StatusNotFound), respObj.Results[1].Status)
+	suite.Equal(int32(http.StatusFailedDependency), respObj.Results[2].Status)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineInventorySuite) Test_BatchInventory_DbServiceBestEffort() {
+	// ARRANGE
+	body := `{
+		"mode": "best-effort",
+		"operations": [
+			{ "op": "create", "entry": { "medicineId": "test-medicine-id", "count": 3 } },
+			{ "op": "update", "entryId": "test-entry", "entry": { "count": 30 } }
+		]
+	}`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/batch", strings.NewReader(body))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.BatchMedicineInventoryEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj BatchResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.True(respObj.Applied)
+	suite.Equal(int32(http.StatusConflict), respObj.Results[0].Status)
+	suite.Equal(int32(http.StatusOK), respObj.Results[1].Status)
+	suite.dbServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
+	suite.dbServiceMock.AssertCalled(suite.T(), "UpdateElements", mock.Anything, "test-ambulance", []db_service.ElementChange{{
+		ArrayField: "medicineinventory",
+		ElementId:  "test-entry",
+		FieldChange: db_service.FieldChange{
+			Expect: db_service.Eq("count", int32(15)),
+			Set:    map[string]any{"count": int32(30)},
+		},
+	}})
+}
+
+func (suite *MedicineInventorySuite) Test_BatchInventory_FailedLastChangeStoresNothing() {
+	// ARRANGE
+	body := `{
+		"mode": "atomic",
+		"operations": [
+			{ "op": "update", "entryId": "test-entry", "entry": { "count": 30 } },
+			{ "op": "delete", "entryId": "other-entry" },
+			{ "op": "create", "entry": { "id": "new-entry", "medicineId": "new-medicine-id", "count": 3 } }
+		]
+	}`
+	db := db_service.NewMemoryService[Ambulance]()
+	suite.Require().NoError(db.CreateDocument(context.Background(), "test-ambulance", &Ambulance{
+		Id: "test-ambulance",
+		MedicineInventory: []MedicineInventoryEntry{
+			{Id: "test-entry", MedicineId: "test-medicine-id", Count: 15},
+			{Id: "other-entry", MedicineId: "other-medicine-id", Count: 5},
+		},
+	}))
+	concurrent := MedicineInventoryEntry{Id: "new-entry", MedicineId: "concurrent-medicine-id", Count: 1}
+	racing := &racingDbService{DbService: db, afterFind: func(ctx context.Context) error {
+		// another request adds the entry after the batch loaded the ambulance
+		return db.PushElement(ctx, "test-ambulance", inventoryField, concurrent.Id, concurrent)
+	}}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", db_service.DbService[Ambulance](racing))
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/batch", strings.NewReader(body))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.BatchMedicineInventoryEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusConflict, recorder.Code)
+	stored, err := db.FindDocument(context.Background(), "test-ambulance")
+	suite.Require().NoError(err)
+	suite.Equal([]MedicineInventoryEntry{
+		{Id: "test-entry", MedicineId: "test-medicine-id", Count: 15},
+		{Id: "other-entry", MedicineId: "other-medicine-id", Count: 5},
+		concurrent,
+	}, stored.MedicineInventory, "the changes before the failed push must not be stored")
+}
+
+// racingDbService runs the change of a concurrent request right after the handler loads the ambulance
+type racingDbService struct {
+	db_service.DbService[Ambulance]
+	afterFind func(ctx context.Context) error
+}
+
+func (r *racingDbService) FindDocument(ctx context.Context, id any) (*Ambulance, error) {
+	ambulance, err := r.DbService.FindDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ambulance, r.afterFind(ctx)
+}
+
+func (suite *MedicineInventorySuite) Test_GetInventory_CsvFormat() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("GET", "/medicine-inventory/test-ambulance/entries?format=csv&columns=id,count&lang=en", nil)
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.GetMedicineInventoryEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("Entry ID,Count\ntest-entry,15\n", recorder.Body.String())
+	suite.Contains(recorder.Header().Get("Content-Disposition"), `filename="medicine-inventory-test-ambulance.csv"`)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineInventorySuite) Test_GetInventory_JsonByDefault() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("GET", "/medicine-inventory/test-ambulance/entries", nil)
+	ctx.Request.Header.Set("Accept", "*/*")
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.GetMedicineInventoryEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Contains(recorder.Header().Get("Content-Type"), "application/json")
+}
+
+func (suite *MedicineInventorySuite) Test_ImportInventory_MergeMode() {
+	// ARRANGE
+	csv := "medicineId,name,count\ntest-medicine-id,test-name,20\nmig-id,Mig 400,5\n"
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/import", strings.NewReader(csv))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.ImportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj InventoryImportResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.True(respObj.Applied)
+	suite.Equal([]InventoryImportChange{
+		{MedicineId: "test-medicine-id", Name: "test-name", Change: "updated", PreviousCount: 15, Count: 20},
+		{MedicineId: "mig-id", Name: "Mig 400", Change: "added", Count: 5},
+	}, respObj.Changes)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
+	suite.dbServiceMock.AssertCalled(suite.T(), "UpdateElements", mock.Anything, "test-ambulance",
+		mock.MatchedBy(func(changes []db_service.ElementChange) bool {
+			if len(changes) != 2 {
+				return false
+			}
+			pushed, ok := changes[1].Push.(MedicineInventoryEntry)
+			return changes[0].ElementId == "test-entry" &&
+				reflect.DeepEqual(changes[0].Expect, db_service.Eq("count", int32(15))) &&
+				changes[0].Set["count"] == int32(20) &&
+				ok && pushed.MedicineId == "mig-id" && pushed.Id != "" && changes[1].ElementId == pushed.Id
 		}),
 	)
 }
+
+func (suite *MedicineInventorySuite) Test_ImportInventory_AddModeWithSlovakHeaders() {
+	// ARRANGE
+	csv := "ID lieku;Liek;Počet\ntest-medicine-id;test-name;5\n"
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/import?mode=add", strings.NewReader(csv))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.ImportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbServiceMock.AssertCalled(suite.T(), "UpdateElements", mock.Anything, "test-ambulance", []db_service.ElementChange{{
+		ArrayField: "medicineinventory",
+		ElementId:  "test-entry",
+		FieldChange: db_service.FieldChange{
+			Expect: db_service.Eq("count", int32(15)),
+			Set:    map[string]any{"count": int32(20)},
+		},
+	}})
+}
+
+func (suite *MedicineInventorySuite) Test_ImportInventory_ReplaceModeDryRun() {
+	// ARRANGE
+	csv := "medicineId,name,count\nmig-id,Mig 400,5\n"
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/import?mode=replace&dryRun=true", strings.NewReader(csv))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.ImportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj InventoryImportResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.False(respObj.Applied)
+	suite.Equal([]InventoryImportChange{
+		{MedicineId: "mig-id", Name: "Mig 400", Change: "added", Count: 5},
+		{MedicineId: "test-medicine-id", Name: "test-name", Change: "removed", PreviousCount: 15},
+	}, respObj.Changes)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineInventorySuite) Test_ImportInventory_ReportsInvalidRows() {
+	// ARRANGE
+	csv := "medicineId,name,count\nmig-id,Mig 400,5\n,Paralen,3\nibalgin-id,Ibalgin,-1\nmig-id,Mig 400,abc\nmig-id,Mig 400,2\n"
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/import", strings.NewReader(csv))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.ImportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	var respObj InventoryImportResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.Equal([]InventoryImportRowError{
+		{Row: 3, Message: "medicineId is required"},
+		{Row: 4, MedicineId: "ibalgin-id", Message: "count must not be negative"},
+		{Row: 5, MedicineId: "mig-id", Message: `count "abc" is not a valid number`},
+		{Row: 6, MedicineId: "mig-id", Message: "medicine is already imported on row 2"},
+	}, respObj.Errors)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineInventorySuite) Test_ImportInventory_MissingColumn() {
+	// ARRANGE
+	csv := "medicineId,count\nmig-id,5\n"
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-inventory/test-ambulance/import", strings.NewReader(csv))
+
+	sut := implMedicineInventoryAPI{}
+
+	// ACT
+	sut.ImportMedicineInventory(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Contains(recorder.Body.String(), "column name is missing")
+}
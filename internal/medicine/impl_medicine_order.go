@@ -3,9 +3,11 @@ package medicine
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/undy45/medicine-webapi/internal/db_service"
 	"net/http"
 	"reflect"
 	"slices"
+	"strconv"
 )
 
 type implMedicineOrderAPI struct {
@@ -15,11 +17,12 @@ func NewMedicineOrderAPI() MedicineOrderAPI {
 	return &implMedicineOrderAPI{}
 }
 
-func (o implMedicineOrderAPI) CreateMedicineOrderEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		var entry MedicineOrderEntry
+func (o implMedicineOrderAPI) BatchMedicineOrderEntries(c *gin.Context) {
+	var notifications []OrderNotification
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var request MedicineOrderBatchRequest
 
-		if err := c.ShouldBindJSON(&entry); err != nil {
+		if err := c.ShouldBindJSON(&request); err != nil {
 			return nil, gin.H{
 				"status":  http.StatusBadRequest,
 				"message": "Invalid request body",
@@ -27,94 +30,348 @@ func (o implMedicineOrderAPI) CreateMedicineOrderEntry(c *gin.Context) {
 			}, http.StatusBadRequest
 		}
 
-		if entry.MedicineId == "" {
+		force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+		if err != nil {
 			return nil, gin.H{
 				"status":  http.StatusBadRequest,
-				"message": "Patient ID is required",
+				"message": "Invalid value of force parameter",
+				"error":   err.Error(),
 			}, http.StatusBadRequest
 		}
 
-		if entry.Id == "" || entry.Id == "@new" {
-			entry.Id = uuid.NewString()
+		if responseObject, status := validateBatch(request.Mode, len(request.Operations)); status != http.StatusOK {
+			return nil, responseObject, status
 		}
 
-		conflictIndx := slices.IndexFunc(ambulance.MedicineOrders, func(order MedicineOrderEntry) bool {
-			if len(order.Status.ValidTransitions) != 0 {
-				return false
+		// notifications are collected by order id, the batch may touch the same order more than once
+		pending := map[string]string{}
+		var pendingOrder []string
+		notify := func(entryId string, event string) {
+			if _, ok := pending[entryId]; !ok {
+				pendingOrder = append(pendingOrder, entryId)
 			}
-			return entry.Id == order.Id || entry.MedicineId == order.MedicineId
-		})
-
-		statusService := implUtilsOrderStatuses{}
-		initialStatus := statusService.GetInitialStatus(c)
-		entry.Status = *initialStatus
+			pending[entryId] = event
+		}
 
-		if conflictIndx >= 0 {
-			return nil, gin.H{
-				"status":  http.StatusConflict,
-				"message": "Entry already exists",
-			}, http.StatusConflict
+		operations := make([]batchOperation, len(request.Operations))
+		for i, operation := range request.Operations {
+			operations[i] = func(ambulance *Ambulance) (string, interface{}, int) {
+				switch operation.Op {
+				case CREATE:
+					entryIndx, merged, responseObject, status := addOrderEntry(c, ambulance, operation.Entry, force)
+					if entryIndx < 0 {
+						return operation.Entry.Id, responseObject, status
+					}
+					entryId := ambulance.MedicineOrders[entryIndx].Id
+					if merged {
+						notify(entryId, OrderMerged)
+					} else {
+						notify(entryId, OrderCreated)
+					}
+					return entryId, ambulance.MedicineOrders[entryIndx], http.StatusOK
+				case UPDATE:
+					entryIndx, priorityChanged, responseObject, status := updateOrderEntry(c, ambulance, operation.EntryId, operation.Entry)
+					if entryIndx < 0 {
+						return operation.EntryId, responseObject, status
+					}
+					if priorityChanged {
+						notify(operation.EntryId, OrderPriorityChanged)
+					}
+					return operation.EntryId, ambulance.MedicineOrders[entryIndx], http.StatusOK
+				case DELETE:
+					responseObject, status := deleteOrderEntry(ambulance, operation.EntryId)
+					return operation.EntryId, responseObject, status
+				default:
+					responseObject, status := unknownBatchOperation(operation.Op)
+					return operation.EntryId, responseObject, status
+				}
+			}
 		}
 
-		ambulance.MedicineOrders = append(ambulance.MedicineOrders, entry)
-		// entry was copied by value return reconciled value from the list
-		entryIndx := slices.IndexFunc(ambulance.MedicineOrders, func(orderEntry MedicineOrderEntry) bool {
-			return entry.Id == orderEntry.Id
-		})
-		if entryIndx < 0 {
-			return nil, gin.H{
-				"status":  http.StatusInternalServerError,
-				"message": "Failed to save entry",
-			}, http.StatusInternalServerError
+		inventory := slices.Clone(ambulance.MedicineInventory)
+		orders := slices.Clone(ambulance.MedicineOrders)
+		updatedAmbulance, responseObject, status := applyBatch(c, ambulance, request.Mode, operations)
+		if updatedAmbulance == nil {
+			return nil, responseObject, status
+		}
+		for _, entryId := range pendingOrder {
+			entryIndx := slices.IndexFunc(updatedAmbulance.MedicineOrders, func(order MedicineOrderEntry) bool {
+				return entryId == order.Id
+			})
+			if entryIndx >= 0 {
+				notifications = append(notifications, OrderNotification{
+					Event: pending[entryId],
+					Order: updatedAmbulance.MedicineOrders[entryIndx],
+				})
+			}
 		}
-		return ambulance, ambulance.MedicineOrders[entryIndx], http.StatusOK
+		// delivered orders change the inventory, both are written in the same update
+		changes := append(
+			orderChanges(orders, updatedAmbulance.MedicineOrders),
+			inventoryChanges(inventory, updatedAmbulance.MedicineInventory)...,
+		)
+		return elementsPatch(changes), responseObject, status
 	})
+	if c.Writer.Status() < http.StatusMultipleChoices {
+		for _, notification := range notifications {
+			notifyOrder(c, notification.Event, notification.Order)
+		}
+	}
 }
 
-func (o implMedicineOrderAPI) DeleteMedicineOrderEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
-		entryId := c.Param("entryId")
+func (o implMedicineOrderAPI) CreateMedicineOrderEntry(c *gin.Context) {
+	var stored *MedicineOrderEntry
+	var merged bool
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var entry MedicineOrderEntry
 
-		if entryId == "" {
+		if err := c.ShouldBindJSON(&entry); err != nil {
 			return nil, gin.H{
 				"status":  http.StatusBadRequest,
-				"message": "Entry ID is required",
+				"message": "Invalid request body",
+				"error":   err.Error(),
 			}, http.StatusBadRequest
 		}
 
-		entryIndx := slices.IndexFunc(ambulance.MedicineOrders, func(waiting MedicineOrderEntry) bool {
-			return entryId == waiting.Id
-		})
+		force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+		if err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid value of force parameter",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
 
+		before := slices.Clone(ambulance.MedicineOrders)
+		entryIndx, isMerged, responseObject, status := addOrderEntry(c, ambulance, entry, force)
 		if entryIndx < 0 {
-			return nil, gin.H{
-				"status":  http.StatusNotFound,
-				"message": "Entry not found",
-			}, http.StatusNotFound
+			return nil, responseObject, status
+		}
+		stored = &ambulance.MedicineOrders[entryIndx]
+		merged = isMerged
+		if !isMerged {
+			return pushElement(ordersField, stored.Id, *stored), *stored, http.StatusOK
 		}
 
-		ambulance.MedicineOrders = append(ambulance.MedicineOrders[:entryIndx], ambulance.MedicineOrders[entryIndx+1:]...)
-		return ambulance, nil, http.StatusNoContent
+		// count is incremented, so concurrent merges into the same order are not lost,
+		// and the order must still have the status it was merged in
+		change := db_service.ElementChange{
+			ArrayField: ordersField,
+			ElementId:  stored.Id,
+			FieldChange: db_service.FieldChange{
+				Expect:    db_service.Eq("status.id", before[entryIndx].Status.Id),
+				Increment: map[string]int64{"count": int64(stored.Count - before[entryIndx].Count)},
+			},
+		}
+		fields := orderFieldChanges(before[entryIndx], *stored)
+		delete(fields, "count")
+		if len(fields) > 0 {
+			change.Set = fields
+		}
+		return updateElements(change), *stored, http.StatusOK
 	})
+	if stored != nil && c.Writer.Status() < http.StatusMultipleChoices {
+		if merged {
+			notifyOrder(c, OrderMerged, *stored)
+		} else {
+			notifyOrder(c, OrderCreated, *stored)
+		}
+	}
+}
+
+// addOrderEntry validates the new order and stores it into the ambulance orders with its initial status.
+// If there is an open order for the same medicine, the ambulance duplicate order policy decides whether
+// the new order is rejected or merged into the open one, unless force is set. Only orders which were not
+// processed yet take merged orders, otherwise the merged order is stored as a new one.
+// Returns index of the stored order, or -1 together with the error response and its status code.
+func addOrderEntry(c *gin.Context, ambulance *Ambulance, entry MedicineOrderEntry, force bool) (int, bool, interface{}, int) {
+	if entry.MedicineId == "" {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Medicine ID is required",
+		}, http.StatusBadRequest
+	}
+
+	if entry.Id == "" || entry.Id == "@new" {
+		entry.Id = uuid.NewString()
+	}
+
+	if !entry.Priority.isValid() {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Unknown order priority",
+		}, http.StatusBadRequest
+	}
+	if entry.Priority == "" {
+		entry.Priority = ROUTINE
+	}
+
+	if slices.ContainsFunc(ambulance.MedicineOrders, func(order MedicineOrderEntry) bool {
+		return entry.Id == order.Id
+	}) {
+		return -1, false, gin.H{
+			"status":  http.StatusConflict,
+			"message": "Entry already exists",
+		}, http.StatusConflict
+	}
+
+	openIndx := -1
+	if !force {
+		openIndx = slices.IndexFunc(ambulance.MedicineOrders, func(order MedicineOrderEntry) bool {
+			return entry.MedicineId == order.MedicineId && isOpenOrder(order)
+		})
+	}
+
+	if openIndx >= 0 && ambulance.DuplicateOrderPolicy != MERGE {
+		return -1, false, gin.H{
+			"status":          http.StatusConflict,
+			"message":         "Open order for this medicine already exists",
+			"existingEntryId": ambulance.MedicineOrders[openIndx].Id,
+		}, http.StatusConflict
+	}
+
+	mergeIndx := -1
+	if openIndx >= 0 {
+		startStatuses := newOrderStatusIds(c)
+		if startStatuses == nil {
+			// error response was already written by the status lookup
+			return -1, false, nil, c.Writer.Status()
+		}
+		// orders which were already processed further, e.g. shipped, cannot take more medicine
+		mergeIndx = slices.IndexFunc(ambulance.MedicineOrders, func(order MedicineOrderEntry) bool {
+			return entry.MedicineId == order.MedicineId && startStatuses[order.Status.Id]
+		})
+	}
+
+	if mergeIndx >= 0 {
+		openOrder := &ambulance.MedicineOrders[mergeIndx]
+		openOrder.Count += entry.Count
+		if entry.Priority.rank() < openOrder.Priority.rank() {
+			openOrder.Priority = entry.Priority
+			changedStatus := resolveStatusForPriority(c, &openOrder.Status, openOrder.Priority)
+			if changedStatus == nil {
+				// error response was already written by the status lookup
+				return -1, false, nil, c.Writer.Status()
+			}
+			openOrder.Status = *changedStatus
+		}
+		return mergeIndx, true, nil, http.StatusOK
+	}
+
+	statusService := implUtilsOrderStatuses{}
+	initialStatus := resolveStatusForPriority(c, statusService.GetInitialStatus(c), entry.Priority)
+	if initialStatus == nil {
+		// error response was already written by the status lookup
+		return -1, false, nil, c.Writer.Status()
+	}
+	entry.Status = *initialStatus
+
+	ambulance.MedicineOrders = append(ambulance.MedicineOrders, entry)
+	return len(ambulance.MedicineOrders) - 1, false, nil, http.StatusOK
+}
+
+// isOpenOrder reports whether the order is still being processed, finished orders have no transitions left
+func isOpenOrder(order MedicineOrderEntry) bool {
+	return len(order.Status.ValidTransitions) != 0
+}
+
+// newOrderStatusIds returns ids of the statuses new orders start in, the initial status and the statuses
+// urgent orders move to past the approval stages. Returns nil if the status lookup failed, in which case
+// the error response is already written.
+func newOrderStatusIds(c *gin.Context) map[int32]bool {
+	statusService := implUtilsOrderStatuses{}
+	status := statusService.GetInitialStatus(c)
+	ids := map[int32]bool{}
+	for status != nil && !ids[status.Id] {
+		ids[status.Id] = true
+		if !status.ApprovalStage || len(status.ValidTransitions) == 0 {
+			return ids
+		}
+		status = statusService.GetStatus(c, int(status.ValidTransitions[0]))
+	}
+	if status == nil {
+		return nil
+	}
+	return ids
+}
+
+func (o implMedicineOrderAPI) DeleteMedicineOrderEntry(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		entryId := c.Param("entryId")
+		responseObject, status := deleteOrderEntry(ambulance, entryId)
+		if status >= http.StatusMultipleChoices {
+			return nil, responseObject, status
+		}
+		return pullElement(ordersField, entryId), responseObject, status
+	})
+}
+
+// deleteOrderEntry removes the order from the ambulance.
+// Returns the error response and its status code if there is no such order.
+func deleteOrderEntry(ambulance *Ambulance, entryId string) (interface{}, int) {
+	if entryId == "" {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Entry ID is required",
+		}, http.StatusBadRequest
+	}
+
+	entryIndx := slices.IndexFunc(ambulance.MedicineOrders, func(waiting MedicineOrderEntry) bool {
+		return entryId == waiting.Id
+	})
+
+	if entryIndx < 0 {
+		return gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Entry not found",
+		}, http.StatusNotFound
+	}
+
+	ambulance.MedicineOrders = append(ambulance.MedicineOrders[:entryIndx], ambulance.MedicineOrders[entryIndx+1:]...)
+	return nil, http.StatusNoContent
 }
 
 func (o implMedicineOrderAPI) GetMedicineOrderEntries(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
+	readAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (interface{}, int) {
+		format, err := negotiateExportFormat(c, exportJSON, exportCSV, exportXLSX)
+		if err != nil {
+			return invalidExportFormat(err), http.StatusBadRequest
+		}
+
 		result := ambulance.MedicineOrders
 		if result == nil {
 			result = []MedicineOrderEntry{}
 		}
-		// return nil ambulance - no need to update it in db
-		return nil, result, http.StatusOK
+
+		switch sort := c.Query("sort"); sort {
+		case "":
+		case "priority", "-priority":
+			SortOrdersByPriority(result, sort == "priority")
+		default:
+			return gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Unsupported sort order",
+				"error":   "sort must be one of: priority, -priority",
+			}, http.StatusBadRequest
+		}
+		if format != exportJSON {
+			responseObject, status := streamExport(
+				c, format, "medicine-orders-"+ambulance.Id, orderExportColumns, defaultOrderExportColumns,
+				func(yield func(*Ambulance, []MedicineOrderEntry) error) error {
+					return yield(ambulance, result)
+				})
+			return responseObject, status
+		}
+		return result, http.StatusOK
 	})
 }
 
 func (o implMedicineOrderAPI) GetMedicineOrderEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
+	readAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (interface{}, int) {
 		entryId := c.Param("entryId")
 
 		if entryId == "" {
-			return nil, gin.H{
+			return gin.H{
 				"status":  http.StatusBadRequest,
 				"message": "Entry ID is required",
 			}, http.StatusBadRequest
@@ -125,19 +382,19 @@ func (o implMedicineOrderAPI) GetMedicineOrderEntry(c *gin.Context) {
 		})
 
 		if entryIndx < 0 {
-			return nil, gin.H{
+			return gin.H{
 				"status":  http.StatusNotFound,
 				"message": "Entry not found",
 			}, http.StatusNotFound
 		}
 
-		// return nil ambulance - no need to update it in db
-		return nil, ambulance.MedicineOrders[entryIndx], http.StatusOK
+		return ambulance.MedicineOrders[entryIndx], http.StatusOK
 	})
 }
 
 func (o implMedicineOrderAPI) UpdateMedicineOrderEntry(c *gin.Context) {
-	updateAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (*Ambulance, interface{}, int) {
+	var reprioritized *MedicineOrderEntry
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
 		var entry MedicineOrderEntry
 
 		if err := c.ShouldBindJSON(&entry); err != nil {
@@ -148,81 +405,209 @@ func (o implMedicineOrderAPI) UpdateMedicineOrderEntry(c *gin.Context) {
 			}, http.StatusBadRequest
 		}
 
-		entryId := c.Param("entryId")
-
-		if entryId == "" {
-			return nil, gin.H{
-				"status":  http.StatusBadRequest,
-				"message": "Entry ID is required",
-			}, http.StatusBadRequest
-		}
-
-		entryIndx := slices.IndexFunc(ambulance.MedicineOrders, func(order MedicineOrderEntry) bool {
-			return entryId == order.Id
-		})
-
+		inventory := slices.Clone(ambulance.MedicineInventory)
+		before := slices.Clone(ambulance.MedicineOrders)
+		entryIndx, priorityChanged, responseObject, status := updateOrderEntry(c, ambulance, c.Param("entryId"), entry)
 		if entryIndx < 0 {
-			return nil, gin.H{
-				"status":  http.StatusNotFound,
-				"message": "Entry not found",
-			}, http.StatusNotFound
+			return nil, responseObject, status
 		}
 
-		if entry.Count > 0 {
-			ambulance.MedicineOrders[entryIndx].Count = entry.Count
+		updated := ambulance.MedicineOrders[entryIndx]
+		if priorityChanged {
+			reprioritized = &updated
 		}
 
-		if entry.MedicineId != "" && entry.MedicineId != ambulance.MedicineOrders[entryIndx].MedicineId {
-			return nil, gin.H{
-				"status":  http.StatusBadRequest,
-				"message": "Cannot update MedicineId in existing order",
-			}, http.StatusBadRequest
+		fields := orderFieldChanges(before[entryIndx], updated)
+		if len(fields) == 0 {
+			return nil, updated, http.StatusOK
 		}
-
-		if entry.Id != "" && entry.Id != ambulance.MedicineOrders[entryIndx].Id {
-			return nil, gin.H{
-				"status":  http.StatusBadRequest,
-				"message": "Cannot update Id in existing order",
-			}, http.StatusBadRequest
+		changes := []db_service.ElementChange{{
+			ArrayField:  ordersField,
+			ElementId:   before[entryIndx].Id,
+			FieldChange: db_service.FieldChange{Set: fields},
+		}}
+		if updated.Status.Id != before[entryIndx].Status.Id {
+			// the transition is valid only from the status the order was loaded with
+			changes[0].Expect = db_service.Eq("status.id", before[entryIndx].Status.Id)
+			if updated.Status.Value == "Delivered" {
+				changes = append(changes, deliveredOrderChange(inventory, updated))
+			}
 		}
+		return updateElements(changes...), updated, http.StatusOK
+	})
+	if reprioritized != nil && c.Writer.Status() < http.StatusMultipleChoices {
+		notifyOrder(c, OrderPriorityChanged, *reprioritized)
+	}
+}
 
-		if entry.Name != "" {
-			ambulance.MedicineOrders[entryIndx].Name = entry.Name
-		}
+// updateOrderEntry applies the requested changes to the order. Status can only change along the valid
+// transitions of the current status, delivered orders are added to the ambulance inventory.
+// Returns index of the order and whether its priority changed, or -1 together with the error response
+// and its status code.
+func updateOrderEntry(c *gin.Context, ambulance *Ambulance, entryId string, entry MedicineOrderEntry) (int, bool, interface{}, int) {
+	if entryId == "" {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Entry ID is required",
+		}, http.StatusBadRequest
+	}
 
-		currentValidTransitions := ambulance.MedicineOrders[entryIndx].Status.ValidTransitions
-		if entry.Status.ValidTransitions != nil && !reflect.DeepEqual(entry.Status.ValidTransitions, currentValidTransitions) {
-			return nil, gin.H{
-				"status":  http.StatusBadRequest,
-				"message": "Can only update status id to change state (Trying to update ValidTransitions)",
-			}, http.StatusBadRequest
-		}
+	entryIndx := slices.IndexFunc(ambulance.MedicineOrders, func(order MedicineOrderEntry) bool {
+		return entryId == order.Id
+	})
 
-		currentValue := ambulance.MedicineOrders[entryIndx].Status.Value
-		if entry.Status.ValidTransitions != nil && !reflect.DeepEqual(entry.Status.Value, currentValue) {
-			return nil, gin.H{
-				"status":  http.StatusBadRequest,
-				"message": "Can only update status id to change state (Trying to update Value)",
-			}, http.StatusBadRequest
-		}
+	if entryIndx < 0 {
+		return -1, false, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Entry not found",
+		}, http.StatusNotFound
+	}
 
-		if entry.Status.Id == 0 {
-			return ambulance, ambulance.MedicineOrders[entryIndx], http.StatusOK
-		}
-		currentStatus := ambulance.MedicineOrders[entryIndx].Status
-		if !slices.Contains(currentStatus.ValidTransitions, entry.Status.Id) && entry.Status.Id != currentStatus.Id {
-			return nil, gin.H{
+	if entry.Count > 0 {
+		ambulance.MedicineOrders[entryIndx].Count = entry.Count
+	}
+
+	if entry.MedicineId != "" && entry.MedicineId != ambulance.MedicineOrders[entryIndx].MedicineId {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Cannot update MedicineId in existing order",
+		}, http.StatusBadRequest
+	}
+
+	if entry.Id != "" && entry.Id != ambulance.MedicineOrders[entryIndx].Id {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Cannot update Id in existing order",
+		}, http.StatusBadRequest
+	}
+
+	if entry.Name != "" {
+		ambulance.MedicineOrders[entryIndx].Name = entry.Name
+	}
+
+	if !entry.Priority.isValid() {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Unknown order priority",
+		}, http.StatusBadRequest
+	}
+	priorityChanged := entry.Priority != "" && entry.Priority != ambulance.MedicineOrders[entryIndx].Priority
+	if priorityChanged {
+		ambulance.MedicineOrders[entryIndx].Priority = entry.Priority
+	}
+
+	currentValidTransitions := ambulance.MedicineOrders[entryIndx].Status.ValidTransitions
+	if entry.Status.ValidTransitions != nil && !reflect.DeepEqual(entry.Status.ValidTransitions, currentValidTransitions) {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Can only update status id to change state (Trying to update ValidTransitions)",
+		}, http.StatusBadRequest
+	}
+
+	currentValue := ambulance.MedicineOrders[entryIndx].Status.Value
+	if entry.Status.ValidTransitions != nil && !reflect.DeepEqual(entry.Status.Value, currentValue) {
+		return -1, false, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Can only update status id to change state (Trying to update Value)",
+		}, http.StatusBadRequest
+	}
+
+	currentStatus := ambulance.MedicineOrders[entryIndx].Status
+	targetStatus := &currentStatus
+	if entry.Status.Id != 0 && entry.Status.Id != currentStatus.Id {
+		if !slices.Contains(currentStatus.ValidTransitions, entry.Status.Id) {
+			return -1, false, gin.H{
 				"status":  http.StatusBadRequest,
 				"message": "Changed status is not valid for current order state",
 			}, http.StatusBadRequest
 		}
 		statusService := implUtilsOrderStatuses{}
-		changedStatus := statusService.GetStatus(c, int(entry.Status.Id))
-		ambulance.MedicineOrders[entryIndx].Status = *changedStatus
+		targetStatus = statusService.GetStatus(c, int(entry.Status.Id))
+	}
+	// raising the priority may release the order from its current approval stage
+	changedStatus := resolveStatusForPriority(c, targetStatus, ambulance.MedicineOrders[entryIndx].Priority)
+	if changedStatus == nil {
+		// error response was already written by the status lookup
+		return -1, false, nil, c.Writer.Status()
+	}
+	ambulance.MedicineOrders[entryIndx].Status = *changedStatus
+	if changedStatus.Id != currentStatus.Id {
 		HandleIfDelivered(ambulance, ambulance.MedicineOrders[entryIndx])
+	}
+
+	return entryIndx, priorityChanged, nil, http.StatusOK
+}
+
+// orderFieldChanges lists the stored fields of the order which differ after the change
+func orderFieldChanges(before MedicineOrderEntry, after MedicineOrderEntry) map[string]any {
+	fields := map[string]any{}
+	if before.Name != after.Name {
+		fields["name"] = after.Name
+	}
+	if before.Count != after.Count {
+		fields["count"] = after.Count
+	}
+	if before.Priority != after.Priority {
+		fields["priority"] = after.Priority
+	}
+	if !reflect.DeepEqual(before.Status, after.Status) {
+		fields["status"] = after.Status
+	}
+	return fields
+}
+
+// orderChanges lists the element changes turning the loaded orders into the changed ones, so the orders
+// changed meanwhile by other requests are kept. The changed orders are expected to keep their loaded status,
+// and their loaded count if it changes, otherwise nothing is written and ErrConflict is returned.
+func orderChanges(before []MedicineOrderEntry, after []MedicineOrderEntry) []db_service.ElementChange {
+	var changes []db_service.ElementChange
+	kept := map[string]bool{}
+	for _, entry := range after {
+		kept[entry.Id] = true
+		beforeIndx := slices.IndexFunc(before, func(stored MedicineOrderEntry) bool {
+			return entry.Id == stored.Id
+		})
+		if beforeIndx < 0 {
+			changes = append(changes, db_service.ElementChange{ArrayField: ordersField, ElementId: entry.Id, Push: entry})
+			continue
+		}
+		fields := orderFieldChanges(before[beforeIndx], entry)
+		if len(fields) == 0 {
+			continue
+		}
+		conditions := []db_service.Filter{db_service.Eq("status.id", before[beforeIndx].Status.Id)}
+		if _, ok := fields["count"]; ok {
+			conditions = append(conditions, db_service.Eq("count", before[beforeIndx].Count))
+		}
+		changes = append(changes, db_service.ElementChange{
+			ArrayField:  ordersField,
+			ElementId:   entry.Id,
+			FieldChange: db_service.FieldChange{Expect: allOf(conditions), Set: fields},
+		})
+	}
+	for _, entry := range before {
+		if !kept[entry.Id] {
+			changes = append(changes, db_service.ElementChange{ArrayField: ordersField, ElementId: entry.Id, Pull: true})
+		}
+	}
+	return changes
+}
 
-		return ambulance, ambulance.MedicineOrders[entryIndx], http.StatusOK
+// deliveredOrderChange adds the delivered order to the inventory the same way as HandleIfDelivered,
+// the inventory is the one loaded before the order was changed
+func deliveredOrderChange(inventory []MedicineInventoryEntry, order MedicineOrderEntry) db_service.ElementChange {
+	foundIndx := slices.IndexFunc(inventory, func(entry MedicineInventoryEntry) bool {
+		return order.Id == entry.Id || order.MedicineId == entry.MedicineId
 	})
+	if foundIndx >= 0 {
+		return db_service.ElementChange{
+			ArrayField:  inventoryField,
+			ElementId:   inventory[foundIndx].Id,
+			FieldChange: db_service.FieldChange{Increment: map[string]int64{"count": int64(order.Count)}},
+		}
+	}
+	inventoryEntry := ConvertOrderToInventoryEntry(order)
+	return db_service.ElementChange{ArrayField: inventoryField, ElementId: inventoryEntry.Id, Push: inventoryEntry}
 }
 
 func HandleIfDelivered(ambulance *Ambulance, entry MedicineOrderEntry) {
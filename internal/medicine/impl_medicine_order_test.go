@@ -1,6 +1,7 @@
 package medicine
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/mock"
@@ -33,6 +35,11 @@ func (suite *MedicineOrderSuite) SetupTest() {
 	var _ db_service.DbService[Ambulance] = suite.dbAmbulanceServiceMock
 	var _ db_service.DbService[Status] = suite.dbStatusServiceMock
 
+	// no status is marked as initial, new orders start in the status 1
+	suite.dbStatusServiceMock.
+		On("FindDocuments", mock.Anything, db_service.Query{Filter: db_service.Eq("initial", true), Limit: 1}).
+		Return([]*Status{}, nil)
+
 	suite.dbAmbulanceServiceMock.
 		On("FindDocument", mock.Anything, mock.Anything).
 		Return(
@@ -123,6 +130,18 @@ func (suite *MedicineOrderSuite) SetupTest() {
 	suite.dbAmbulanceServiceMock.
 		On("UpdateDocument", mock.Anything, mock.Anything, mock.Anything).
 		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("PushElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("PullElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("UpdateElements", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
 }
 
 func (suite *MedicineOrderSuite) Test_DeleteOrder_DbService() {
@@ -144,15 +163,8 @@ func (suite *MedicineOrderSuite) Test_DeleteOrder_DbService() {
 
 	// ASSERT
 	suite.Equal(http.StatusNoContent, recorder.Code)
-	suite.dbAmbulanceServiceMock.AssertCalled(
-		suite.T(),
-		"UpdateDocument",
-		mock.Anything,
-		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			return len(arg.MedicineOrders) == 0
-		}),
-	)
+	suite.dbAmbulanceServiceMock.AssertCalled(suite.T(), "PullElement", mock.Anything, "test-ambulance", "medicineorders", "test-entry")
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *MedicineOrderSuite) Test_GetOrder_DbService() {
@@ -270,21 +282,17 @@ func (suite *MedicineOrderSuite) Test_CreateOrder_DbService() {
 			Value:            "To_ship",
 			ValidTransitions: []int32{2, 4},
 		},
+		Priority: ROUTINE,
 	}
 	suite.Equal(http.StatusOK, recorder.Code)
 	suite.dbAmbulanceServiceMock.AssertCalled(
 		suite.T(),
-		"UpdateDocument",
+		"PushElement",
 		mock.Anything,
 		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			for _, entry := range arg.MedicineOrders {
-				if reflect.DeepEqual(entry, *expectedObj) {
-					return true
-				}
-			}
-			return false
-		}),
+		"medicineorders",
+		"input-entry-id",
+		*expectedObj,
 	)
 }
 
@@ -327,21 +335,17 @@ func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceWillIgnoreRequestStat
 			Value:            "To_ship",
 			ValidTransitions: []int32{2, 4},
 		},
+		Priority: ROUTINE,
 	}
 	suite.Equal(http.StatusOK, recorder.Code)
 	suite.dbAmbulanceServiceMock.AssertCalled(
 		suite.T(),
-		"UpdateDocument",
+		"PushElement",
 		mock.Anything,
 		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			for _, entry := range arg.MedicineOrders {
-				if reflect.DeepEqual(entry, *expectedObj) {
-					return true
-				}
-			}
-			return false
-		}),
+		"medicineorders",
+		"input-entry-id",
+		*expectedObj,
 	)
 }
 
@@ -369,20 +373,11 @@ func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceUpdateSimpleFields()
 
 	// ASSERT
 	suite.Equal(http.StatusOK, recorder.Code)
-	suite.dbAmbulanceServiceMock.AssertCalled(
-		suite.T(),
-		"UpdateDocument",
-		mock.Anything,
-		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			for _, entry := range arg.MedicineOrders {
-				if entry.Id == "test-entry" && entry.Count == 20 {
-					return true
-				}
-			}
-			return false
-		}),
-	)
+	suite.dbAmbulanceServiceMock.AssertCalled(suite.T(), "UpdateElements", mock.Anything, "test-ambulance", []db_service.ElementChange{{
+		ArrayField:  "medicineorders",
+		ElementId:   "test-entry",
+		FieldChange: db_service.FieldChange{Set: map[string]any{"count": int32(20)}},
+	}})
 }
 
 func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceCannotUpdateId() {
@@ -409,17 +404,60 @@ func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceCannotUpdateId() {
 
 	// ASSERT
 	suite.Equal(http.StatusBadRequest, recorder.Code)
-	suite.dbAmbulanceServiceMock.AssertNotCalled(
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceUpdateStatus() {
+	// ARRANGE
+	json := `{
+		"status": {
+			"id": 2
+		}
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "entryId", Value: "test-entry"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/medicine-order/test-ambulance/entries/test-entry", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.UpdateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertCalled(
 		suite.T(),
-		"UpdateDocument",
+		"UpdateElements",
 		mock.Anything,
 		"test-ambulance",
-		mock.Anything,
+		mock.MatchedBy(func(changes []db_service.ElementChange) bool {
+			if len(changes) != 1 {
+				return false
+			}
+			status, ok := changes[0].Set["status"].(Status)
+			return changes[0].ArrayField == "medicineorders" && changes[0].ElementId == "test-entry" &&
+				ok && status.Id == 2 &&
+				reflect.DeepEqual(changes[0].Expect, db_service.Eq("status.id", int32(1)))
+		}),
 	)
 }
 
-func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceUpdateStatus() {
+func (suite *MedicineOrderSuite) Test_UpdateOrder_StatusChangedMeanwhileIsConflict() {
 	// ARRANGE
+	suite.dbAmbulanceServiceMock.
+		On("UpdateElements", mock.Anything, mock.Anything, mock.Anything).
+		Unset().
+		On("UpdateElements", mock.Anything, mock.Anything, mock.Anything).
+		Return(db_service.ErrConflict)
+
 	json := `{
 		"status": {
 			"id": 2
@@ -442,22 +480,87 @@ func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceUpdateStatus() {
 	// ACT
 	sut.UpdateMedicineOrderEntry(ctx)
 
+	// ASSERT
+	suite.Equal(http.StatusConflict, recorder.Code)
+}
+
+func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceDeliveredOrderIncrementsInventory() {
+	// ARRANGE
+	suite.dbAmbulanceServiceMock.
+		On("FindDocument", mock.Anything, mock.Anything).
+		Unset().
+		On("FindDocument", mock.Anything, mock.Anything).
+		Return(
+			&Ambulance{
+				Id: "test-ambulance",
+				MedicineInventory: []MedicineInventoryEntry{
+					{
+						Id:         "inventory-entry",
+						MedicineId: "test-medicine-id",
+						Count:      3,
+					},
+				},
+				MedicineOrders: []MedicineOrderEntry{
+					{
+						Id:         "test-entry",
+						MedicineId: "test-medicine-id",
+						Count:      15,
+						Status: Status{
+							Id:               2,
+							Value:            "Shipped",
+							ValidTransitions: []int32{3, 4},
+						},
+					},
+				},
+			},
+			nil,
+		)
+
+	json := `{
+		"status": {
+			"id": 3
+		}
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "entryId", Value: "test-entry"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/medicine-order/test-ambulance/entries/test-entry", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.UpdateMedicineOrderEntry(ctx)
+
 	// ASSERT
 	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
 	suite.dbAmbulanceServiceMock.AssertCalled(
 		suite.T(),
-		"UpdateDocument",
+		"UpdateElements",
 		mock.Anything,
 		"test-ambulance",
-		mock.MatchedBy(func(arg *Ambulance) bool {
-			for _, entry := range arg.MedicineOrders {
-				if entry.Id == "test-entry" && entry.Status.Id == 2 {
-					return true
-				}
+		mock.MatchedBy(func(changes []db_service.ElementChange) bool {
+			if len(changes) != 2 {
+				return false
 			}
-			return false
+			status, ok := changes[0].Set["status"].(Status)
+			return changes[0].ElementId == "test-entry" && ok && status.Id == 3 &&
+				reflect.DeepEqual(changes[0].Expect, db_service.Eq("status.id", int32(2))) &&
+				reflect.DeepEqual(changes[1], db_service.ElementChange{
+					ArrayField:  "medicineinventory",
+					ElementId:   "inventory-entry",
+					FieldChange: db_service.FieldChange{Increment: map[string]int64{"count": 15}},
+				})
 		}),
 	)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceCannotUpdateInvalidStatus() {
@@ -486,13 +589,7 @@ func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceCannotUpdateInvalidSt
 
 	// ASSERT
 	suite.Equal(http.StatusBadRequest, recorder.Code)
-	suite.dbAmbulanceServiceMock.AssertNotCalled(
-		suite.T(),
-		"UpdateDocument",
-		mock.Anything,
-		"test-ambulance",
-		mock.Anything,
-	)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceCannotChangeAnythingFromFinishedStatus() {
@@ -545,11 +642,570 @@ func (suite *MedicineOrderSuite) Test_UpdateOrder_DbServiceCannotChangeAnythingF
 
 	// ASSERT
 	suite.Equal(http.StatusBadRequest, recorder.Code)
-	suite.dbAmbulanceServiceMock.AssertNotCalled(
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineOrderSuite) Test_GetOrder_DbServiceSortByPriority() {
+	// ARRANGE
+	suite.dbAmbulanceServiceMock.
+		On("FindDocument", mock.Anything, mock.Anything).
+		Unset().
+		On("FindDocument", mock.Anything, mock.Anything).
+		Return(
+			&Ambulance{
+				Id: "test-ambulance",
+				MedicineOrders: []MedicineOrderEntry{
+					{Id: "routine-entry", MedicineId: "routine-medicine", Count: 1, Priority: ROUTINE},
+					{Id: "legacy-entry", MedicineId: "legacy-medicine", Count: 1},
+					{Id: "emergency-entry", MedicineId: "emergency-medicine", Count: 1, Priority: EMERGENCY},
+					{Id: "urgent-entry", MedicineId: "urgent-medicine", Count: 1, Priority: URGENT},
+				},
+			},
+			nil,
+		)
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("GET", "/medicine-order/test-ambulance/entries?sort=priority", nil)
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.GetMedicineOrderEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj []MedicineOrderEntry
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	ids := make([]string, len(respObj))
+	for i, entry := range respObj {
+		ids[i] = entry.Id
+	}
+	suite.Equal([]string{"emergency-entry", "urgent-entry", "routine-entry", "legacy-entry"}, ids)
+}
+
+func (suite *MedicineOrderSuite) Test_GetOrder_DbServiceRejectsUnknownSort() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("GET", "/medicine-order/test-ambulance/entries?sort=name", nil)
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.GetMedicineOrderEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceUrgentSkipsApprovalStage() {
+	// ARRANGE
+	suite.dbStatusServiceMock.
+		On("FindDocument", mock.Anything, 1).
+		Unset().
+		On("FindDocument", mock.Anything, 1).
+		Return(
+			&Status{
+				Id:               1,
+				Value:            "Awaiting_approval",
+				ValidTransitions: []int32{2, 4},
+				ApprovalStage:    true,
+			},
+			nil,
+		)
+
+	json := `{
+        "id": "input-entry-id",
+        "medicineId": "input-test-medicine-id",
+		"count": 20,
+		"priority": "urgent"
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertCalled(
 		suite.T(),
-		"UpdateDocument",
+		"PushElement",
 		mock.Anything,
 		"test-ambulance",
-		mock.Anything,
+		"medicineorders",
+		"input-entry-id",
+		mock.MatchedBy(func(arg MedicineOrderEntry) bool {
+			return arg.Status.Id == 2 && arg.Priority == URGENT
+		}),
+	)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceRejectsUnknownPriority() {
+	// ARRANGE
+	json := `{
+        "medicineId": "input-test-medicine-id",
+		"count": 20,
+		"priority": "whenever"
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "PushElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceEmergencyRaisesAlert() {
+	// ARRANGE
+	sink := &notificationSinkStub{}
+	notifier := NewDigestOrderNotifier(sink, time.Hour)
+
+	json := `{
+        "id": "input-entry-id",
+        "medicineId": "input-test-medicine-id",
+		"count": 20,
+		"priority": "emergency"
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Set("order_notifier", notifier)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Require().Len(sink.alerts, 1)
+	suite.Equal("input-entry-id", sink.alerts[0].Order.Id)
+	suite.Equal("test-ambulance", sink.alerts[0].AmbulanceId)
+	suite.NoError(notifier.Flush(context.Background()))
+	suite.Empty(sink.digests)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceRoutineWaitsForDigest() {
+	// ARRANGE
+	sink := &notificationSinkStub{}
+	notifier := NewDigestOrderNotifier(sink, time.Hour)
+
+	json := `{
+        "id": "input-entry-id",
+        "medicineId": "input-test-medicine-id",
+		"count": 20
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Set("order_notifier", notifier)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Empty(sink.alerts)
+	suite.NoError(notifier.Flush(context.Background()))
+	suite.Require().Len(sink.digests, 1)
+	suite.Equal("input-entry-id", sink.digests[0][0].Order.Id)
+}
+
+type notificationSinkStub struct {
+	alerts  []OrderNotification
+	digests [][]OrderNotification
+}
+
+func (s *notificationSinkStub) SendAlert(ctx context.Context, notification OrderNotification) error {
+	s.alerts = append(s.alerts, notification)
+	return nil
+}
+
+func (s *notificationSinkStub) SendDigest(ctx context.Context, notifications []OrderNotification) error {
+	s.digests = append(s.digests, notifications)
+	return nil
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceRejectsOpenDuplicate() {
+	// ARRANGE
+	json := `{
+        "medicineId": "test-medicine-id",
+		"count": 5
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusConflict, recorder.Code)
+	suite.Contains(recorder.Body.String(), `"existingEntryId":"test-entry"`)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "PushElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceMergesOpenDuplicate() {
+	// ARRANGE
+	suite.dbAmbulanceServiceMock.
+		On("FindDocument", mock.Anything, mock.Anything).
+		Unset().
+		On("FindDocument", mock.Anything, mock.Anything).
+		Return(
+			&Ambulance{
+				Id:                   "test-ambulance",
+				DuplicateOrderPolicy: MERGE,
+				MedicineOrders: []MedicineOrderEntry{
+					{
+						Id:         "test-entry",
+						MedicineId: "test-medicine-id",
+						Count:      15,
+						Status: Status{
+							Id:               1,
+							Value:            "To_ship",
+							ValidTransitions: []int32{2, 4},
+						},
+						Priority: ROUTINE,
+					},
+				},
+			},
+			nil,
+		)
+
+	json := `{
+        "medicineId": "test-medicine-id",
+		"count": 5
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
+	suite.dbAmbulanceServiceMock.AssertCalled(suite.T(), "UpdateElements", mock.Anything, "test-ambulance", []db_service.ElementChange{{
+		ArrayField: "medicineorders",
+		ElementId:  "test-entry",
+		FieldChange: db_service.FieldChange{
+			Expect:    db_service.Eq("status.id", int32(1)),
+			Increment: map[string]int64{"count": 5},
+		},
+	}})
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "PushElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceDoesNotMergeIntoShippedOrder() {
+	// ARRANGE
+	suite.dbAmbulanceServiceMock.
+		On("FindDocument", mock.Anything, mock.Anything).
+		Unset().
+		On("FindDocument", mock.Anything, mock.Anything).
+		Return(
+			&Ambulance{
+				Id:                   "test-ambulance",
+				DuplicateOrderPolicy: MERGE,
+				MedicineOrders: []MedicineOrderEntry{
+					{
+						Id:         "test-entry",
+						MedicineId: "test-medicine-id",
+						Count:      15,
+						Status: Status{
+							Id:               2,
+							Value:            "Shipped",
+							ValidTransitions: []int32{3, 4},
+						},
+						Priority: ROUTINE,
+					},
+				},
+			},
+			nil,
+		)
+
+	json := `{
+        "medicineId": "test-medicine-id",
+		"count": 5
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"PushElement",
+		mock.Anything,
+		"test-ambulance",
+		"medicineorders",
+		mock.Anything,
+		mock.MatchedBy(func(arg MedicineOrderEntry) bool {
+			return arg.Id != "test-entry" && arg.Count == 5 && arg.Status.Id == 1
+		}),
+	)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceForceCreatesDuplicate() {
+	// ARRANGE
+	json := `{
+        "medicineId": "test-medicine-id",
+		"count": 5
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries?force=true", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"PushElement",
+		mock.Anything,
+		"test-ambulance",
+		"medicineorders",
+		mock.Anything,
+		mock.MatchedBy(func(arg MedicineOrderEntry) bool {
+			return arg.Id != "test-entry" && arg.MedicineId == "test-medicine-id" && arg.Count == 5
+		}),
+	)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceAllowsReorderOfDeliveredMedicine() {
+	// ARRANGE
+	suite.dbAmbulanceServiceMock.
+		On("FindDocument", mock.Anything, mock.Anything).
+		Unset().
+		On("FindDocument", mock.Anything, mock.Anything).
+		Return(
+			&Ambulance{
+				Id: "test-ambulance",
+				MedicineOrders: []MedicineOrderEntry{
+					{
+						Id:         "test-entry",
+						MedicineId: "test-medicine-id",
+						Count:      15,
+						Status: Status{
+							Id:               3,
+							Value:            "Delivered",
+							ValidTransitions: []int32{},
+						},
+					},
+				},
+			},
+			nil,
+		)
+
+	json := `{
+        "medicineId": "test-medicine-id",
+		"count": 5
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"PushElement",
+		mock.Anything,
+		"test-ambulance",
+		"medicineorders",
+		mock.Anything,
+		mock.MatchedBy(func(arg MedicineOrderEntry) bool {
+			return arg.Id != "test-entry" && arg.MedicineId == "test-medicine-id" && arg.Count == 5
+		}),
+	)
+}
+
+func (suite *MedicineOrderSuite) Test_CreateOrder_DbServiceRejectsExistingId() {
+	// ARRANGE
+	json := `{
+        "id": "test-entry",
+        "medicineId": "other-medicine-id",
+		"count": 5
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/entries?force=true", strings.NewReader(json))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.CreateMedicineOrderEntry(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusConflict, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "PushElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *MedicineOrderSuite) Test_BatchOrder_DbServiceBestEffort() {
+	// ARRANGE
+	body := `{
+		"mode": "best-effort",
+		"operations": [
+			{ "op": "create", "entry": { "id": "new-entry", "medicineId": "new-medicine-id", "count": 3 } },
+			{ "op": "update", "entryId": "test-entry", "entry": { "status": { "id": 3 } } },
+			{ "op": "update", "entryId": "test-entry", "entry": { "status": { "id": 2 } } }
+		]
+	}`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/batch", strings.NewReader(body))
+
+	sut := implMedicineOrderAPI{}
+
+	// ACT
+	sut.BatchMedicineOrderEntries(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj BatchResult
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.True(respObj.Applied)
+	suite.Equal(int32(http.StatusOK), respObj.Results[0].Status)
+	suite.Equal(int32(http.StatusBadRequest), respObj.Results[1].Status)
+	suite.Equal(int32(http.StatusOK), respObj.Results[2].Status)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"UpdateElements",
+		mock.Anything,
+		"test-ambulance",
+		mock.MatchedBy(func(changes []db_service.ElementChange) bool {
+			if len(changes) != 2 {
+				return false
+			}
+			status, ok := changes[0].Set["status"].(Status)
+			return changes[0].ElementId == "test-entry" &&
+				reflect.DeepEqual(changes[0].Expect, db_service.Eq("status.id", int32(1))) &&
+				ok && status.Id == 2 &&
+				changes[1].ElementId == "new-entry" && changes[1].Push != nil
+		}),
 	)
 }
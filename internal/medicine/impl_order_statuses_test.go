@@ -27,6 +27,11 @@ func (suite *OrderStatusesSuite) SetupTest() {
 	// Compile time Assert that the mock is of type db_service.DbService[Status]
 	var _ db_service.DbService[Status] = suite.dbServiceMock
 
+	// no status is marked as initial, new orders start in the status 1
+	suite.dbServiceMock.
+		On("FindDocuments", mock.Anything, db_service.Query{Filter: db_service.Eq("initial", true), Limit: 1}).
+		Return([]*Status{}, nil)
+
 	suite.dbServiceMock.
 		On("FindAllDocuments", mock.Anything, mock.Anything).
 		Return(
@@ -91,6 +96,34 @@ func (suite *OrderStatusesSuite) Test_GetInitialStatus_DbService() {
 	})
 }
 
+func (suite *OrderStatusesSuite) Test_GetInitialStatus_MarkedStatus() {
+	// ARRANGE
+	suite.dbServiceMock.
+		On("FindDocuments", mock.Anything, mock.Anything).
+		Unset().
+		On("FindDocuments", mock.Anything, db_service.Query{Filter: db_service.Eq("initial", true), Limit: 1}).
+		Return([]*Status{{Id: 5, Value: "Awaiting_approval", ValidTransitions: []int32{1, 4}, ApprovalStage: true, Initial: true}}, nil)
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_status", suite.dbServiceMock)
+	ctx.Request = httptest.NewRequest("GET", "/medicine-order/initial-status", nil)
+
+	sut := implOrderStatusesApi{}
+
+	// ACT
+	sut.GetInitialStatus(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj Status
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.Equal(Status{Id: 5, Value: "Awaiting_approval", ValidTransitions: []int32{1, 4}, ApprovalStage: true, Initial: true}, respObj)
+	suite.dbServiceMock.AssertNotCalled(suite.T(), "FindDocument", mock.Anything, mock.Anything)
+}
+
 func (suite *OrderStatusesSuite) Test_GetStatus_DbService() {
 	// ARRANGE
 	gin.SetMode(gin.TestMode)
@@ -0,0 +1,281 @@
+package medicine
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type implOrderTemplatesAPI struct {
+}
+
+func NewOrderTemplatesAPI() OrderTemplatesAPI {
+	return &implOrderTemplatesAPI{}
+}
+
+func (o implOrderTemplatesAPI) CreateOrderTemplate(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var template OrderTemplate
+
+		if err := c.ShouldBindJSON(&template); err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
+
+		if template.Id == "" || template.Id == "@new" {
+			template.Id = uuid.NewString()
+		}
+
+		if err := validateOrderTemplate(template); err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid order template",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
+
+		if slices.ContainsFunc(ambulance.OrderTemplates, func(existing OrderTemplate) bool {
+			return template.Id == existing.Id
+		}) {
+			return nil, gin.H{
+				"status":  http.StatusConflict,
+				"message": "Template already exists",
+			}, http.StatusConflict
+		}
+
+		return pushElement(templatesField, template.Id, template), template, http.StatusOK
+	})
+}
+
+func (o implOrderTemplatesAPI) DeleteOrderTemplate(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		templateIndx, responseObject, status := findOrderTemplate(c, ambulance)
+		if templateIndx < 0 {
+			return nil, responseObject, status
+		}
+
+		return pullElement(templatesField, ambulance.OrderTemplates[templateIndx].Id), nil, http.StatusNoContent
+	})
+}
+
+func (o implOrderTemplatesAPI) GetOrderTemplate(c *gin.Context) {
+	readAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (interface{}, int) {
+		templateIndx, responseObject, status := findOrderTemplate(c, ambulance)
+		if templateIndx < 0 {
+			return responseObject, status
+		}
+
+		return ambulance.OrderTemplates[templateIndx], http.StatusOK
+	})
+}
+
+func (o implOrderTemplatesAPI) GetOrderTemplates(c *gin.Context) {
+	readAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (interface{}, int) {
+		result := ambulance.OrderTemplates
+		if result == nil {
+			result = []OrderTemplate{}
+		}
+		return result, http.StatusOK
+	})
+}
+
+func (o implOrderTemplatesAPI) InstantiateOrderTemplate(c *gin.Context) {
+	var stored []MedicineOrderEntry
+	var merged []bool
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		templateIndx, responseObject, status := findOrderTemplate(c, ambulance)
+		if templateIndx < 0 {
+			return nil, responseObject, status
+		}
+		template := ambulance.OrderTemplates[templateIndx]
+
+		var instantiation OrderTemplateInstantiation
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&instantiation); err != nil {
+				return nil, gin.H{
+					"status":  http.StatusBadRequest,
+					"message": "Invalid request body",
+					"error":   err.Error(),
+				}, http.StatusBadRequest
+			}
+		}
+
+		force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+		if err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid value of force parameter",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
+
+		overrides := map[string]OrderTemplateOverride{}
+		for _, override := range instantiation.Overrides {
+			if !slices.ContainsFunc(template.Entries, func(entry OrderTemplateEntry) bool {
+				return entry.MedicineId == override.MedicineId
+			}) {
+				return nil, gin.H{
+					"status":     http.StatusBadRequest,
+					"message":    "Override refers to medicine which is not in the template",
+					"medicineId": override.MedicineId,
+				}, http.StatusBadRequest
+			}
+			if override.Count < 0 || !override.Priority.isValid() {
+				return nil, gin.H{
+					"status":     http.StatusBadRequest,
+					"message":    "Invalid override of the template entry",
+					"medicineId": override.MedicineId,
+				}, http.StatusBadRequest
+			}
+			overrides[override.MedicineId] = override
+		}
+
+		// orders are added one by one to the loaded ambulance and written together in one update,
+		// if any of them fails none of the orders is created
+		before := slices.Clone(ambulance.MedicineOrders)
+		var storedIndexes []int
+		for _, templateEntry := range template.Entries {
+			entry := MedicineOrderEntry{
+				Name:       templateEntry.Name,
+				MedicineId: templateEntry.MedicineId,
+				Count:      templateEntry.Count,
+				Priority:   templateEntry.Priority,
+			}
+			if override, ok := overrides[templateEntry.MedicineId]; ok {
+				if override.Skip {
+					continue
+				}
+				if override.Count > 0 {
+					entry.Count = override.Count
+				}
+				if override.Priority != "" {
+					entry.Priority = override.Priority
+				}
+			}
+
+			entryIndx, isMerged, responseObject, status := addOrderEntry(c, ambulance, entry, force)
+			if entryIndx < 0 {
+				if responseObject == nil {
+					return nil, nil, status
+				}
+				return nil, gin.H{
+					"status":     status,
+					"message":    "Failed to create order from the template, no order was created",
+					"medicineId": templateEntry.MedicineId,
+					"error":      responseObject,
+				}, status
+			}
+			storedIndexes = append(storedIndexes, entryIndx)
+			merged = append(merged, isMerged)
+		}
+
+		stored = make([]MedicineOrderEntry, len(storedIndexes))
+		for i, entryIndx := range storedIndexes {
+			stored[i] = ambulance.MedicineOrders[entryIndx]
+		}
+		return elementsPatch(orderChanges(before, ambulance.MedicineOrders)), stored, http.StatusOK
+	})
+	if c.Writer.Status() < http.StatusMultipleChoices {
+		for i, entry := range stored {
+			if merged[i] {
+				notifyOrder(c, OrderMerged, entry)
+			} else {
+				notifyOrder(c, OrderCreated, entry)
+			}
+		}
+	}
+}
+
+func (o implOrderTemplatesAPI) UpdateOrderTemplate(c *gin.Context) {
+	patchAmbulanceFunc(c, func(c *gin.Context, ambulance *Ambulance) (ambulancePatch, interface{}, int) {
+		var template OrderTemplate
+
+		if err := c.ShouldBindJSON(&template); err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
+
+		templateIndx, responseObject, status := findOrderTemplate(c, ambulance)
+		if templateIndx < 0 {
+			return nil, responseObject, status
+		}
+
+		if template.Id == "" {
+			template.Id = ambulance.OrderTemplates[templateIndx].Id
+		} else if template.Id != ambulance.OrderTemplates[templateIndx].Id {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Cannot update Id of existing template",
+			}, http.StatusBadRequest
+		}
+
+		if err := validateOrderTemplate(template); err != nil {
+			return nil, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Invalid order template",
+				"error":   err.Error(),
+			}, http.StatusBadRequest
+		}
+
+		fields := map[string]any{"name": template.Name, "entries": template.Entries}
+		return setElementFields(templatesField, template.Id, fields), template, http.StatusOK
+	})
+}
+
+// findOrderTemplate looks up the template addressed by the templateId parameter.
+// Returns its index, or -1 together with the error response and its status code.
+func findOrderTemplate(c *gin.Context, ambulance *Ambulance) (int, interface{}, int) {
+	templateId := c.Param("templateId")
+
+	if templateId == "" {
+		return -1, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Template ID is required",
+		}, http.StatusBadRequest
+	}
+
+	templateIndx := slices.IndexFunc(ambulance.OrderTemplates, func(template OrderTemplate) bool {
+		return templateId == template.Id
+	})
+
+	if templateIndx < 0 {
+		return -1, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Template not found",
+		}, http.StatusNotFound
+	}
+	return templateIndx, nil, http.StatusOK
+}
+
+func validateOrderTemplate(template OrderTemplate) error {
+	if template.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	seen := map[string]bool{}
+	for _, entry := range template.Entries {
+		if entry.MedicineId == "" {
+			return fmt.Errorf("medicineId is required for every template entry")
+		}
+		if seen[entry.MedicineId] {
+			return fmt.Errorf("medicine %v is listed more than once", entry.MedicineId)
+		}
+		seen[entry.MedicineId] = true
+		if entry.Count <= 0 {
+			return fmt.Errorf("count of medicine %v must be positive", entry.MedicineId)
+		}
+		if !entry.Priority.isValid() {
+			return fmt.Errorf("unknown priority %v of medicine %v", entry.Priority, entry.MedicineId)
+		}
+	}
+	return nil
+}
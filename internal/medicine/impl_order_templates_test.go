@@ -0,0 +1,423 @@
+package medicine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type OrderTemplatesSuite struct {
+	suite.Suite
+	dbAmbulanceServiceMock *DbServiceMock[Ambulance]
+	dbStatusServiceMock    *DbServiceMock[Status]
+}
+
+func TestOrderTemplatesSuite(t *testing.T) {
+	suite.Run(t, new(OrderTemplatesSuite))
+}
+
+func (suite *OrderTemplatesSuite) SetupTest() {
+	suite.dbAmbulanceServiceMock = &DbServiceMock[Ambulance]{}
+	suite.dbStatusServiceMock = &DbServiceMock[Status]{}
+
+	// Compile time Assert that the mock is of type db_service.DbService[Ambulance]
+	var _ db_service.DbService[Ambulance] = suite.dbAmbulanceServiceMock
+	var _ db_service.DbService[Status] = suite.dbStatusServiceMock
+
+	// no status is marked as initial, new orders start in the status 1
+	suite.dbStatusServiceMock.
+		On("FindDocuments", mock.Anything, db_service.Query{Filter: db_service.Eq("initial", true), Limit: 1}).
+		Return([]*Status{}, nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("FindDocument", mock.Anything, mock.Anything).
+		Return(
+			&Ambulance{
+				Id: "test-ambulance",
+				MedicineOrders: []MedicineOrderEntry{
+					{
+						Id:         "test-entry",
+						MedicineId: "open-medicine-id",
+						Count:      15,
+						Status: Status{
+							Id:               1,
+							Value:            "To_ship",
+							ValidTransitions: []int32{2, 4},
+						},
+						Priority: ROUTINE,
+					},
+				},
+				OrderTemplates: []OrderTemplate{
+					{
+						Id:   "test-template",
+						Name: "Weekly basics",
+						Entries: []OrderTemplateEntry{
+							{MedicineId: "paralen-id", Name: "Paralen", Count: 10},
+							{MedicineId: "mig-id", Name: "Mig 400", Count: 5, Priority: URGENT},
+						},
+					},
+					{
+						Id:   "conflicting-template",
+						Name: "Conflicting",
+						Entries: []OrderTemplateEntry{
+							{MedicineId: "paralen-id", Name: "Paralen", Count: 10},
+							{MedicineId: "open-medicine-id", Count: 5},
+						},
+					},
+				},
+			},
+			nil,
+		)
+
+	suite.dbStatusServiceMock.
+		On("FindDocument", mock.Anything, 1).
+		Return(
+			&Status{
+				Id:               1,
+				Value:            "To_ship",
+				ValidTransitions: []int32{2, 4},
+			},
+			nil,
+		)
+
+	suite.dbAmbulanceServiceMock.
+		On("UpdateDocument", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("PushElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("PullElement", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("SetElementFields", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.dbAmbulanceServiceMock.
+		On("UpdateElements", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+}
+
+func (suite *OrderTemplatesSuite) Test_CreateTemplate_DbService() {
+	// ARRANGE
+	json := `{
+        "name": "Night shift",
+        "entries": [
+            { "medicineId": "paralen-id", "count": 3 }
+        ]
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/templates", strings.NewReader(json))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.CreateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"PushElement",
+		mock.Anything,
+		"test-ambulance",
+		"ordertemplates",
+		mock.Anything,
+		mock.MatchedBy(func(arg OrderTemplate) bool {
+			return arg.Name == "Night shift" && arg.Id != ""
+		}),
+	)
+}
+
+func (suite *OrderTemplatesSuite) Test_CreateTemplate_DbServiceRejectsDuplicateMedicine() {
+	// ARRANGE
+	json := `{
+        "name": "Night shift",
+        "entries": [
+            { "medicineId": "paralen-id", "count": 3 },
+            { "medicineId": "paralen-id", "count": 4 }
+        ]
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/templates", strings.NewReader(json))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.CreateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OrderTemplatesSuite) Test_DeleteTemplate_DbService() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "test-template"},
+	}
+	ctx.Request = httptest.NewRequest("DELETE", "/medicine-order/test-ambulance/templates/test-template", nil)
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.DeleteOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusNoContent, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertCalled(suite.T(), "PullElement", mock.Anything, "test-ambulance", "ordertemplates", "test-template")
+}
+
+func (suite *OrderTemplatesSuite) Test_UpdateTemplate_DbServiceSetsFields() {
+	// ARRANGE
+	body := `{
+        "name": "Weekly extras",
+        "entries": [
+            { "medicineId": "paralen-id", "name": "Paralen", "count": 12 }
+        ]
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "test-template"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/medicine-order/test-ambulance/templates/test-template", strings.NewReader(body))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.UpdateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertCalled(suite.T(), "SetElementFields", mock.Anything, "test-ambulance", "ordertemplates", "test-template",
+		map[string]any{
+			"name":    "Weekly extras",
+			"entries": []OrderTemplateEntry{{MedicineId: "paralen-id", Name: "Paralen", Count: 12}},
+		})
+}
+
+func (suite *OrderTemplatesSuite) Test_UpdateTemplate_KeepsConcurrentInventoryChange() {
+	// ARRANGE
+	body := `{ "name": "Weekly extras", "entries": [ { "medicineId": "paralen-id", "count": 12 } ] }`
+	db := db_service.NewMemoryService[Ambulance]()
+	suite.Require().NoError(db.CreateDocument(context.Background(), "test-ambulance", &Ambulance{
+		Id:                "test-ambulance",
+		MedicineInventory: []MedicineInventoryEntry{{Id: "test-entry", MedicineId: "paralen-id", Count: 15}},
+		OrderTemplates: []OrderTemplate{
+			{Id: "test-template", Name: "Weekly basics", Entries: []OrderTemplateEntry{{MedicineId: "paralen-id", Count: 10}}},
+		},
+	}))
+	racing := &racingDbService{DbService: db, afterFind: func(ctx context.Context) error {
+		// the inventory is updated after the template edit loaded the ambulance
+		return db.SetElementFields(ctx, "test-ambulance", inventoryField, "test-entry", map[string]any{"count": int32(30)})
+	}}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", db_service.DbService[Ambulance](racing))
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "test-template"},
+	}
+	ctx.Request = httptest.NewRequest("PUT", "/medicine-order/test-ambulance/templates/test-template", strings.NewReader(body))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.UpdateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	stored, err := db.FindDocument(context.Background(), "test-ambulance")
+	suite.Require().NoError(err)
+	suite.Equal([]MedicineInventoryEntry{{Id: "test-entry", MedicineId: "paralen-id", Count: 30}}, stored.MedicineInventory,
+		"the template edit must keep the inventory changed meanwhile")
+	suite.Equal([]OrderTemplate{
+		{Id: "test-template", Name: "Weekly extras", Entries: []OrderTemplateEntry{{MedicineId: "paralen-id", Count: 12}}},
+	}, stored.OrderTemplates)
+}
+
+func (suite *OrderTemplatesSuite) Test_InstantiateTemplate_DbServiceWithOverrides() {
+	// ARRANGE
+	body := `{
+        "overrides": [
+            { "medicineId": "paralen-id", "count": 20, "priority": "emergency" }
+        ]
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "test-template"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/templates/test-template/instantiate", strings.NewReader(body))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.InstantiateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	var respObj []MedicineOrderEntry
+	err := json.Unmarshal(recorder.Body.Bytes(), &respObj)
+	suite.Require().NoError(err)
+	suite.Require().Len(respObj, 2)
+	suite.Equal("paralen-id", respObj[0].MedicineId)
+	suite.Equal(int32(20), respObj[0].Count)
+	suite.Equal(EMERGENCY, respObj[0].Priority)
+	suite.Equal(int32(1), respObj[0].Status.Id)
+	suite.Equal("mig-id", respObj[1].MedicineId)
+	suite.Equal(int32(5), respObj[1].Count)
+	suite.Equal(URGENT, respObj[1].Priority)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertNumberOfCalls(suite.T(), "UpdateElements", 1)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"UpdateElements",
+		mock.Anything,
+		"test-ambulance",
+		mock.MatchedBy(func(changes []db_service.ElementChange) bool {
+			return len(changes) == 2 && changes[0].Push != nil && changes[1].Push != nil
+		}),
+	)
+}
+
+func (suite *OrderTemplatesSuite) Test_InstantiateTemplate_DbServiceSkipsEntry() {
+	// ARRANGE
+	body := `{
+        "overrides": [
+            { "medicineId": "mig-id", "skip": true }
+        ]
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "test-template"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/templates/test-template/instantiate", strings.NewReader(body))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.InstantiateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertCalled(
+		suite.T(),
+		"UpdateElements",
+		mock.Anything,
+		"test-ambulance",
+		mock.MatchedBy(func(changes []db_service.ElementChange) bool {
+			if len(changes) != 1 {
+				return false
+			}
+			pushed, ok := changes[0].Push.(MedicineOrderEntry)
+			return ok && pushed.MedicineId == "paralen-id"
+		}),
+	)
+}
+
+func (suite *OrderTemplatesSuite) Test_InstantiateTemplate_DbServiceIsAllOrNothing() {
+	// ARRANGE
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "conflicting-template"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/templates/conflicting-template/instantiate", nil)
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.InstantiateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusConflict, recorder.Code)
+	suite.Contains(recorder.Body.String(), `"medicineId":"open-medicine-id"`)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OrderTemplatesSuite) Test_InstantiateTemplate_DbServiceRejectsUnknownOverride() {
+	// ARRANGE
+	body := `{
+        "overrides": [
+            { "medicineId": "unknown-id", "count": 2 }
+        ]
+    }`
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Set("db_service_ambulance", suite.dbAmbulanceServiceMock)
+	ctx.Set("db_service_status", suite.dbStatusServiceMock)
+	ctx.Params = []gin.Param{
+		{Key: "ambulanceId", Value: "test-ambulance"},
+		{Key: "templateId", Value: "test-template"},
+	}
+	ctx.Request = httptest.NewRequest("POST", "/medicine-order/test-ambulance/templates/test-template/instantiate", strings.NewReader(body))
+
+	sut := implOrderTemplatesAPI{}
+
+	// ACT
+	sut.InstantiateOrderTemplate(ctx)
+
+	// ASSERT
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateDocument", mock.Anything, mock.Anything, mock.Anything)
+	suite.dbAmbulanceServiceMock.AssertNotCalled(suite.T(), "UpdateElements", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -2,6 +2,7 @@ package medicine
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/undy45/medicine-webapi/internal/db_service"
 )
 
 type implUtilsOrderStatuses struct {
@@ -11,10 +12,23 @@ func UtilsOrderStatuses() OrderStatusHandler {
 	return &implUtilsOrderStatuses{}
 }
 
+// defaultInitialStatusId is the status new orders start in, unless there is a status marked as initial
+const defaultInitialStatusId = 1
+
 func (o implUtilsOrderStatuses) GetInitialStatus(c *gin.Context) *Status {
-	statusId := 1
 	db := HandleConnectionToCollection[Status](c, "db_service_status")
-	responseObject, err := db.FindDocument(c, statusId)
+	if db == nil {
+		return nil
+	}
+	marked, err := db.FindDocuments(c, db_service.Query{Filter: db_service.Eq("initial", true), Limit: 1})
+	if err != nil {
+		HandleRetrievalError(c, err)
+		return nil
+	}
+	if len(marked) > 0 {
+		return marked[0]
+	}
+	responseObject, err := db.FindDocument(c, defaultInitialStatusId)
 	if err != nil {
 		HandleRetrievalError(c, err)
 		return nil
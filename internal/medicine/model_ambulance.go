@@ -23,4 +23,8 @@ type Ambulance struct {
 	MedicineInventory []MedicineInventoryEntry `json:"medicineInventory,omitempty"`
 
 	MedicineOrders []MedicineOrderEntry `json:"medicineOrders,omitempty"`
+
+	DuplicateOrderPolicy DuplicateOrderPolicy `json:"duplicateOrderPolicy,omitempty"`
+
+	OrderTemplates []OrderTemplate `json:"orderTemplates,omitempty"`
 }
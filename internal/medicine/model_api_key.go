@@ -0,0 +1,37 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+import (
+	"time"
+)
+
+type ApiKey struct {
+
+	// Unique identifier of the key
+	Id string `json:"id"`
+
+	Name string `json:"name"`
+
+	Ambulances []string `json:"ambulances"`
+
+	Permissions []string `json:"permissions"`
+
+	// Subject of the administrator who issued the key
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// Last use of the key with the precision of a minute, missing if the key was not used
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
@@ -0,0 +1,30 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+import (
+	"time"
+)
+
+type ApiKeyRequest struct {
+
+	// Human readable name of the integration using the key
+	Name string `json:"name"`
+
+	// Ids of the ambulances the key may access, `*` is every ambulance
+	Ambulances []string `json:"ambulances"`
+
+	// Operations the key may call
+	Permissions []string `json:"permissions"`
+
+	// Expiry of the key, the key expires in 90 days if not given
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
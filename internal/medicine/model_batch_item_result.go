@@ -0,0 +1,26 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type BatchItemResult struct {
+
+	// Position of the operation in the request
+	Index int32 `json:"index"`
+
+	// Id of the affected entry
+	EntryId string `json:"entryId,omitempty"`
+
+	// HTTP status code the operation would get as a single request. Operations not processed because of an earlier failure in atomic mode have status 424.
+	Status int32 `json:"status"`
+
+	// Reason of the failure
+	Message string `json:"message,omitempty"`
+}
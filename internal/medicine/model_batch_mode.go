@@ -0,0 +1,20 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+// BatchMode : `atomic` applies all operations or none of them, `best-effort` applies every operation which succeeds. Requests without mode are atomic.
+type BatchMode string
+
+// List of BatchMode
+const (
+	ATOMIC      BatchMode = "atomic"
+	BEST_EFFORT BatchMode = "best-effort"
+)
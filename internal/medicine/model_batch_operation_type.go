@@ -0,0 +1,21 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+// BatchOperationType : Kind of the batch operation
+type BatchOperationType string
+
+// List of BatchOperationType
+const (
+	CREATE BatchOperationType = "create"
+	UPDATE BatchOperationType = "update"
+	DELETE BatchOperationType = "delete"
+)
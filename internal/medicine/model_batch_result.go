@@ -0,0 +1,19 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type BatchResult struct {
+
+	// True if the successful operations were stored
+	Applied bool `json:"applied"`
+
+	Results []BatchItemResult `json:"results"`
+}
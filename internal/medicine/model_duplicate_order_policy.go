@@ -0,0 +1,20 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+// DuplicateOrderPolicy : How to handle a new order for a medicine which already has an open order in the ambulance. `reject` refuses the new order, `merge` adds its count to the open order unless the open order was already processed further, e.g. shipped, in which case new order is created. Ambulances without policy reject duplicate orders.
+type DuplicateOrderPolicy string
+
+// List of DuplicateOrderPolicy
+const (
+	REJECT DuplicateOrderPolicy = "reject"
+	MERGE  DuplicateOrderPolicy = "merge"
+)
@@ -0,0 +1,25 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type InventoryImportChange struct {
+	MedicineId string `json:"medicineId"`
+
+	Name string `json:"name,omitempty"`
+
+	Change string `json:"change"`
+
+	// Count before the import, 0 for added entries
+	PreviousCount int32 `json:"previousCount"`
+
+	// Count after the import, 0 for removed entries
+	Count int32 `json:"count"`
+}
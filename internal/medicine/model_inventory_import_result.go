@@ -0,0 +1,26 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type InventoryImportResult struct {
+
+	// Mode the import was processed in
+	Mode string `json:"mode"`
+
+	DryRun bool `json:"dryRun"`
+
+	// True if the changes were stored
+	Applied bool `json:"applied"`
+
+	Changes []InventoryImportChange `json:"changes"`
+
+	Errors []InventoryImportRowError `json:"errors"`
+}
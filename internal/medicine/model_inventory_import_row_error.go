@@ -0,0 +1,21 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type InventoryImportRowError struct {
+
+	// Line number in the CSV, the header is line 1
+	Row int32 `json:"row"`
+
+	MedicineId string `json:"medicineId,omitempty"`
+
+	Message string `json:"message"`
+}
@@ -0,0 +1,20 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type MedicineInventoryBatchOperation struct {
+	Op BatchOperationType `json:"op"`
+
+	// Id of the entry to update or delete
+	EntryId string `json:"entryId,omitempty"`
+
+	Entry MedicineInventoryEntry `json:"entry,omitempty"`
+}
@@ -0,0 +1,17 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type MedicineInventoryBatchRequest struct {
+	Mode BatchMode `json:"mode,omitempty"`
+
+	Operations []MedicineInventoryBatchOperation `json:"operations"`
+}
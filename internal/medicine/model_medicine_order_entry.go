@@ -25,4 +25,6 @@ type MedicineOrderEntry struct {
 	Count int32 `json:"count"`
 
 	Status Status `json:"status"`
+
+	Priority OrderPriority `json:"priority,omitempty"`
 }
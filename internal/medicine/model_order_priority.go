@@ -0,0 +1,21 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+// OrderPriority : Priority of the order. Urgent and emergency orders skip approval stages of the status workflow, emergency orders raise an immediate alert. Orders without priority are handled as routine.
+type OrderPriority string
+
+// List of OrderPriority
+const (
+	ROUTINE   OrderPriority = "routine"
+	URGENT    OrderPriority = "urgent"
+	EMERGENCY OrderPriority = "emergency"
+)
@@ -0,0 +1,22 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type OrderTemplate struct {
+
+	// Unique id of the template in this ambulance
+	Id string `json:"id"`
+
+	// Human readable name of the template
+	Name string `json:"name"`
+
+	Entries []OrderTemplateEntry `json:"entries"`
+}
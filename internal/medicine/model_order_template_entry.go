@@ -0,0 +1,25 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type OrderTemplateEntry struct {
+
+	// Unique identifier of the medicine known to Web-In-Cloud system
+	MedicineId string `json:"medicineId"`
+
+	// Name of the medicine
+	Name string `json:"name,omitempty"`
+
+	// Default number of packages to order
+	Count int32 `json:"count"`
+
+	Priority OrderPriority `json:"priority,omitempty"`
+}
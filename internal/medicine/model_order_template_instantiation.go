@@ -0,0 +1,15 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type OrderTemplateInstantiation struct {
+	Overrides []OrderTemplateOverride `json:"overrides,omitempty"`
+}
@@ -0,0 +1,25 @@
+/*
+ * Medicine Inventory API
+ *
+ * Medicine inventory management for Web-In-Cloud system
+ *
+ * API version: 1.0.0
+ * Contact: your_email@stuba.sk
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package medicine
+
+type OrderTemplateOverride struct {
+
+	// Medicine of the template entry to override
+	MedicineId string `json:"medicineId"`
+
+	// Number of packages to order instead of the template default
+	Count int32 `json:"count,omitempty"`
+
+	Priority OrderPriority `json:"priority,omitempty"`
+
+	// Do not create order for this template entry
+	Skip bool `json:"skip,omitempty"`
+}
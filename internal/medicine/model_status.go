@@ -19,4 +19,10 @@ type Status struct {
 	Value string `json:"value"`
 
 	ValidTransitions []int32 `json:"validTransitions,omitempty"`
+
+	// Marks the status as an approval stage. Urgent and emergency orders do not stop in approval stages, they continue to the first of its valid transitions.
+	ApprovalStage bool `json:"approvalStage,omitempty"`
+
+	// Marks the status new orders start in. Without such status new orders start in the status with id 1.
+	Initial bool `json:"initial,omitempty"`
 }
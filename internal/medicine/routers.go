@@ -65,12 +65,18 @@ type ApiHandleFunctions struct {
 
 	// Routes for the AmbulancesAPI part of the API
 	AmbulancesAPI AmbulancesAPI
+	// Routes for the ApiKeysAPI part of the API
+	ApiKeysAPI ApiKeysAPI
+	// Routes for the ExportsAPI part of the API
+	ExportsAPI ExportsAPI
 	// Routes for the MedicineInventoryAPI part of the API
 	MedicineInventoryAPI MedicineInventoryAPI
 	// Routes for the MedicineOrderAPI part of the API
 	MedicineOrderAPI MedicineOrderAPI
 	// Routes for the OrderStatusesAPI part of the API
 	OrderStatusesAPI OrderStatusesAPI
+	// Routes for the OrderTemplatesAPI part of the API
+	OrderTemplatesAPI OrderTemplatesAPI
 }
 
 func getRoutes(handleFunctions ApiHandleFunctions) []Route {
@@ -87,6 +93,54 @@ func getRoutes(handleFunctions ApiHandleFunctions) []Route {
 			"/api/ambulance/:ambulanceId",
 			handleFunctions.AmbulancesAPI.DeleteAmbulance,
 		},
+		{
+			"GetAmbulances",
+			http.MethodGet,
+			"/api/ambulance",
+			handleFunctions.AmbulancesAPI.GetAmbulances,
+		},
+		{
+			"UpdateAmbulance",
+			http.MethodPut,
+			"/api/ambulance/:ambulanceId",
+			handleFunctions.AmbulancesAPI.UpdateAmbulance,
+		},
+		{
+			"GetApiKeys",
+			http.MethodGet,
+			"/api/api-keys",
+			handleFunctions.ApiKeysAPI.GetApiKeys,
+		},
+		{
+			"IssueApiKey",
+			http.MethodPost,
+			"/api/api-keys",
+			handleFunctions.ApiKeysAPI.IssueApiKey,
+		},
+		{
+			"RevokeApiKey",
+			http.MethodDelete,
+			"/api/api-keys/:keyId",
+			handleFunctions.ApiKeysAPI.RevokeApiKey,
+		},
+		{
+			"ExportMedicineInventory",
+			http.MethodGet,
+			"/api/export/medicine-inventory",
+			handleFunctions.ExportsAPI.ExportMedicineInventory,
+		},
+		{
+			"ExportMedicineOrders",
+			http.MethodGet,
+			"/api/export/medicine-orders",
+			handleFunctions.ExportsAPI.ExportMedicineOrders,
+		},
+		{
+			"BatchMedicineInventoryEntries",
+			http.MethodPost,
+			"/api/medicine-inventory/:ambulanceId/batch",
+			handleFunctions.MedicineInventoryAPI.BatchMedicineInventoryEntries,
+		},
 		{
 			"DeleteMedicineInventoryEntry",
 			http.MethodDelete,
@@ -105,12 +159,24 @@ func getRoutes(handleFunctions ApiHandleFunctions) []Route {
 			"/api/medicine-inventory/:ambulanceId/entries/:entryId",
 			handleFunctions.MedicineInventoryAPI.GetMedicineInventoryEntry,
 		},
+		{
+			"ImportMedicineInventory",
+			http.MethodPost,
+			"/api/medicine-inventory/:ambulanceId/import",
+			handleFunctions.MedicineInventoryAPI.ImportMedicineInventory,
+		},
 		{
 			"UpdateMedicineInventoryEntry",
 			http.MethodPut,
 			"/api/medicine-inventory/:ambulanceId/entries/:entryId",
 			handleFunctions.MedicineInventoryAPI.UpdateMedicineInventoryEntry,
 		},
+		{
+			"BatchMedicineOrderEntries",
+			http.MethodPost,
+			"/api/medicine-order/:ambulanceId/batch",
+			handleFunctions.MedicineOrderAPI.BatchMedicineOrderEntries,
+		},
 		{
 			"CreateMedicineOrderEntry",
 			http.MethodPost,
@@ -159,5 +225,41 @@ func getRoutes(handleFunctions ApiHandleFunctions) []Route {
 			"/api/medicine-order/statuses",
 			handleFunctions.OrderStatusesAPI.GetStatuses,
 		},
+		{
+			"CreateOrderTemplate",
+			http.MethodPost,
+			"/api/medicine-order/:ambulanceId/templates",
+			handleFunctions.OrderTemplatesAPI.CreateOrderTemplate,
+		},
+		{
+			"DeleteOrderTemplate",
+			http.MethodDelete,
+			"/api/medicine-order/:ambulanceId/templates/:templateId",
+			handleFunctions.OrderTemplatesAPI.DeleteOrderTemplate,
+		},
+		{
+			"GetOrderTemplate",
+			http.MethodGet,
+			"/api/medicine-order/:ambulanceId/templates/:templateId",
+			handleFunctions.OrderTemplatesAPI.GetOrderTemplate,
+		},
+		{
+			"GetOrderTemplates",
+			http.MethodGet,
+			"/api/medicine-order/:ambulanceId/templates",
+			handleFunctions.OrderTemplatesAPI.GetOrderTemplates,
+		},
+		{
+			"InstantiateOrderTemplate",
+			http.MethodPost,
+			"/api/medicine-order/:ambulanceId/templates/:templateId/instantiate",
+			handleFunctions.OrderTemplatesAPI.InstantiateOrderTemplate,
+		},
+		{
+			"UpdateOrderTemplate",
+			http.MethodPut,
+			"/api/medicine-order/:ambulanceId/templates/:templateId",
+			handleFunctions.OrderTemplatesAPI.UpdateOrderTemplate,
+		},
 	}
 }
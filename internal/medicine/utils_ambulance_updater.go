@@ -1,39 +1,80 @@
 package medicine
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/undy45/medicine-webapi/internal/db_service"
 )
 
-type ambulanceUpdater = func(
+// stored names of the ambulance array fields
+const (
+	inventoryField = "medicineinventory"
+	ordersField    = "medicineorders"
+	templatesField = "ordertemplates"
+)
+
+type ambulanceReader = func(
 	ctx *gin.Context,
 	ambulance *Ambulance,
-) (updatedAmbulance *Ambulance, responseContent interface{}, status int)
+) (responseContent interface{}, status int)
 
-func updateAmbulanceFunc(ctx *gin.Context, updater ambulanceUpdater) {
+// readAmbulanceFunc loads the ambulance for the reader, which responds from it without changing it
+func readAmbulanceFunc(ctx *gin.Context, reader ambulanceReader) {
+	db := HandleConnectionToCollection[Ambulance](ctx, "db_service_ambulance")
+	if db == nil {
+		return
+	}
+	ambulance, err := db.FindDocument(ctx, ctx.Param("ambulanceId"))
+	if err != nil {
+		HandleRetrievalError(ctx, err)
+		return
+	}
+
+	responseObject, status := reader(ctx, ambulance)
+	if responseObject != nil {
+		ctx.JSON(status, responseObject)
+	} else if !ctx.Writer.Written() {
+		// reader may have streamed the response on its own
+		ctx.AbortWithStatus(status)
+	}
+}
+
+// ambulancePatch applies a change of the ambulance directly in the database
+type ambulancePatch = func(ctx context.Context, db db_service.DbService[Ambulance], ambulanceId string) error
+
+type ambulancePatcher = func(
+	ctx *gin.Context,
+	ambulance *Ambulance,
+) (patch ambulancePatch, responseContent interface{}, status int)
+
+// patchAmbulanceFunc loads the ambulance for the patcher and applies the returned patch, which changes
+// only the affected fields and array elements instead of replacing the whole ambulance document.
+// The loaded ambulance serves for validation of the request and for the response.
+func patchAmbulanceFunc(ctx *gin.Context, patcher ambulancePatcher) {
 	ambulanceId := ctx.Param("ambulanceId")
 	db := HandleConnectionToCollection[Ambulance](ctx, "db_service_ambulance")
+	if db == nil {
+		return
+	}
 	ambulance, err := db.FindDocument(ctx, ambulanceId)
 	if err != nil {
 		HandleRetrievalError(ctx, err)
 		return
 	}
 
-	updatedAmbulance, responseObject, status := updater(ctx, ambulance)
+	patch, responseObject, status := patcher(ctx, ambulance)
 
-	if updatedAmbulance != nil {
-		err = db.UpdateDocument(ctx, ambulanceId, updatedAmbulance)
-	} else {
-		err = nil // redundant but for clarity
+	if patch != nil {
+		err = patch(ctx, db, ambulanceId)
 	}
 
 	switch err {
 	case nil:
 		if responseObject != nil {
 			ctx.JSON(status, responseObject)
-		} else {
+		} else if !ctx.Writer.Written() {
 			ctx.AbortWithStatus(status)
 		}
 	case db_service.ErrNotFound:
@@ -41,7 +82,16 @@ func updateAmbulanceFunc(ctx *gin.Context, updater ambulanceUpdater) {
 			http.StatusNotFound,
 			gin.H{
 				"status":  "Not Found",
-				"message": "Ambulance was deleted while processing the request",
+				"message": "Ambulance or entry was deleted while processing the request",
+				"error":   err.Error(),
+			},
+		)
+	case db_service.ErrConflict:
+		ctx.JSON(
+			http.StatusConflict,
+			gin.H{
+				"status":  "Conflict",
+				"message": "Entry was created or changed while processing the request",
 				"error":   err.Error(),
 			},
 		)
@@ -54,5 +104,45 @@ func updateAmbulanceFunc(ctx *gin.Context, updater ambulanceUpdater) {
 				"error":   err.Error(),
 			})
 	}
+}
+
+// elementsPatch applies all the element changes in one update, there is no patch without changes
+func elementsPatch(changes []db_service.ElementChange) ambulancePatch {
+	if len(changes) == 0 {
+		return nil
+	}
+	return updateElements(changes...)
+}
+
+// allOf matches the documents matching all the conditions
+func allOf(conditions []db_service.Filter) db_service.Filter {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return db_service.And(conditions...)
+}
+
+// updateElements applies all the element changes in one update
+func updateElements(changes ...db_service.ElementChange) ambulancePatch {
+	return func(ctx context.Context, db db_service.DbService[Ambulance], ambulanceId string) error {
+		return db.UpdateElements(ctx, ambulanceId, changes...)
+	}
+}
+
+func pushElement(arrayField string, elementId string, element any) ambulancePatch {
+	return func(ctx context.Context, db db_service.DbService[Ambulance], ambulanceId string) error {
+		return db.PushElement(ctx, ambulanceId, arrayField, elementId, element)
+	}
+}
 
+func pullElement(arrayField string, elementId string) ambulancePatch {
+	return func(ctx context.Context, db db_service.DbService[Ambulance], ambulanceId string) error {
+		return db.PullElement(ctx, ambulanceId, arrayField, elementId)
+	}
+}
+
+func setElementFields(arrayField string, elementId string, fields map[string]any) ambulancePatch {
+	return func(ctx context.Context, db db_service.DbService[Ambulance], ambulanceId string) error {
+		return db.SetElementFields(ctx, ambulanceId, arrayField, elementId, fields)
+	}
 }
@@ -0,0 +1,102 @@
+package medicine
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBatchOperations = 1000
+
+// batchOperation applies one operation of the batch to the loaded ambulance.
+// Returns id of the affected entry, the response of the operation and its status code.
+type batchOperation = func(ambulance *Ambulance) (entryId string, responseContent interface{}, status int)
+
+// applyBatch runs all operations against the same ambulance document, so the whole batch costs one
+// read and its changes are written together, not per operation. Failed operations leave the ambulance
+// as it was before them.
+// In atomic mode processing stops at the first failure and the ambulance is not returned for update.
+// If an operation already wrote an error response, the batch is abandoned and nil result returned.
+func applyBatch(c *gin.Context, ambulance *Ambulance, mode BatchMode, operations []batchOperation) (*Ambulance, interface{}, int) {
+	result := BatchResult{
+		Results: make([]BatchItemResult, len(operations)),
+	}
+	applied := 0
+	failed := false
+	for i, operation := range operations {
+		result.Results[i].Index = int32(i)
+		if failed && mode != BEST_EFFORT {
+			result.Results[i].Status = http.StatusFailedDependency
+			result.Results[i].Message = "Not processed because of previous failure"
+			continue
+		}
+
+		inventory := slices.Clone(ambulance.MedicineInventory)
+		orders := slices.Clone(ambulance.MedicineOrders)
+		entryId, responseObject, status := operation(ambulance)
+		if c.Writer.Written() {
+			return nil, nil, c.Writer.Status()
+		}
+		result.Results[i].EntryId = entryId
+		result.Results[i].Status = int32(status)
+		if status >= http.StatusMultipleChoices {
+			ambulance.MedicineInventory = inventory
+			ambulance.MedicineOrders = orders
+			result.Results[i].Message = batchErrorMessage(responseObject)
+			failed = true
+			continue
+		}
+		applied++
+	}
+
+	if failed && mode != BEST_EFFORT {
+		return nil, result, http.StatusBadRequest
+	}
+	result.Applied = applied > 0
+	if !result.Applied {
+		return nil, result, http.StatusOK
+	}
+	return ambulance, result, http.StatusOK
+}
+
+// validateBatch checks the parts of the batch request common to all kinds of batches
+func validateBatch(mode BatchMode, count int) (interface{}, int) {
+	if mode != "" && mode != ATOMIC && mode != BEST_EFFORT {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Unknown batch mode",
+			"error":   "mode must be one of: atomic, best-effort",
+		}, http.StatusBadRequest
+	}
+	if count == 0 {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Batch contains no operations",
+		}, http.StatusBadRequest
+	}
+	if count > maxBatchOperations {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("Batch can contain at most %v operations", maxBatchOperations),
+		}, http.StatusBadRequest
+	}
+	return nil, http.StatusOK
+}
+
+func unknownBatchOperation(op BatchOperationType) (interface{}, int) {
+	return gin.H{
+		"status":  http.StatusBadRequest,
+		"message": fmt.Sprintf("Unknown operation %q, must be one of: create, update, delete", op),
+	}, http.StatusBadRequest
+}
+
+func batchErrorMessage(responseObject interface{}) string {
+	if response, ok := responseObject.(gin.H); ok {
+		if message, ok := response["message"].(string); ok {
+			return message
+		}
+	}
+	return ""
+}
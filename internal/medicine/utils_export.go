@@ -0,0 +1,456 @@
+package medicine
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type exportFormat string
+
+const (
+	exportJSON exportFormat = "json"
+	exportCSV  exportFormat = "csv"
+	exportXLSX exportFormat = "xlsx"
+)
+
+const (
+	mimeCSV  = "text/csv"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// rows are flushed to the client in chunks so large exports are never buffered as a whole
+const exportFlushRows = 100
+
+// negotiateExportFormat selects the response format from the format query parameter or the Accept header.
+// The first of the offered formats is used if the client has no preference.
+func negotiateExportFormat(c *gin.Context, offered ...exportFormat) (exportFormat, error) {
+	if format := c.Query("format"); format != "" {
+		for _, candidate := range offered {
+			if strings.EqualFold(format, string(candidate)) {
+				return candidate, nil
+			}
+		}
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+
+	mimeTypes := make([]string, len(offered))
+	for i, format := range offered {
+		mimeTypes[i] = format.mimeType()
+	}
+	negotiated := c.NegotiateFormat(mimeTypes...)
+	for _, format := range offered {
+		if negotiated == format.mimeType() {
+			return format, nil
+		}
+	}
+	return offered[0], nil
+}
+
+func (f exportFormat) mimeType() string {
+	switch f {
+	case exportCSV:
+		return mimeCSV
+	case exportXLSX:
+		return mimeXLSX
+	default:
+		return binding.MIMEJSON
+	}
+}
+
+// exportLanguage selects language of the column headers from the lang query parameter or the
+// Accept-Language header. English is used for all languages except Slovak.
+func exportLanguage(c *gin.Context) string {
+	lang := c.Query("lang")
+	if lang == "" {
+		lang = c.GetHeader("Accept-Language")
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(lang)), "sk") {
+		return "sk"
+	}
+	return "en"
+}
+
+// exportColumn describes one column of the exported table
+type exportColumn[T any] struct {
+	key    string
+	header map[string]string
+	value  func(ambulance *Ambulance, entry T) any
+}
+
+// selectExportColumns picks the columns listed in the columns query parameter, in the requested order.
+// Without the parameter the default columns are used.
+func selectExportColumns[T any](c *gin.Context, available []exportColumn[T], defaults []string) ([]exportColumn[T], error) {
+	keys := defaults
+	if requested := c.Query("columns"); requested != "" {
+		keys = strings.Split(requested, ",")
+	}
+	columns := make([]exportColumn[T], 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		found := false
+		for _, column := range available {
+			if strings.EqualFold(column.key, key) {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			supported := make([]string, len(available))
+			for i, column := range available {
+				supported[i] = column.key
+			}
+			return nil, fmt.Errorf("unknown column %q, supported columns are: %v", key, strings.Join(supported, ", "))
+		}
+	}
+	return columns, nil
+}
+
+var inventoryExportColumns = []exportColumn[MedicineInventoryEntry]{
+	{
+		key:    "ambulanceId",
+		header: map[string]string{"en": "Ambulance ID", "sk": "ID ambulancie"},
+		value:  func(a *Ambulance, e MedicineInventoryEntry) any { return a.Id },
+	},
+	{
+		key:    "ambulanceName",
+		header: map[string]string{"en": "Ambulance", "sk": "Ambulancia"},
+		value:  func(a *Ambulance, e MedicineInventoryEntry) any { return a.Name },
+	},
+	{
+		key:    "id",
+		header: map[string]string{"en": "Entry ID", "sk": "ID záznamu"},
+		value:  func(a *Ambulance, e MedicineInventoryEntry) any { return e.Id },
+	},
+	{
+		key:    "medicineId",
+		header: map[string]string{"en": "Medicine ID", "sk": "ID lieku"},
+		value:  func(a *Ambulance, e MedicineInventoryEntry) any { return e.MedicineId },
+	},
+	{
+		key:    "name",
+		header: map[string]string{"en": "Medicine", "sk": "Liek"},
+		value:  func(a *Ambulance, e MedicineInventoryEntry) any { return e.Name },
+	},
+	{
+		key:    "count",
+		header: map[string]string{"en": "Count", "sk": "Počet"},
+		value:  func(a *Ambulance, e MedicineInventoryEntry) any { return e.Count },
+	},
+}
+
+var orderExportColumns = []exportColumn[MedicineOrderEntry]{
+	{
+		key:    "ambulanceId",
+		header: map[string]string{"en": "Ambulance ID", "sk": "ID ambulancie"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return a.Id },
+	},
+	{
+		key:    "ambulanceName",
+		header: map[string]string{"en": "Ambulance", "sk": "Ambulancia"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return a.Name },
+	},
+	{
+		key:    "id",
+		header: map[string]string{"en": "Order ID", "sk": "ID objednávky"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return e.Id },
+	},
+	{
+		key:    "medicineId",
+		header: map[string]string{"en": "Medicine ID", "sk": "ID lieku"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return e.MedicineId },
+	},
+	{
+		key:    "name",
+		header: map[string]string{"en": "Medicine", "sk": "Liek"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return e.Name },
+	},
+	{
+		key:    "count",
+		header: map[string]string{"en": "Count", "sk": "Počet"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return e.Count },
+	},
+	{
+		key:    "status",
+		header: map[string]string{"en": "Status", "sk": "Stav"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return e.Status.Value },
+	},
+	{
+		key:    "priority",
+		header: map[string]string{"en": "Priority", "sk": "Priorita"},
+		value:  func(a *Ambulance, e MedicineOrderEntry) any { return string(e.Priority) },
+	},
+}
+
+// tableWriter writes rows of the export in the negotiated format
+type tableWriter interface {
+	WriteRow(values []any) error
+	Close() error
+}
+
+// newTableWriter writes response headers and returns writer for the table rows
+func newTableWriter(c *gin.Context, format exportFormat, fileName string) (tableWriter, error) {
+	c.Header("Content-Type", format.mimeType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+"."+string(format)))
+	c.Status(http.StatusOK)
+	switch format {
+	case exportCSV:
+		return &csvTableWriter{writer: csv.NewWriter(c.Writer), flusher: c.Writer}, nil
+	case exportXLSX:
+		return newXlsxTableWriter(c.Writer, fileName)
+	default:
+		return nil, fmt.Errorf("format %v cannot be streamed", format)
+	}
+}
+
+// writeExport streams the entries of the ambulances as a table with the selected columns
+func writeExport[T any](
+	writer tableWriter,
+	columns []exportColumn[T],
+	lang string,
+	ambulances func(yield func(ambulance *Ambulance, entries []T) error) error,
+) error {
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column.header[lang]
+	}
+	if err := writer.WriteRow(header); err != nil {
+		return err
+	}
+	row := make([]any, len(columns))
+	err := ambulances(func(ambulance *Ambulance, entries []T) error {
+		for _, entry := range entries {
+			for i, column := range columns {
+				row[i] = column.value(ambulance, entry)
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// defaultInventoryExportColumns are used if the columns query parameter is not present
+var defaultInventoryExportColumns = []string{"medicineId", "name", "count"}
+
+// defaultOrderExportColumns are used if the columns query parameter is not present
+var defaultOrderExportColumns = []string{"medicineId", "name", "count", "status", "priority"}
+
+// streamExport writes the entries as a table in the given format. Problems detected before
+// the first byte is sent are returned as an error response, later ones can only be logged.
+func streamExport[T any](
+	c *gin.Context,
+	format exportFormat,
+	fileName string,
+	available []exportColumn[T],
+	defaults []string,
+	ambulances func(yield func(ambulance *Ambulance, entries []T) error) error,
+) (interface{}, int) {
+	columns, err := selectExportColumns(c, available, defaults)
+	if err != nil {
+		return gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Invalid columns parameter",
+			"error":   err.Error(),
+		}, http.StatusBadRequest
+	}
+
+	writer, err := newTableWriter(c, format, fileName)
+	if err != nil {
+		return gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Failed to start export",
+			"error":   err.Error(),
+		}, http.StatusInternalServerError
+	}
+	if err := writeExport(writer, columns, exportLanguage(c), ambulances); err != nil {
+		slog.WarnContext(c, "Export interrupted", "file", fileName, "error", err)
+	}
+	return nil, http.StatusOK
+}
+
+// invalidExportFormat is the response for unsupported format parameter
+func invalidExportFormat(err error) gin.H {
+	return gin.H{
+		"status":  http.StatusBadRequest,
+		"message": "Unsupported export format",
+		"error":   err.Error(),
+	}
+}
+
+// escapeFormula prefixes the text cells which spreadsheet applications would evaluate as a formula
+// with an apostrophe, so the entered names cannot inject formulas into the exported tables
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+type csvTableWriter struct {
+	writer  *csv.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+func (w *csvTableWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		if text, ok := value.(string); ok {
+			record[i] = escapeFormula(text)
+		} else {
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%exportFlushRows == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *csvTableWriter) Close() error {
+	return w.flush()
+}
+
+func (w *csvTableWriter) flush() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return err
+	}
+	w.flusher.Flush()
+	return nil
+}
+
+// xlsxTableWriter produces minimal single sheet workbook. The sheet is written row by row
+// directly into the zip stream, so the workbook is never held in memory.
+type xlsxTableWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+func newXlsxTableWriter(w gin.ResponseWriter, sheetName string) (*xlsxTableWriter, error) {
+	archive := zip.NewWriter(w)
+	// sheet names are limited to 31 characters
+	if len(sheetName) > 31 {
+		sheetName = sheetName[:31]
+	}
+	var escapedName strings.Builder
+	if err := xml.EscapeText(&escapedName, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRelationships},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, escapedName.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	}
+	for _, part := range parts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxTableWriter{archive: archive, sheet: sheet, flusher: w}, nil
+}
+
+func (w *xlsxTableWriter) WriteRow(values []any) error {
+	var row strings.Builder
+	row.WriteString("<row>")
+	for _, value := range values {
+		switch number := value.(type) {
+		case int32:
+			row.WriteString("<c><v>" + strconv.FormatInt(int64(number), 10) + "</v></c>")
+		case int:
+			row.WriteString("<c><v>" + strconv.Itoa(number) + "</v></c>")
+		default:
+			row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(&row, []byte(escapeFormula(fmt.Sprint(value)))); err != nil {
+				return err
+			}
+			row.WriteString("</t></is></c>")
+		}
+	}
+	row.WriteString("</row>")
+	if _, err := io.WriteString(w.sheet, row.String()); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%exportFlushRows == 0 {
+		if err := w.archive.Flush(); err != nil {
+			return err
+		}
+		w.flusher.Flush()
+	}
+	return nil
+}
+
+func (w *xlsxTableWriter) Close() error {
+	if _, err := io.WriteString(w.sheet, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	if err := w.archive.Close(); err != nil {
+		return err
+	}
+	w.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,246 @@
+package medicine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	importReplace = "replace"
+	importMerge   = "merge"
+	importAdd     = "add"
+)
+
+const (
+	importAdded   = "added"
+	importUpdated = "updated"
+	importRemoved = "removed"
+)
+
+const maxImportRows = 10000
+
+// importedEntry is a valid row of the imported CSV
+type importedEntry struct {
+	row   int32
+	entry MedicineInventoryEntry
+}
+
+// importColumns are the columns read from the CSV, other columns are ignored
+var importColumns = []string{"medicineId", "name", "count"}
+
+// parseInventoryCsv reads inventory entries from CSV with a header row. Columns are recognized by
+// their key or by any of the localized headers used in the export, so exported files can be imported back.
+// Both comma and semicolon separated files are accepted. Invalid rows are reported and skipped,
+// the error is returned only if the file as a whole cannot be processed.
+func parseInventoryCsv(input io.Reader) ([]importedEntry, []InventoryImportRowError, error) {
+	buffered := bufio.NewReader(input)
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if firstLine, _ := buffered.Peek(buffered.Size()); isSemicolonSeparated(firstLine) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("CSV is empty, header row is required")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	positions, err := importColumnPositions(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []importedEntry
+	var rowErrors []InventoryImportRowError
+	seen := map[string]int32{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, InventoryImportRowError{Row: int32(parseErr.StartLine), Message: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		row := int32(line)
+		if len(entries)+len(rowErrors) >= maxImportRows {
+			return nil, nil, fmt.Errorf("CSV can contain at most %v rows", maxImportRows)
+		}
+
+		field := func(column string) string {
+			position := positions[column]
+			if position >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[position])
+		}
+		entry := MedicineInventoryEntry{
+			MedicineId: field("medicineId"),
+			Name:       field("name"),
+		}
+		rowError := InventoryImportRowError{Row: row, MedicineId: entry.MedicineId}
+
+		count, err := strconv.ParseInt(field("count"), 10, 32)
+		switch {
+		case entry.MedicineId == "":
+			rowError.Message = "medicineId is required"
+		case entry.Name == "":
+			rowError.Message = "name is required"
+		case err != nil:
+			rowError.Message = fmt.Sprintf("count %q is not a valid number", field("count"))
+		case count < 0:
+			rowError.Message = "count must not be negative"
+		case seen[entry.MedicineId] != 0:
+			rowError.Message = fmt.Sprintf("medicine is already imported on row %v", seen[entry.MedicineId])
+		}
+		if rowError.Message != "" {
+			rowErrors = append(rowErrors, rowError)
+			continue
+		}
+
+		entry.Count = int32(count)
+		seen[entry.MedicineId] = row
+		entries = append(entries, importedEntry{row: row, entry: entry})
+	}
+	return entries, rowErrors, nil
+}
+
+func isSemicolonSeparated(content []byte) bool {
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+	return bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(","))
+}
+
+// importColumnPositions maps the imported columns to their position in the header
+func importColumnPositions(header []string) (map[string]int, error) {
+	positions := map[string]int{}
+	for i, cell := range header {
+		cell = strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff"))
+		for _, column := range inventoryExportColumns {
+			if !slices.Contains(importColumns, column.key) {
+				continue
+			}
+			matches := strings.EqualFold(cell, column.key)
+			for _, localized := range column.header {
+				matches = matches || strings.EqualFold(cell, localized)
+			}
+			if _, found := positions[column.key]; matches && !found {
+				positions[column.key] = i
+			}
+		}
+	}
+	for _, column := range importColumns {
+		if _, found := positions[column]; !found {
+			return nil, fmt.Errorf("column %v is missing in the CSV header", column)
+		}
+	}
+	return positions, nil
+}
+
+// mergeInventoryImport computes the inventory resulting from the import in the given mode.
+// The original inventory is not modified. Returns the new inventory with the list of changes,
+// or errors of the rows which cannot be applied.
+func mergeInventoryImport(
+	inventory []MedicineInventoryEntry,
+	mode string,
+	imported []importedEntry,
+) ([]MedicineInventoryEntry, []InventoryImportChange, []InventoryImportRowError) {
+	result := slices.Clone(inventory)
+	changes := []InventoryImportChange{}
+	var rowErrors []InventoryImportRowError
+	importedIds := map[string]bool{}
+
+	for _, item := range imported {
+		importedIds[item.entry.MedicineId] = true
+		entryIndx := slices.IndexFunc(result, func(existing MedicineInventoryEntry) bool {
+			return existing.MedicineId == item.entry.MedicineId
+		})
+
+		if entryIndx < 0 {
+			if item.entry.Count == 0 {
+				continue
+			}
+			entry := item.entry
+			entry.Id = uuid.NewString()
+			result = append(result, entry)
+			changes = append(changes, InventoryImportChange{
+				MedicineId: entry.MedicineId,
+				Name:       entry.Name,
+				Change:     importAdded,
+				Count:      entry.Count,
+			})
+			continue
+		}
+
+		existing := &result[entryIndx]
+		change := InventoryImportChange{
+			MedicineId:    existing.MedicineId,
+			Name:          item.entry.Name,
+			PreviousCount: existing.Count,
+			Count:         item.entry.Count,
+		}
+		if mode == importAdd {
+			total := int64(existing.Count) + int64(item.entry.Count)
+			if total > math.MaxInt32 {
+				rowErrors = append(rowErrors, InventoryImportRowError{
+					Row:        item.row,
+					MedicineId: item.entry.MedicineId,
+					Message:    "resulting count is too large",
+				})
+				continue
+			}
+			change.Name = existing.Name
+			change.Count = int32(total)
+		}
+
+		switch {
+		case change.Count == 0:
+			change.Change = importRemoved
+		case change.Count != existing.Count || change.Name != existing.Name:
+			change.Change = importUpdated
+			existing.Count = change.Count
+			existing.Name = change.Name
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	if mode == importReplace {
+		for _, existing := range result {
+			if !importedIds[existing.MedicineId] {
+				changes = append(changes, InventoryImportChange{
+					MedicineId:    existing.MedicineId,
+					Name:          existing.Name,
+					Change:        importRemoved,
+					PreviousCount: existing.Count,
+				})
+			}
+		}
+	}
+
+	// entries are removed at the end, so the indexes stay valid while processing the rows
+	result = slices.DeleteFunc(result, func(entry MedicineInventoryEntry) bool {
+		return slices.ContainsFunc(changes, func(change InventoryImportChange) bool {
+			return change.Change == importRemoved && change.MedicineId == entry.MedicineId
+		})
+	})
+	return result, changes, rowErrors
+}
@@ -0,0 +1,148 @@
+package medicine
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	OrderCreated         = "created"
+	OrderMerged          = "merged"
+	OrderPriorityChanged = "priorityChanged"
+)
+
+// OrderNotification describes an order event for the people handling orders
+type OrderNotification struct {
+	AmbulanceId string
+	Event       string
+	Order       MedicineOrderEntry
+	Time        time.Time
+}
+
+// NotificationSink delivers notifications to their recipients
+type NotificationSink interface {
+	SendAlert(ctx context.Context, notification OrderNotification) error
+	SendDigest(ctx context.Context, notifications []OrderNotification) error
+}
+
+type OrderNotifier interface {
+	NotifyOrder(ctx context.Context, notification OrderNotification)
+}
+
+// DigestOrderNotifier collects order notifications and sends them as a periodic digest.
+// Emergency orders are not queued, they are sent as an alert right away.
+type DigestOrderNotifier struct {
+	sink     NotificationSink
+	interval time.Duration
+	lock     sync.Mutex
+	pending  []OrderNotification
+}
+
+func NewDigestOrderNotifier(sink NotificationSink, interval time.Duration) *DigestOrderNotifier {
+	return &DigestOrderNotifier{
+		sink:     sink,
+		interval: interval,
+	}
+}
+
+func (n *DigestOrderNotifier) NotifyOrder(ctx context.Context, notification OrderNotification) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+	if notification.Order.Priority == EMERGENCY {
+		if err := n.sink.SendAlert(ctx, notification); err != nil {
+			slog.ErrorContext(ctx, "Failed to send order alert", "orderId", notification.Order.Id, "error", err)
+		}
+		return
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.pending = append(n.pending, notification)
+}
+
+// Flush sends all pending notifications as one digest
+func (n *DigestOrderNotifier) Flush(ctx context.Context) error {
+	n.lock.Lock()
+	pending := n.pending
+	n.pending = nil
+	n.lock.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	return n.sink.SendDigest(ctx, pending)
+}
+
+// Run sends digests in the configured interval until the context is done.
+// Pending notifications are flushed before returning.
+func (n *DigestOrderNotifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := n.Flush(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to send order digest", "error", err)
+			}
+		case <-ctx.Done():
+			if err := n.Flush(context.Background()); err != nil {
+				slog.Error("Failed to send order digest", "error", err)
+			}
+			return
+		}
+	}
+}
+
+type logNotificationSink struct {
+}
+
+// NewLogNotificationSink returns sink writing notifications into the service log
+func NewLogNotificationSink() NotificationSink {
+	return &logNotificationSink{}
+}
+
+func (s logNotificationSink) SendAlert(ctx context.Context, notification OrderNotification) error {
+	slog.WarnContext(ctx, "Order alert", notificationAttributes(notification)...)
+	return nil
+}
+
+func (s logNotificationSink) SendDigest(ctx context.Context, notifications []OrderNotification) error {
+	slog.InfoContext(ctx, "Order digest", "notifications", len(notifications))
+	for _, notification := range notifications {
+		slog.InfoContext(ctx, "Order digest entry", notificationAttributes(notification)...)
+	}
+	return nil
+}
+
+// notificationAttributes returns the log attributes of the notification
+func notificationAttributes(notification OrderNotification) []any {
+	return []any{
+		"priority", notification.Order.Priority,
+		"event", notification.Event,
+		"count", notification.Order.Count,
+		"medicineId", notification.Order.MedicineId,
+		"name", notification.Order.Name,
+		"ambulanceId", notification.AmbulanceId,
+	}
+}
+
+// notifyOrder passes the notification to the notifier registered in the context, if there is one
+func notifyOrder(c *gin.Context, event string, entry MedicineOrderEntry) {
+	value, exists := c.Get("order_notifier")
+	if !exists {
+		return
+	}
+	notifier, ok := value.(OrderNotifier)
+	if !ok {
+		return
+	}
+	notifier.NotifyOrder(c, OrderNotification{
+		AmbulanceId: c.Param("ambulanceId"),
+		Event:       event,
+		Order:       entry,
+	})
+}
@@ -0,0 +1,58 @@
+package medicine
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rank orders priorities from the most to the least urgent one
+func (p OrderPriority) rank() int {
+	switch p {
+	case EMERGENCY:
+		return 0
+	case URGENT:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (p OrderPriority) isValid() bool {
+	return p == "" || p == ROUTINE || p == URGENT || p == EMERGENCY
+}
+
+// skipsApproval reports whether orders of this priority bypass approval stages
+func (p OrderPriority) skipsApproval() bool {
+	return p == URGENT || p == EMERGENCY
+}
+
+// SortOrdersByPriority sorts orders in place, the most urgent first when urgentFirst is set.
+// Orders without priority are treated as routine, equal priorities keep their relative order.
+func SortOrdersByPriority(orders []MedicineOrderEntry, urgentFirst bool) {
+	slices.SortStableFunc(orders, func(a, b MedicineOrderEntry) int {
+		if urgentFirst {
+			return a.Priority.rank() - b.Priority.rank()
+		}
+		return b.Priority.rank() - a.Priority.rank()
+	})
+}
+
+// resolveStatusForPriority moves urgent and emergency orders past approval stages.
+// The first valid transition of an approval stage is taken as the approved path.
+// Returns nil if the status lookup failed, in which case the error response is already written.
+func resolveStatusForPriority(c *gin.Context, status *Status, priority OrderPriority) *Status {
+	if status == nil || !priority.skipsApproval() {
+		return status
+	}
+	statusService := implUtilsOrderStatuses{}
+	visited := map[int32]bool{}
+	for status.ApprovalStage && len(status.ValidTransitions) > 0 && !visited[status.Id] {
+		visited[status.Id] = true
+		status = statusService.GetStatus(c, int(status.ValidTransitions[0]))
+		if status == nil {
+			return nil
+		}
+	}
+	return status
+}
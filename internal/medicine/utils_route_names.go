@@ -0,0 +1,11 @@
+package medicine
+
+// RouteNames returns the names of the API routes keyed by their method and pattern, for example
+// "GET /api/ambulance/:ambulanceId", so that the requests can be reported by the route name
+func RouteNames(handleFunctions ApiHandleFunctions) map[string]string {
+	names := map[string]string{}
+	for _, route := range getRoutes(handleFunctions) {
+		names[route.Method+" "+route.Pattern] = route.Name
+	}
+	return names
+}
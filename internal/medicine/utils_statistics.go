@@ -0,0 +1,52 @@
+package medicine
+
+import (
+	"context"
+
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+// Statistics summarizes the orders and inventory of all the ambulances
+type Statistics struct {
+	// OpenOrders counts the orders by the value of their status. Every status with valid
+	// transitions is open and has its count, even if there are no orders in it.
+	OpenOrders map[string]int
+	// InventoryLines is the number of the inventory entries
+	InventoryLines int
+}
+
+// LoadStatistics counts the orders and inventory entries of all the ambulances
+func LoadStatistics(
+	ctx context.Context,
+	ambulancesDb db_service.DbService[Ambulance],
+	statusesDb db_service.DbService[Status],
+) (Statistics, error) {
+	statuses, err := statusesDb.FindAllDocuments(ctx)
+	if err != nil {
+		return Statistics{}, err
+	}
+	statistics := Statistics{OpenOrders: map[string]int{}}
+	openStatuses := map[int32]string{}
+	for _, status := range statuses {
+		if len(status.ValidTransitions) > 0 {
+			openStatuses[status.Id] = status.Value
+			statistics.OpenOrders[status.Value] = 0
+		}
+	}
+
+	ambulances, err := ambulancesDb.FindDocuments(ctx, db_service.Query{
+		Projection: []string{"id", inventoryField, ordersField},
+	})
+	if err != nil {
+		return Statistics{}, err
+	}
+	for _, ambulance := range ambulances {
+		statistics.InventoryLines += len(ambulance.MedicineInventory)
+		for _, order := range ambulance.MedicineOrders {
+			if value, open := openStatuses[order.Status.Id]; open {
+				statistics.OpenOrders[value]++
+			}
+		}
+	}
+	return statistics, nil
+}
@@ -0,0 +1,64 @@
+package medicine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/db_service"
+)
+
+type StatisticsSuite struct {
+	suite.Suite
+	ambulances db_service.DbService[Ambulance]
+	statuses   db_service.DbService[Status]
+}
+
+func TestStatisticsSuite(t *testing.T) {
+	suite.Run(t, new(StatisticsSuite))
+}
+
+func (suite *StatisticsSuite) SetupTest() {
+	ctx := context.Background()
+	suite.ambulances = db_service.NewMemoryService[Ambulance]()
+	suite.statuses = db_service.NewMemoryService[Status]()
+	for _, status := range []Status{
+		{Id: 1, Value: "To_ship", ValidTransitions: []int32{2, 4}},
+		{Id: 2, Value: "Shipped", ValidTransitions: []int32{3, 4}},
+		{Id: 3, Value: "Delivered"},
+		{Id: 4, Value: "Canceled"},
+	} {
+		suite.Require().NoError(suite.statuses.CreateDocument(ctx, status.Id, &status))
+	}
+}
+
+func (suite *StatisticsSuite) Test_LoadStatistics_CountsOpenOrdersAndInventory() {
+	// ARRANGE
+	ctx := context.Background()
+	for _, ambulance := range []Ambulance{
+		{
+			Id:                "a",
+			MedicineInventory: []MedicineInventoryEntry{{Id: "1"}, {Id: "2"}},
+			MedicineOrders: []MedicineOrderEntry{
+				{Id: "1", Status: Status{Id: 1}},
+				{Id: "2", Status: Status{Id: 1}},
+				{Id: "3", Status: Status{Id: 3}},
+			},
+		},
+		{
+			Id:                "b",
+			MedicineInventory: []MedicineInventoryEntry{{Id: "1"}},
+			MedicineOrders:    []MedicineOrderEntry{{Id: "1", Status: Status{Id: 4}}},
+		},
+	} {
+		suite.Require().NoError(suite.ambulances.CreateDocument(ctx, ambulance.Id, &ambulance))
+	}
+
+	// ACT
+	statistics, err := LoadStatistics(ctx, suite.ambulances, suite.statuses)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal(map[string]int{"To_ship": 2, "Shipped": 0}, statistics.OpenOrders)
+	suite.Equal(3, statistics.InventoryLines)
+}
@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+// Migration is a numbered change of the database schema or data. Migrations are applied
+// in the order of their versions and every version is applied only once.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db Database) error
+	// Down reverts the migration, nil if the migration cannot be reverted
+	Down func(ctx context.Context, db Database) error
+}
+
+// Index is an ascending index over the listed fields
+type Index struct {
+	Name   string
+	Keys   []string
+	Unique bool
+}
+
+// Database lists the operations available to the migrations. The operations are idempotent,
+// so a migration interrupted in the middle can be applied again.
+type Database interface {
+	CreateIndex(ctx context.Context, collection string, index Index) error
+	// DropIndex succeeds also if there is no such index
+	DropIndex(ctx context.Context, collection string, name string) error
+	// InsertMissing stores the document unless a document with the same id already exists
+	InsertMissing(ctx context.Context, collection string, id any, document any) error
+	DeleteDocuments(ctx context.Context, collection string, ids ...any) error
+	// RenameField renames the top level field in all documents of the collection
+	RenameField(ctx context.Context, collection string, from string, to string) error
+}
+
+type AppliedMigration struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+}
+
+// Store keeps track of the applied migrations and guards them with a lock shared by all
+// replicas of the service.
+type Store interface {
+	Database
+	Init(ctx context.Context) error
+	// Lock acquires the lock for the owner, returns false if it is held by someone else.
+	// The lock expires after the lease, so a crashed owner does not block the migrations forever.
+	Lock(ctx context.Context, owner string, lease time.Duration) (bool, error)
+	Unlock(ctx context.Context, owner string) error
+	Applied(ctx context.Context) ([]AppliedMigration, error)
+	MarkApplied(ctx context.Context, migration AppliedMigration) error
+	MarkReverted(ctx context.Context, version int) error
+}
+
+var ErrLocked = fmt.Errorf("migrations are locked by another process")
+
+type MigrationStatus struct {
+	Version     int
+	Description string
+	// nil if the migration is not applied yet
+	AppliedAt *time.Time
+	// the migration is applied but not known to this version of the service
+	Unknown bool
+}
+
+type Runner struct {
+	store      Store
+	migrations []Migration
+	owner      string
+	// how long the lock is held at most
+	lease time.Duration
+	// how often a busy lock is checked
+	retryInterval time.Duration
+	now           func() time.Time
+}
+
+// NewRunner validates the migrations and returns runner applying them to the store
+func NewRunner(store Store, migrations []Migration) (*Runner, error) {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int { return a.Version - b.Version })
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("migration %q must have positive version", migration.Description)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("duplicate migration version %v", migration.Version)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %v has no up step", migration.Version)
+		}
+	}
+
+	host, _ := os.Hostname()
+	return &Runner{
+		store:         store,
+		migrations:    sorted,
+		owner:         fmt.Sprintf("%v-%v-%v", host, os.Getpid(), time.Now().UnixNano()),
+		lease:         10 * time.Minute,
+		retryInterval: 5 * time.Second,
+		now:           time.Now,
+	}, nil
+}
+
+// Up applies pending migrations up to the target version, all of them if target is 0.
+// Returns versions of the applied migrations.
+func (r *Runner) Up(ctx context.Context, target int) ([]int, error) {
+	var done []int
+	err := r.locked(ctx, func(applied map[int]AppliedMigration) error {
+		for _, migration := range r.migrations {
+			if target > 0 && migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			slog.InfoContext(ctx, "Applying migration", "version", migration.Version, "description", migration.Description)
+			if err := migration.Up(ctx, r.store); err != nil {
+				return fmt.Errorf("migration %v failed: %w", migration.Version, err)
+			}
+			err := r.store.MarkApplied(ctx, AppliedMigration{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   r.now().UTC(),
+			})
+			if err != nil {
+				return err
+			}
+			done = append(done, migration.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts applied migrations with version above the target, newest first.
+// Returns versions of the reverted migrations.
+func (r *Runner) Down(ctx context.Context, target int) ([]int, error) {
+	var done []int
+	err := r.locked(ctx, func(applied map[int]AppliedMigration) error {
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			if version > target {
+				versions = append(versions, version)
+			}
+		}
+		slices.Sort(versions)
+		slices.Reverse(versions)
+
+		for _, version := range versions {
+			index, found := slices.BinarySearchFunc(r.migrations, version, func(m Migration, v int) int {
+				return m.Version - v
+			})
+			if !found {
+				return fmt.Errorf("migration %v is not known to this version of the service", version)
+			}
+			migration := r.migrations[index]
+			if migration.Down == nil {
+				return fmt.Errorf("migration %v cannot be reverted", version)
+			}
+			slog.InfoContext(ctx, "Reverting migration", "version", migration.Version, "description", migration.Description)
+			if err := migration.Down(ctx, r.store); err != nil {
+				return fmt.Errorf("reverting migration %v failed: %w", version, err)
+			}
+			if err := r.store.MarkReverted(ctx, version); err != nil {
+				return err
+			}
+			done = append(done, version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists the known and the applied migrations ordered by version
+func (r *Runner) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := r.store.Init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, migration := range r.migrations {
+		status := MigrationStatus{Version: migration.Version, Description: migration.Description}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version:     record.Version,
+			Description: record.Description,
+			AppliedAt:   &record.AppliedAt,
+			Unknown:     true,
+		})
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int { return a.Version - b.Version })
+	return statuses, nil
+}
+
+// locked runs the action while holding the lock, waiting for the lock until the context is done
+func (r *Runner) locked(ctx context.Context, action func(applied map[int]AppliedMigration) error) error {
+	if err := r.store.Init(ctx); err != nil {
+		return err
+	}
+	for {
+		acquired, err := r.store.Lock(ctx, r.owner, r.lease)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+		slog.InfoContext(ctx, "Migrations are locked by another process", "retryAfter", r.retryInterval.String())
+		select {
+		case <-ctx.Done():
+			return errors.Join(ErrLocked, ctx.Err())
+		case <-time.After(r.retryInterval):
+		}
+	}
+	defer func() {
+		// release the lock even if the context was cancelled
+		if err := r.store.Unlock(context.WithoutCancel(ctx), r.owner); err != nil {
+			slog.ErrorContext(ctx, "Failed to release migrations lock", "error", err)
+		}
+	}()
+
+	// applied migrations are read under the lock, another process may have just finished
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return action(applied)
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]AppliedMigration, error) {
+	records, err := r.store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]AppliedMigration{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
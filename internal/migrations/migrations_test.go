@@ -0,0 +1,317 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+// fakeStore keeps the state of the database in memory and records the performed operations
+type fakeStore struct {
+	operations []string
+	applied    map[int]AppliedMigration
+	documents  map[string]map[any]any
+	lockOwner  string
+	lockCalls  int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{applied: map[int]AppliedMigration{}, documents: map[string]map[any]any{}}
+}
+
+func (s *fakeStore) record(format string, args ...any) {
+	s.operations = append(s.operations, fmt.Sprintf(format, args...))
+}
+
+func (s *fakeStore) Init(ctx context.Context) error { return nil }
+
+func (s *fakeStore) Lock(ctx context.Context, owner string, lease time.Duration) (bool, error) {
+	s.lockCalls++
+	if s.lockOwner != "" && s.lockOwner != owner {
+		return false, nil
+	}
+	s.lockOwner = owner
+	return true, nil
+}
+
+func (s *fakeStore) Unlock(ctx context.Context, owner string) error {
+	if s.lockOwner == owner {
+		s.lockOwner = ""
+	}
+	return nil
+}
+
+func (s *fakeStore) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	var applied []AppliedMigration
+	for _, migration := range s.applied {
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+func (s *fakeStore) MarkApplied(ctx context.Context, migration AppliedMigration) error {
+	s.applied[migration.Version] = migration
+	return nil
+}
+
+func (s *fakeStore) MarkReverted(ctx context.Context, version int) error {
+	delete(s.applied, version)
+	return nil
+}
+
+func (s *fakeStore) CreateIndex(ctx context.Context, collection string, index Index) error {
+	s.record("create index %v.%v unique=%v", collection, index.Name, index.Unique)
+	return nil
+}
+
+func (s *fakeStore) DropIndex(ctx context.Context, collection string, name string) error {
+	s.record("drop index %v.%v", collection, name)
+	return nil
+}
+
+func (s *fakeStore) InsertMissing(ctx context.Context, collection string, id any, document any) error {
+	if s.documents[collection] == nil {
+		s.documents[collection] = map[any]any{}
+	}
+	if _, ok := s.documents[collection][id]; !ok {
+		s.documents[collection][id] = document
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteDocuments(ctx context.Context, collection string, ids ...any) error {
+	for _, id := range ids {
+		delete(s.documents[collection], id)
+	}
+	return nil
+}
+
+func (s *fakeStore) RenameField(ctx context.Context, collection string, from string, to string) error {
+	s.record("rename %v.%v to %v", collection, from, to)
+	return nil
+}
+
+// fakeLayout records the moves between the storage layouts
+type fakeLayout struct {
+	moves []string
+}
+
+func (l *fakeLayout) Split(ctx context.Context) error {
+	l.moves = append(l.moves, "split")
+	return nil
+}
+
+func (l *fakeLayout) Embed(ctx context.Context) error {
+	l.moves = append(l.moves, "embed")
+	return nil
+}
+
+type MigrationsSuite struct {
+	suite.Suite
+	store *fakeStore
+}
+
+func TestMigrationsSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsSuite))
+}
+
+func (suite *MigrationsSuite) SetupTest() {
+	suite.store = newFakeStore()
+}
+
+func (suite *MigrationsSuite) numbered(versions ...int) []Migration {
+	var migrations []Migration
+	for _, version := range versions {
+		migrations = append(migrations, Migration{
+			Version:     version,
+			Description: fmt.Sprintf("migration %v", version),
+			Up: func(ctx context.Context, db Database) error {
+				return db.CreateIndex(ctx, "test", Index{Name: fmt.Sprint(version)})
+			},
+			Down: func(ctx context.Context, db Database) error {
+				return db.DropIndex(ctx, "test", fmt.Sprint(version))
+			},
+		})
+	}
+	return migrations
+}
+
+func (suite *MigrationsSuite) Test_NewRunner_RejectsDuplicateVersions() {
+	// ACT
+	_, err := NewRunner(suite.store, suite.numbered(1, 2, 1))
+
+	// ASSERT
+	suite.ErrorContains(err, "duplicate migration version 1")
+}
+
+func (suite *MigrationsSuite) Test_Up_AppliesPendingInOrder() {
+	// ARRANGE
+	suite.store.applied[2] = AppliedMigration{Version: 2}
+	sut, err := NewRunner(suite.store, suite.numbered(3, 1, 2, 4))
+	suite.Require().NoError(err)
+
+	// ACT
+	applied, err := sut.Up(context.Background(), 3)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]int{1, 3}, applied)
+	suite.Equal([]string{"create index test.1 unique=false", "create index test.3 unique=false"}, suite.store.operations)
+	suite.Contains(suite.store.applied, 3)
+	suite.NotContains(suite.store.applied, 4)
+	suite.Empty(suite.store.lockOwner, "lock must be released")
+}
+
+func (suite *MigrationsSuite) Test_Up_StopsAtFailedMigration() {
+	// ARRANGE
+	migrations := suite.numbered(1, 3)
+	migrations = append(migrations, Migration{
+		Version: 2,
+		Up:      func(ctx context.Context, db Database) error { return fmt.Errorf("boom") },
+	})
+	sut, err := NewRunner(suite.store, migrations)
+	suite.Require().NoError(err)
+
+	// ACT
+	applied, err := sut.Up(context.Background(), 0)
+
+	// ASSERT
+	suite.ErrorContains(err, "migration 2 failed: boom")
+	suite.Equal([]int{1}, applied)
+	suite.NotContains(suite.store.applied, 2)
+	suite.NotContains(suite.store.applied, 3)
+	suite.Empty(suite.store.lockOwner, "lock must be released")
+}
+
+func (suite *MigrationsSuite) Test_Up_WaitsForLock() {
+	// ARRANGE
+	suite.store.lockOwner = "other-replica"
+	sut, err := NewRunner(suite.store, suite.numbered(1))
+	suite.Require().NoError(err)
+	sut.retryInterval = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// ACT
+	applied, err := sut.Up(ctx, 0)
+
+	// ASSERT
+	suite.ErrorIs(err, ErrLocked)
+	suite.Empty(applied)
+	suite.Greater(suite.store.lockCalls, 1)
+	suite.Equal("other-replica", suite.store.lockOwner)
+}
+
+func (suite *MigrationsSuite) Test_Down_RevertsNewestFirst() {
+	// ARRANGE
+	sut, err := NewRunner(suite.store, suite.numbered(1, 2, 3))
+	suite.Require().NoError(err)
+	_, err = sut.Up(context.Background(), 0)
+	suite.Require().NoError(err)
+	suite.store.operations = nil
+
+	// ACT
+	reverted, err := sut.Down(context.Background(), 1)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]int{3, 2}, reverted)
+	suite.Equal([]string{"drop index test.3", "drop index test.2"}, suite.store.operations)
+	suite.Contains(suite.store.applied, 1)
+	suite.Len(suite.store.applied, 1)
+}
+
+func (suite *MigrationsSuite) Test_Down_IrreversibleMigration() {
+	// ARRANGE
+	migrations := []Migration{{Version: 1, Up: func(ctx context.Context, db Database) error { return nil }}}
+	sut, err := NewRunner(suite.store, migrations)
+	suite.Require().NoError(err)
+	suite.store.applied[1] = AppliedMigration{Version: 1}
+
+	// ACT
+	_, err = sut.Down(context.Background(), 0)
+
+	// ASSERT
+	suite.ErrorContains(err, "migration 1 cannot be reverted")
+	suite.Contains(suite.store.applied, 1)
+}
+
+func (suite *MigrationsSuite) Test_Status_ReportsUnknownMigrations() {
+	// ARRANGE
+	appliedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	suite.store.applied[1] = AppliedMigration{Version: 1, Description: "migration 1", AppliedAt: appliedAt}
+	suite.store.applied[9] = AppliedMigration{Version: 9, Description: "from newer version", AppliedAt: appliedAt}
+	sut, err := NewRunner(suite.store, suite.numbered(1, 2))
+	suite.Require().NoError(err)
+
+	// ACT
+	statuses, err := sut.Status(context.Background())
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Equal([]MigrationStatus{
+		{Version: 1, Description: "migration 1", AppliedAt: &appliedAt},
+		{Version: 2, Description: "migration 2"},
+		{Version: 9, Description: "from newer version", AppliedAt: &appliedAt, Unknown: true},
+	}, statuses)
+}
+
+func (suite *MigrationsSuite) Test_All_SeedsAndRevertsDatabase() {
+	// ARRANGE
+	collections := Collections{Ambulance: "ambulance", Status: "status", Inventory: "inventory", Orders: "orders", ApiKey: "apikey"}
+	sut, err := NewRunner(suite.store, All(collections))
+	suite.Require().NoError(err)
+
+	// ACT
+	applied, err := sut.Up(context.Background(), 0)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Len(applied, len(All(collections)))
+	suite.Contains(suite.store.operations, "create index ambulance.id_1 unique=true")
+	suite.Contains(suite.store.operations, "rename status.ValidTransitions to validtransitions")
+	suite.Contains(suite.store.operations, "create index orders.ambulanceid_1_position_1 unique=false")
+	suite.Contains(suite.store.operations, "create index apikey.id_1 unique=true")
+	suite.Equal(
+		medicine.Status{Id: 1, Value: "To_ship", ValidTransitions: []int32{2, 4}},
+		suite.store.documents["status"][int32(1)],
+	)
+	suite.Equal(ApprovalStatus, suite.store.documents["status"][int32(5)])
+	suite.Len(suite.store.documents["status"], 5)
+	suite.Contains(suite.store.documents["ambulance"], "bobulova")
+
+	// ACT
+	_, err = sut.Down(context.Background(), 0)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Empty(suite.store.applied)
+	suite.Empty(suite.store.documents["status"])
+	suite.Empty(suite.store.documents["ambulance"])
+	suite.Equal("create index ambulance.id_1 unique=false", suite.store.operations[len(suite.store.operations)-1])
+}
+
+func (suite *MigrationsSuite) Test_All_SplitLayoutMovesElements() {
+	// ARRANGE
+	layout := &fakeLayout{}
+	collections := Collections{Ambulance: "ambulance", Status: "status", Inventory: "inventory", Orders: "orders", ApiKey: "apikey"}
+	embedded := All(collections)
+	collections.Layout = layout
+	sut, err := NewRunner(suite.store, All(collections))
+	suite.Require().NoError(err)
+
+	// ACT
+	applied, err := sut.Up(context.Background(), 0)
+	reverted, downErr := sut.Down(context.Background(), 7)
+
+	// ASSERT
+	suite.Require().NoError(err)
+	suite.Len(applied, len(embedded)+1, "only the split layout moves the elements")
+	suite.Require().NoError(downErr)
+	suite.Equal([]int{9, 8}, reverted)
+	suite.Equal([]string{"split", "embed"}, layout.moves)
+}
@@ -0,0 +1,130 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	appliedCollection = "migrations"
+	lockCollection    = "migrations_lock"
+	lockId            = "migrations"
+)
+
+type mongoStore struct {
+	db *mongo.Database
+}
+
+// NewMongoStore returns store keeping the applied migrations in the database
+func NewMongoStore(db *mongo.Database) Store {
+	return &mongoStore{db: db}
+}
+
+func (s *mongoStore) Init(ctx context.Context) error {
+	return s.CreateIndex(ctx, appliedCollection, Index{Name: "version_1", Keys: []string{"version"}, Unique: true})
+}
+
+func (s *mongoStore) Lock(ctx context.Context, owner string, lease time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	// takes over missing or expired lock, the upsert of held lock fails on the duplicate _id
+	_, err := s.db.Collection(lockCollection).UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: lockId}, {Key: "expiresat", Value: bson.D{{Key: "$lt", Value: now}}}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "owner", Value: owner},
+			{Key: "expiresat", Value: now.Add(lease)},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *mongoStore) Unlock(ctx context.Context, owner string) error {
+	_, err := s.db.Collection(lockCollection).DeleteOne(
+		ctx,
+		bson.D{{Key: "_id", Value: lockId}, {Key: "owner", Value: owner}},
+	)
+	return err
+}
+
+func (s *mongoStore) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	cursor, err := s.db.Collection(appliedCollection).Find(
+		ctx,
+		bson.D{},
+		options.Find().SetSort(bson.D{{Key: "version", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var applied []AppliedMigration
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (s *mongoStore) MarkApplied(ctx context.Context, migration AppliedMigration) error {
+	_, err := s.db.Collection(appliedCollection).InsertOne(ctx, migration)
+	return err
+}
+
+func (s *mongoStore) MarkReverted(ctx context.Context, version int) error {
+	_, err := s.db.Collection(appliedCollection).DeleteOne(ctx, bson.D{{Key: "version", Value: version}})
+	return err
+}
+
+func (s *mongoStore) CreateIndex(ctx context.Context, collection string, index Index) error {
+	keys := bson.D{}
+	for _, key := range index.Keys {
+		keys = append(keys, bson.E{Key: key, Value: 1})
+	}
+	_, err := s.db.Collection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName(index.Name).SetUnique(index.Unique),
+	})
+	return err
+}
+
+func (s *mongoStore) DropIndex(ctx context.Context, collection string, name string) error {
+	_, err := s.db.Collection(collection).Indexes().DropOne(ctx, name)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && (commandErr.Name == "IndexNotFound" || commandErr.Name == "NamespaceNotFound") {
+		return nil
+	}
+	return err
+}
+
+func (s *mongoStore) InsertMissing(ctx context.Context, collection string, id any, document any) error {
+	_, err := s.db.Collection(collection).UpdateOne(
+		ctx,
+		bson.D{{Key: "id", Value: id}},
+		bson.D{{Key: "$setOnInsert", Value: document}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *mongoStore) DeleteDocuments(ctx context.Context, collection string, ids ...any) error {
+	_, err := s.db.Collection(collection).DeleteMany(
+		ctx,
+		bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: ids}}}},
+	)
+	return err
+}
+
+func (s *mongoStore) RenameField(ctx context.Context, collection string, from string, to string) error {
+	_, err := s.db.Collection(collection).UpdateMany(
+		ctx,
+		bson.D{{Key: from, Value: bson.D{{Key: "$exists", Value: true}}}},
+		bson.D{{Key: "$rename", Value: bson.D{{Key: from, Value: to}}}},
+	)
+	return err
+}
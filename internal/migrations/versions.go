@@ -0,0 +1,191 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/undy45/medicine-webapi/internal/medicine"
+)
+
+// Collections names the collections the migrations work with
+type Collections struct {
+	Ambulance string
+	Status    string
+	Inventory string
+	Orders    string
+	ApiKey    string
+	// Layout moves the inventory and orders into their own collections, nil if the service uses
+	// the embedded storage layout
+	Layout LayoutMigration
+}
+
+// LayoutMigration moves the inventory and orders embedded in the ambulance documents into their own
+// collections of the split storage layout, and back. Both directions can be repeated if interrupted.
+type LayoutMigration interface {
+	Split(ctx context.Context) error
+	Embed(ctx context.Context) error
+}
+
+// DefaultStatuses are the order statuses the service starts with
+var DefaultStatuses = []medicine.Status{
+	{Id: 1, Value: "To_ship", ValidTransitions: []int32{2, 4}},
+	{Id: 2, Value: "Shipped", ValidTransitions: []int32{3, 4}},
+	{Id: 3, Value: "Delivered", ValidTransitions: []int32{}},
+	{Id: 4, Value: "Canceled", ValidTransitions: []int32{}},
+}
+
+// ApprovalStatus is the approval stage new orders start in, urgent and emergency orders skip it
+var ApprovalStatus = medicine.Status{
+	Id: 5, Value: "Awaiting_approval", ValidTransitions: []int32{1, 4}, ApprovalStage: true, Initial: true,
+}
+
+// SampleAmbulance is created in new databases, so the service has something to show
+var SampleAmbulance = medicine.Ambulance{
+	Id:         "bobulova",
+	Name:       "Dr.Bobulová",
+	RoomNumber: "123",
+}
+
+// idIndex is the index created by the former init-db.js script, the migrations make it unique
+var idIndex = Index{Name: "id_1", Keys: []string{"id"}, Unique: true}
+
+// All returns migrations of the service database. New migrations are appended with the next version,
+// released migrations are never changed. The layout migration is included only for the split layout,
+// to go back to the embedded layout it has to be reverted before the layout is changed.
+func All(collections Collections) []Migration {
+	all := []Migration{
+		{
+			Version:     1,
+			Description: "Unique ambulance ids",
+			Up:          uniqueIdUp(collections.Ambulance),
+			Down:        uniqueIdDown(collections.Ambulance),
+		},
+		{
+			Version:     2,
+			Description: "Unique order status ids",
+			Up:          uniqueIdUp(collections.Status),
+			Down:        uniqueIdDown(collections.Status),
+		},
+		{
+			Version:     3,
+			Description: "Rename valid transitions of order statuses written by init-db.js",
+			Up: func(ctx context.Context, db Database) error {
+				return db.RenameField(ctx, collections.Status, "ValidTransitions", "validtransitions")
+			},
+			// the misspelled field was never read by the service, there is nothing to restore
+			Down: func(ctx context.Context, db Database) error { return nil },
+		},
+		{
+			Version:     4,
+			Description: "Default order statuses",
+			Up: func(ctx context.Context, db Database) error {
+				for _, status := range DefaultStatuses {
+					if err := db.InsertMissing(ctx, collections.Status, status.Id, status); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(ctx context.Context, db Database) error {
+				var ids []any
+				for _, status := range DefaultStatuses {
+					ids = append(ids, status.Id)
+				}
+				return db.DeleteDocuments(ctx, collections.Status, ids...)
+			},
+		},
+		{
+			Version:     5,
+			Description: "Sample ambulance",
+			Up: func(ctx context.Context, db Database) error {
+				return db.InsertMissing(ctx, collections.Ambulance, SampleAmbulance.Id, SampleAmbulance)
+			},
+			Down: func(ctx context.Context, db Database) error {
+				return db.DeleteDocuments(ctx, collections.Ambulance, SampleAmbulance.Id)
+			},
+		},
+		{
+			Version:     6,
+			Description: "Indexes of the split storage layout collections",
+			Up: func(ctx context.Context, db Database) error {
+				for _, collection := range []string{collections.Inventory, collections.Orders} {
+					if err := db.CreateIndex(ctx, collection, idIndex); err != nil {
+						return err
+					}
+					if err := db.CreateIndex(ctx, collection, ambulanceElementsIndex); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Down: func(ctx context.Context, db Database) error {
+				for _, collection := range []string{collections.Inventory, collections.Orders} {
+					if err := db.DropIndex(ctx, collection, ambulanceElementsIndex.Name); err != nil {
+						return err
+					}
+					if err := db.DropIndex(ctx, collection, idIndex.Name); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version:     7,
+			Description: "Unique API key ids",
+			Up: func(ctx context.Context, db Database) error {
+				return db.CreateIndex(ctx, collections.ApiKey, idIndex)
+			},
+			Down: func(ctx context.Context, db Database) error {
+				return db.DropIndex(ctx, collections.ApiKey, idIndex.Name)
+			},
+		},
+	}
+	if collections.Layout != nil {
+		all = append(all, Migration{
+			Version:     8,
+			Description: "Split storage layout, inventory and orders in their own collections",
+			Up: func(ctx context.Context, db Database) error {
+				return collections.Layout.Split(ctx)
+			},
+			Down: func(ctx context.Context, db Database) error {
+				return collections.Layout.Embed(ctx)
+			},
+		})
+	}
+	all = append(all, Migration{
+		Version:     9,
+		Description: "Approval stage of new orders",
+		Up: func(ctx context.Context, db Database) error {
+			return db.InsertMissing(ctx, collections.Status, ApprovalStatus.Id, ApprovalStatus)
+		},
+		// the orders waiting for approval keep the status, they can still be moved on by their transitions
+		Down: func(ctx context.Context, db Database) error {
+			return db.DeleteDocuments(ctx, collections.Status, ApprovalStatus.Id)
+		},
+	})
+	return all
+}
+
+// ambulanceElementsIndex serves loading of the entries of an ambulance in their order
+var ambulanceElementsIndex = Index{Name: "ambulanceid_1_position_1", Keys: []string{"ambulanceid", "position"}}
+
+// uniqueIdUp replaces the plain id index with the unique one
+func uniqueIdUp(collection string) func(ctx context.Context, db Database) error {
+	return func(ctx context.Context, db Database) error {
+		if err := db.DropIndex(ctx, collection, idIndex.Name); err != nil {
+			return err
+		}
+		return db.CreateIndex(ctx, collection, idIndex)
+	}
+}
+
+func uniqueIdDown(collection string) func(ctx context.Context, db Database) error {
+	return func(ctx context.Context, db Database) error {
+		if err := db.DropIndex(ctx, collection, idIndex.Name); err != nil {
+			return err
+		}
+		plain := idIndex
+		plain.Unique = false
+		return db.CreateIndex(ctx, collection, plain)
+	}
+}
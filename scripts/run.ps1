@@ -24,6 +24,7 @@ switch ($command) {
     "start" {
         try {
             mongo up --detach
+            go run ${ProjectRoot}/cmd/medicine-api-service migrate
             go run ${ProjectRoot}/cmd/medicine-api-service
         } finally {
             mongo down
@@ -32,6 +33,13 @@ switch ($command) {
     "test" {
         go test -v ./...
     }
+    "memory" {
+        $env:MEDICINE_API_STORAGE="memory"
+        go run ${ProjectRoot}/cmd/medicine-api-service
+    }
+    "migrate" {
+        go run ${ProjectRoot}/cmd/medicine-api-service migrate
+    }
     "mongo" {
         mongo up
     }